@@ -0,0 +1,260 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/sukhantharot/go-service/handlers"
+	"github.com/sukhantharot/go-service/mail"
+	"github.com/sukhantharot/go-service/migrate"
+	"github.com/sukhantharot/go-service/repository"
+	"github.com/sukhantharot/go-service/repository/memory"
+	"github.com/sukhantharot/go-service/seed"
+	"github.com/sukhantharot/go-service/service"
+	"gorm.io/gorm"
+)
+
+// Repositories are the data access objects, all bound to the same database or memory.Store
+type Repositories struct {
+	Users              repository.UserRepository
+	Roles              repository.RoleRepository
+	Permissions        repository.PermissionRepository
+	Grants             repository.GrantRepository
+	Policies           repository.PolicyRepository
+	Organizations      repository.OrganizationRepository
+	Invitations        repository.InvitationRepository
+	Elevations         repository.ElevationRepository
+	RefreshTokens      repository.RefreshTokenRepository
+	Revocations        repository.RevocationRepository
+	PasswordResets     repository.PasswordResetRepository
+	EmailVerifications repository.EmailVerificationRepository
+	EmailChanges       repository.EmailChangeRepository
+	MFA                repository.MFARepository
+	LoginThrottles     repository.LoginThrottleRepository
+}
+
+type Services struct {
+	Keys          *service.KeySet
+	Revocations   *service.RevocationStore
+	Tokens        *service.TokenService
+	Auth          *service.AuthService
+	Passwords     *service.PasswordService
+	Verification  *service.VerificationService
+	MFA           *service.MFAService
+	Throttle      *service.LoginThrottle
+	Permissions   *service.PermissionCache
+	RBAC          *service.RBACService
+	Access        *service.AccessService
+	Organizations *service.OrganizationService
+	Invitations   *service.InvitationService
+	Elevations    *service.ElevationService
+	Policies      *service.PolicyService
+	Users         *service.UserService
+}
+
+type Handlers struct {
+	Auth          *handlers.AuthHandler
+	Users         *handlers.UserHandler
+	RBAC          *handlers.RBACHandler
+	Grants        *handlers.GrantHandler
+	Policies      *handlers.PolicyHandler
+	Organizations *handlers.OrganizationHandler
+	Invitations   *handlers.InvitationHandler
+	Elevations    *handlers.ElevationHandler
+	Passwords     *handlers.PasswordHandler
+	Verification  *handlers.VerificationHandler
+	MFA           *handlers.MFAHandler
+	Keys          *handlers.KeysHandler
+}
+
+// App is the application container. It owns everything built from one database and one
+// Config, so several instances can live in the same process.
+type App struct {
+	// DB is nil when the app runs on in-memory repositories
+	DB           *gorm.DB
+	Config       Config
+	Repositories Repositories
+	Services     Services
+	Handlers     Handlers
+}
+
+// NewRepositories returns the GORM repositories of db
+func NewRepositories(db *gorm.DB) Repositories {
+	return Repositories{
+		Users:              repository.NewUserRepository(db),
+		Roles:              repository.NewRoleRepository(db),
+		Permissions:        repository.NewPermissionRepository(db),
+		Grants:             repository.NewGrantRepository(db),
+		Policies:           repository.NewPolicyRepository(db),
+		Organizations:      repository.NewOrganizationRepository(db),
+		Invitations:        repository.NewInvitationRepository(db),
+		Elevations:         repository.NewElevationRepository(db),
+		RefreshTokens:      repository.NewRefreshTokenRepository(db),
+		Revocations:        repository.NewRevocationRepository(db),
+		PasswordResets:     repository.NewPasswordResetRepository(db),
+		EmailVerifications: repository.NewEmailVerificationRepository(db),
+		EmailChanges:       repository.NewEmailChangeRepository(db),
+		MFA:                repository.NewMFARepository(db),
+		LoginThrottles:     repository.NewLoginThrottleRepository(db),
+	}
+}
+
+// NewMemoryRepositories returns in-memory repositories sharing one empty store,
+// for tests and running without Postgres
+func NewMemoryRepositories() Repositories {
+	store := memory.NewStore()
+	return Repositories{
+		Users:              memory.NewUserRepository(store),
+		Roles:              memory.NewRoleRepository(store),
+		Permissions:        memory.NewPermissionRepository(store),
+		Grants:             memory.NewGrantRepository(store),
+		Policies:           memory.NewPolicyRepository(store),
+		Organizations:      memory.NewOrganizationRepository(store),
+		Invitations:        memory.NewInvitationRepository(store),
+		Elevations:         memory.NewElevationRepository(store),
+		RefreshTokens:      memory.NewRefreshTokenRepository(store),
+		Revocations:        memory.NewRevocationRepository(store),
+		PasswordResets:     memory.NewPasswordResetRepository(store),
+		EmailVerifications: memory.NewEmailVerificationRepository(store),
+		EmailChanges:       memory.NewEmailChangeRepository(store),
+		MFA:                memory.NewMFARepository(store),
+		LoginThrottles:     memory.NewLoginThrottleRepository(store),
+	}
+}
+
+// New wires repositories, services and handlers on top of db
+func New(db *gorm.DB, cfg Config) (*App, error) {
+	a, err := NewWithRepositories(NewRepositories(db), cfg)
+	if err != nil {
+		return nil, err
+	}
+	a.DB = db
+	return a, nil
+}
+
+// NewWithRepositories wires services and handlers on top of repos
+func NewWithRepositories(repos Repositories, cfg Config) (*App, error) {
+	keys, err := service.LoadKeySet(cfg.SigningKeyPath, cfg.VerificationKeyPaths, cfg.JWTSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load JWT signing keys: %w", err)
+	}
+
+	mailer, err := mail.NewSender(cfg.Mail)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure mail sender: %w", err)
+	}
+
+	revocations := service.NewRevocationStore(repos.Revocations, cfg.RevocationCacheTTL)
+	tokens := service.NewTokenService(repos.RefreshTokens, repos.Users, repos.Organizations, repos.Elevations, revocations, keys, cfg.Token)
+	verification := service.NewVerificationService(repos.Users, repos.EmailVerifications, repos.EmailChanges, mailer, cfg.Verification)
+	mfa := service.NewMFAService(repos.Users, repos.MFA, repos.LoginThrottles, tokens, cfg.MFA)
+	throttle := service.NewLoginThrottle(repos.LoginThrottles, cfg.Throttle)
+	permissions := service.NewPermissionCache(repos.Roles, cfg.PermissionCacheTTL)
+	rbac := service.NewRBACService(repos.Roles, repos.Permissions, permissions)
+	organizations := service.NewOrganizationService(repos.Organizations, repos.Users, permissions, tokens, revocations)
+	policies, err := service.NewPolicyService(repos.Policies, repos.Users, permissions, cfg.Policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load policies: %w", err)
+	}
+
+	services := Services{
+		Keys:          keys,
+		Revocations:   revocations,
+		Tokens:        tokens,
+		Auth:          service.NewAuthService(repos.Users, repos.Roles, tokens, verification, mfa, throttle),
+		Passwords:     service.NewPasswordService(repos.Users, repos.PasswordResets, tokens, mailer, cfg.Password),
+		Verification:  verification,
+		MFA:           mfa,
+		Throttle:      throttle,
+		Permissions:   permissions,
+		RBAC:          rbac,
+		Access:        service.NewAccessService(repos.Grants, repos.Users, repos.Organizations, rbac, permissions),
+		Policies:      policies,
+		Organizations: organizations,
+		Invitations:   service.NewInvitationService(repos.Invitations, organizations, repos.Users, permissions, throttle, mailer, cfg.Invitation),
+		Elevations:    service.NewElevationService(repos.Elevations, repos.Users, permissions, revocations, cfg.Elevation),
+		Users:         service.NewUserService(repos.Users, repos.Roles, tokens, revocations),
+	}
+
+	return &App{
+		Config:       cfg,
+		Repositories: repos,
+		Services:     services,
+		Handlers: Handlers{
+			Auth:          handlers.NewAuthHandler(services.Auth),
+			Users:         handlers.NewUserHandler(services.Auth, services.Users),
+			RBAC:          handlers.NewRBACHandler(services.RBAC),
+			Grants:        handlers.NewGrantHandler(services.Access),
+			Policies:      handlers.NewPolicyHandler(services.Policies),
+			Organizations: handlers.NewOrganizationHandler(services.Organizations),
+			Invitations:   handlers.NewInvitationHandler(services.Invitations),
+			Elevations:    handlers.NewElevationHandler(services.Elevations),
+			Passwords:     handlers.NewPasswordHandler(services.Passwords),
+			Verification:  handlers.NewVerificationHandler(services.Verification),
+			MFA:           handlers.NewMFAHandler(services.MFA),
+			Keys:          handlers.NewKeysHandler(services.Keys),
+		},
+	}, nil
+}
+
+// SeedRBAC applies the roles and permissions of the seed file at path, or of seed.Default()
+// when path is empty, see service.RBACService.Seed. On a database the seed is applied in
+// one transaction holding the migration lock, so replicas starting together take turns
+// and a failure leaves nothing half applied.
+func (a *App) SeedRBAC(path string, options service.SeedOptions) ([]service.SeedChange, error) {
+	file := seed.Default()
+	if path != "" {
+		var err error
+		if file, err = seed.LoadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if a.DB == nil {
+		return a.Services.RBAC.Seed(file, options)
+	}
+
+	sqlDB, err := a.DB.DB()
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	var changes []service.SeedChange
+	err = migrate.New(sqlDB, nil).Locked(ctx, func(conn *sql.Conn) error {
+		locked := a.DB.Session(&gorm.Session{NewDB: true, Context: ctx})
+		locked.Statement.ConnPool = conn
+		return locked.Transaction(func(tx *gorm.DB) error {
+			roles := repository.NewRoleRepository(tx)
+			rbac := service.NewRBACService(roles, repository.NewPermissionRepository(tx), service.NewPermissionCache(roles, a.Config.PermissionCacheTTL))
+			var err error
+			changes, err = rbac.Seed(file, options)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.Services.Permissions.Invalidate()
+	return changes, nil
+}
+
+// RunJanitor periodically removes token revocations and login throttles that no longer matter, until ctx is done
+func (a *App) RunJanitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := a.Services.Revocations.PurgeExpired(); err != nil {
+			log.Printf("Failed to purge expired token revocations: %v", err)
+		}
+		if err := a.Services.Throttle.PurgeStale(); err != nil {
+			log.Printf("Failed to purge stale login throttles: %v", err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/sukhantharot/go-service/config"
+	"github.com/sukhantharot/go-service/mail"
+	"github.com/sukhantharot/go-service/service"
+)
+
+// Config holds every setting the application reads from the environment
+type Config struct {
+	// JWTSecret signs HS256 tokens, SigningKeyPath switches to RS256/EdDSA
+	JWTSecret            string
+	SigningKeyPath       string
+	VerificationKeyPaths []string
+	RevocationCacheTTL   time.Duration
+	// PermissionCacheTTL bounds how long permission checks see roles as they were
+	PermissionCacheTTL time.Duration
+	// ProblemDetails renders every error as RFC 7807 problem+json
+	ProblemDetails bool
+	// TrustedProxies are the IPs and CIDRs whose X-Forwarded-For names the client IP used
+	// by the login throttle, none by default so clients cannot pick their own IP
+	TrustedProxies []string
+	// Migrations is what startup does about pending migrations, see config.MigrationsApply
+	Migrations string
+	// SeedRBAC applies RBACSeedFile at startup, or seed.Default() when it is empty
+	SeedRBAC      bool
+	RBACSeedFile  string
+	RBACSeedPrune bool
+
+	Token        service.TokenConfig
+	Mail         mail.Config
+	Password     service.PasswordConfig
+	Verification service.VerificationConfig
+	Invitation   service.InvitationConfig
+	Elevation    service.ElevationConfig
+	MFA          service.MFAConfig
+	Throttle     service.ThrottleConfig
+	Policy       service.PolicyConfig
+}
+
+// LoadConfig reads the configuration from environment variables, see .env.example
+func LoadConfig() (Config, error) {
+	unverifiedPolicy, err := service.ParseUnverifiedPolicy(os.Getenv("UNVERIFIED_ACCOUNT_POLICY"))
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid UNVERIFIED_ACCOUNT_POLICY: %w", err)
+	}
+
+	policyLocation, err := time.LoadLocation(config.GetEnv("POLICY_TIMEZONE", "UTC"))
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid POLICY_TIMEZONE: %w", err)
+	}
+
+	trustedProxies := config.GetListEnv("TRUSTED_PROXIES")
+	for _, proxy := range trustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return Config{}, fmt.Errorf("invalid TRUSTED_PROXIES entry %q: not an IP or CIDR", proxy)
+		}
+	}
+
+	migrations := config.GetEnv("DB_MIGRATIONS", config.MigrationsApply)
+	if migrations != config.MigrationsApply && migrations != config.MigrationsCheck && migrations != config.MigrationsOff {
+		return Config{}, fmt.Errorf("invalid DB_MIGRATIONS %q: must be apply, check or off", migrations)
+	}
+
+	mfaPolicy := service.MFAPolicy{
+		RequiredPermissions: config.GetListEnv("MFA_REQUIRED_PERMISSIONS"),
+	}
+
+	return Config{
+		JWTSecret:            os.Getenv("JWT_SECRET"),
+		SigningKeyPath:       os.Getenv("JWT_SIGNING_KEY"),
+		VerificationKeyPaths: config.GetListEnv("JWT_VERIFICATION_KEYS"),
+		RevocationCacheTTL:   config.GetDurationEnv("REVOCATION_CACHE_TTL", 30*time.Second),
+		PermissionCacheTTL:   config.GetDurationEnv("PERMISSION_CACHE_TTL", time.Minute),
+		ProblemDetails:       config.GetEnv("ERROR_FORMAT", "json") == "problem",
+		TrustedProxies:       trustedProxies,
+		Migrations:           migrations,
+		SeedRBAC:             config.GetBoolEnv("RBAC_SEED", true),
+		RBACSeedFile:         os.Getenv("RBAC_SEED_FILE"),
+		RBACSeedPrune:        config.GetBoolEnv("RBAC_SEED_PRUNE", false),
+		Token: service.TokenConfig{
+			AccessTTL:        config.GetDurationEnv("ACCESS_TOKEN_TTL", 15*time.Minute),
+			RefreshTTL:       config.GetDurationEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+			MFA:              mfaPolicy,
+			EmbedPermissions: config.GetBoolEnv("JWT_EMBED_PERMISSIONS", false),
+		},
+		Mail: mail.Config{
+			Driver:   os.Getenv("MAIL_DRIVER"),
+			From:     config.GetEnv("MAIL_FROM", "no-reply@example.com"),
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			Dir:      os.Getenv("MAIL_FILE_DIR"),
+		},
+		Password: service.PasswordConfig{
+			ResetTTL: config.GetDurationEnv("PASSWORD_RESET_TTL", time.Hour),
+			ResetURL: config.GetEnv("PASSWORD_RESET_URL", "http://localhost:3000/reset-password"),
+		},
+		Verification: service.VerificationConfig{
+			TTL:       config.GetDurationEnv("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+			URL:       config.GetEnv("EMAIL_VERIFICATION_URL", "http://localhost:3000/verify-email"),
+			Policy:    unverifiedPolicy,
+			ChangeURL: config.GetEnv("EMAIL_CHANGE_URL", "http://localhost:3000/confirm-email-change"),
+		},
+		Invitation: service.InvitationConfig{
+			TTL: config.GetDurationEnv("INVITATION_TTL", 7*24*time.Hour),
+			URL: config.GetEnv("INVITATION_URL", "http://localhost:3000/accept-invitation"),
+		},
+		Elevation: service.ElevationConfig{
+			MaxDuration:   config.GetDurationEnv("ELEVATION_MAX_DURATION", 8*time.Hour),
+			RequestTTL:    config.GetDurationEnv("ELEVATION_REQUEST_TTL", 24*time.Hour),
+			SweepInterval: config.GetDurationEnv("ELEVATION_SWEEP_INTERVAL", time.Minute),
+		},
+		MFA: service.MFAConfig{
+			Issuer:        config.GetEnv("MFA_ISSUER", "Go Service"),
+			ChallengeTTL:  config.GetDurationEnv("MFA_CHALLENGE_TTL", 5*time.Minute),
+			MaxAttempts:   5,
+			RecoveryCodes: 10,
+			Policy:        mfaPolicy,
+		},
+		Throttle: service.ThrottleConfig{
+			MaxAccountFailures: config.GetIntEnv("LOGIN_MAX_ACCOUNT_FAILURES", 10),
+			MaxIPFailures:      config.GetIntEnv("LOGIN_MAX_IP_FAILURES", 50),
+			LockoutDuration:    config.GetDurationEnv("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+			Window:             config.GetDurationEnv("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+			BackoffAfter:       3,
+			BackoffBase:        time.Second,
+			BackoffMax:         time.Minute,
+		},
+		Policy: service.PolicyConfig{
+			Files:           config.GetListEnv("POLICY_FILES"),
+			Location:        policyLocation,
+			RefreshInterval: config.GetDurationEnv("POLICY_REFRESH_INTERVAL", time.Minute),
+		},
+	}, nil
+}
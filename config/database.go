@@ -1,20 +1,22 @@
 package config
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"log"
 	"net/url"
 	"os"
 	"strings"
 
+	"github.com/sukhantharot/go-service/migrate"
+	"github.com/sukhantharot/go-service/migrations"
 	"github.com/sukhantharot/go-service/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-var DB *gorm.DB
-
 func cleanConnectionString(connStr string) string {
 	// If it's a URL format
 	if strings.HasPrefix(connStr, "postgres://") || strings.HasPrefix(connStr, "postgresql://") {
@@ -44,6 +46,7 @@ func cleanConnectionString(connStr string) string {
 	return strings.Join(validParts, " ")
 }
 
+// InitDB connects to the database, MigrateDB brings its schema up to date
 func InitDB() *gorm.DB {
 	log.Println("Starting database initialization...")
 	log.Printf("Running in environment: %s", os.Getenv("APP_ENV"))
@@ -125,6 +128,8 @@ func InitDB() *gorm.DB {
 				Colorful: false,
 			},
 		),
+		// Report unique violations as gorm.ErrDuplicatedKey so they map to a typed error
+		TranslateError: true,
 	}
 
 	db, err := gorm.Open(postgres.Open(dsn), gormConfig)
@@ -148,13 +153,131 @@ func InitDB() *gorm.DB {
 
 	log.Println("Database ping successful")
 
-	// Auto Migrate the schema
-	log.Println("Starting database migration...")
-	if err := db.AutoMigrate(&models.User{}, &models.Role{}, &models.Permission{}); err != nil {
-		log.Fatal("Failed to migrate database schema:", err)
+	return db
+}
+
+// Modes of DB_MIGRATIONS, what startup does about pending migrations
+const (
+	// MigrationsApply applies them
+	MigrationsApply = "apply"
+	// MigrationsCheck fails startup while any are pending
+	MigrationsCheck = "check"
+	// MigrationsOff leaves the schema alone
+	MigrationsOff = "off"
+)
+
+// autoMigratedVersion is the last migration whose schema AutoMigrate created, before
+// startup applied the migrations
+const autoMigratedVersion = 17
+
+// NewMigrator returns a migrator applying the embedded migrations to db
+func NewMigrator(db *gorm.DB) (*migrate.Migrator, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	all, err := migrate.Load(migrations.Files)
+	if err != nil {
+		return nil, err
 	}
-	log.Println("Database migration completed successfully")
+	return migrate.New(sqlDB, all), nil
+}
 
-	DB = db
-	return db
+// MigrateDB applies the pending migrations, or with MigrationsCheck fails when any are
+// pending or were edited after they ran
+func MigrateDB(db *gorm.DB, mode string) error {
+	if mode == MigrationsOff {
+		return nil
+	}
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	if mode == MigrationsCheck {
+		return migrator.Check(context.Background())
+	}
+
+	applied, err := ApplyMigrations(db, migrator, 0)
+	for _, migration := range applied {
+		log.Printf("Applied migration %s", migration)
+	}
+	return err
+}
+
+// ApplyMigrations applies the pending migrations up to version to, every one when to is
+// zero. A database created by AutoMigrate is adopted first.
+func ApplyMigrations(db *gorm.DB, migrator *migrate.Migrator, to int64) ([]migrate.Migration, error) {
+	ctx := context.Background()
+	_, err := migrator.Adopt(ctx, autoMigratedVersion, func(conn *sql.Conn) (bool, error) {
+		// On the connection holding the migration lock, with no migration recorded yet
+		locked := db.Session(&gorm.Session{NewDB: true, Context: ctx})
+		locked.Statement.ConnPool = conn
+		return adoptAutoMigrated(locked)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("adopting the AutoMigrate schema: %w", err)
+	}
+	return migrator.Up(ctx, to)
+}
+
+// adoptAutoMigrated prepares a database whose schema AutoMigrate managed to be taken over,
+// reporting false when there is none. AutoMigrate brings it up to the models one last
+// time, then the migrations it stood in for are recorded as applied without running
+// them, later ones run as usual.
+func adoptAutoMigrated(db *gorm.DB) (bool, error) {
+	if !db.Migrator().HasTable(&models.User{}) {
+		return false, nil
+	}
+	log.Println("Adopting a database created by AutoMigrate...")
+
+	if err := db.AutoMigrate(&models.User{}, &models.Role{}, &models.Permission{}, &models.Grant{}, &models.Policy{}, &models.Organization{}, &models.Membership{}, &models.Invitation{}, &models.Elevation{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.PasswordResetToken{}, &models.EmailVerificationToken{}, &models.EmailChangeToken{}, &models.RecoveryCode{}, &models.MFAChallenge{}, &models.LoginThrottle{}); err != nil {
+		return false, err
+	}
+	if err := migrateUserRoles(db); err != nil {
+		return false, fmt.Errorf("moving user roles to user_roles: %w", err)
+	}
+	if err := migratePermissionNames(db); err != nil {
+		return false, fmt.Errorf("renaming permissions: %w", err)
+	}
+	return true, nil
+}
+
+// migrateUserRoles moves users.role_id, from before users could have several roles, into
+// user_roles like migrations/010_user_roles.up.sql. AutoMigrate never drops columns.
+func migrateUserRoles(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&models.User{}, "role_id") {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`INSERT INTO user_roles (user_id, role_id)
+			SELECT id, role_id FROM users WHERE role_id IS NOT NULL
+			ON CONFLICT DO NOTHING`).Error
+		if err != nil {
+			return err
+		}
+		// Dropping the column drops its foreign key too
+		return tx.Exec("ALTER TABLE users DROP COLUMN role_id").Error
+	})
+}
+
+// legacyPermissionNames maps the action:resource names seeded by 001_init.sql to the
+// resource:action names the routes check
+var legacyPermissionNames = map[string]string{
+	"read:users":   "users:read",
+	"write:users":  "users:write",
+	"delete:users": "users:delete",
+}
+
+// migratePermissionNames renames the seeded permissions like migrations/012_permission_names.up.sql
+func migratePermissionNames(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for legacy, name := range legacyPermissionNames {
+			err := tx.Exec(`UPDATE permissions SET name = ?
+				WHERE name = ? AND NOT EXISTS (SELECT 1 FROM permissions WHERE name = ?)`, name, legacy, name).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
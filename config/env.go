@@ -1,8 +1,13 @@
 package config
 
 import (
-	"github.com/joho/godotenv"
 	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
 )
 
 func LoadEnv() {
@@ -10,4 +15,65 @@ func LoadEnv() {
 	if err != nil {
 		log.Printf("Warning: .env file not found")
 	}
-} 
\ No newline at end of file
+}
+
+// GetEnv returns the value of the environment variable or the fallback when it is unset
+func GetEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// GetDurationEnv parses the environment variable as a time.Duration (e.g. "15m", "720h")
+func GetDurationEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Warning: invalid duration for %s: %q, using %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+// GetIntEnv parses the environment variable as an integer
+func GetIntEnv(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Warning: invalid integer for %s: %q, using %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+// GetBoolEnv parses the environment variable as a boolean ("true", "1", "false", "0", ...)
+func GetBoolEnv(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Warning: invalid boolean for %s: %q, using %t", key, value, fallback)
+		return fallback
+	}
+	return b
+}
+
+// GetListEnv splits a comma separated environment variable, ignoring empty entries
+func GetListEnv(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -4,51 +4,109 @@ import (
 	"net/http"
 )
 
-// AppError represents an application error
+// AppError represents an application error. Status is the HTTP status, Code a stable
+// machine-readable identifier clients can switch on and Message is safe to show to users.
 type AppError struct {
-	Code    int    `json:"code"`
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
 	Message string `json:"message"`
 	Details string `json:"details,omitempty"`
+	// Fields maps request fields to what is wrong with them
+	Fields map[string]string `json:"fields,omitempty"`
+	// Err is the underlying cause, it is logged but never sent to clients
+	Err error `json:"-"`
 }
 
 // Error implements the error interface
 func (e *AppError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
 	return e.Message
 }
 
+// Unwrap returns the underlying cause
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches any AppError with the same code, so copies made by Wrap or WithDetails
+// still match their sentinel with errors.Is
+func (e *AppError) Is(target error) bool {
+	t, ok := target.(*AppError)
+	return ok && t.Code == e.Code
+}
+
+// Wrap returns a copy of the error with err as its cause
+func (e *AppError) Wrap(err error) *AppError {
+	copied := *e
+	copied.Err = err
+	return &copied
+}
+
+// WithDetails returns a copy of the error with more information for the client
+func (e *AppError) WithDetails(details string) *AppError {
+	copied := *e
+	copied.Details = details
+	return &copied
+}
+
+// WithMessage returns a copy of the error with a more specific message
+func (e *AppError) WithMessage(message string) *AppError {
+	copied := *e
+	copied.Message = message
+	return &copied
+}
+
 // New creates a new AppError
-func New(code int, message string, details string) *AppError {
+func New(status int, code, message string) *AppError {
 	return &AppError{
+		Status:  status,
 		Code:    code,
 		Message: message,
-		Details: details,
 	}
 }
 
 // Common errors
 var (
-	ErrUnauthorized = New(http.StatusUnauthorized, "Unauthorized", "")
-	ErrForbidden    = New(http.StatusForbidden, "Forbidden", "")
-	ErrNotFound     = New(http.StatusNotFound, "Not Found", "")
-	ErrBadRequest   = New(http.StatusBadRequest, "Bad Request", "")
-	ErrInternal     = New(http.StatusInternalServerError, "Internal Server Error", "")
+	ErrUnauthorized = New(http.StatusUnauthorized, "unauthorized", "Not authenticated")
+	ErrForbidden    = New(http.StatusForbidden, "forbidden", "Forbidden")
+	ErrNotFound     = New(http.StatusNotFound, "not_found", "Not found")
+	ErrBadRequest   = New(http.StatusBadRequest, "bad_request", "Bad request")
+	ErrConflict     = New(http.StatusConflict, "conflict", "Conflict")
+	ErrInternal     = New(http.StatusInternalServerError, "internal_error", "Internal server error")
 )
 
 // Database errors
 var (
-	ErrDatabaseConnection = New(http.StatusInternalServerError, "Database Connection Error", "")
-	ErrRecordNotFound     = New(http.StatusNotFound, "Record Not Found", "")
-	ErrDuplicateEntry     = New(http.StatusBadRequest, "Duplicate Entry", "")
+	ErrDatabaseConnection = New(http.StatusServiceUnavailable, "database_unavailable", "Database connection error")
+	ErrRecordNotFound     = New(http.StatusNotFound, "record_not_found", "Record not found")
+	ErrDuplicateEntry     = New(http.StatusConflict, "duplicate_entry", "Duplicate entry")
+	ErrReferencedEntry    = New(http.StatusConflict, "referenced_entry", "Entry is referenced by other records")
 )
 
 // Authentication errors
 var (
-	ErrInvalidCredentials = New(http.StatusUnauthorized, "Invalid Credentials", "")
-	ErrTokenExpired       = New(http.StatusUnauthorized, "Token Expired", "")
-	ErrTokenInvalid       = New(http.StatusUnauthorized, "Invalid Token", "")
+	ErrInvalidCredentials = New(http.StatusUnauthorized, "invalid_credentials", "Invalid credentials")
+	ErrTokenExpired       = New(http.StatusUnauthorized, "token_expired", "Token expired")
+	ErrTokenInvalid       = New(http.StatusUnauthorized, "token_invalid", "Invalid token")
+	ErrTokenRevoked       = New(http.StatusUnauthorized, "token_revoked", "Token has been revoked")
+	ErrMissingAuthHeader  = New(http.StatusUnauthorized, "authorization_required", "Authorization header is required")
+	ErrInvalidAuthHeader  = New(http.StatusUnauthorized, "authorization_invalid", "Authorization header format must be Bearer {token}")
+	ErrAccountLocked      = New(http.StatusLocked, "account_locked", "Account is temporarily locked")
+	ErrTooManyRequests    = New(http.StatusTooManyRequests, "too_many_requests", "Too many failed login attempts, try again later")
+	ErrEmailNotVerified   = New(http.StatusForbidden, "email_not_verified", "Email address is not verified")
+	ErrMFARequired        = New(http.StatusForbidden, "mfa_required", "Two-factor authentication is required")
+	ErrAccountSuspended   = New(http.StatusForbidden, "account_suspended", "Account is suspended")
+)
+
+// Authorization errors
+var (
+	ErrPermissionDenied     = New(http.StatusForbidden, "permission_denied", "Permission denied")
+	ErrOrganizationRequired = New(http.StatusForbidden, "organization_required", "Switch to an organization first")
 )
 
 // Validation errors
 var (
-	ErrValidation = New(http.StatusBadRequest, "Validation Error", "")
-) 
\ No newline at end of file
+	ErrValidation = New(http.StatusBadRequest, "validation_error", "Validation failed")
+)
@@ -0,0 +1,57 @@
+package errors
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestAppErrorIs(t *testing.T) {
+	cause := stderrors.New("boom")
+	wrapped := ErrInvalidCredentials.Wrap(cause).WithDetails("details")
+
+	assert.True(t, stderrors.Is(wrapped, ErrInvalidCredentials))
+	assert.True(t, stderrors.Is(wrapped, cause))
+	assert.False(t, stderrors.Is(wrapped, ErrTokenInvalid))
+	assert.True(t, stderrors.Is(fmt.Errorf("login: %w", wrapped), ErrInvalidCredentials))
+
+	// Copies must not change the sentinel
+	assert.Empty(t, ErrInvalidCredentials.Details)
+	assert.Nil(t, ErrInvalidCredentials.Err)
+}
+
+func TestFrom(t *testing.T) {
+	assert.Same(t, ErrForbidden, From(ErrForbidden))
+	assert.Equal(t, "record_not_found", From(fmt.Errorf("find user: %w", gorm.ErrRecordNotFound)).Code)
+	assert.Equal(t, http.StatusConflict, From(gorm.ErrDuplicatedKey).Status)
+	assert.Equal(t, "referenced_entry", From(gorm.ErrForeignKeyViolated).Code)
+
+	internal := From(stderrors.New("connection refused"))
+	assert.Equal(t, http.StatusInternalServerError, internal.Status)
+	assert.Equal(t, "Internal server error", internal.Message)
+}
+
+func TestValidation(t *testing.T) {
+	type request struct {
+		Email    string `validate:"required,email"`
+		Password string `validate:"min=6"`
+	}
+	err := validator.New().Struct(request{Email: "nope", Password: "123"})
+
+	appErr := Validation(err)
+	assert.Equal(t, "validation_error", appErr.Code)
+	assert.Equal(t, map[string]string{
+		"Email":    "must be a valid email address",
+		"Password": "must be at least 6 characters",
+	}, appErr.Fields)
+
+	var target struct{}
+	syntaxErr := json.Unmarshal([]byte("{"), &target)
+	assert.Equal(t, "bad_request", Validation(syntaxErr).Code)
+}
@@ -0,0 +1,86 @@
+package errors
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// From returns err as an AppError. GORM not-found, unique and foreign key violations become
+// ErrRecordNotFound, ErrDuplicateEntry and ErrReferencedEntry, anything unknown becomes ErrInternal.
+func From(err error) *AppError {
+	var appErr *AppError
+	if stderrors.As(err, &appErr) {
+		return appErr
+	}
+
+	switch {
+	case stderrors.Is(err, gorm.ErrRecordNotFound):
+		return ErrRecordNotFound.Wrap(err)
+	case stderrors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrDuplicateEntry.Wrap(err)
+	case stderrors.Is(err, gorm.ErrForeignKeyViolated):
+		return ErrReferencedEntry.Wrap(err)
+	}
+	return ErrInternal.Wrap(err)
+}
+
+// Validation turns a request binding error into ErrValidation with a message per field,
+// or ErrBadRequest when the body could not be decoded at all
+func Validation(err error) *AppError {
+	var validationErrors validator.ValidationErrors
+	if stderrors.As(err, &validationErrors) {
+		fields := make(map[string]string, len(validationErrors))
+		for _, fieldErr := range validationErrors {
+			fields[fieldErr.Field()] = fieldMessage(fieldErr)
+		}
+		appErr := ErrValidation.Wrap(err)
+		appErr.Fields = fields
+		return appErr
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case stderrors.Is(err, io.EOF):
+		return ErrBadRequest.WithDetails("Request body is empty").Wrap(err)
+	case stderrors.As(err, &syntaxErr):
+		return ErrBadRequest.WithDetails("Request body is not valid JSON").Wrap(err)
+	case stderrors.As(err, &typeErr):
+		return ErrBadRequest.WithDetails(fmt.Sprintf("%s must be a %s", typeErr.Field, typeErr.Type)).Wrap(err)
+	}
+	return ErrBadRequest.Wrap(err)
+}
+
+func fieldMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min":
+		return "must be at least " + fieldErr.Param() + lengthUnit(fieldErr)
+	case "max":
+		return "must be at most " + fieldErr.Param() + lengthUnit(fieldErr)
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fieldErr.Param(), " ", ", ")
+	}
+	return "is invalid"
+}
+
+// lengthUnit is what min and max count: characters of strings, nothing for numbers
+func lengthUnit(fieldErr validator.FieldError) string {
+	switch fieldErr.Kind() {
+	case reflect.String:
+		return " characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return " items"
+	}
+	return ""
+}
@@ -1,14 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
-	"os"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/sukhantharot/go-service/config"
+	apperrors "github.com/sukhantharot/go-service/errors"
 	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/service"
 )
 
 type LoginRequest struct {
@@ -16,6 +17,14 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
 type RegisterRequest struct {
 	Email     string `json:"email" binding:"required,email"`
 	Password  string `json:"password" binding:"required,min=6"`
@@ -23,150 +32,167 @@ type RegisterRequest struct {
 	LastName  string `json:"last_name" binding:"required"`
 }
 
-func Login(c *gin.Context) {
+type AuthHandler struct {
+	auth *service.AuthService
+}
+
+func NewAuthHandler(auth *service.AuthService) *AuthHandler {
+	return &AuthHandler{auth: auth}
+}
+
+func (h *AuthHandler) Login(c *gin.Context) {
 	var req LoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	var user models.User
-	if err := config.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	result, err := h.auth.Login(req.Email, req.Password, c.ClientIP())
+	if err != nil {
+		retryAfter(c, err)
+		fail(c, err)
 		return
 	}
 
-	if !user.CheckPassword(req.Password) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	// Users with a second factor get a challenge to complete at /api/auth/login/mfa
+	if result.Challenge != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required": true,
+			"mfa_token":    result.Challenge.Token,
+			"expires_at":   result.Challenge.ExpiresAt,
+		})
 		return
 	}
 
-	// Generate JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID,
-		"role_id": user.RoleID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
-	})
+	response := loginResponse(result.Tokens, result.User)
+	// Tell clients to send the user through enrollment before privileged routes work
+	response["mfa_enrollment_required"] = result.MFAEnrollmentRequired
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if !bindJSON(c, &req) {
+		return
+	}
 
-	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	pair, _, err := h.auth.Refresh(req.RefreshToken)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+		fail(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token": tokenString,
-		"user": gin.H{
-			"id":        user.ID,
-			"email":     user.Email,
-			"firstName": user.FirstName,
-			"lastName":  user.LastName,
-			"role":      user.Role,
-		},
-	})
+	c.JSON(http.StatusOK, pair)
 }
 
-func Register(c *gin.Context) {
-	var req RegisterRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+// Logout revokes the access token used for the request and, if sent, its refresh token
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req LogoutRequest
+	if c.Request.ContentLength > 0 && !bindJSON(c, &req) {
 		return
 	}
 
-	// Check if user already exists
-	var existingUser models.User
-	if err := config.DB.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Email already registered"})
+	userID, ok := currentUserID(c)
+	if !ok {
+		fail(c, apperrors.ErrUnauthorized)
 		return
 	}
 
-	// Create new user
-	user := models.User{
-		Email:     req.Email,
-		Password:  req.Password,
-		FirstName: req.FirstName,
-		LastName:  req.LastName,
-		RoleID:    1, // Default role ID (you should set up default roles in your database)
+	jti := c.GetString("jti")
+	if jti == "" {
+		fail(c, apperrors.ErrBadRequest.WithMessage("Token cannot be revoked, use logout-all"))
+		return
 	}
+	expiresAt, _ := c.Get("token_expires_at")
+	exp, _ := expiresAt.(time.Time)
 
-	if err := config.DB.Create(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create user"})
+	if err := h.auth.Logout(jti, userID, exp, req.RefreshToken); err != nil {
+		fail(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "User registered successfully",
-		"user": gin.H{
-			"id":        user.ID,
-			"email":     user.Email,
-			"firstName": user.FirstName,
-			"lastName":  user.LastName,
-		},
-	})
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
 
-func GetCurrentUser(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+// LogoutAll revokes every access and refresh token of the current user
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		fail(c, apperrors.ErrUnauthorized)
 		return
 	}
 
-	var user models.User
-	if err := config.DB.Preload("Role.Permissions").First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if err := h.auth.LogoutAll(userID); err != nil {
+		fail(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"user": gin.H{
-			"id":         user.ID,
-			"email":      user.Email,
-			"firstName":  user.FirstName,
-			"lastName":   user.LastName,
-			"role":       user.Role,
-			"created_at": user.CreatedAt,
-			"updated_at": user.UpdatedAt,
-		},
-	})
-}
-
-func GetAllUsers(c *gin.Context) {
-	var users []models.User
-	if err := config.DB.Preload("Role").Find(&users).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch users"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"users": users})
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of all sessions"})
 }
 
-func CreateRole(c *gin.Context) {
-	var role models.Role
-	if err := c.ShouldBindJSON(&role); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+func (h *AuthHandler) Register(c *gin.Context) {
+	var req RegisterRequest
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	if err := config.DB.Create(&role).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create role"})
+	user, err := h.auth.Register(req.Email, req.Password, req.FirstName, req.LastName)
+	if err != nil {
+		fail(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"role": role})
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "User registered successfully",
+		"user": gin.H{
+			"id":        user.ID,
+			"email":     user.Email,
+			"firstName": user.FirstName,
+			"lastName":  user.LastName,
+		},
+	})
 }
 
-func CreatePermission(c *gin.Context) {
-	var permission models.Permission
-	if err := c.ShouldBindJSON(&permission); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+// currentUserID reads the user ID set by middleware.JWTAuth
+func currentUserID(c *gin.Context) (uint, bool) {
+	value, exists := c.Get("user_id")
+	if !exists {
+		return 0, false
+	}
+	switch id := value.(type) {
+	case float64:
+		return uint(id), true
+	case uint:
+		return id, true
+	case int:
+		return uint(id), true
 	}
+	return 0, false
+}
 
-	if err := config.DB.Create(&permission).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create permission"})
-		return
+// loginResponse is the body returned once a user is fully logged in
+func loginResponse(pair *service.TokenPair, user *models.User) gin.H {
+	return gin.H{
+		"token":              pair.AccessToken,
+		"expires_at":         pair.ExpiresAt,
+		"refresh_token":      pair.RefreshToken,
+		"refresh_expires_at": pair.RefreshExpiresAt,
+		"user": gin.H{
+			"id":        user.ID,
+			"email":     user.Email,
+			"firstName": user.FirstName,
+			"lastName":  user.LastName,
+			"roles":     user.Roles,
+			// Lets clients prompt for verification under the restrict policy
+			"emailVerified": user.IsEmailVerified(),
+			"mfaEnabled":    user.IsMFAEnabled(),
+		},
 	}
+}
 
-	c.JSON(http.StatusCreated, gin.H{"permission": permission})
+// retryAfter tells throttled clients how long to wait before the next attempt
+func retryAfter(c *gin.Context, err error) {
+	var throttled *service.ThrottleError
+	if errors.As(err, &throttled) {
+		c.Header("Retry-After", strconv.Itoa(throttled.RetryAfterSeconds()))
+	}
 }
@@ -6,39 +6,118 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"github.com/sukhantharot/go-service/config"
+	"github.com/sukhantharot/go-service/mail"
+	"github.com/sukhantharot/go-service/middleware"
 	"github.com/sukhantharot/go-service/models"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
+	"github.com/sukhantharot/go-service/repository/memory"
+	"github.com/sukhantharot/go-service/service"
 )
 
-func setupTestDB(t *testing.T) *gorm.DB {
-	// Setup test database
-	db, err := gorm.Open(postgres.Open("host=localhost user=test password=test dbname=test_db port=5432 sslmode=disable"), &gorm.Config{})
-	require.NoError(t, err)
+func setupTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.Use(middleware.RequestID(), middleware.ErrorHandler(false))
+	return router
+}
 
-	// Migrate schema
-	err = db.AutoMigrate(&models.User{}, &models.Role{}, &models.Permission{})
+// hashPassword hashes a password for a user created directly through a repository
+func hashPassword(t *testing.T, password string) string {
+	hashed, err := models.HashPassword(password)
 	require.NoError(t, err)
+	return hashed
+}
 
-	return db
+// testConfig tweaks the services built by setupTestServices, zero values let password logins through
+type testConfig struct {
+	policy   service.UnverifiedPolicy
+	mailer   mail.Sender
+	throttle service.ThrottleConfig
 }
 
-func setupTestRouter(db *gorm.DB) *gin.Engine {
-	gin.SetMode(gin.TestMode)
-	router := gin.Default()
-	config.DB = db
-	return router
+// testServices are the services of one test, all bound to the same in-memory store
+type testServices struct {
+	keys         *service.KeySet
+	revocations  *service.RevocationStore
+	tokens       *service.TokenService
+	verification *service.VerificationService
+	mfa          *service.MFAService
+	throttle     *service.LoginThrottle
+	auth         *service.AuthService
+	users        *service.UserService
+}
+
+func setupTestServices(store *memory.Store, config testConfig) *testServices {
+	if config.mailer == nil {
+		config.mailer = mail.NewLogSender()
+	}
+	users := memory.NewUserRepository(store)
+
+	s := &testServices{keys: service.NewHMACKeySet("test_secret")}
+	s.revocations = service.NewRevocationStore(memory.NewRevocationRepository(store), time.Second)
+	s.tokens = service.NewTokenService(
+		memory.NewRefreshTokenRepository(store),
+		users,
+		memory.NewOrganizationRepository(store),
+		memory.NewElevationRepository(store),
+		s.revocations,
+		s.keys,
+		service.TokenConfig{
+			AccessTTL:  15 * time.Minute,
+			RefreshTTL: time.Hour,
+		},
+	)
+	s.verification = service.NewVerificationService(
+		users,
+		memory.NewEmailVerificationRepository(store),
+		memory.NewEmailChangeRepository(store),
+		config.mailer,
+		service.VerificationConfig{
+			TTL:       time.Hour,
+			URL:       "http://localhost/verify",
+			Policy:    config.policy,
+			ChangeURL: "http://localhost/confirm-email-change",
+		},
+	)
+	s.mfa = service.NewMFAService(
+		users,
+		memory.NewMFARepository(store),
+		memory.NewLoginThrottleRepository(store),
+		s.tokens,
+		service.MFAConfig{
+			Issuer:        "Go Service",
+			ChallengeTTL:  time.Minute,
+			MaxAttempts:   5,
+			RecoveryCodes: 10,
+			Policy:        service.MFAPolicy{RequiredPermissions: []string{"admin"}},
+		},
+	)
+	s.throttle = service.NewLoginThrottle(memory.NewLoginThrottleRepository(store), config.throttle)
+	s.auth = service.NewAuthService(users, memory.NewRoleRepository(store), s.tokens, s.verification, s.mfa, s.throttle)
+	s.users = service.NewUserService(users, memory.NewRoleRepository(store), s.tokens, s.revocations)
+	return s
+}
+
+// authenticated returns a route group behind JWTAuth
+func (s *testServices) authenticated(router *gin.Engine) *gin.RouterGroup {
+	return router.Group("/api", middleware.JWTAuth(s.keys.Keyfunc, s.revocations))
 }
 
 func TestRegister(t *testing.T) {
 	// Setup
-	db := setupTestDB(t)
-	router := setupTestRouter(db)
+	store := memory.NewStore()
+	router := setupTestRouter()
+	auth := NewAuthHandler(setupTestServices(store, testConfig{}).auth)
+	router.POST("/api/auth/register", auth.Register)
+	// Registration grants the user role, found by name whatever its ID
+	roles := memory.NewRoleRepository(store)
+	require.NoError(t, roles.Create(&models.Role{Name: models.RoleAdmin}))
+	userRole := models.Role{Name: models.RoleUser}
+	require.NoError(t, roles.Create(&userRole))
 
 	// Test cases
 	tests := []struct {
@@ -88,9 +167,6 @@ func TestRegister(t *testing.T) {
 			req.Header.Set("Content-Type", "application/json")
 			w := httptest.NewRecorder()
 
-			// Setup route
-			router.POST("/api/auth/register", Register)
-
 			// Perform request
 			router.ServeHTTP(w, req)
 
@@ -104,22 +180,27 @@ func TestRegister(t *testing.T) {
 			}
 		})
 	}
+
+	user, err := memory.NewUserRepository(store).FindByEmail("test@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []uint{userRole.ID}, user.RoleIDs())
 }
 
 func TestLogin(t *testing.T) {
 	// Setup
-	db := setupTestDB(t)
-	router := setupTestRouter(db)
+	store := memory.NewStore()
+	router := setupTestRouter()
+	auth := NewAuthHandler(setupTestServices(store, testConfig{}).auth)
+	router.POST("/api/auth/login", auth.Login)
 
 	// Create test user
 	user := models.User{
 		Email:     "test@example.com",
-		Password:  "password123",
+		Password:  hashPassword(t, "password123"),
 		FirstName: "Test",
 		LastName:  "User",
-		RoleID:    1,
 	}
-	db.Create(&user)
+	require.NoError(t, memory.NewUserRepository(store).Create(&user))
 
 	// Test cases
 	tests := []struct {
@@ -164,9 +245,6 @@ func TestLogin(t *testing.T) {
 			req.Header.Set("Content-Type", "application/json")
 			w := httptest.NewRecorder()
 
-			// Setup route
-			router.POST("/api/auth/login", Login)
-
 			// Perform request
 			router.ServeHTTP(w, req)
 
@@ -181,3 +259,139 @@ func TestLogin(t *testing.T) {
 		})
 	}
 }
+
+func TestRefresh(t *testing.T) {
+	// Setup
+	store := memory.NewStore()
+	router := setupTestRouter()
+	auth := NewAuthHandler(setupTestServices(store, testConfig{}).auth)
+	router.POST("/api/auth/login", auth.Login)
+	router.POST("/api/auth/refresh", auth.Refresh)
+
+	// Create test user
+	user := models.User{
+		Email:     "refresh@example.com",
+		Password:  hashPassword(t, "password123"),
+		FirstName: "Test",
+		LastName:  "User",
+	}
+	require.NoError(t, memory.NewUserRepository(store).Create(&user))
+
+	post := func(url string, payload interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+		jsonData, _ := json.Marshal(payload)
+		req := httptest.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	w, login := post("/api/auth/login", LoginRequest{Email: "refresh@example.com", Password: "password123"})
+	require.Equal(t, http.StatusOK, w.Code)
+	firstRefresh, _ := login["refresh_token"].(string)
+	require.NotEmpty(t, firstRefresh)
+
+	// Exchanging the refresh token rotates it
+	w, refreshed := post("/api/auth/refresh", RefreshRequest{RefreshToken: firstRefresh})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, refreshed["token"])
+	secondRefresh, _ := refreshed["refresh_token"].(string)
+	assert.NotEmpty(t, secondRefresh)
+	assert.NotEqual(t, firstRefresh, secondRefresh)
+
+	// Replaying the rotated token is detected as reuse
+	w, response := post("/api/auth/refresh", RefreshRequest{RefreshToken: firstRefresh})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "Refresh token reuse detected, please log in again", response["error"])
+
+	// Reuse revokes the whole family, including the latest token
+	w, response = post("/api/auth/refresh", RefreshRequest{RefreshToken: secondRefresh})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "Invalid refresh token", response["error"])
+
+	// Unknown tokens are rejected
+	w, response = post("/api/auth/refresh", RefreshRequest{RefreshToken: "not-a-token"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "Invalid refresh token", response["error"])
+}
+
+func TestLogout(t *testing.T) {
+	// Setup
+	store := memory.NewStore()
+	router := setupTestRouter()
+	services := setupTestServices(store, testConfig{})
+	auth := NewAuthHandler(services.auth)
+	users := NewUserHandler(services.auth, services.users)
+	router.POST("/api/auth/login", auth.Login)
+	router.POST("/api/auth/refresh", auth.Refresh)
+	protected := services.authenticated(router)
+	protected.GET("/users/me", users.GetCurrentUser)
+	protected.POST("/auth/logout", auth.Logout)
+	protected.POST("/auth/logout-all", auth.LogoutAll)
+
+	// Create test user
+	user := models.User{
+		Email:     "logout@example.com",
+		Password:  hashPassword(t, "password123"),
+		FirstName: "Test",
+		LastName:  "User",
+	}
+	require.NoError(t, memory.NewUserRepository(store).Create(&user))
+
+	do := func(method, url, token string, payload interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+		jsonData, _ := json.Marshal(payload)
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+	login := func() (string, string) {
+		w, response := do("POST", "/api/auth/login", "", LoginRequest{Email: "logout@example.com", Password: "password123"})
+		require.Equal(t, http.StatusOK, w.Code)
+		return response["token"].(string), response["refresh_token"].(string)
+	}
+
+	t.Run("logout revokes the access and refresh token", func(t *testing.T) {
+		accessToken, refreshToken := login()
+
+		w, _ := do("GET", "/api/users/me", accessToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w, _ = do("POST", "/api/auth/logout", accessToken, LogoutRequest{RefreshToken: refreshToken})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w, response := do("GET", "/api/users/me", accessToken, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, "Token has been revoked", response["error"])
+
+		w, _ = do("POST", "/api/auth/refresh", "", RefreshRequest{RefreshToken: refreshToken})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("logout-all revokes every session", func(t *testing.T) {
+		firstToken, firstRefresh := login()
+		secondToken, _ := login()
+		// Tokens carry second precision, make sure the next login is after the cutoff
+		defer time.Sleep(time.Second)
+
+		w, _ := do("POST", "/api/auth/logout-all", firstToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w, _ = do("GET", "/api/users/me", firstToken, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		w, _ = do("GET", "/api/users/me", secondToken, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		w, _ = do("POST", "/api/auth/refresh", "", RefreshRequest{RefreshToken: firstRefresh})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository"
+	"github.com/sukhantharot/go-service/service"
+)
+
+type ElevationRequest struct {
+	RoleID          uint   `json:"role_id" binding:"required"`
+	DurationMinutes int    `json:"duration_minutes" binding:"required,min=1"`
+	Justification   string `json:"justification" binding:"required,max=2000"`
+}
+
+// GrantElevationRequest elevates another user right away
+type GrantElevationRequest struct {
+	UserID uint `json:"user_id" binding:"required"`
+	ElevationRequest
+}
+
+// ApproveElevationRequest may shorten the requested window with DurationMinutes
+type ApproveElevationRequest struct {
+	DurationMinutes int    `json:"duration_minutes" binding:"omitempty,min=1"`
+	Note            string `json:"note" binding:"max=2000"`
+}
+
+type DenyElevationRequest struct {
+	Note string `json:"note" binding:"max=2000"`
+}
+
+type ListElevationsQuery struct {
+	UserID uint   `form:"user_id"`
+	Status string `form:"status" binding:"omitempty,oneof=pending approved denied revoked expired"`
+}
+
+type ElevationHandler struct {
+	elevations *service.ElevationService
+}
+
+func NewElevationHandler(elevations *service.ElevationService) *ElevationHandler {
+	return &ElevationHandler{elevations: elevations}
+}
+
+// RequestElevation asks for a role for a while on behalf of the current user
+func (h *ElevationHandler) RequestElevation(c *gin.Context) {
+	var req ElevationRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		fail(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	elevation, err := h.elevations.Request(userID, req.RoleID, req.DurationMinutes, req.Justification)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"elevation": elevation})
+}
+
+// ListMyElevations lists the elevations of the current user, requested or granted
+func (h *ElevationHandler) ListMyElevations(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		fail(c, apperrors.ErrUnauthorized)
+		return
+	}
+	h.list(c, repository.ElevationFilter{UserID: userID})
+}
+
+// ListElevations lists elevations filtered by user_id and status, for approvers
+func (h *ElevationHandler) ListElevations(c *gin.Context) {
+	var query ListElevationsQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+	h.list(c, repository.ElevationFilter{UserID: query.UserID, Status: query.Status})
+}
+
+// GrantElevation gives another user a role for a while without a request
+func (h *ElevationHandler) GrantElevation(c *gin.Context) {
+	var req GrantElevationRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	actorID, ok := currentUserID(c)
+	if !ok {
+		fail(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	elevation, err := h.elevations.Grant(actorID, req.UserID, req.RoleID, req.DurationMinutes, req.Justification)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"elevation": elevation})
+}
+
+func (h *ElevationHandler) ApproveElevation(c *gin.Context) {
+	var req ApproveElevationRequest
+	h.review(c, &req, func(actorID, id uint) (*models.Elevation, error) {
+		return h.elevations.Approve(actorID, id, req.DurationMinutes, req.Note)
+	})
+}
+
+func (h *ElevationHandler) DenyElevation(c *gin.Context) {
+	var req DenyElevationRequest
+	h.review(c, &req, func(actorID, id uint) (*models.Elevation, error) {
+		return h.elevations.Deny(actorID, id, req.Note)
+	})
+}
+
+// RevokeElevation ends an active elevation before it expires
+func (h *ElevationHandler) RevokeElevation(c *gin.Context) {
+	h.review(c, nil, h.elevations.Revoke)
+}
+
+func (h *ElevationHandler) list(c *gin.Context, filter repository.ElevationFilter) {
+	elevations, err := h.elevations.List(filter)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"elevations": elevations})
+}
+
+// review binds req unless it is nil and applies a decision of the current user to the
+// elevation in the :id path parameter
+func (h *ElevationHandler) review(c *gin.Context, req interface{}, decide func(actorID, id uint) (*models.Elevation, error)) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	if req != nil && !bindJSON(c, req) {
+		return
+	}
+	actorID, ok := currentUserID(c)
+	if !ok {
+		fail(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	elevation, err := decide(actorID, id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"elevation": elevation})
+}
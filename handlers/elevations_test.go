@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sukhantharot/go-service/middleware"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository"
+	"github.com/sukhantharot/go-service/repository/memory"
+	"github.com/sukhantharot/go-service/service"
+)
+
+func TestElevations(t *testing.T) {
+	// Setup
+	store := memory.NewStore()
+	router := setupTestRouter()
+	services := setupTestServices(store, testConfig{})
+	roles := memory.NewRoleRepository(store)
+	permissions := memory.NewPermissionRepository(store)
+	users := memory.NewUserRepository(store)
+	cache := service.NewPermissionCache(roles, time.Minute)
+	newElevations := func(requestTTL time.Duration) *service.ElevationService {
+		return service.NewElevationService(
+			memory.NewElevationRepository(store),
+			users,
+			cache,
+			services.revocations,
+			service.ElevationConfig{MaxDuration: 8 * time.Hour, RequestTTL: requestTTL},
+		)
+	}
+	handler := NewElevationHandler(newElevations(time.Hour))
+	auth := NewAuthHandler(services.auth)
+	router.POST("/api/auth/login", auth.Login)
+	router.POST("/api/auth/refresh", auth.Refresh)
+	protected := services.authenticated(router)
+	protected.GET("/users/me/elevations", handler.ListMyElevations)
+	protected.POST("/users/me/elevations", handler.RequestElevation)
+	protected.GET("/deploy", middleware.RequirePermission(cache, "ops:deploy"), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "deployed"})
+	})
+	approvers := protected.Group("/elevations", middleware.RequireAnyPermission(cache, "elevations:approve", models.PermissionAdmin))
+	approvers.GET("", handler.ListElevations)
+	approvers.POST("", handler.GrantElevation)
+	approvers.POST("/:id/approve", handler.ApproveElevation)
+	approvers.POST("/:id/deny", handler.DenyElevation)
+	approvers.POST("/:id/revoke", handler.RevokeElevation)
+
+	deploy := models.Permission{Name: "ops:deploy"}
+	require.NoError(t, permissions.Create(&deploy))
+	approve := models.Permission{Name: "elevations:approve"}
+	require.NoError(t, permissions.Create(&approve))
+	operator := models.Role{Name: "operator", Permissions: []models.Permission{deploy}}
+	require.NoError(t, roles.Create(&operator))
+	// Approvers only hand out permissions they have themselves
+	approver := models.Role{Name: "approver", Permissions: []models.Permission{approve, deploy}}
+	require.NoError(t, roles.Create(&approver))
+	migrate := models.Permission{Name: "db:migrate"}
+	require.NoError(t, permissions.Create(&migrate))
+	dba := models.Role{Name: "dba", Permissions: []models.Permission{migrate}}
+	require.NoError(t, roles.Create(&dba))
+	admin := models.Permission{Name: models.PermissionAdmin}
+	require.NoError(t, permissions.Create(&admin))
+	adminRole := models.Role{Name: models.RoleAdmin, Permissions: []models.Permission{admin}}
+	require.NoError(t, roles.Create(&adminRole))
+	alice := models.User{Email: "alice@example.com", Password: hashPassword(t, "password123")}
+	require.NoError(t, users.Create(&alice))
+	bob := models.User{Email: "bob@example.com", Password: hashPassword(t, "password123"), Roles: []models.Role{approver}}
+	require.NoError(t, users.Create(&bob))
+	carol := models.User{Email: "carol@example.com", Password: hashPassword(t, "password123"), Roles: []models.Role{adminRole}}
+	require.NoError(t, users.Create(&carol))
+
+	request := func(method, url, token string, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var response map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+	login := func(user models.User) (string, string) {
+		w, response := request("POST", "/api/auth/login", "", LoginRequest{Email: user.Email, Password: "password123"})
+		require.Equal(t, http.StatusOK, w.Code)
+		return response["token"].(string), response["refresh_token"].(string)
+	}
+	refresh := func(refreshToken string) (string, string) {
+		// Tokens issued in the same millisecond as a revocation count as revoked
+		time.Sleep(time.Millisecond)
+		w, response := request("POST", "/api/auth/refresh", "", RefreshRequest{RefreshToken: refreshToken})
+		require.Equal(t, http.StatusOK, w.Code)
+		return response["token"].(string), response["refresh_token"].(string)
+	}
+	elevationID := func(response map[string]interface{}) uint {
+		return uint(response["elevation"].(map[string]interface{})["ID"].(float64))
+	}
+	aliceToken, aliceRefresh := login(alice)
+	bobToken, _ := login(bob)
+
+	t.Run("request, approve and revoke", func(t *testing.T) {
+		w, _ := request("GET", "/api/deploy", aliceToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w, response := request("POST", "/api/users/me/elevations", aliceToken, ElevationRequest{RoleID: operator.ID, DurationMinutes: 600, Justification: "Release"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "elevation_too_long", response["code"])
+
+		w, response = request("POST", "/api/users/me/elevations", aliceToken, ElevationRequest{RoleID: operator.ID, DurationMinutes: 60, Justification: "Release 1.2"})
+		require.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, models.ElevationPending, response["elevation"].(map[string]interface{})["status"])
+		id := elevationID(response)
+
+		w, response = request("POST", "/api/users/me/elevations", aliceToken, ElevationRequest{RoleID: operator.ID, DurationMinutes: 60, Justification: "Again"})
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, "elevation_exists", response["code"])
+
+		// Requesters cannot approve
+		w, _ = request("POST", fmt.Sprintf("/api/elevations/%d/approve", id), aliceToken, ApproveElevationRequest{})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w, response = request("GET", "/api/elevations?status=pending", bobToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, response["elevations"], 1)
+
+		w, response = request("POST", fmt.Sprintf("/api/elevations/%d/approve", id), bobToken, ApproveElevationRequest{DurationMinutes: 90})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w, response = request("POST", fmt.Sprintf("/api/elevations/%d/approve", id), bobToken, ApproveElevationRequest{DurationMinutes: 30, Note: "Go ahead"})
+		require.Equal(t, http.StatusOK, w.Code)
+		elevation := response["elevation"].(map[string]interface{})
+		assert.Equal(t, models.ElevationApproved, elevation["status"])
+		assert.EqualValues(t, 30, elevation["duration_minutes"])
+		w, response = request("POST", fmt.Sprintf("/api/elevations/%d/deny", id), bobToken, DenyElevationRequest{})
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, "elevation_not_pending", response["code"])
+
+		// The role arrives with the next token
+		aliceToken, aliceRefresh = refresh(aliceRefresh)
+		w, _ = request("GET", "/api/deploy", aliceToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w, _ = request("POST", fmt.Sprintf("/api/elevations/%d/revoke", id), bobToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		w, _ = request("GET", "/api/deploy", aliceToken, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		aliceToken, aliceRefresh = refresh(aliceRefresh)
+		w, _ = request("GET", "/api/deploy", aliceToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w, response = request("POST", fmt.Sprintf("/api/elevations/%d/revoke", id), bobToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, "elevation_not_active", response["code"])
+	})
+
+	t.Run("deny", func(t *testing.T) {
+		w, response := request("POST", "/api/users/me/elevations", aliceToken, ElevationRequest{RoleID: operator.ID, DurationMinutes: 60, Justification: "Hotfix"})
+		require.Equal(t, http.StatusCreated, w.Code)
+		w, response = request("POST", fmt.Sprintf("/api/elevations/%d/deny", elevationID(response)), bobToken, DenyElevationRequest{Note: "Not now"})
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, models.ElevationDenied, response["elevation"].(map[string]interface{})["status"])
+	})
+
+	t.Run("approvers cannot elevate themselves", func(t *testing.T) {
+		w, response := request("POST", "/api/users/me/elevations", bobToken, ElevationRequest{RoleID: operator.ID, DurationMinutes: 60, Justification: "Mine"})
+		require.Equal(t, http.StatusCreated, w.Code)
+		w, response = request("POST", fmt.Sprintf("/api/elevations/%d/approve", elevationID(response)), bobToken, ApproveElevationRequest{})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, "self_action_forbidden", response["code"])
+
+		w, _ = request("POST", "/api/elevations", bobToken, GrantElevationRequest{UserID: bob.ID, ElevationRequest: ElevationRequest{RoleID: operator.ID, DurationMinutes: 60, Justification: "Mine"}})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("grant", func(t *testing.T) {
+		w, response := request("POST", "/api/elevations", bobToken, GrantElevationRequest{UserID: alice.ID, ElevationRequest: ElevationRequest{RoleID: operator.ID, DurationMinutes: 15, Justification: "Incident"}})
+		require.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, models.ElevationApproved, response["elevation"].(map[string]interface{})["status"])
+
+		aliceToken, aliceRefresh = refresh(aliceRefresh)
+		w, _ = request("GET", "/api/deploy", aliceToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w, response = request("GET", "/api/users/me/elevations", aliceToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, response["elevations"], 3)
+	})
+
+	t.Run("approvers cannot grant permissions they lack", func(t *testing.T) {
+		w, response := request("POST", "/api/elevations", bobToken, GrantElevationRequest{UserID: alice.ID, ElevationRequest: ElevationRequest{RoleID: dba.ID, DurationMinutes: 15, Justification: "Migration"}})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, "elevation_escalation", response["code"])
+		w, response = request("POST", "/api/elevations", bobToken, GrantElevationRequest{UserID: alice.ID, ElevationRequest: ElevationRequest{RoleID: adminRole.ID, DurationMinutes: 15, Justification: "Migration"}})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, "elevation_escalation", response["code"])
+
+		w, response = request("POST", "/api/users/me/elevations", aliceToken, ElevationRequest{RoleID: dba.ID, DurationMinutes: 15, Justification: "Migration"})
+		require.Equal(t, http.StatusCreated, w.Code)
+		id := elevationID(response)
+		w, response = request("POST", fmt.Sprintf("/api/elevations/%d/approve", id), bobToken, ApproveElevationRequest{})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, "elevation_escalation", response["code"])
+
+		// Admins may grant any role
+		carolToken, _ := login(carol)
+		w, _ = request("POST", fmt.Sprintf("/api/elevations/%d/approve", id), carolToken, ApproveElevationRequest{})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("sweeper expires stale requests", func(t *testing.T) {
+		// Bob's own request is still pending
+		expired, err := newElevations(-time.Second).ExpireDue()
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, expired)
+
+		pending, err := newElevations(time.Hour).List(repository.ElevationFilter{Status: models.ElevationPending})
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+	})
+}
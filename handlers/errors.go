@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"gorm.io/gorm"
+)
+
+func init() {
+	// Report validation errors with the JSON or query parameter names clients send
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "" {
+				name = strings.SplitN(field.Tag.Get("form"), ",", 2)[0]
+			}
+			if name == "" || name == "-" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
+// fail hands err to middleware.ErrorHandler, which renders it, and stops the handler chain
+func fail(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// notFound gives a record-not-found error a message naming what was missing, other errors pass through
+func notFound(err error, message string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperrors.ErrNotFound.WithMessage(message).Wrap(err)
+	}
+	return err
+}
+
+// bindJSON binds the request body into req, failing the request with a validation error
+func bindJSON(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		fail(c, apperrors.Validation(err))
+		return false
+	}
+	return true
+}
+
+// bindQuery binds the query string into req, failing the request with a validation error
+func bindQuery(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindQuery(req); err != nil {
+		fail(c, apperrors.Validation(err))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sukhantharot/go-service/repository"
+	"github.com/sukhantharot/go-service/service"
+)
+
+// CreateGrantRequest grants a permission on one resource to a user or a role. ResourceID is
+// the ID of the resource, or "own" for every resource of the type the user owns.
+type CreateGrantRequest struct {
+	PermissionID uint   `json:"permission_id" binding:"required"`
+	UserID       uint   `json:"user_id"`
+	RoleID       uint   `json:"role_id"`
+	ResourceType string `json:"resource_type" binding:"required,max=255"`
+	ResourceID   string `json:"resource_id" binding:"required,max=255"`
+}
+
+type ListGrantsQuery struct {
+	UserID uint `form:"user_id"`
+	RoleID uint `form:"role_id"`
+}
+
+type GrantHandler struct {
+	access *service.AccessService
+}
+
+func NewGrantHandler(access *service.AccessService) *GrantHandler {
+	return &GrantHandler{access: access}
+}
+
+func (h *GrantHandler) ListGrants(c *gin.Context) {
+	var query ListGrantsQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+
+	grants, err := h.access.ListGrants(repository.GrantFilter{UserID: query.UserID, RoleID: query.RoleID})
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"grants": grants})
+}
+
+func (h *GrantHandler) CreateGrant(c *gin.Context) {
+	var req CreateGrantRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	grant, err := h.access.CreateGrant(req.PermissionID, req.UserID, req.RoleID, req.ResourceType, req.ResourceID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"grant": grant})
+}
+
+func (h *GrantHandler) DeleteGrant(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.access.DeleteGrant(id); err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Grant deleted"})
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sukhantharot/go-service/middleware"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository/memory"
+	"github.com/sukhantharot/go-service/service"
+)
+
+func TestResourceGrants(t *testing.T) {
+	// Setup
+	store := memory.NewStore()
+	router := setupTestRouter()
+	services := setupTestServices(store, testConfig{})
+	roles := memory.NewRoleRepository(store)
+	permissions := memory.NewPermissionRepository(store)
+	cache := service.NewPermissionCache(roles, time.Minute)
+	access := service.NewAccessService(
+		memory.NewGrantRepository(store),
+		memory.NewUserRepository(store),
+		memory.NewOrganizationRepository(store),
+		service.NewRBACService(roles, permissions, cache),
+		cache,
+	)
+	users := NewUserHandler(services.auth, services.users)
+	grants := NewGrantHandler(access)
+	router.POST("/api/auth/login", NewAuthHandler(services.auth).Login)
+	protected := services.authenticated(router)
+	protected.GET("/users/:id", middleware.RequireResourcePermission(access, "users:read", middleware.UserParam("id", access)), users.GetUser)
+	protected.PUT("/users/:id", middleware.RequireResourcePermission(access, "users:write", middleware.UserParam("id", access)), users.UpdateGrantedUser)
+	router.GET("/api/admin/grants", grants.ListGrants)
+	router.POST("/api/admin/grants", grants.CreateGrant)
+	router.DELETE("/api/admin/grants/:id", grants.DeleteGrant)
+
+	read := models.Permission{Name: "users:read"}
+	require.NoError(t, permissions.Create(&read))
+	write := models.Permission{Name: "users:write"}
+	require.NoError(t, permissions.Create(&write))
+	userRole := models.Role{Name: models.RoleUser}
+	require.NoError(t, roles.Create(&userRole))
+
+	userRepo := memory.NewUserRepository(store)
+	var alice, bob, carol models.User
+	for _, user := range []*models.User{&alice, &bob, &carol} {
+		*user = models.User{Password: hashPassword(t, "password123"), Roles: []models.Role{userRole}}
+	}
+	alice.Email, bob.Email, carol.Email = "alice@example.com", "bob@example.com", "carol@example.com"
+	for _, user := range []*models.User{&alice, &bob, &carol} {
+		require.NoError(t, userRepo.Create(user))
+	}
+
+	request := func(method, url, token string, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var response map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+	_, response := request("POST", "/api/auth/login", "", LoginRequest{Email: alice.Email, Password: "password123"})
+	token := response["token"].(string)
+	userURL := func(user models.User) string { return fmt.Sprintf("/api/users/%d", user.ID) }
+	update := UpdateUserRequest{Email: alice.Email, FirstName: "Alice", LastName: "Smith"}
+
+	// Without grants the role gives no rights on users
+	w, _ := request("GET", userURL(bob), token, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w, _ = request("GET", "/api/users/abc", token, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	t.Run("role grant on own records", func(t *testing.T) {
+		w, _ := request("POST", "/api/admin/grants", "", CreateGrantRequest{
+			PermissionID: write.ID, RoleID: userRole.ID, ResourceType: models.ResourceUsers, ResourceID: models.ResourceOwn,
+		})
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		w, response := request("PUT", userURL(alice), token, update)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Alice", response["user"].(map[string]interface{})["first_name"])
+		w, _ = request("PUT", userURL(bob), token, update)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		// A new email has to be confirmed by its owner, the grant does not cover it
+		w, response = request("PUT", userURL(alice), token, UpdateUserRequest{Email: "new@example.com", FirstName: "Alice", LastName: "Smith"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, "email_change_forbidden", response["code"])
+	})
+
+	t.Run("user grant on one record", func(t *testing.T) {
+		w, response := request("POST", "/api/admin/grants", "", CreateGrantRequest{
+			PermissionID: read.ID, UserID: alice.ID, ResourceType: models.ResourceUsers, ResourceID: fmt.Sprint(bob.ID),
+		})
+		require.Equal(t, http.StatusCreated, w.Code)
+		grantID := uint(response["grant"].(map[string]interface{})["ID"].(float64))
+
+		w, _ = request("GET", userURL(bob), token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w, _ = request("GET", userURL(carol), token, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		// Reading is a different permission than writing
+		w, _ = request("GET", userURL(alice), token, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		_, response = request("GET", fmt.Sprintf("/api/admin/grants?user_id=%d", alice.ID), "", nil)
+		assert.Len(t, response["grants"], 1)
+		_, response = request("GET", "/api/admin/grants", "", nil)
+		assert.Len(t, response["grants"], 2)
+
+		w, _ = request("DELETE", fmt.Sprintf("/api/admin/grants/%d", grantID), "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w, _ = request("GET", userURL(bob), token, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w, response = request("DELETE", fmt.Sprintf("/api/admin/grants/%d", grantID), "", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "grant_not_found", response["code"])
+	})
+
+	t.Run("create validates input", func(t *testing.T) {
+		w, response := request("POST", "/api/admin/grants", "", CreateGrantRequest{
+			PermissionID: read.ID, UserID: alice.ID, RoleID: userRole.ID, ResourceType: models.ResourceUsers, ResourceID: "1",
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "invalid_grant", response["code"])
+
+		w, response = request("POST", "/api/admin/grants", "", CreateGrantRequest{
+			PermissionID: read.ID, UserID: 999, ResourceType: models.ResourceUsers, ResourceID: "1",
+		})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "user_not_found", response["code"])
+
+		w, response = request("POST", "/api/admin/grants", "", CreateGrantRequest{
+			PermissionID: 999, RoleID: userRole.ID, ResourceType: models.ResourceUsers, ResourceID: "1",
+		})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "permission_not_found", response["code"])
+
+		w, _ = request("POST", "/api/admin/grants", "", map[string]interface{}{"permission_id": read.ID, "role_id": userRole.ID})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
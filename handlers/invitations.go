@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/service"
+)
+
+type InvitationRequest struct {
+	Email  string `json:"email" binding:"required,email"`
+	RoleID uint   `json:"role_id" binding:"required"`
+}
+
+// AcceptInvitationRequest needs the names only when the invited email has no account yet,
+// the password is the existing one or the one of the new account
+type AcceptInvitationRequest struct {
+	Token     string `json:"token" binding:"required"`
+	Password  string `json:"password" binding:"required,min=6"`
+	FirstName string `json:"first_name" binding:"omitempty,max=255"`
+	LastName  string `json:"last_name" binding:"omitempty,max=255"`
+}
+
+type InvitationHandler struct {
+	invitations *service.InvitationService
+}
+
+func NewInvitationHandler(invitations *service.InvitationService) *InvitationHandler {
+	return &InvitationHandler{invitations: invitations}
+}
+
+// ListInvitations lists the pending invitations to an organization
+func (h *InvitationHandler) ListInvitations(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	invitations, err := h.invitations.List(id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invitations": invitations})
+}
+
+func (h *InvitationHandler) CreateInvitation(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	var req InvitationRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	actorID, ok := currentUserID(c)
+	if !ok {
+		fail(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	invitation, err := h.invitations.Create(actorID, id, req.Email, req.RoleID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"invitation": invitation})
+}
+
+func (h *InvitationHandler) RevokeInvitation(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.invitations.Revoke(id); err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked"})
+}
+
+// AcceptInvitation joins the organization of an invitation, creating the account first
+// when the invited email has none
+func (h *InvitationHandler) AcceptInvitation(c *gin.Context) {
+	var req AcceptInvitationRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	accepted, err := h.invitations.Accept(req.Token, req.Password, req.FirstName, req.LastName, c.ClientIP())
+	if err != nil {
+		retryAfter(c, err)
+		fail(c, err)
+		return
+	}
+
+	status := http.StatusOK
+	if accepted.Registered {
+		status = http.StatusCreated
+	}
+	c.JSON(status, gin.H{
+		"message": "Invitation accepted",
+		"user": gin.H{
+			"id":        accepted.User.ID,
+			"email":     accepted.User.Email,
+			"firstName": accepted.User.FirstName,
+			"lastName":  accepted.User.LastName,
+		},
+		"membership": accepted.Membership,
+	})
+}
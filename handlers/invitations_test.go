@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sukhantharot/go-service/mail"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository/memory"
+	"github.com/sukhantharot/go-service/service"
+)
+
+func TestInvitations(t *testing.T) {
+	// Setup
+	store := memory.NewStore()
+	router := setupTestRouter()
+	mailDir := t.TempDir()
+	mailer, err := mail.NewFileSender(mailDir, "no-reply@example.com")
+	require.NoError(t, err)
+	services := setupTestServices(store, testConfig{mailer: mailer, throttle: service.ThrottleConfig{
+		MaxAccountFailures: 3,
+		LockoutDuration:    time.Minute,
+		Window:             time.Minute,
+	}})
+	roles := memory.NewRoleRepository(store)
+	permissions := memory.NewPermissionRepository(store)
+	users := memory.NewUserRepository(store)
+	cache := service.NewPermissionCache(roles, time.Minute)
+	organizations := service.NewOrganizationService(memory.NewOrganizationRepository(store), users, cache, services.tokens, services.revocations)
+	newInvitations := func(ttl time.Duration) *InvitationHandler {
+		return NewInvitationHandler(service.NewInvitationService(
+			memory.NewInvitationRepository(store),
+			organizations,
+			users,
+			cache,
+			services.throttle,
+			mailer,
+			service.InvitationConfig{TTL: ttl, URL: "http://localhost/accept-invitation"},
+		))
+	}
+	handler := newInvitations(time.Hour)
+	router.POST("/api/auth/login", NewAuthHandler(services.auth).Login)
+	router.POST("/api/invitations/accept", handler.AcceptInvitation)
+	protected := services.authenticated(router)
+	protected.GET("/users/me/organizations", NewOrganizationHandler(organizations).ListMyOrganizations)
+	protected.GET("/admin/organizations/:id/invitations", handler.ListInvitations)
+	protected.POST("/admin/organizations/:id/invitations", handler.CreateInvitation)
+	protected.POST("/admin/organizations/:id/expired-invitations", newInvitations(-time.Minute).CreateInvitation)
+	protected.DELETE("/admin/invitations/:id", handler.RevokeInvitation)
+
+	userRole := models.Role{Name: models.RoleUser}
+	require.NoError(t, roles.Create(&userRole))
+	admin := models.Permission{Name: models.PermissionAdmin}
+	require.NoError(t, permissions.Create(&admin))
+	adminRole := models.Role{Name: models.RoleAdmin, Permissions: []models.Permission{admin}}
+	require.NoError(t, roles.Create(&adminRole))
+	member := models.Role{Name: "org_member"}
+	require.NoError(t, roles.Create(&member))
+	inviter := models.User{Email: "inviter@example.com", Password: hashPassword(t, "password123"), Roles: []models.Role{adminRole}}
+	require.NoError(t, users.Create(&inviter))
+	existing := models.User{Email: "existing@example.com", Password: hashPassword(t, "password123")}
+	require.NoError(t, users.Create(&existing))
+	acme, err := organizations.Create("Acme", "")
+	require.NoError(t, err)
+
+	request := func(method, url, token string, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var response map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+	login := func(email string) string {
+		w, response := request("POST", "/api/auth/login", "", LoginRequest{Email: email, Password: "password123"})
+		require.Equal(t, http.StatusOK, w.Code)
+		return response["token"].(string)
+	}
+	adminToken := login(inviter.Email)
+	invitationsURL := fmt.Sprintf("/api/admin/organizations/%d/invitations", acme.ID)
+	invite := func(email string) (string, uint) {
+		w, response := request("POST", invitationsURL, adminToken, InvitationRequest{Email: email, RoleID: member.ID})
+		require.Equal(t, http.StatusCreated, w.Code)
+		return lastMailToken(t, mailDir), uint(response["invitation"].(map[string]interface{})["ID"].(float64))
+	}
+	accept := func(req AcceptInvitationRequest) (*httptest.ResponseRecorder, map[string]interface{}) {
+		return request("POST", "/api/invitations/accept", "", req)
+	}
+
+	t.Run("admin roles cannot be invited to", func(t *testing.T) {
+		w, response := request("POST", invitationsURL, adminToken, InvitationRequest{Email: "new@example.com", RoleID: adminRole.ID})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "admin_membership_role", response["code"])
+	})
+
+	t.Run("new users register", func(t *testing.T) {
+		stale, _ := invite("new@example.com")
+		token, _ := invite("new@example.com")
+		// Inviting again replaces the earlier invitation
+		w, response := request("GET", invitationsURL, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, response["invitations"], 1)
+		w, response = accept(AcceptInvitationRequest{Token: stale, Password: "password123", FirstName: "New", LastName: "User"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "invalid_invitation", response["code"])
+
+		w, response = accept(AcceptInvitationRequest{Token: token, Password: "password123"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "name_required", response["code"])
+
+		w, response = accept(AcceptInvitationRequest{Token: token, Password: "password123", FirstName: "New", LastName: "User"})
+		require.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "new@example.com", response["user"].(map[string]interface{})["email"])
+
+		user, err := users.FindByEmail("new@example.com")
+		require.NoError(t, err)
+		assert.True(t, user.IsEmailVerified())
+		assert.Equal(t, []uint{userRole.ID}, user.RoleIDs())
+
+		w, response = request("GET", "/api/users/me/organizations", login("new@example.com"), nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, response["memberships"], 1)
+
+		w, _ = accept(AcceptInvitationRequest{Token: token, Password: "password123", FirstName: "New", LastName: "User"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("existing users join with their password", func(t *testing.T) {
+		token, _ := invite(existing.Email)
+
+		w, _ := accept(AcceptInvitationRequest{Token: token, Password: "wrong-password"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w, response := accept(AcceptInvitationRequest{Token: token, Password: "password123"})
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.EqualValues(t, member.ID, response["membership"].(map[string]interface{})["role_id"])
+
+		w, response = request("POST", invitationsURL, adminToken, InvitationRequest{Email: existing.Email, RoleID: member.ID})
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, "already_member", response["code"])
+	})
+
+	t.Run("wrong passwords lock the account like logins", func(t *testing.T) {
+		guessed := models.User{Email: "guessed@example.com", Password: hashPassword(t, "password123")}
+		require.NoError(t, users.Create(&guessed))
+		token, _ := invite(guessed.Email)
+
+		for i := 0; i < 3; i++ {
+			w, _ := accept(AcceptInvitationRequest{Token: token, Password: "wrong-password"})
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+		}
+
+		// The right password does not help while the account is locked
+		w, response := accept(AcceptInvitationRequest{Token: token, Password: "password123"})
+		assert.Equal(t, http.StatusLocked, w.Code)
+		assert.Equal(t, "account_locked", response["code"])
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+		w, _ = request("POST", "/api/auth/login", "", LoginRequest{Email: guessed.Email, Password: "password123"})
+		assert.Equal(t, http.StatusLocked, w.Code)
+
+		require.NoError(t, services.throttle.Unlock(guessed.Email))
+		w, _ = accept(AcceptInvitationRequest{Token: token, Password: "password123"})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("revoked and expired invitations", func(t *testing.T) {
+		token, id := invite("revoked@example.com")
+		w, _ := request("DELETE", fmt.Sprintf("/api/admin/invitations/%d", id), adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w, _ = request("DELETE", fmt.Sprintf("/api/admin/invitations/%d", id), adminToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w, _ = accept(AcceptInvitationRequest{Token: token, Password: "password123", FirstName: "Revoked", LastName: "User"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w, _ = request("POST", fmt.Sprintf("/api/admin/organizations/%d/expired-invitations", acme.ID), adminToken, InvitationRequest{Email: "late@example.com", RoleID: member.ID})
+		require.Equal(t, http.StatusCreated, w.Code)
+		w, _ = accept(AcceptInvitationRequest{Token: lastMailToken(t, mailDir), Password: "password123", FirstName: "Late", LastName: "User"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w, response := request("GET", invitationsURL, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, response["invitations"])
+	})
+
+	t.Run("roles of pending invitations cannot be deleted", func(t *testing.T) {
+		rbac := service.NewRBACService(roles, permissions, cache)
+		guest := models.Role{Name: "org_guest"}
+		require.NoError(t, roles.Create(&guest))
+		w, response := request("POST", invitationsURL, adminToken, InvitationRequest{Email: "guest@example.com", RoleID: guest.ID})
+		require.Equal(t, http.StatusCreated, w.Code)
+		id := uint(response["invitation"].(map[string]interface{})["ID"].(float64))
+
+		assert.ErrorIs(t, rbac.DeleteRole(guest.ID), service.ErrRoleInUse)
+
+		// Once revoked the invitation goes with the role
+		w, _ = request("DELETE", fmt.Sprintf("/api/admin/invitations/%d", id), adminToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NoError(t, rbac.DeleteRole(guest.ID))
+	})
+}
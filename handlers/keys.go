@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sukhantharot/go-service/service"
+)
+
+type KeysHandler struct {
+	keys *service.KeySet
+}
+
+func NewKeysHandler(keys *service.KeySet) *KeysHandler {
+	return &KeysHandler{keys: keys}
+}
+
+// JWKS publishes the public keys that verify our access tokens
+func (h *KeysHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": h.keys.JWKS()})
+}
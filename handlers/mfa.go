@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/service"
+)
+
+type LoginMFARequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	// Code is a TOTP code or one of the recovery codes
+	Code string `json:"code" binding:"required"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type MFAHandler struct {
+	mfa *service.MFAService
+}
+
+func NewMFAHandler(mfa *service.MFAService) *MFAHandler {
+	return &MFAHandler{mfa: mfa}
+}
+
+// LoginMFA completes a login that answered with mfa_required
+func (h *MFAHandler) LoginMFA(c *gin.Context) {
+	var req LoginMFARequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	pair, user, err := h.mfa.CompleteChallenge(req.MFAToken, req.Code)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, loginResponse(pair, user))
+}
+
+// StartTOTPEnrollment returns a new secret and otpauth:// URI for the authenticator app
+func (h *MFAHandler) StartTOTPEnrollment(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		fail(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	enrollment, err := h.mfa.StartEnrollment(userID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmTOTPEnrollment enables MFA and returns the recovery codes, they are not shown again
+func (h *MFAHandler) ConfirmTOTPEnrollment(c *gin.Context) {
+	var req MFACodeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		fail(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	codes, err := h.mfa.ConfirmEnrollment(userID, req.Code)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled, log in again to use it",
+		"recovery_codes": codes,
+	})
+}
+
+func (h *MFAHandler) DisableTOTP(c *gin.Context) {
+	var req MFACodeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		fail(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	if err := h.mfa.Disable(userID, req.Code); err != nil {
+		retryAfter(c, err)
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req MFACodeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		fail(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	codes, err := h.mfa.RegenerateRecoveryCodes(userID, req.Code)
+	if err != nil {
+		retryAfter(c, err)
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository/memory"
+	"github.com/sukhantharot/go-service/totp"
+)
+
+func TestTOTPLogin(t *testing.T) {
+	// Setup
+	store := memory.NewStore()
+	router := setupTestRouter()
+	services := setupTestServices(store, testConfig{})
+	auth := NewAuthHandler(services.auth)
+	mfa := NewMFAHandler(services.mfa)
+	router.POST("/api/auth/login", auth.Login)
+	router.POST("/api/auth/login/mfa", mfa.LoginMFA)
+	protected := services.authenticated(router)
+	protected.POST("/users/me/mfa/totp", mfa.StartTOTPEnrollment)
+	protected.POST("/users/me/mfa/totp/confirm", mfa.ConfirmTOTPEnrollment)
+
+	// Create test user
+	user := models.User{
+		Email:     "mfa@example.com",
+		Password:  hashPassword(t, "password123"),
+		FirstName: "Test",
+		LastName:  "User",
+	}
+	require.NoError(t, memory.NewUserRepository(store).Create(&user))
+
+	do := func(url, token string, payload interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+		jsonData, _ := json.Marshal(payload)
+		req := httptest.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+	credentials := LoginRequest{Email: "mfa@example.com", Password: "password123"}
+
+	// Enroll
+	w, login := do("/api/auth/login", "", credentials)
+	require.Equal(t, http.StatusOK, w.Code)
+	accessToken := login["token"].(string)
+
+	w, enrollment := do("/api/users/me/mfa/totp", accessToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	secret := enrollment["secret"].(string)
+	assert.Contains(t, enrollment["otpauth_uri"], "otpauth://totp/")
+
+	w, _ = do("/api/users/me/mfa/totp/confirm", accessToken, MFACodeRequest{Code: "000000"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	require.NoError(t, err)
+	w, confirmed := do("/api/users/me/mfa/totp/confirm", accessToken, MFACodeRequest{Code: code})
+	require.Equal(t, http.StatusOK, w.Code)
+	recoveryCodes := confirmed["recovery_codes"].([]interface{})
+	assert.Len(t, recoveryCodes, 10)
+
+	// Login now answers with a challenge instead of tokens
+	w, login = do("/api/auth/login", "", credentials)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, login["mfa_required"])
+	assert.Nil(t, login["token"])
+	mfaToken := login["mfa_token"].(string)
+
+	// The code used for enrollment cannot be replayed
+	w, _ = do("/api/auth/login/mfa", "", LoginMFARequest{MFAToken: mfaToken, Code: code})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// A recovery code completes the login once
+	recoveryCode := recoveryCodes[0].(string)
+	w, completed := do("/api/auth/login/mfa", "", LoginMFARequest{MFAToken: mfaToken, Code: recoveryCode})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, completed["token"])
+
+	// The challenge is used up and so is the recovery code
+	w, _ = do("/api/auth/login/mfa", "", LoginMFARequest{MFAToken: mfaToken, Code: recoveryCodes[1].(string)})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	_, login = do("/api/auth/login", "", credentials)
+	w, _ = do("/api/auth/login/mfa", "", LoginMFARequest{MFAToken: login["mfa_token"].(string), Code: recoveryCode})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestMFACodeAttemptsAreLimited(t *testing.T) {
+	// Setup
+	store := memory.NewStore()
+	router := setupTestRouter()
+	services := setupTestServices(store, testConfig{})
+	auth := NewAuthHandler(services.auth)
+	mfa := NewMFAHandler(services.mfa)
+	router.POST("/api/auth/login", auth.Login)
+	protected := services.authenticated(router)
+	protected.POST("/users/me/mfa/totp", mfa.StartTOTPEnrollment)
+	protected.POST("/users/me/mfa/totp/confirm", mfa.ConfirmTOTPEnrollment)
+	protected.POST("/users/me/mfa/totp/disable", mfa.DisableTOTP)
+	protected.POST("/users/me/mfa/recovery-codes", mfa.RegenerateRecoveryCodes)
+
+	user := models.User{
+		Email:     "guess@example.com",
+		Password:  hashPassword(t, "password123"),
+		FirstName: "Test",
+		LastName:  "User",
+	}
+	require.NoError(t, memory.NewUserRepository(store).Create(&user))
+
+	do := func(url, token string, payload interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+		jsonData, _ := json.Marshal(payload)
+		req := httptest.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	// Enroll while the account has no second factor yet
+	w, login := do("/api/auth/login", "", LoginRequest{Email: "guess@example.com", Password: "password123"})
+	require.Equal(t, http.StatusOK, w.Code)
+	accessToken := login["token"].(string)
+	_, enrollment := do("/api/users/me/mfa/totp", accessToken, nil)
+	code, err := totp.Code(enrollment["secret"].(string), totp.Step(time.Now()))
+	require.NoError(t, err)
+	w, confirmed := do("/api/users/me/mfa/totp/confirm", accessToken, MFACodeRequest{Code: code})
+	require.Equal(t, http.StatusOK, w.Code)
+	recoveryCode := confirmed["recovery_codes"].([]interface{})[0].(string)
+
+	// Wrong codes count across both routes up to MaxAttempts
+	for i := 0; i < 5; i++ {
+		url := "/api/users/me/mfa/totp/disable"
+		if i%2 == 1 {
+			url = "/api/users/me/mfa/recovery-codes"
+		}
+		w, _ = do(url, accessToken, MFACodeRequest{Code: "aaaaa-aaaaa"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+
+	// Once they are used up even a valid code is refused
+	w, _ = do("/api/users/me/mfa/totp/disable", accessToken, MFACodeRequest{Code: recoveryCode})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	w, _ = do("/api/users/me/mfa/recovery-codes", accessToken, MFACodeRequest{Code: recoveryCode})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	// The refused recovery code was not used up
+	stored, err := memory.NewUserRepository(store).FindByID(user.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.IsMFAEnabled())
+	unused, err := memory.NewMFARepository(store).CountUnusedRecoveryCodes(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), unused)
+}
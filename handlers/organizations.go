@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/service"
+)
+
+type OrganizationRequest struct {
+	Name        string `json:"name" binding:"required,max=255"`
+	Description string `json:"description"`
+}
+
+type MemberRequest struct {
+	RoleID uint `json:"role_id" binding:"required"`
+}
+
+// SwitchOrganizationRequest names the organization to make active, zero for none
+type SwitchOrganizationRequest struct {
+	OrganizationID uint `json:"organization_id"`
+}
+
+type OrganizationHandler struct {
+	organizations *service.OrganizationService
+}
+
+func NewOrganizationHandler(organizations *service.OrganizationService) *OrganizationHandler {
+	return &OrganizationHandler{organizations: organizations}
+}
+
+func (h *OrganizationHandler) ListOrganizations(c *gin.Context) {
+	organizations, err := h.organizations.List()
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organizations": organizations})
+}
+
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	var req OrganizationRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	organization, err := h.organizations.Create(req.Name, req.Description)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"organization": organization})
+}
+
+func (h *OrganizationHandler) GetOrganization(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	organization, err := h.organizations.Get(id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organization": organization})
+}
+
+func (h *OrganizationHandler) UpdateOrganization(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	var req OrganizationRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	organization, err := h.organizations.Update(id, req.Name, req.Description)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organization": organization})
+}
+
+func (h *OrganizationHandler) DeleteOrganization(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.organizations.Delete(id); err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Organization deleted"})
+}
+
+func (h *OrganizationHandler) ListMembers(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	h.listMembers(c, id)
+}
+
+func (h *OrganizationHandler) SetMember(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	var req MemberRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	membership, err := h.organizations.SetMember(id, userID, req.RoleID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"membership": membership})
+}
+
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	h.removeMember(c, id)
+}
+
+// ListMyOrganizations lists the memberships of the current user
+func (h *OrganizationHandler) ListMyOrganizations(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		fail(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	memberships, err := h.organizations.ListForUser(userID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"memberships": memberships})
+}
+
+// SwitchOrganization returns new tokens for the organization the user wants to act in
+func (h *OrganizationHandler) SwitchOrganization(c *gin.Context) {
+	var req SwitchOrganizationRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		fail(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	pair, err := h.organizations.Switch(userID, c.GetBool("mfa"), req.OrganizationID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, pair)
+}
+
+// GetCurrentOrganization returns the active organization of the access token
+func (h *OrganizationHandler) GetCurrentOrganization(c *gin.Context) {
+	organizationID, ok := currentOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	organization, err := h.organizations.Get(organizationID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organization": organization})
+}
+
+// ListCurrentMembers lists the members of the active organization
+func (h *OrganizationHandler) ListCurrentMembers(c *gin.Context) {
+	organizationID, ok := currentOrganizationID(c)
+	if !ok {
+		return
+	}
+	h.listMembers(c, organizationID)
+}
+
+// AssignCurrentMember adds a user to the active organization or changes their role there
+func (h *OrganizationHandler) AssignCurrentMember(c *gin.Context) {
+	organizationID, ok := currentOrganizationID(c)
+	if !ok {
+		return
+	}
+	actorID, ok := currentUserID(c)
+	if !ok {
+		fail(c, apperrors.ErrUnauthorized)
+		return
+	}
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	var req MemberRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	membership, err := h.organizations.AssignMember(actorID, organizationID, userID, req.RoleID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"membership": membership})
+}
+
+// RemoveCurrentMember takes a user out of the active organization
+func (h *OrganizationHandler) RemoveCurrentMember(c *gin.Context) {
+	organizationID, ok := currentOrganizationID(c)
+	if !ok {
+		return
+	}
+	h.removeMember(c, organizationID)
+}
+
+func (h *OrganizationHandler) listMembers(c *gin.Context, organizationID uint) {
+	memberships, err := h.organizations.ListMembers(organizationID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"members": memberships})
+}
+
+func (h *OrganizationHandler) removeMember(c *gin.Context, organizationID uint) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.organizations.RemoveMember(organizationID, userID); err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed"})
+}
+
+// userIDParam parses the :user_id path parameter
+func userIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("user_id"), 10, 64)
+	if err != nil || id == 0 {
+		fail(c, apperrors.ErrBadRequest.WithMessage("Invalid user ID").Wrap(err))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// currentOrganizationID reads the active organization set by JWTAuth. Without one it
+// fails the request, routes using it are behind middleware.RequireOrganization anyway.
+func currentOrganizationID(c *gin.Context) (uint, bool) {
+	value, _ := c.Get("org_id")
+	id, ok := value.(uint)
+	if !ok {
+		fail(c, apperrors.ErrOrganizationRequired)
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sukhantharot/go-service/middleware"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository/memory"
+	"github.com/sukhantharot/go-service/service"
+)
+
+func TestOrganizations(t *testing.T) {
+	// Setup
+	store := memory.NewStore()
+	router := setupTestRouter()
+	services := setupTestServices(store, testConfig{})
+	roles := memory.NewRoleRepository(store)
+	permissions := memory.NewPermissionRepository(store)
+	organizationRepo := memory.NewOrganizationRepository(store)
+	cache := service.NewPermissionCache(roles, time.Minute)
+	access := service.NewAccessService(
+		memory.NewGrantRepository(store),
+		memory.NewUserRepository(store),
+		organizationRepo,
+		service.NewRBACService(roles, permissions, cache),
+		cache,
+	)
+	organizations := service.NewOrganizationService(organizationRepo, memory.NewUserRepository(store), cache, services.tokens, services.revocations)
+	handler := NewOrganizationHandler(organizations)
+	users := NewUserHandler(services.auth, services.users)
+	router.POST("/api/auth/login", NewAuthHandler(services.auth).Login)
+	router.POST("/api/auth/refresh", NewAuthHandler(services.auth).Refresh)
+	protected := services.authenticated(router)
+	protected.POST("/auth/switch-organization", handler.SwitchOrganization)
+	protected.GET("/users/me/organizations", handler.ListMyOrganizations)
+	protected.GET("/users/:id", middleware.RequireResourcePermission(access, "users:read", middleware.UserParam("id", access)), users.GetUser)
+	protected.PUT("/users/:id", middleware.RequireResourcePermission(access, "users:write", middleware.UserParam("id", access)), users.UpdateGrantedUser)
+	organization := protected.Group("/organization", middleware.RequireOrganization())
+	organization.GET("", handler.GetCurrentOrganization)
+	organization.GET("/members", middleware.RequireOrganizationPermission(cache, "members:read"), handler.ListCurrentMembers)
+	organization.PUT("/members/:user_id", middleware.RequireOrganizationPermission(cache, "members:write"), handler.AssignCurrentMember)
+	router.POST("/api/admin/organizations", handler.CreateOrganization)
+	router.GET("/api/admin/organizations/:id/members", handler.ListMembers)
+	router.PUT("/api/admin/organizations/:id/members/:user_id", handler.SetMember)
+	router.DELETE("/api/admin/organizations/:id/members/:user_id", handler.RemoveMember)
+	router.GET("/api/admin/users", users.GetAllUsers)
+
+	var perms []models.Permission
+	for _, name := range []string{"members:read", "members:write", "users:read", "reports:write", models.PermissionAdmin} {
+		permission := models.Permission{Name: name}
+		require.NoError(t, permissions.Create(&permission))
+		perms = append(perms, permission)
+	}
+	usersWrite := models.Permission{Name: "users:write"}
+	require.NoError(t, permissions.Create(&usersWrite))
+	manager := models.Role{Name: "org_manager", Permissions: append(perms[:3:3], usersWrite)}
+	require.NoError(t, roles.Create(&manager))
+	member := models.Role{Name: "org_member"}
+	require.NoError(t, roles.Create(&member))
+	reporter := models.Role{Name: "org_reporter", Permissions: perms[3:4]}
+	require.NoError(t, roles.Create(&reporter))
+	adminRole := models.Role{Name: models.RoleAdmin, Permissions: perms[4:]}
+	require.NoError(t, roles.Create(&adminRole))
+
+	userRepo := memory.NewUserRepository(store)
+	var alice, bob, carol, dave models.User
+	for _, user := range []*models.User{&alice, &bob, &carol, &dave} {
+		*user = models.User{Password: hashPassword(t, "password123")}
+	}
+	alice.Email, bob.Email, carol.Email, dave.Email = "alice@example.com", "bob@example.com", "carol@example.com", "dave@example.com"
+	// Dave is a global admin
+	dave.Roles = []models.Role{adminRole}
+	for _, user := range []*models.User{&alice, &bob, &carol, &dave} {
+		require.NoError(t, userRepo.Create(user))
+	}
+
+	request := func(method, url, token string, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var response map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+	login := func(user models.User) (string, string) {
+		// Membership changes revoke tokens issued up to the same millisecond
+		time.Sleep(time.Millisecond)
+		_, response := request("POST", "/api/auth/login", "", LoginRequest{Email: user.Email, Password: "password123"})
+		return response["token"].(string), response["refresh_token"].(string)
+	}
+	createOrganization := func(name string) uint {
+		w, response := request("POST", "/api/admin/organizations", "", OrganizationRequest{Name: name})
+		require.Equal(t, http.StatusCreated, w.Code)
+		return uint(response["organization"].(map[string]interface{})["ID"].(float64))
+	}
+	membersURL := func(organizationID uint, user models.User) string {
+		return fmt.Sprintf("/api/admin/organizations/%d/members/%d", organizationID, user.ID)
+	}
+
+	acme := createOrganization("Acme")
+	globex := createOrganization("Globex")
+	w, _ := request("POST", "/api/admin/organizations", "", OrganizationRequest{Name: "Acme"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	for _, m := range []struct {
+		organization uint
+		user         models.User
+		role         models.Role
+	}{
+		{acme, alice, manager},
+		{acme, bob, member},
+		{globex, alice, member},
+		{globex, carol, manager},
+	} {
+		w, _ := request("PUT", membersURL(m.organization, m.user), "", MemberRequest{RoleID: m.role.ID})
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+	w, response := request("PUT", membersURL(acme, carol), "", MemberRequest{RoleID: adminRole.ID})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "admin_membership_role", response["code"])
+
+	aliceToken, aliceRefresh := login(alice)
+	bobToken, _ := login(bob)
+
+	t.Run("login activates the oldest membership", func(t *testing.T) {
+		w, response := request("GET", "/api/organization", aliceToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Acme", response["organization"].(map[string]interface{})["name"])
+
+		w, response = request("GET", "/api/users/me/organizations", aliceToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, response["memberships"], 2)
+	})
+
+	t.Run("permissions of the membership role", func(t *testing.T) {
+		w, response := request("GET", "/api/organization/members", aliceToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, response["members"], 2)
+
+		w, _ = request("GET", "/api/organization/members", bobToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("membership role only reaches members", func(t *testing.T) {
+		w, _ := request("GET", fmt.Sprintf("/api/users/%d", bob.ID), aliceToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		// Carol is in Globex only
+		w, _ = request("GET", fmt.Sprintf("/api/users/%d", carol.ID), aliceToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		update := UpdateUserRequest{Email: bob.Email, FirstName: "Bob", LastName: "Jones"}
+		w, _ = request("PUT", fmt.Sprintf("/api/users/%d", bob.ID), aliceToken, update)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("membership role does not reach global admins", func(t *testing.T) {
+		w, _ := request("PUT", membersURL(acme, dave), "", MemberRequest{RoleID: member.ID})
+		require.Equal(t, http.StatusOK, w.Code)
+		defer request("DELETE", membersURL(acme, dave), "", nil)
+
+		w, _ = request("GET", fmt.Sprintf("/api/users/%d", dave.ID), aliceToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		update := UpdateUserRequest{Email: dave.Email, FirstName: "Dave", LastName: "Smith"}
+		w, _ = request("PUT", fmt.Sprintf("/api/users/%d", dave.ID), aliceToken, update)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("assign members", func(t *testing.T) {
+		url := func(user models.User) string { return fmt.Sprintf("/api/organization/members/%d", user.ID) }
+
+		w, response := request("PUT", url(carol), aliceToken, MemberRequest{RoleID: reporter.ID})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, "membership_escalation", response["code"])
+
+		w, _ = request("PUT", url(alice), aliceToken, MemberRequest{RoleID: member.ID})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w, _ = request("PUT", url(carol), aliceToken, MemberRequest{RoleID: member.ID})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w, response = request("GET", fmt.Sprintf("/api/admin/users?organization_id=%d", acme), "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.EqualValues(t, 3, response["total"])
+
+		w, _ = request("DELETE", membersURL(acme, carol), "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w, _ = request("DELETE", membersURL(acme, carol), "", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("switch organization", func(t *testing.T) {
+		w, response := request("POST", "/api/auth/switch-organization", aliceToken, SwitchOrganizationRequest{OrganizationID: globex})
+		require.Equal(t, http.StatusOK, w.Code)
+		globexToken := response["token"].(string)
+		globexRefresh := response["refresh_token"].(string)
+
+		w, response = request("GET", "/api/organization", globexToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Globex", response["organization"].(map[string]interface{})["name"])
+		// Alice manages Acme but is a plain member of Globex
+		w, _ = request("GET", "/api/organization/members", globexToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		// Refreshing keeps the organization
+		w, response = request("POST", "/api/auth/refresh", "", RefreshRequest{RefreshToken: globexRefresh})
+		require.Equal(t, http.StatusOK, w.Code)
+		w, response = request("GET", "/api/organization", response["token"].(string), nil)
+		assert.Equal(t, "Globex", response["organization"].(map[string]interface{})["name"])
+
+		w, response = request("POST", "/api/auth/switch-organization", bobToken, SwitchOrganizationRequest{OrganizationID: globex})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, "not_a_member", response["code"])
+
+		w, response = request("POST", "/api/auth/switch-organization", aliceToken, SwitchOrganizationRequest{})
+		require.Equal(t, http.StatusOK, w.Code)
+		w, response = request("GET", "/api/organization", response["token"].(string), nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, "organization_required", response["code"])
+	})
+
+	t.Run("removed members lose the organization", func(t *testing.T) {
+		w, _ := request("DELETE", membersURL(acme, alice), "", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w, _ = request("GET", "/api/organization", aliceToken, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		// Refreshing drops the organization the token was issued for
+		time.Sleep(time.Millisecond)
+		w, response := request("POST", "/api/auth/refresh", "", RefreshRequest{RefreshToken: aliceRefresh})
+		require.Equal(t, http.StatusOK, w.Code)
+		w, _ = request("GET", "/api/organization", response["token"].(string), nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		// Globex is the only membership left
+		token, _ := login(alice)
+		w, response = request("GET", "/api/organization", token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Globex", response["organization"].(map[string]interface{})["name"])
+	})
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/logger"
+	"github.com/sukhantharot/go-service/service"
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+type PasswordHandler struct {
+	passwords *service.PasswordService
+}
+
+func NewPasswordHandler(passwords *service.PasswordService) *PasswordHandler {
+	return &PasswordHandler{passwords: passwords}
+}
+
+// ForgotPassword always answers the same way so it cannot be used to find registered emails
+func (h *PasswordHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.passwords.ForgotPassword(req.Email); err != nil {
+		// Still answer with the generic message, failures must not reveal the account exists
+		logger.Error("Could not send password reset email", err, nil)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the email is registered, a password reset link has been sent"})
+}
+
+func (h *PasswordHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.passwords.ResetPassword(req.Token, req.Password); err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
+
+// ChangePassword sets a new password for the signed in user and signs out every other
+// session. The tokens used for the request are revoked too, the response carries new ones.
+func (h *PasswordHandler) ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		fail(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	pair, err := h.passwords.ChangePassword(userID, req.CurrentPassword, req.NewPassword, c.GetBool("mfa"))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":            "Password changed",
+		"token":              pair.AccessToken,
+		"expires_at":         pair.ExpiresAt,
+		"refresh_token":      pair.RefreshToken,
+		"refresh_expires_at": pair.RefreshExpiresAt,
+	})
+}
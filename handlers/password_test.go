@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sukhantharot/go-service/mail"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository/memory"
+	"github.com/sukhantharot/go-service/service"
+)
+
+var resetTokenPattern = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+// lastMailToken returns the token= value of the most recent email written by a FileSender
+func lastMailToken(t *testing.T, dir string) string {
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	content, err := os.ReadFile(files[len(files)-1])
+	require.NoError(t, err)
+	match := resetTokenPattern.FindStringSubmatch(string(content))
+	require.Len(t, match, 2)
+	return match[1]
+}
+
+func TestPasswordReset(t *testing.T) {
+	// Setup
+	store := memory.NewStore()
+	router := setupTestRouter()
+	mailDir := t.TempDir()
+	mailer, err := mail.NewFileSender(mailDir, "no-reply@example.com")
+	require.NoError(t, err)
+	services := setupTestServices(store, testConfig{mailer: mailer})
+	passwords := NewPasswordHandler(service.NewPasswordService(
+		memory.NewUserRepository(store),
+		memory.NewPasswordResetRepository(store),
+		services.tokens,
+		mailer,
+		service.PasswordConfig{ResetTTL: time.Hour, ResetURL: "http://localhost/reset"},
+	))
+	router.POST("/api/auth/login", NewAuthHandler(services.auth).Login)
+	router.POST("/api/auth/password/forgot", passwords.ForgotPassword)
+	router.POST("/api/auth/password/reset", passwords.ResetPassword)
+
+	// Create test user
+	user := models.User{
+		Email:     "reset@example.com",
+		Password:  hashPassword(t, "password123"),
+		FirstName: "Test",
+		LastName:  "User",
+	}
+	require.NoError(t, memory.NewUserRepository(store).Create(&user))
+
+	post := func(url string, payload interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+		jsonData, _ := json.Marshal(payload)
+		req := httptest.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	// Unknown and known emails get the same answer, only the known one gets mail
+	w, unknown := post("/api/auth/password/forgot", ForgotPasswordRequest{Email: "nobody@example.com"})
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	files, _ := filepath.Glob(filepath.Join(mailDir, "*.eml"))
+	assert.Empty(t, files)
+
+	w, known := post("/api/auth/password/forgot", ForgotPasswordRequest{Email: "reset@example.com"})
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, unknown, known)
+	resetToken := lastMailToken(t, mailDir)
+
+	// Invalid tokens are rejected
+	w, response := post("/api/auth/password/reset", ResetPasswordRequest{Token: "bogus", Password: "newpassword"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "Invalid or expired reset token", response["error"])
+
+	// The token resets the password once
+	w, _ = post("/api/auth/password/reset", ResetPasswordRequest{Token: resetToken, Password: "newpassword"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w, _ = post("/api/auth/password/reset", ResetPasswordRequest{Token: resetToken, Password: "another"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Only the new password works
+	w, _ = post("/api/auth/login", LoginRequest{Email: "reset@example.com", Password: "password123"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w, _ = post("/api/auth/login", LoginRequest{Email: "reset@example.com", Password: "newpassword"})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestChangePassword(t *testing.T) {
+	// Setup
+	store := memory.NewStore()
+	router := setupTestRouter()
+	mailDir := t.TempDir()
+	mailer, err := mail.NewFileSender(mailDir, "no-reply@example.com")
+	require.NoError(t, err)
+	services := setupTestServices(store, testConfig{mailer: mailer})
+	passwordService := service.NewPasswordService(
+		memory.NewUserRepository(store),
+		memory.NewPasswordResetRepository(store),
+		services.tokens,
+		mailer,
+		service.PasswordConfig{ResetTTL: time.Hour, ResetURL: "http://localhost/reset"},
+	)
+	passwords := NewPasswordHandler(passwordService)
+	router.POST("/api/auth/login", NewAuthHandler(services.auth).Login)
+	router.POST("/api/auth/password/reset", passwords.ResetPassword)
+	protected := services.authenticated(router)
+	protected.GET("/users/me", NewUserHandler(services.auth, services.users).GetCurrentUser)
+	protected.POST("/users/me/password", passwords.ChangePassword)
+
+	user := models.User{Email: "change@example.com", Password: hashPassword(t, "password123")}
+	require.NoError(t, memory.NewUserRepository(store).Create(&user))
+
+	request := func(method, url, token string, payload interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+		jsonData, _ := json.Marshal(payload)
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+	_, response := request("POST", "/api/auth/login", "", LoginRequest{Email: user.Email, Password: "password123"})
+	current := response["token"].(string)
+	_, response = request("POST", "/api/auth/login", "", LoginRequest{Email: user.Email, Password: "password123"})
+	other := response["token"].(string)
+
+	// A reset link requested before the change must not undo it
+	require.NoError(t, passwordService.ForgotPassword(user.Email))
+	resetToken := lastMailToken(t, mailDir)
+
+	w, response := request("POST", "/api/users/me/password", current, ChangePasswordRequest{CurrentPassword: "wrong", NewPassword: "newpassword"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "incorrect_password", response["code"])
+
+	w, response = request("POST", "/api/users/me/password", current, ChangePasswordRequest{CurrentPassword: "password123", NewPassword: "newpassword"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, response["refresh_token"])
+
+	// Only the session that changed the password continues, with the new tokens
+	w, _ = request("GET", "/api/users/me", response["token"].(string), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w, _ = request("GET", "/api/users/me", other, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w, _ = request("GET", "/api/users/me", current, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w, _ = request("POST", "/api/auth/password/reset", "", ResetPasswordRequest{Token: resetToken, Password: "resetpassword"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, _ = request("POST", "/api/auth/login", "", LoginRequest{Email: user.Email, Password: "password123"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w, _ = request("POST", "/api/auth/login", "", LoginRequest{Email: user.Email, Password: "newpassword"})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
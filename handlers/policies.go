@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/policy"
+	"github.com/sukhantharot/go-service/service"
+)
+
+type PolicyHandler struct {
+	policies *service.PolicyService
+}
+
+func NewPolicyHandler(policies *service.PolicyService) *PolicyHandler {
+	return &PolicyHandler{policies: policies}
+}
+
+func (h *PolicyHandler) ListPolicies(c *gin.Context) {
+	policies, err := h.policies.List()
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"policies": policies})
+}
+
+// CreatePolicy stores a policy in the format of policy files, in JSON
+func (h *PolicyHandler) CreatePolicy(c *gin.Context) {
+	var req policy.Policy
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	created, err := h.policies.Create(req)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"policy": created})
+}
+
+func (h *PolicyHandler) DeletePolicy(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.policies.Delete(id); err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Policy deleted"})
+}
+
+// EvaluatePolicies decides a request given with all its attributes without acting on
+// it, to try policies out
+func (h *PolicyHandler) EvaluatePolicies(c *gin.Context) {
+	var req policy.Request
+	if !bindJSON(c, &req) {
+		return
+	}
+	if req.Action == "" {
+		fail(c, apperrors.ErrBadRequest.WithMessage("action is required"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"decision": h.policies.Evaluate(req)})
+}
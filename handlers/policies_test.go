@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sukhantharot/go-service/middleware"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/policy"
+	"github.com/sukhantharot/go-service/repository/memory"
+	"github.com/sukhantharot/go-service/service"
+)
+
+func TestPolicies(t *testing.T) {
+	// Setup
+	store := memory.NewStore()
+	router := setupTestRouter()
+	services := setupTestServices(store, testConfig{})
+	roles := memory.NewRoleRepository(store)
+	permissions := memory.NewPermissionRepository(store)
+	cache := service.NewPermissionCache(roles, time.Minute)
+	policies, err := service.NewPolicyService(memory.NewPolicyRepository(store), memory.NewUserRepository(store), cache, service.PolicyConfig{})
+	require.NoError(t, err)
+	users := NewUserHandler(services.auth, services.users)
+	handler := NewPolicyHandler(policies)
+	router.POST("/api/auth/login", NewAuthHandler(services.auth).Login)
+	protected := services.authenticated(router)
+	protected.GET("/users/:id",
+		middleware.RequirePermission(cache, "users:read"),
+		middleware.EnforcePolicies(policies, "users:read", middleware.PolicyResourceParam("id", policies.UserAttributes)),
+		users.GetUser)
+	protected.GET("/reports", middleware.RequirePolicy(policies, "reports:read", nil), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"reports": []string{}})
+	})
+	router.GET("/api/admin/policies", handler.ListPolicies)
+	router.POST("/api/admin/policies", handler.CreatePolicy)
+	router.DELETE("/api/admin/policies/:id", handler.DeletePolicy)
+	router.POST("/api/admin/policies/evaluate", handler.EvaluatePolicies)
+
+	read := models.Permission{Name: "users:read"}
+	require.NoError(t, permissions.Create(&read))
+	userRole := models.Role{Name: models.RoleUser, Permissions: []models.Permission{read}}
+	require.NoError(t, roles.Create(&userRole))
+	adminRole := models.Role{Name: models.RoleAdmin, Permissions: []models.Permission{read}}
+	require.NoError(t, roles.Create(&adminRole))
+
+	userRepo := memory.NewUserRepository(store)
+	alice := models.User{Email: "alice@example.com", Password: hashPassword(t, "password123"), Roles: []models.Role{userRole}}
+	require.NoError(t, userRepo.Create(&alice))
+	root := models.User{Email: "root@example.com", Password: hashPassword(t, "password123"), Roles: []models.Role{adminRole}}
+	require.NoError(t, userRepo.Create(&root))
+
+	request := func(method, url, token string, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var response map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+	_, response := request("POST", "/api/auth/login", "", LoginRequest{Email: alice.Email, Password: "password123"})
+	token := response["token"].(string)
+	userURL := func(user models.User) string { return fmt.Sprintf("/api/users/%d", user.ID) }
+
+	adminsOnly := policy.Policy{
+		Name:      "only-admins-read-admins",
+		Effect:    policy.Deny,
+		Actions:   []string{"users:*"},
+		Resources: []string{models.ResourceUsers},
+		Conditions: []policy.Condition{
+			{Attribute: "resource.roles", Operator: policy.OpContains, Value: models.RoleAdmin},
+			{Attribute: "subject.roles", Operator: policy.OpNotContains, Value: models.RoleAdmin},
+		},
+	}
+
+	t.Run("deny policy", func(t *testing.T) {
+		w, _ := request("GET", userURL(root), token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w, response := request("POST", "/api/admin/policies", "", adminsOnly)
+		require.Equal(t, http.StatusCreated, w.Code)
+		created := response["policy"].(map[string]interface{})
+		assert.Equal(t, adminsOnly.Name, created["name"])
+		assert.Equal(t, service.PolicySourceDatabase, created["source"])
+		policyURL := fmt.Sprintf("/api/admin/policies/%v", created["id"])
+
+		w, _ = request("GET", userURL(root), token, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w, _ = request("GET", userURL(alice), token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w, _ = request("GET", "/api/users/999", token, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w, response = request("GET", "/api/admin/policies", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, response["policies"], 1)
+
+		w, _ = request("POST", "/api/admin/policies", "", adminsOnly)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w, _ = request("DELETE", policyURL, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w, _ = request("DELETE", policyURL, "", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w, _ = request("GET", userURL(root), token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("allow policy", func(t *testing.T) {
+		w, _ := request("GET", "/api/reports", token, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w, _ = request("POST", "/api/admin/policies", "", policy.Policy{
+			Name:       "users-read-reports",
+			Effect:     policy.Allow,
+			Actions:    []string{"reports:read"},
+			Conditions: []policy.Condition{{Attribute: "subject.roles", Operator: policy.OpContains, Value: models.RoleUser}},
+		})
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		w, _ = request("GET", "/api/reports", token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("invalid policy", func(t *testing.T) {
+		w, response := request("POST", "/api/admin/policies", "", policy.Policy{Name: "broken", Effect: "maybe", Actions: []string{"users:read"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "invalid_policy", response["code"])
+	})
+
+	t.Run("evaluate", func(t *testing.T) {
+		w, response := request("POST", "/api/admin/policies/evaluate", "", policy.Request{
+			Action:  "reports:read",
+			Subject: policy.Attributes{"roles": []string{models.RoleUser}},
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+		decision := response["decision"].(map[string]interface{})
+		assert.Equal(t, string(policy.Allow), decision["effect"])
+		assert.Equal(t, "users-read-reports", decision["policy"])
+
+		w, _ = request("POST", "/api/admin/policies/evaluate", "", policy.Request{})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
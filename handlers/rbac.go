@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/service"
+)
+
+type CreateRoleRequest struct {
+	Name        string `json:"name" binding:"required,max=255"`
+	Description string `json:"description"`
+	// PermissionIDs are granted to the new role, each must exist
+	PermissionIDs []uint `json:"permission_ids"`
+}
+
+type UpdateRoleRequest struct {
+	Name        string `json:"name" binding:"required,max=255"`
+	Description string `json:"description"`
+}
+
+type PermissionRequest struct {
+	Name        string `json:"name" binding:"required,max=255"`
+	Description string `json:"description"`
+}
+
+type RBACHandler struct {
+	rbac *service.RBACService
+}
+
+func NewRBACHandler(rbac *service.RBACService) *RBACHandler {
+	return &RBACHandler{rbac: rbac}
+}
+
+func (h *RBACHandler) ListRoles(c *gin.Context) {
+	roles, err := h.rbac.ListRoles()
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+func (h *RBACHandler) GetRole(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	role, err := h.rbac.GetRole(id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"role": role})
+}
+
+func (h *RBACHandler) CreateRole(c *gin.Context) {
+	var req CreateRoleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	role, err := h.rbac.CreateRole(req.Name, req.Description, req.PermissionIDs)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"role": role})
+}
+
+func (h *RBACHandler) UpdateRole(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	var req UpdateRoleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	role, err := h.rbac.UpdateRole(id, req.Name, req.Description)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"role": role})
+}
+
+// DeleteRole refuses built-in roles and roles that are still assigned to users
+func (h *RBACHandler) DeleteRole(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.rbac.DeleteRole(id); err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted"})
+}
+
+func (h *RBACHandler) AttachPermission(c *gin.Context) {
+	roleID, permissionID, ok := rolePermissionParams(c)
+	if !ok {
+		return
+	}
+
+	role, err := h.rbac.AttachPermission(roleID, permissionID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"role": role})
+}
+
+func (h *RBACHandler) DetachPermission(c *gin.Context) {
+	roleID, permissionID, ok := rolePermissionParams(c)
+	if !ok {
+		return
+	}
+
+	role, err := h.rbac.DetachPermission(roleID, permissionID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"role": role})
+}
+
+func (h *RBACHandler) AddParent(c *gin.Context) {
+	roleID, parentID, ok := roleParentParams(c)
+	if !ok {
+		return
+	}
+
+	role, err := h.rbac.AddParent(roleID, parentID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"role": role})
+}
+
+func (h *RBACHandler) RemoveParent(c *gin.Context) {
+	roleID, parentID, ok := roleParentParams(c)
+	if !ok {
+		return
+	}
+
+	role, err := h.rbac.RemoveParent(roleID, parentID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"role": role})
+}
+
+// EffectivePermissions lists the role's own and inherited permissions with the role granting each
+func (h *RBACHandler) EffectivePermissions(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	permissions, err := h.rbac.EffectivePermissions(id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"role_id": id, "permissions": permissions})
+}
+
+func (h *RBACHandler) ListPermissions(c *gin.Context) {
+	permissions, err := h.rbac.ListPermissions()
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"permissions": permissions})
+}
+
+func (h *RBACHandler) GetPermission(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	permission, err := h.rbac.GetPermission(id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"permission": permission})
+}
+
+func (h *RBACHandler) CreatePermission(c *gin.Context) {
+	var req PermissionRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	permission, err := h.rbac.CreatePermission(req.Name, req.Description)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"permission": permission})
+}
+
+func (h *RBACHandler) UpdatePermission(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	var req PermissionRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	permission, err := h.rbac.UpdatePermission(id, req.Name, req.Description)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"permission": permission})
+}
+
+// DeletePermission deletes the permission and revokes it from every role
+func (h *RBACHandler) DeletePermission(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.rbac.DeletePermission(id); err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Permission deleted"})
+}
+
+// rolePermissionParams parses the :id and :permission_id path parameters
+func rolePermissionParams(c *gin.Context) (uint, uint, bool) {
+	roleID, ok := idParam(c)
+	if !ok {
+		return 0, 0, false
+	}
+	permissionID, err := strconv.ParseUint(c.Param("permission_id"), 10, 64)
+	if err != nil || permissionID == 0 {
+		fail(c, apperrors.ErrBadRequest.WithMessage("Invalid permission ID").Wrap(err))
+		return 0, 0, false
+	}
+	return roleID, uint(permissionID), true
+}
+
+// roleParentParams parses the :id and :parent_id path parameters
+func roleParentParams(c *gin.Context) (uint, uint, bool) {
+	roleID, ok := idParam(c)
+	if !ok {
+		return 0, 0, false
+	}
+	parentID, err := strconv.ParseUint(c.Param("parent_id"), 10, 64)
+	if err != nil || parentID == 0 {
+		fail(c, apperrors.ErrBadRequest.WithMessage("Invalid parent role ID").Wrap(err))
+		return 0, 0, false
+	}
+	return roleID, uint(parentID), true
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository/memory"
+	"github.com/sukhantharot/go-service/service"
+	"gorm.io/gorm"
+)
+
+func TestRoleManagement(t *testing.T) {
+	// Setup
+	store := memory.NewStore()
+	roles := memory.NewRoleRepository(store)
+	permissions := memory.NewPermissionRepository(store)
+	router := setupTestRouter()
+	rbac := NewRBACHandler(service.NewRBACService(roles, permissions, service.NewPermissionCache(roles, time.Minute)))
+	router.GET("/api/admin/roles", rbac.ListRoles)
+	router.POST("/api/admin/roles", rbac.CreateRole)
+	router.GET("/api/admin/roles/:id", rbac.GetRole)
+	router.PUT("/api/admin/roles/:id", rbac.UpdateRole)
+	router.DELETE("/api/admin/roles/:id", rbac.DeleteRole)
+	router.POST("/api/admin/roles/:id/permissions/:permission_id", rbac.AttachPermission)
+	router.DELETE("/api/admin/roles/:id/permissions/:permission_id", rbac.DetachPermission)
+	router.GET("/api/admin/roles/:id/effective-permissions", rbac.EffectivePermissions)
+	router.POST("/api/admin/roles/:id/parents/:parent_id", rbac.AddParent)
+	router.DELETE("/api/admin/roles/:id/parents/:parent_id", rbac.RemoveParent)
+	router.POST("/api/admin/permissions", rbac.CreatePermission)
+	router.PUT("/api/admin/permissions/:id", rbac.UpdatePermission)
+	router.DELETE("/api/admin/permissions/:id", rbac.DeletePermission)
+
+	// Seed the built-in roles like 001_init.sql
+	adminPermission := models.Permission{Name: models.PermissionAdmin}
+	require.NoError(t, permissions.Create(&adminPermission))
+	adminRole := models.Role{Name: models.RoleAdmin, Permissions: []models.Permission{adminPermission}}
+	require.NoError(t, roles.Create(&adminRole))
+	userRole := models.Role{Name: models.RoleUser}
+	require.NoError(t, roles.Create(&userRole))
+
+	request := func(method, url string, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var response map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	w, response := request("POST", "/api/admin/permissions", PermissionRequest{Name: "write:reports"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	permissionID := uint(response["permission"].(map[string]interface{})["ID"].(float64))
+
+	w, response = request("POST", "/api/admin/roles", CreateRoleRequest{Name: "editor", PermissionIDs: []uint{permissionID}})
+	require.Equal(t, http.StatusCreated, w.Code)
+	role := response["role"].(map[string]interface{})
+	roleID := uint(role["ID"].(float64))
+	assert.Len(t, role["permissions"], 1)
+	roleURL := fmt.Sprintf("/api/admin/roles/%d", roleID)
+
+	t.Run("create validates input", func(t *testing.T) {
+		w, response := request("POST", "/api/admin/roles", map[string]interface{}{"ID": 99, "description": "no name"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "validation_error", response["code"])
+
+		w, response = request("POST", "/api/admin/roles", CreateRoleRequest{Name: "editor"})
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, "role_name_taken", response["code"])
+
+		w, response = request("POST", "/api/admin/roles", CreateRoleRequest{Name: "viewer", PermissionIDs: []uint{999}})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "permission_not_found", response["code"])
+
+		w, response = request("POST", "/api/admin/permissions", PermissionRequest{Name: "users::read"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "invalid_permission_name", response["code"])
+		w, _ = request("POST", "/api/admin/permissions", PermissionRequest{Name: "reports:*"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("list and get", func(t *testing.T) {
+		w, response := request("GET", "/api/admin/roles", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, response["roles"], 3)
+
+		w, response = request("GET", "/api/admin/roles/999", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "role_not_found", response["code"])
+	})
+
+	t.Run("update", func(t *testing.T) {
+		w, response := request("PUT", roleURL, UpdateRoleRequest{Name: "writer", Description: "Writes reports"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "writer", response["role"].(map[string]interface{})["name"])
+
+		w, response = request("PUT", fmt.Sprintf("/api/admin/roles/%d", adminRole.ID), UpdateRoleRequest{Name: "root"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, "role_protected", response["code"])
+
+		w, _ = request("PUT", fmt.Sprintf("/api/admin/roles/%d", adminRole.ID), UpdateRoleRequest{Name: models.RoleAdmin, Description: "Everything"})
+		assert.Equal(t, http.StatusOK, w.Code, "built-in roles can change their description")
+	})
+
+	t.Run("attach and detach permissions", func(t *testing.T) {
+		url := fmt.Sprintf("%s/permissions/%d", roleURL, adminPermission.ID)
+		w, response := request("POST", url, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, response["role"].(map[string]interface{})["permissions"], 2)
+
+		// Attaching twice is a no-op
+		w, response = request("POST", url, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, response["role"].(map[string]interface{})["permissions"], 2)
+
+		w, response = request("DELETE", url, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, response["role"].(map[string]interface{})["permissions"], 1)
+
+		w, response = request("POST", roleURL+"/permissions/999", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "permission_not_found", response["code"])
+
+		w, response = request("DELETE", fmt.Sprintf("/api/admin/roles/%d/permissions/%d", adminRole.ID, adminPermission.ID), nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, "role_protected", response["code"])
+	})
+
+	t.Run("built-in permissions are protected", func(t *testing.T) {
+		url := fmt.Sprintf("/api/admin/permissions/%d", adminPermission.ID)
+		w, response := request("PUT", url, PermissionRequest{Name: "superuser"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, "permission_protected", response["code"])
+
+		w, _ = request("DELETE", url, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("inheritance", func(t *testing.T) {
+		w, response := request("POST", "/api/admin/roles", CreateRoleRequest{Name: "senior-editor"})
+		require.Equal(t, http.StatusCreated, w.Code)
+		seniorID := uint(response["role"].(map[string]interface{})["ID"].(float64))
+		seniorURL := fmt.Sprintf("/api/admin/roles/%d", seniorID)
+
+		w, response = request("POST", fmt.Sprintf("%s/parents/%d", seniorURL, roleID), nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, response["role"].(map[string]interface{})["parents"], 1)
+		w, _ = request("POST", fmt.Sprintf("%s/parents/%d", roleURL, adminRole.ID), nil)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		// Permissions come from the nearest role granting them
+		w, response = request("GET", seniorURL+"/effective-permissions", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		granted := response["permissions"].([]interface{})
+		require.Len(t, granted, 2)
+		first := granted[0].(map[string]interface{})
+		assert.Equal(t, "write:reports", first["permission"].(map[string]interface{})["name"])
+		assert.Equal(t, "writer", first["granted_by"])
+		assert.Equal(t, true, first["inherited"])
+		assert.Equal(t, models.RoleAdmin, granted[1].(map[string]interface{})["granted_by"])
+
+		_, response = request("GET", roleURL+"/effective-permissions", nil)
+		assert.Equal(t, false, response["permissions"].([]interface{})[0].(map[string]interface{})["inherited"])
+
+		// A role cannot become its own ancestor
+		w, response = request("POST", fmt.Sprintf("%s/parents/%d", roleURL, roleID), nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, "role_cycle", response["code"])
+		w, _ = request("POST", fmt.Sprintf("/api/admin/roles/%d/parents/%d", adminRole.ID, seniorID), nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w, response = request("POST", seniorURL+"/parents/999", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "role_not_found", response["code"])
+
+		w, _ = request("DELETE", fmt.Sprintf("%s/parents/%d", roleURL, adminRole.ID), nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		_, response = request("GET", seniorURL+"/effective-permissions", nil)
+		assert.Len(t, response["permissions"], 1)
+
+		// Deleting a parent takes away what it granted
+		w, response = request("POST", "/api/admin/roles", CreateRoleRequest{Name: "reviewer"})
+		require.Equal(t, http.StatusCreated, w.Code)
+		reviewerID := uint(response["role"].(map[string]interface{})["ID"].(float64))
+		w, _ = request("POST", fmt.Sprintf("%s/parents/%d", seniorURL, reviewerID), nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		w, _ = request("DELETE", fmt.Sprintf("/api/admin/roles/%d", reviewerID), nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		_, response = request("GET", seniorURL, nil)
+		assert.Len(t, response["role"].(map[string]interface{})["parents"], 1)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		w, response := request("DELETE", fmt.Sprintf("/api/admin/roles/%d", userRole.ID), nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, "role_protected", response["code"])
+
+		users := memory.NewUserRepository(store)
+		user := models.User{Email: "writer@example.com", Password: hashPassword(t, "password123"), Roles: []models.Role{{Model: gorm.Model{ID: roleID}}}}
+		require.NoError(t, users.Create(&user))
+		w, response = request("DELETE", roleURL, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, "role_in_use", response["code"])
+
+		// Soft deleted users still reference the role
+		require.NoError(t, users.Delete(user.ID))
+		w, _ = request("DELETE", roleURL, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w, _ = request("POST", "/api/admin/roles", CreateRoleRequest{Name: "temporary", PermissionIDs: []uint{permissionID}})
+		require.Equal(t, http.StatusCreated, w.Code)
+		temporary, err := roles.List()
+		require.NoError(t, err)
+		temporaryURL := fmt.Sprintf("/api/admin/roles/%d", temporary[len(temporary)-1].ID)
+		w, _ = request("DELETE", temporaryURL, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w, _ = request("GET", temporaryURL, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		// A deleted role frees its name
+		w, _ = request("POST", "/api/admin/roles", CreateRoleRequest{Name: "temporary"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		// Deleting a permission revokes it from every role
+		w, _ = request("DELETE", fmt.Sprintf("/api/admin/permissions/%d", permissionID), nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		_, response = request("GET", roleURL, nil)
+		assert.Empty(t, response["role"].(map[string]interface{})["permissions"])
+	})
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository/memory"
+	"github.com/sukhantharot/go-service/service"
+)
+
+func TestLoginLockout(t *testing.T) {
+	// Setup
+	store := memory.NewStore()
+	router := setupTestRouter()
+	services := setupTestServices(store, testConfig{throttle: service.ThrottleConfig{
+		MaxAccountFailures: 3,
+		LockoutDuration:    time.Minute,
+		Window:             time.Minute,
+	}})
+	router.POST("/api/auth/login", NewAuthHandler(services.auth).Login)
+	router.POST("/api/admin/users/:id/unlock", NewUserHandler(services.auth, services.users).UnlockAccount)
+
+	// Create test user
+	user := models.User{
+		Email:     "locked@example.com",
+		Password:  hashPassword(t, "password123"),
+		FirstName: "Test",
+		LastName:  "User",
+	}
+	require.NoError(t, memory.NewUserRepository(store).Create(&user))
+
+	login := func(email, password string) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(LoginRequest{Email: email, Password: password})
+		req := httptest.NewRequest("POST", "/api/auth/login", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, login(user.Email, "wrongpassword").Code)
+	}
+
+	// The right password does not help while the account is locked
+	w := login(user.Email, "password123")
+	assert.Equal(t, http.StatusLocked, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "account_locked", response["code"])
+
+	// Unknown emails lock the same way so the lockout does not reveal which accounts exist
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, login("ghost@example.com", "wrongpassword").Code)
+	}
+	assert.Equal(t, http.StatusLocked, login("ghost@example.com", "wrongpassword").Code)
+
+	// An admin can lift the lockout early
+	req := httptest.NewRequest("POST", fmt.Sprintf("/api/admin/users/%d/unlock", user.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, http.StatusOK, login(user.Email, "password123").Code)
+}
+
+func TestLoginIPBlockIgnoresForwardedFor(t *testing.T) {
+	// Setup
+	store := memory.NewStore()
+	router := setupTestRouter()
+	// Like main with TRUSTED_PROXIES empty
+	require.NoError(t, router.SetTrustedProxies(nil))
+	services := setupTestServices(store, testConfig{throttle: service.ThrottleConfig{
+		MaxIPFailures:   3,
+		LockoutDuration: time.Minute,
+		Window:          time.Minute,
+	}})
+	router.POST("/api/auth/login", NewAuthHandler(services.auth).Login)
+
+	login := func(email, forwardedFor string) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(LoginRequest{Email: email, Password: "wrongpassword"})
+		req := httptest.NewRequest("POST", "/api/auth/login", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		req.RemoteAddr = "192.0.2.10:4321"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Every attempt claims another IP and another account, the block follows the peer anyway
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, login(fmt.Sprintf("user%d@example.com", i), fmt.Sprintf("203.0.113.%d", i)).Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, login("fresh@example.com", "203.0.113.99").Code)
+}
@@ -0,0 +1,421 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/policy"
+	"github.com/sukhantharot/go-service/repository"
+	"github.com/sukhantharot/go-service/service"
+)
+
+type UpdateUserRequest struct {
+	Email     string `json:"email" binding:"required,email"`
+	FirstName string `json:"first_name" binding:"required"`
+	LastName  string `json:"last_name" binding:"required"`
+}
+
+// UpdateCurrentUserRequest changes the names of the signed in user, omitted fields stay as they are
+type UpdateCurrentUserRequest struct {
+	FirstName *string `json:"first_name" binding:"omitempty,max=255"`
+	LastName  *string `json:"last_name" binding:"omitempty,max=255"`
+}
+
+// SetRolesRequest replaces every role of a user, an empty list takes all of them away
+type SetRolesRequest struct {
+	RoleIDs []uint `json:"role_ids" binding:"required"`
+}
+
+type SuspendUserRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// ListUsersQuery are the query parameters of GetAllUsers. Pass next_cursor of a page
+// as cursor to get the next one, offset is ignored then.
+type ListUsersQuery struct {
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset int    `form:"offset" binding:"omitempty,min=0"`
+	Cursor string `form:"cursor"`
+	Sort   string `form:"sort"`
+	Order  string `form:"order" binding:"omitempty,oneof=asc desc"`
+	RoleID uint   `form:"role_id"`
+	// OrganizationID lists the members of one organization
+	OrganizationID uint `form:"organization_id"`
+	// EmailDomain matches the part of the email after the @
+	EmailDomain   string     `form:"email_domain"`
+	CreatedAfter  *time.Time `form:"created_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedBefore *time.Time `form:"created_before" time_format:"2006-01-02T15:04:05Z07:00"`
+	Status        string     `form:"status" binding:"omitempty,oneof=active suspended deleted"`
+	// Q searches emails and full names
+	Q string `form:"q"`
+}
+
+type UserHandler struct {
+	auth  *service.AuthService
+	users *service.UserService
+}
+
+func NewUserHandler(auth *service.AuthService, users *service.UserService) *UserHandler {
+	return &UserHandler{auth: auth, users: users}
+}
+
+func (h *UserHandler) GetCurrentUser(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		fail(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	user, err := h.auth.GetUserByID(userID)
+	if err != nil {
+		fail(c, notFound(err, "User not found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": gin.H{
+			"id":          user.ID,
+			"email":       user.Email,
+			"firstName":   user.FirstName,
+			"lastName":    user.LastName,
+			"roles":       user.Roles,
+			"permissions": permissionNames(user.Permissions()),
+			"created_at":  user.CreatedAt,
+			"updated_at":  user.UpdatedAt,
+		},
+	})
+}
+
+// UpdateCurrentUser changes the names of the signed in user. The email is changed through
+// VerificationHandler.RequestEmailChange, the password through PasswordHandler.ChangePassword.
+func (h *UserHandler) UpdateCurrentUser(c *gin.Context) {
+	var req UpdateCurrentUserRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		fail(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	user, err := h.users.UpdateName(userID, req.FirstName, req.LastName)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func (h *UserHandler) GetAllUsers(c *gin.Context) {
+	var query ListUsersQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+
+	filter := repository.UserFilter{
+		RoleID:         query.RoleID,
+		OrganizationID: query.OrganizationID,
+		EmailDomain:    query.EmailDomain,
+		CreatedAfter:   query.CreatedAfter,
+		CreatedBefore:  query.CreatedBefore,
+		Status:         query.Status,
+		Search:         query.Q,
+	}
+	// Inside an organization the list defaults to its users, and callers whose right to list
+	// users comes from their membership cannot look at another organization
+	if organizationID, ok := c.Get("org_id"); ok && filter.OrganizationID == 0 {
+		filter.OrganizationID, _ = organizationID.(uint)
+	}
+	if scope, ok := c.Get("organization_scope"); ok && filter.OrganizationID != scope.(uint) {
+		fail(c, apperrors.ErrPermissionDenied)
+		return
+	}
+	page := repository.PageRequest{
+		Limit:  query.Limit,
+		Offset: query.Offset,
+		Cursor: query.Cursor,
+		Sort:   query.Sort,
+		Desc:   query.Order == "desc",
+	}.Normalized()
+	if page.Cursor != "" {
+		page.Offset = 0
+	}
+
+	users, err := h.users.List(filter, page)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	// Users a policy keeps from the caller are left out of the page, total still counts them
+	if value, ok := c.Get("policy_filter"); ok {
+		visible := value.(func(resource policy.Attributes) (bool, error))
+		items := make([]models.User, 0, len(users.Items))
+		for i := range users.Items {
+			allowed, err := visible(service.UserPolicyAttributes(&users.Items[i]))
+			if err != nil {
+				fail(c, err)
+				return
+			}
+			if allowed {
+				items = append(items, users.Items[i])
+			}
+		}
+		users.Items = items
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users":       users.Items,
+		"total":       users.Total,
+		"next_cursor": users.NextCursor,
+		"limit":       page.Limit,
+		"offset":      page.Offset,
+	})
+}
+
+func (h *UserHandler) GetUser(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.users.Get(id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func (h *UserHandler) UpdateUser(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	var req UpdateUserRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	user, err := h.users.Update(id, req.Email, req.FirstName, req.LastName)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+// UpdateGrantedUser is UpdateUser for users allowed through a grant rather than admin
+// rights, they can change the names but not the email the account logs in with
+func (h *UserHandler) UpdateGrantedUser(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	var req UpdateUserRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	user, err := h.users.UpdateNames(id, req.Email, req.FirstName, req.LastName)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func (h *UserHandler) SetRoles(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	var req SetRolesRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	actorID, ok := currentUserID(c)
+	if !ok {
+		fail(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	user, err := h.users.SetRoles(actorID, id, req.RoleIDs)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func (h *UserHandler) AddRole(c *gin.Context) {
+	id, roleID, ok := userRoleParams(c)
+	if !ok {
+		return
+	}
+	actorID, ok := currentUserID(c)
+	if !ok {
+		fail(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	user, err := h.users.AddRole(actorID, id, roleID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func (h *UserHandler) RemoveRole(c *gin.Context) {
+	id, roleID, ok := userRoleParams(c)
+	if !ok {
+		return
+	}
+	actorID, ok := currentUserID(c)
+	if !ok {
+		fail(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	user, err := h.users.RemoveRole(actorID, id, roleID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+// SuspendUser blocks logins of the user and rejects their tokens until ReactivateUser
+func (h *UserHandler) SuspendUser(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	var req SuspendUserRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	actorID, ok := currentUserID(c)
+	if !ok {
+		fail(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	user, err := h.users.Suspend(actorID, id, req.Reason)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func (h *UserHandler) ReactivateUser(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.users.Reactivate(id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+// DeleteUser soft deletes the user, RestoreUser undoes it
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	actorID, ok := currentUserID(c)
+	if !ok {
+		fail(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	if err := h.users.Delete(actorID, id); err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted"})
+}
+
+func (h *UserHandler) RestoreUser(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.users.Restore(id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+// UnlockAccount lifts a lockout caused by failed logins before it expires
+func (h *UserHandler) UnlockAccount(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.auth.Unlock(id); err != nil {
+		fail(c, notFound(err, "User not found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account unlocked"})
+}
+
+// idParam parses the :id path parameter, failing the request when it is not an ID
+func idParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		fail(c, apperrors.ErrBadRequest.WithMessage("Invalid ID").Wrap(err))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// userRoleParams parses the :id and :role_id path parameters
+func userRoleParams(c *gin.Context) (uint, uint, bool) {
+	id, ok := idParam(c)
+	if !ok {
+		return 0, 0, false
+	}
+	roleID, err := strconv.ParseUint(c.Param("role_id"), 10, 64)
+	if err != nil || roleID == 0 {
+		fail(c, apperrors.ErrBadRequest.WithMessage("Invalid role ID").Wrap(err))
+		return 0, 0, false
+	}
+	return id, uint(roleID), true
+}
+
+// permissionNames lists the names of permissions, clients check them without knowing IDs
+func permissionNames(permissions []models.Permission) []string {
+	names := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		names = append(names, permission.Name)
+	}
+	return names
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository/memory"
+)
+
+func TestAdminUserManagement(t *testing.T) {
+	// Setup
+	store := memory.NewStore()
+	router := setupTestRouter()
+	services := setupTestServices(store, testConfig{})
+	auth := NewAuthHandler(services.auth)
+	users := NewUserHandler(services.auth, services.users)
+	router.POST("/api/auth/login", auth.Login)
+	router.POST("/api/auth/refresh", auth.Refresh)
+	services.authenticated(router).GET("/users/me", users.GetCurrentUser)
+
+	roles := memory.NewRoleRepository(store)
+	userRole := models.Role{Name: models.RoleUser}
+	require.NoError(t, roles.Create(&userRole))
+	editorRole := models.Role{Name: "editor"}
+	require.NoError(t, roles.Create(&editorRole))
+
+	userRepo := memory.NewUserRepository(store)
+	admin := models.User{Email: "admin@example.com", Password: hashPassword(t, "password123"), Roles: []models.Role{userRole}}
+	require.NoError(t, userRepo.Create(&admin))
+	user := models.User{Email: "test@example.com", Password: hashPassword(t, "password123"), FirstName: "Test", LastName: "User", Roles: []models.Role{userRole}}
+	require.NoError(t, userRepo.Create(&user))
+
+	// The admin routes run as admin, permissions are covered by the middleware tests
+	adminGroup := router.Group("/api/admin", func(c *gin.Context) {
+		c.Set("user_id", admin.ID)
+	})
+	adminGroup.GET("/users", users.GetAllUsers)
+	adminGroup.GET("/users/:id", users.GetUser)
+	adminGroup.PUT("/users/:id", users.UpdateUser)
+	adminGroup.PUT("/users/:id/roles", users.SetRoles)
+	adminGroup.POST("/users/:id/roles/:role_id", users.AddRole)
+	adminGroup.DELETE("/users/:id/roles/:role_id", users.RemoveRole)
+	adminGroup.POST("/users/:id/suspend", users.SuspendUser)
+	adminGroup.POST("/users/:id/reactivate", users.ReactivateUser)
+	adminGroup.DELETE("/users/:id", users.DeleteUser)
+	adminGroup.POST("/users/:id/restore", users.RestoreUser)
+
+	request := func(method, url, token string, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var response map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+	login := func(email string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		return request("POST", "/api/auth/login", "", LoginRequest{Email: email, Password: "password123"})
+	}
+	userURL := fmt.Sprintf("/api/admin/users/%d", user.ID)
+
+	t.Run("list", func(t *testing.T) {
+		w, response := request("GET", "/api/admin/users?limit=1&sort=email", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, float64(2), response["total"])
+		assert.Equal(t, float64(1), response["limit"])
+		require.Len(t, response["users"], 1)
+		assert.Equal(t, admin.Email, response["users"].([]interface{})[0].(map[string]interface{})["email"])
+		cursor := response["next_cursor"].(string)
+		require.NotEmpty(t, cursor)
+
+		w, response = request("GET", "/api/admin/users?limit=1&sort=email&cursor="+cursor, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, user.Email, response["users"].([]interface{})[0].(map[string]interface{})["email"])
+		assert.Empty(t, response["next_cursor"])
+
+		_, response = request("GET", "/api/admin/users?q=test+user&status=active&email_domain=example.com", "", nil)
+		assert.Equal(t, float64(1), response["total"])
+		_, response = request("GET", "/api/admin/users?created_after=2999-01-01T00:00:00Z", "", nil)
+		assert.Equal(t, float64(0), response["total"])
+
+		w, response = request("GET", "/api/admin/users?status=banned&limit=500", "", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "validation_error", response["code"])
+		assert.Contains(t, response["fields"], "status")
+		assert.Contains(t, response["fields"], "limit")
+
+		w, _ = request("GET", "/api/admin/users?sort=password", "", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w, _ = request("GET", "/api/admin/users?sort=id&cursor="+cursor, "", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		w, response := request("GET", userURL, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, user.Email, response["user"].(map[string]interface{})["email"])
+		assert.NotContains(t, response["user"], "Password")
+
+		w, response = request("GET", "/api/admin/users/999", "", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "user_not_found", response["code"])
+	})
+
+	t.Run("update", func(t *testing.T) {
+		w, response := request("PUT", userURL, "", UpdateUserRequest{Email: admin.Email, FirstName: "Test", LastName: "User"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "email_taken", response["code"])
+
+		w, response = request("PUT", userURL, "", UpdateUserRequest{Email: "renamed@example.com", FirstName: "Renamed", LastName: "User"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		updated := response["user"].(map[string]interface{})
+		assert.Equal(t, "renamed@example.com", updated["email"])
+		assert.Equal(t, "Renamed", updated["first_name"])
+		user.Email = "renamed@example.com"
+
+		// The password survives the update
+		w, _ = login(user.Email)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("change roles", func(t *testing.T) {
+		_, response := login(user.Email)
+		token := response["token"].(string)
+		roleIDs := func(response map[string]interface{}) []float64 {
+			var ids []float64
+			for _, role := range response["user"].(map[string]interface{})["roles"].([]interface{}) {
+				ids = append(ids, role.(map[string]interface{})["ID"].(float64))
+			}
+			return ids
+		}
+
+		w, response := request("PUT", userURL+"/roles", "", SetRolesRequest{RoleIDs: []uint{999}})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "role_not_found", response["code"])
+
+		w, response = request("PUT", fmt.Sprintf("/api/admin/users/%d/roles", admin.ID), "", SetRolesRequest{RoleIDs: []uint{editorRole.ID}})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, "self_action_forbidden", response["code"])
+
+		w, response = request("PUT", userURL+"/roles", "", SetRolesRequest{RoleIDs: []uint{editorRole.ID}})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []float64{float64(editorRole.ID)}, roleIDs(response))
+
+		// Tokens carrying the old roles are revoked
+		w, _ = request("GET", "/api/users/me", token, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w, response = request("POST", fmt.Sprintf("%s/roles/%d", userURL, userRole.ID), "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []float64{float64(editorRole.ID), float64(userRole.ID)}, roleIDs(response))
+
+		w, response = request("DELETE", fmt.Sprintf("%s/roles/%d", userURL, editorRole.ID), "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []float64{float64(userRole.ID)}, roleIDs(response))
+
+		w, _ = request("POST", userURL+"/roles/abc", "", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("suspend and reactivate", func(t *testing.T) {
+		_, response := login(user.Email)
+		token := response["token"].(string)
+		refreshToken := response["refresh_token"].(string)
+
+		w, _ := request("POST", userURL+"/suspend", "", map[string]string{})
+		assert.Equal(t, http.StatusBadRequest, w.Code, "a reason is required")
+
+		w, response = request("POST", userURL+"/suspend", "", SuspendUserRequest{Reason: "Abuse report"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Abuse report", response["user"].(map[string]interface{})["suspension_reason"])
+
+		w, response = request("GET", "/api/users/me", token, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, "account_suspended", response["code"])
+
+		w, response = login(user.Email)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, "account_suspended", response["code"])
+
+		w, _ = request("POST", "/api/auth/refresh", "", RefreshRequest{RefreshToken: refreshToken})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w, response = request("POST", userURL+"/reactivate", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Nil(t, response["user"].(map[string]interface{})["suspended_at"])
+
+		w, response = login(user.Email)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w, _ = request("GET", "/api/users/me", response["token"].(string), nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("delete and restore", func(t *testing.T) {
+		_, response := login(user.Email)
+		token := response["token"].(string)
+
+		w, _ := request("DELETE", fmt.Sprintf("/api/admin/users/%d", admin.ID), "", nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w, response = request("POST", userURL+"/restore", "", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code, "only deleted users can be restored")
+
+		w, _ = request("DELETE", userURL, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w, _ = request("GET", userURL, "", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w, _ = request("GET", "/api/users/me", token, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		w, _ = login(user.Email)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w, _ = request("POST", userURL+"/restore", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w, _ = login(user.Email)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestUpdateCurrentUser(t *testing.T) {
+	// Setup
+	store := memory.NewStore()
+	router := setupTestRouter()
+	services := setupTestServices(store, testConfig{})
+	users := NewUserHandler(services.auth, services.users)
+	router.POST("/api/auth/login", NewAuthHandler(services.auth).Login)
+	services.authenticated(router).PATCH("/users/me", users.UpdateCurrentUser)
+
+	user := models.User{Email: "me@example.com", Password: hashPassword(t, "password123"), FirstName: "Old", LastName: "Name"}
+	require.NoError(t, memory.NewUserRepository(store).Create(&user))
+
+	request := func(method, url, token string, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, url, bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var response map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+	_, response := request("POST", "/api/auth/login", "", LoginRequest{Email: user.Email, Password: "password123"})
+	token := response["token"].(string)
+
+	w, _ := request("PATCH", "/api/users/me", "", map[string]string{"first_name": "New"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Omitted fields and fields the endpoint does not own stay as they are
+	w, response = request("PATCH", "/api/users/me", token, map[string]interface{}{"first_name": "New", "email": "other@example.com", "role_ids": []uint{99}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	updated := response["user"].(map[string]interface{})
+	assert.Equal(t, "New", updated["first_name"])
+	assert.Equal(t, "Name", updated["last_name"])
+	assert.Equal(t, user.Email, updated["email"])
+	assert.Empty(t, updated["roles"])
+
+	// The update kept the password
+	w, _ = request("POST", "/api/auth/login", "", LoginRequest{Email: user.Email, Password: "password123"})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
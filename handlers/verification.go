@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/logger"
+	"github.com/sukhantharot/go-service/service"
+)
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ChangeEmailRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type VerificationHandler struct {
+	verification *service.VerificationService
+}
+
+func NewVerificationHandler(verification *service.VerificationService) *VerificationHandler {
+	return &VerificationHandler{verification: verification}
+}
+
+func (h *VerificationHandler) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.verification.Verify(req.Token); err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// ResendVerification always answers the same way so it cannot be used to find registered emails
+func (h *VerificationHandler) ResendVerification(c *gin.Context) {
+	var req ResendVerificationRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.verification.Resend(req.Email); err != nil {
+		logger.Error("Could not resend verification email", err, nil)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the email is registered and not verified yet, a verification link has been sent"})
+}
+
+// RequestEmailChange emails a confirmation link to the new address of the signed in user,
+// the email only changes once ConfirmEmailChange redeems it
+func (h *VerificationHandler) RequestEmailChange(c *gin.Context) {
+	var req ChangeEmailRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		fail(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	if err := h.verification.RequestEmailChange(userID, req.Password, req.Email); err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "A confirmation link has been sent to the new email address"})
+}
+
+func (h *VerificationHandler) ConfirmEmailChange(c *gin.Context) {
+	var req ConfirmEmailChangeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.verification.ConfirmEmailChange(req.Token); err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email changed successfully"})
+}
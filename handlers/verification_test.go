@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sukhantharot/go-service/mail"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository/memory"
+	"github.com/sukhantharot/go-service/service"
+)
+
+func TestEmailVerification(t *testing.T) {
+	// Setup
+	store := memory.NewStore()
+	router := setupTestRouter()
+	mailDir := t.TempDir()
+	mailer, err := mail.NewFileSender(mailDir, "no-reply@example.com")
+	require.NoError(t, err)
+	services := setupTestServices(store, testConfig{policy: service.UnverifiedBlock, mailer: mailer})
+	auth := NewAuthHandler(services.auth)
+	verification := NewVerificationHandler(services.verification)
+	router.POST("/api/auth/register", auth.Register)
+	router.POST("/api/auth/login", auth.Login)
+	router.POST("/api/auth/verify-email", verification.VerifyEmail)
+	router.POST("/api/auth/verify-email/resend", verification.ResendVerification)
+	// Registration grants role 1
+	require.NoError(t, memory.NewRoleRepository(store).Create(&models.Role{Name: models.RoleUser}))
+
+	post := func(url string, payload interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+		jsonData, _ := json.Marshal(payload)
+		req := httptest.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+	credentials := LoginRequest{Email: "verify@example.com", Password: "password123"}
+
+	// Registration emails a verification link
+	w, _ := post("/api/auth/register", RegisterRequest{
+		Email:     credentials.Email,
+		Password:  credentials.Password,
+		FirstName: "Test",
+		LastName:  "User",
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	firstToken := lastMailToken(t, mailDir)
+
+	// The block policy refuses unverified logins
+	w, response := post("/api/auth/login", credentials)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "Email address is not verified", response["error"])
+
+	// Resending answers the same for unknown emails and issues a new token
+	w, unknown := post("/api/auth/verify-email/resend", ResendVerificationRequest{Email: "nobody@example.com"})
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	w, known := post("/api/auth/verify-email/resend", ResendVerificationRequest{Email: credentials.Email})
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, unknown, known)
+	secondToken := lastMailToken(t, mailDir)
+	assert.NotEqual(t, firstToken, secondToken)
+
+	w, response = post("/api/auth/verify-email", VerifyEmailRequest{Token: "bogus"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "Invalid or expired verification token", response["error"])
+
+	w, _ = post("/api/auth/verify-email", VerifyEmailRequest{Token: secondToken})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Verifying invalidates the other outstanding tokens
+	w, _ = post("/api/auth/verify-email", VerifyEmailRequest{Token: firstToken})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, response = post("/api/auth/login", credentials)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, response["user"].(map[string]interface{})["emailVerified"])
+}
+
+func TestEmailChange(t *testing.T) {
+	// Setup
+	store := memory.NewStore()
+	router := setupTestRouter()
+	mailDir := t.TempDir()
+	mailer, err := mail.NewFileSender(mailDir, "no-reply@example.com")
+	require.NoError(t, err)
+	services := setupTestServices(store, testConfig{mailer: mailer})
+	verification := NewVerificationHandler(services.verification)
+	router.POST("/api/auth/login", NewAuthHandler(services.auth).Login)
+	router.POST("/api/auth/email-change/confirm", verification.ConfirmEmailChange)
+	services.authenticated(router).POST("/users/me/email", verification.RequestEmailChange)
+
+	users := memory.NewUserRepository(store)
+	user := models.User{Email: "old@example.com", Password: hashPassword(t, "password123"), FirstName: "Test"}
+	require.NoError(t, users.Create(&user))
+	taken := models.User{Email: "taken@example.com", Password: hashPassword(t, "password123")}
+	require.NoError(t, users.Create(&taken))
+
+	request := func(url, token string, payload interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+		jsonData, _ := json.Marshal(payload)
+		req := httptest.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+	_, response := request("/api/auth/login", "", LoginRequest{Email: user.Email, Password: "password123"})
+	token := response["token"].(string)
+
+	w, response := request("/api/users/me/email", token, ChangeEmailRequest{Email: "new@example.com", Password: "wrong"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "incorrect_password", response["code"])
+
+	w, response = request("/api/users/me/email", token, ChangeEmailRequest{Email: taken.Email, Password: "password123"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "email_taken", response["code"])
+
+	w, _ = request("/api/users/me/email", token, ChangeEmailRequest{Email: "new@example.com", Password: "password123"})
+	require.Equal(t, http.StatusAccepted, w.Code)
+	changeToken := lastMailToken(t, mailDir)
+
+	// The email only changes once the new address confirmed it
+	stored, err := users.FindByID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "old@example.com", stored.Email)
+
+	w, response = request("/api/auth/email-change/confirm", "", ConfirmEmailChangeRequest{Token: "bogus"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "invalid_email_change_token", response["code"])
+
+	w, _ = request("/api/auth/email-change/confirm", "", ConfirmEmailChangeRequest{Token: changeToken})
+	assert.Equal(t, http.StatusOK, w.Code)
+	stored, err = users.FindByID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "new@example.com", stored.Email)
+	assert.True(t, stored.IsEmailVerified())
+
+	w, _ = request("/api/auth/email-change/confirm", "", ConfirmEmailChangeRequest{Token: changeToken})
+	assert.Equal(t, http.StatusBadRequest, w.Code, "a token changes the email once")
+
+	w, _ = request("/api/auth/login", "", LoginRequest{Email: "new@example.com", Password: "password123"})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
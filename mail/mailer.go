@@ -0,0 +1,146 @@
+package mail
+
+import (
+	"fmt"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sukhantharot/go-service/logger"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers emails
+type Sender interface {
+	Send(msg Message) error
+}
+
+// Config selects and configures a Sender
+type Config struct {
+	Driver   string // smtp, file or log
+	From     string
+	Host     string
+	Port     string
+	Username string
+	Password string
+	Dir      string // output directory of the file driver
+}
+
+// NewSender returns the Sender for the configured driver, log is the default
+func NewSender(cfg Config) (Sender, error) {
+	switch cfg.Driver {
+	case "smtp":
+		if cfg.Host == "" || cfg.From == "" {
+			return nil, fmt.Errorf("smtp mail driver requires a host and a from address")
+		}
+		return NewSMTPSender(cfg), nil
+	case "file":
+		return NewFileSender(cfg.Dir, cfg.From)
+	case "", "log":
+		return NewLogSender(), nil
+	}
+	return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+}
+
+// SMTPSender sends emails through an SMTP server, authenticating when a username is set
+type SMTPSender struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func NewSMTPSender(cfg Config) *SMTPSender {
+	port := cfg.Port
+	if port == "" {
+		port = "587"
+	}
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+
+	return &SMTPSender{
+		addr: cfg.Host + ":" + port,
+		from: cfg.From,
+		auth: auth,
+	}
+}
+
+func (s *SMTPSender) Send(msg Message) error {
+	return smtp.SendMail(s.addr, s.auth, s.from, []string{msg.To}, format(s.from, msg))
+}
+
+// FileSender writes every email to its own .eml file, for local development and tests
+type FileSender struct {
+	dir  string
+	from string
+	seq  uint64
+}
+
+func NewFileSender(dir, from string) (*FileSender, error) {
+	if dir == "" {
+		dir = "tmp/mail"
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileSender{dir: dir, from: from}, nil
+}
+
+func (s *FileSender) Send(msg Message) error {
+	seq := atomic.AddUint64(&s.seq, 1)
+	name := fmt.Sprintf("%d-%04d-%s.eml", time.Now().UnixNano(), seq, sanitize(msg.To))
+	return os.WriteFile(filepath.Join(s.dir, name), format(s.from, msg), 0600)
+}
+
+// LogSender only logs emails, it is the default so nothing is sent by accident
+type LogSender struct{}
+
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+func (s *LogSender) Send(msg Message) error {
+	logger.Info("Email not sent, log mail driver is active", logger.Fields{
+		"to":      msg.To,
+		"subject": msg.Subject,
+		"body":    msg.Body,
+	})
+	return nil
+}
+
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", header(from))
+	fmt.Fprintf(&b, "To: %s\r\n", header(msg.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", header(msg.Subject))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// header strips line breaks so values cannot inject extra headers
+func header(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
+
+func sanitize(address string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, address)
+}
@@ -0,0 +1,66 @@
+package mail
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSender(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "log is the default", config: Config{}, want: &LogSender{}},
+		{name: "file", config: Config{Driver: "file", Dir: t.TempDir()}, want: &FileSender{}},
+		{name: "smtp", config: Config{Driver: "smtp", Host: "localhost", From: "a@example.com"}, want: &SMTPSender{}},
+		{name: "smtp without host", config: Config{Driver: "smtp"}, wantErr: true},
+		{name: "unknown driver", config: Config{Driver: "carrier-pigeon"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender, err := NewSender(tt.config)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.IsType(t, tt.want, sender)
+		})
+	}
+}
+
+func TestFileSender(t *testing.T) {
+	dir := t.TempDir()
+	sender, err := NewFileSender(dir, "no-reply@example.com")
+	require.NoError(t, err)
+
+	err = sender.Send(Message{
+		To:      "user@example.com",
+		Subject: "Hello\r\nBcc: attacker@example.com",
+		Body:    "line one\nline two",
+	})
+	require.NoError(t, err)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	content, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	email := string(content)
+	assert.Contains(t, email, "From: no-reply@example.com\r\n")
+	assert.Contains(t, email, "To: user@example.com\r\n")
+	assert.Contains(t, email, "line one\r\nline two")
+
+	// Line breaks in headers must not create new headers
+	assert.Contains(t, email, "Subject: HelloBcc: attacker@example.com\r\n")
+	assert.False(t, strings.Contains(email, "\r\nBcc:"))
+}
@@ -1,26 +1,78 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sukhantharot/go-service/app"
 	"github.com/sukhantharot/go-service/config"
+	"github.com/sukhantharot/go-service/migrate"
 	"github.com/sukhantharot/go-service/routes"
+	"github.com/sukhantharot/go-service/service"
+	"gorm.io/gorm"
 )
 
 func main() {
 	// Load environment variables
 	config.LoadEnv()
+	cfg, err := app.LoadConfig()
+	if err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
 
 	// Initialize database
 	db := config.InitDB()
 
+	// `migrate` manages the schema and exits instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(db, os.Args[2:])
+		return
+	}
+	if err := config.MigrateDB(db, cfg.Migrations); err != nil {
+		log.Fatal("Failed to migrate database schema: ", err)
+	}
+
+	// Build repositories, services and handlers
+	application, err := app.New(db, cfg)
+	if err != nil {
+		log.Fatal("Failed to build application: ", err)
+	}
+
+	// `seed` applies the roles and permissions and exits instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "seed" {
+		runSeed(application, os.Args[2:])
+		return
+	}
+	if cfg.SeedRBAC {
+		changes, err := application.SeedRBAC(cfg.RBACSeedFile, service.SeedOptions{Prune: cfg.RBACSeedPrune})
+		if err != nil {
+			log.Fatal("Failed to seed roles and permissions: ", err)
+		}
+		for _, change := range changes {
+			log.Printf("Seeded %s", change)
+		}
+	}
+
+	// Background jobs stop once the server returns
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go application.RunJanitor(ctx, time.Hour)
+	go application.Services.Elevations.RunSweeper(ctx)
+
 	// Create Gin router
 	router := gin.Default()
+	// Without this gin takes the client IP from X-Forwarded-For of any peer
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatal("Invalid trusted proxies: ", err)
+	}
 
 	// Setup routes
-	routes.SetupRoutes(router, db)
+	routes.SetupRoutes(router, application)
 
 	// Get port from environment variable or use default
 	port := os.Getenv("PORT")
@@ -33,4 +85,79 @@ func main() {
 	if err := router.Run(":" + port); err != nil {
 		log.Fatal("Error starting server: ", err)
 	}
-} 
\ No newline at end of file
+}
+
+// runSeed applies a seed file, or with -dry-run prints what applying it would change
+func runSeed(application *app.App, args []string) {
+	flags := flag.NewFlagSet("seed", flag.ExitOnError)
+	file := flags.String("file", application.Config.RBACSeedFile, "YAML or JSON seed file, the built-in defaults when empty")
+	dryRun := flags.Bool("dry-run", false, "print the changes without making them")
+	prune := flags.Bool("prune", application.Config.RBACSeedPrune, "delete roles, permissions and links missing from the file")
+	_ = flags.Parse(args)
+
+	changes, err := application.SeedRBAC(*file, service.SeedOptions{DryRun: *dryRun, Prune: *prune})
+	if err != nil {
+		log.Fatal("Failed to seed roles and permissions: ", err)
+	}
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	if len(changes) == 0 {
+		fmt.Println("Roles and permissions are up to date")
+	}
+}
+
+// runMigrate applies (up), rolls back (down) or lists (status) the migrations
+func runMigrate(db *gorm.DB, args []string) {
+	usage := "usage: migrate up [-to version] | down [-steps n] | status"
+	if len(args) == 0 {
+		log.Fatal(usage)
+	}
+	flags := flag.NewFlagSet("migrate "+args[0], flag.ExitOnError)
+	to := flags.Int64("to", 0, "apply the migrations up to this version, every one when 0")
+	steps := flags.Int("steps", 1, "how many applied migrations to roll back")
+	_ = flags.Parse(args[1:])
+
+	migrator, err := config.NewMigrator(db)
+	if err != nil {
+		log.Fatal("Failed to load migrations: ", err)
+	}
+
+	var done []migrate.Migration
+	switch args[0] {
+	case "up":
+		done, err = config.ApplyMigrations(db, migrator, *to)
+	case "down":
+		done, err = migrator.Down(context.Background(), *steps)
+	case "status":
+		statuses, err := migrator.Status(context.Background())
+		if err != nil {
+			log.Fatal("Failed to read migrations: ", err)
+		}
+		for _, status := range statuses {
+			state := "pending"
+			switch {
+			case status.Missing:
+				state = "missing"
+			case status.Modified:
+				state = "modified"
+			case status.AppliedAt != nil:
+				state = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%-30s %s\n", status.Migration, state)
+		}
+		return
+	default:
+		log.Fatal(usage)
+	}
+
+	for _, migration := range done {
+		fmt.Printf("%s %s\n", args[0], migration)
+	}
+	if err != nil {
+		log.Fatal("Failed to migrate: ", err)
+	}
+	if len(done) == 0 {
+		fmt.Println("Nothing to migrate")
+	}
+}
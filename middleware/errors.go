@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/logger"
+)
+
+const problemJSON = "application/problem+json"
+
+// ErrorHandler renders the last error added with c.Error as the JSON error envelope:
+//
+//	{"error": "Invalid credentials", "code": "invalid_credentials", "request_id": "..."}
+//
+// With problemDetails, or when the client accepts application/problem+json, errors are
+// rendered as RFC 7807 problem details instead. Server errors are logged with their cause.
+func ErrorHandler(problemDetails bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 {
+			return
+		}
+		appErr := apperrors.From(c.Errors.Last().Err)
+		requestID := c.GetString("request_id")
+
+		if appErr.Status >= http.StatusInternalServerError {
+			logger.Error("Request failed", c.Errors.Last().Err, logger.Fields{
+				"request_id": requestID,
+				"method":     c.Request.Method,
+				"path":       c.Request.URL.Path,
+			})
+		}
+
+		if c.Writer.Written() {
+			return
+		}
+
+		if problemDetails || strings.Contains(c.GetHeader("Accept"), problemJSON) {
+			body := gin.H{
+				"type":     "about:blank",
+				"title":    http.StatusText(appErr.Status),
+				"status":   appErr.Status,
+				"detail":   appErr.Message,
+				"instance": c.Request.URL.Path,
+				"code":     appErr.Code,
+			}
+			addErrorExtras(body, appErr, requestID)
+			c.Header("Content-Type", problemJSON)
+			c.JSON(appErr.Status, body)
+			return
+		}
+
+		body := gin.H{
+			"error": appErr.Message,
+			"code":  appErr.Code,
+		}
+		addErrorExtras(body, appErr, requestID)
+		c.JSON(appErr.Status, body)
+	}
+}
+
+func addErrorExtras(body gin.H, appErr *apperrors.AppError, requestID string) {
+	if appErr.Details != "" {
+		body["details"] = appErr.Details
+	}
+	if len(appErr.Fields) > 0 {
+		body["fields"] = appErr.Fields
+	}
+	if requestID != "" {
+		body["request_id"] = requestID
+	}
+}
+
+// abortWithError hands err to ErrorHandler and stops the handler chain
+func abortWithError(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
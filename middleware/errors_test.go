@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"gorm.io/gorm"
+)
+
+func TestErrorHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		accept         string
+		problemDetails bool
+		expectedStatus int
+		expectedType   string
+		expectedBody   map[string]interface{}
+	}{
+		{
+			name:           "app error",
+			err:            apperrors.ErrInvalidCredentials,
+			expectedStatus: http.StatusUnauthorized,
+			expectedType:   "application/json; charset=utf-8",
+			expectedBody: map[string]interface{}{
+				"error":      "Invalid credentials",
+				"code":       "invalid_credentials",
+				"request_id": "req-1",
+			},
+		},
+		{
+			name:           "gorm not found",
+			err:            gorm.ErrRecordNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedType:   "application/json; charset=utf-8",
+			expectedBody: map[string]interface{}{
+				"error":      "Record not found",
+				"code":       "record_not_found",
+				"request_id": "req-1",
+			},
+		},
+		{
+			name:           "unknown errors do not leak their cause",
+			err:            errors.New("pq: connection reset"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedType:   "application/json; charset=utf-8",
+			expectedBody: map[string]interface{}{
+				"error":      "Internal server error",
+				"code":       "internal_error",
+				"request_id": "req-1",
+			},
+		},
+		{
+			name:           "problem details when accepted",
+			err:            apperrors.ErrPermissionDenied.WithDetails("admin permission required"),
+			accept:         "application/problem+json",
+			expectedStatus: http.StatusForbidden,
+			expectedType:   "application/problem+json",
+			expectedBody: map[string]interface{}{
+				"type":       "about:blank",
+				"title":      "Forbidden",
+				"status":     float64(http.StatusForbidden),
+				"detail":     "Permission denied",
+				"details":    "admin permission required",
+				"instance":   "/test",
+				"code":       "permission_denied",
+				"request_id": "req-1",
+			},
+		},
+		{
+			name:           "problem details when configured",
+			err:            apperrors.ErrTokenRevoked,
+			problemDetails: true,
+			expectedStatus: http.StatusUnauthorized,
+			expectedType:   "application/problem+json",
+			expectedBody: map[string]interface{}{
+				"type":       "about:blank",
+				"title":      "Unauthorized",
+				"status":     float64(http.StatusUnauthorized),
+				"detail":     "Token has been revoked",
+				"instance":   "/test",
+				"code":       "token_revoked",
+				"request_id": "req-1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(RequestID(), ErrorHandler(tt.problemDetails))
+			router.GET("/test", func(c *gin.Context) {
+				_ = c.Error(tt.err)
+			})
+
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.Header.Set(RequestIDHeader, "req-1")
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, tt.expectedType, w.Header().Get("Content-Type"))
+			assert.Equal(t, "req-1", w.Header().Get(RequestIDHeader))
+
+			var body map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.expectedBody, body)
+		})
+	}
+}
+
+func TestRequestIDRejectsUnsafeValues(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/test", func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("request_id"))
+	})
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set(RequestIDHeader, "bad id\nwith newline")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Len(t, w.Body.String(), 32)
+	assert.Equal(t, w.Body.String(), w.Header().Get(RequestIDHeader))
+}
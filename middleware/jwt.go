@@ -1,52 +1,160 @@
 package middleware
 
 import (
-	"net/http"
-	"os"
+	"errors"
+	"math"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	apperrors "github.com/sukhantharot/go-service/errors"
 )
 
-func JWTAuth() gin.HandlerFunc {
+// RevocationChecker reports whether an access token was revoked before it expired.
+// An AppError, such as ErrAccountSuspended, rejects the token with that error.
+type RevocationChecker interface {
+	IsRevoked(jti string, userID uint, issuedAt time.Time) (bool, error)
+}
+
+// JWTAuth verifies the bearer token with keyfunc and rejects revoked tokens
+func JWTAuth(keyfunc jwt.Keyfunc, revocations RevocationChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
-			c.Abort()
+			abortWithError(c, apperrors.ErrMissingAuthHeader)
 			return
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header format must be Bearer {token}"})
-			c.Abort()
+			abortWithError(c, apperrors.ErrInvalidAuthHeader)
 			return
 		}
 
 		tokenString := parts[1]
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
+		token, err := jwt.Parse(tokenString, keyfunc)
 
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+			abortWithError(c, apperrors.ErrTokenInvalid.Wrap(err))
+			return
+		}
+
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok || !token.Valid {
+			abortWithError(c, apperrors.ErrTokenInvalid.WithMessage("Invalid token claims"))
 			return
 		}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			c.Set("user_id", claims["user_id"])
-			c.Set("role_id", claims["role_id"])
-			c.Next()
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
-			c.Abort()
+		userID, ok := claims["user_id"].(float64)
+		if !ok {
+			abortWithError(c, apperrors.ErrTokenInvalid.WithMessage("Invalid token claims"))
+			return
+		}
+
+		jti, _ := claims["jti"].(string)
+		var issuedAt, expiresAt time.Time
+		// Read iat directly, jwt rounds NumericDates down to whole seconds
+		if iat, ok := claims["iat"].(float64); ok {
+			issuedAt = time.UnixMilli(int64(math.Round(iat * 1000)))
+		}
+		if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+			expiresAt = exp.Time
+		}
+
+		revoked, err := revocations.IsRevoked(jti, uint(userID), issuedAt)
+		var appErr *apperrors.AppError
+		if errors.As(err, &appErr) {
+			abortWithError(c, appErr)
+			return
+		}
+		if err != nil {
+			abortWithError(c, apperrors.ErrInternal.WithMessage("Could not verify token").Wrap(err))
 			return
 		}
+		if revoked {
+			abortWithError(c, apperrors.ErrTokenRevoked)
+			return
+		}
+
+		emailVerified, _ := claims["email_verified"].(bool)
+		mfa, _ := claims["mfa"].(bool)
+		mfaRequired, _ := claims["mfa_required"].(bool)
+
+		roleIDs := claimRoleIDs(claims)
+		// Elevated roles count until their own expiry, which can come before the token's
+		if elevated := claimElevatedRoleIDs(claims, time.Now()); len(elevated) > 0 {
+			roleIDs = append(roleIDs, elevated...)
+			c.Set("elevated_role_ids", elevated)
+		}
+
+		c.Set("user_id", claims["user_id"])
+		c.Set("role_ids", roleIDs)
+		c.Set("email_verified", emailVerified)
+		c.Set("mfa", mfa)
+		c.Set("mfa_required", mfaRequired)
+		c.Set("jti", jti)
+		c.Set("token_issued_at", issuedAt)
+		c.Set("token_expires_at", expiresAt)
+		if permissions, ok := claimPermissions(claims); ok {
+			c.Set("permissions", permissions)
+		}
+		if organizationID, ok := claims["org_id"].(float64); ok {
+			c.Set("org_id", uint(organizationID))
+			if roleID, ok := claims["org_role_id"].(float64); ok {
+				c.Set("org_role_id", uint(roleID))
+			}
+		}
+		c.Next()
+	}
+}
+
+// claimRoleIDs reads the role_ids claim, tokens issued before users had several roles carry role_id
+func claimRoleIDs(claims jwt.MapClaims) []uint {
+	if ids, ok := claims["role_ids"].([]interface{}); ok {
+		roleIDs := make([]uint, 0, len(ids))
+		for _, id := range ids {
+			if id, ok := id.(float64); ok {
+				roleIDs = append(roleIDs, uint(id))
+			}
+		}
+		return roleIDs
+	}
+	if id, ok := claims["role_id"].(float64); ok {
+		return []uint{uint(id)}
+	}
+	return []uint{}
+}
+
+// claimElevatedRoleIDs reads the roles of the elevations claim that are still active at now
+func claimElevatedRoleIDs(claims jwt.MapClaims, now time.Time) []uint {
+	elevations, _ := claims["elevations"].([]interface{})
+	var roleIDs []uint
+	for _, elevation := range elevations {
+		elevation, ok := elevation.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		roleID, ok := elevation["role_id"].(float64)
+		expiresAt, _ := elevation["expires_at"].(float64)
+		if ok && time.Unix(int64(expiresAt), 0).After(now) {
+			roleIDs = append(roleIDs, uint(roleID))
+		}
+	}
+	return roleIDs
+}
+
+// claimPermissions reads the permissions claim of tokens issued with TokenConfig.EmbedPermissions
+func claimPermissions(claims jwt.MapClaims) ([]string, bool) {
+	names, ok := claims["permissions"].([]interface{})
+	if !ok {
+		return nil, false
+	}
+	permissions := make([]string, 0, len(names))
+	for _, name := range names {
+		if name, ok := name.(string); ok {
+			permissions = append(permissions, name)
+		}
 	}
-} 
\ No newline at end of file
+	return permissions, true
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -10,14 +11,23 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/sukhantharot/go-service/service"
 )
 
 func setupTestRouter() *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	router := gin.Default()
+	router.Use(RequestID(), ErrorHandler(false))
 	return router
 }
 
+// fakeRevocations treats the listed jtis as revoked
+type fakeRevocations map[string]bool
+
+func (f fakeRevocations) IsRevoked(jti string, userID uint, issuedAt time.Time) (bool, error) {
+	return f[jti], nil
+}
+
 func TestJWTAuth(t *testing.T) {
 	// Set JWT secret
 	os.Setenv("JWT_SECRET", "test_secret")
@@ -26,6 +36,7 @@ func TestJWTAuth(t *testing.T) {
 	tests := []struct {
 		name           string
 		setupAuth      func() string
+		header         string // sent verbatim instead of "Bearer " + setupAuth()
 		expectedStatus int
 		expectedError  string
 	}{
@@ -33,15 +44,31 @@ func TestJWTAuth(t *testing.T) {
 			name: "valid token",
 			setupAuth: func() string {
 				token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-					"user_id": 1,
-					"role_id": 1,
-					"exp":     time.Now().Add(time.Hour * 24).Unix(),
+					"user_id":  1,
+					"role_ids": []uint{1},
+					"exp":      time.Now().Add(time.Hour * 24).Unix(),
 				})
 				tokenString, _ := token.SignedString([]byte("test_secret"))
 				return tokenString
 			},
 			expectedStatus: http.StatusOK,
 		},
+		{
+			name: "revoked token",
+			setupAuth: func() string {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+					"jti":      "revoked-jti",
+					"user_id":  1,
+					"role_ids": []uint{1},
+					"iat":      time.Now().Unix(),
+					"exp":      time.Now().Add(time.Hour).Unix(),
+				})
+				tokenString, _ := token.SignedString([]byte("test_secret"))
+				return tokenString
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "Token has been revoked",
+		},
 		{
 			name: "missing token",
 			setupAuth: func() string {
@@ -55,6 +82,7 @@ func TestJWTAuth(t *testing.T) {
 			setupAuth: func() string {
 				return "invalid_token_format"
 			},
+			header:         "invalid_token_format",
 			expectedStatus: http.StatusUnauthorized,
 			expectedError:  "Authorization header format must be Bearer {token}",
 		},
@@ -62,9 +90,9 @@ func TestJWTAuth(t *testing.T) {
 			name: "expired token",
 			setupAuth: func() string {
 				token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-					"user_id": 1,
-					"role_id": 1,
-					"exp":     time.Now().Add(-time.Hour).Unix(), // Expired
+					"user_id":  1,
+					"role_ids": []uint{1},
+					"exp":      time.Now().Add(-time.Hour).Unix(), // Expired
 				})
 				tokenString, _ := token.SignedString([]byte("test_secret"))
 				return tokenString
@@ -78,13 +106,15 @@ func TestJWTAuth(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Setup
 			router := setupTestRouter()
-			router.GET("/test", JWTAuth(), func(c *gin.Context) {
+			router.GET("/test", JWTAuth(service.NewHMACKeySet("test_secret").Keyfunc, fakeRevocations{"revoked-jti": true}), func(c *gin.Context) {
 				c.JSON(http.StatusOK, gin.H{"status": "ok"})
 			})
 
 			// Create request
 			req := httptest.NewRequest("GET", "/test", nil)
-			if tt.setupAuth() != "" {
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			} else if tt.setupAuth() != "" {
 				req.Header.Set("Authorization", "Bearer "+tt.setupAuth())
 			}
 			w := httptest.NewRecorder()
@@ -102,4 +132,23 @@ func TestJWTAuth(t *testing.T) {
 			}
 		})
 	}
-} 
\ No newline at end of file
+}
+
+func TestClaimRoleIDs(t *testing.T) {
+	assert.Equal(t, []uint{1, 2}, claimRoleIDs(jwt.MapClaims{"role_ids": []interface{}{float64(1), float64(2)}}))
+	// Tokens issued before users had several roles
+	assert.Equal(t, []uint{3}, claimRoleIDs(jwt.MapClaims{"role_id": float64(3)}))
+	assert.Empty(t, claimRoleIDs(jwt.MapClaims{}))
+}
+
+func TestClaimElevatedRoleIDs(t *testing.T) {
+	now := time.Now()
+	claims := jwt.MapClaims{"elevations": []interface{}{
+		map[string]interface{}{"role_id": float64(4), "expires_at": float64(now.Add(time.Hour).Unix())},
+		map[string]interface{}{"role_id": float64(5), "expires_at": float64(now.Add(-time.Second).Unix())},
+		"malformed",
+	}}
+	// Only the elevation that did not expire yet counts
+	assert.Equal(t, []uint{4}, claimElevatedRoleIDs(claims, now))
+	assert.Empty(t, claimElevatedRoleIDs(jwt.MapClaims{}, now))
+}
@@ -21,6 +21,6 @@ func LoggingMiddleware() gin.HandlerFunc {
 
 		// Log response details
 		duration := time.Since(start)
-		log.Printf("Response: %d %s (%s)", c.Writer.Status(), c.Request.URL.Path, duration)
+		log.Printf("Response: %d %s (%s) request_id=%s", c.Writer.Status(), c.Request.URL.Path, duration, c.GetString("request_id"))
 	}
 }
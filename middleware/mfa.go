@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	apperrors "github.com/sukhantharot/go-service/errors"
+)
+
+// RequireMFA rejects tokens of users whose role requires a second factor when the login did not use one
+func RequireMFA() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool("mfa_required") && !c.GetBool("mfa") {
+			abortWithError(c, apperrors.ErrMFARequired)
+			return
+		}
+
+		c.Next()
+	}
+}
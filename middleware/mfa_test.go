@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireMFA(t *testing.T) {
+	tests := []struct {
+		name           string
+		mfaRequired    bool
+		mfa            bool
+		expectedStatus int
+	}{
+		{name: "not required", mfaRequired: false, mfa: false, expectedStatus: http.StatusOK},
+		{name: "required and used", mfaRequired: true, mfa: true, expectedStatus: http.StatusOK},
+		{name: "required but not used", mfaRequired: true, mfa: false, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupTestRouter()
+			router.GET("/test", func(c *gin.Context) {
+				c.Set("mfa_required", tt.mfaRequired)
+				c.Set("mfa", tt.mfa)
+			}, RequireMFA(), func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{"status": "ok"})
+			})
+
+			req := httptest.NewRequest("GET", "/test", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+)
+
+// RequireOrganization keeps out tokens without an active organization, for routes that act
+// on the data of the active organization
+func RequireOrganization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := contextOrganization(c); !ok {
+			abortWithError(c, apperrors.ErrOrganizationRequired)
+			return
+		}
+		c.Next()
+	}
+}
+
+// ScopeToOrganization marks requests whose caller has the permission only through their
+// role in the active organization, by setting organization_scope to that organization.
+// Handlers listing resources keep such callers to it, see handlers.UserHandler.GetAllUsers.
+func ScopeToOrganization(roles RoleFinder, permissionName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		organizationID, _, active := contextOrganization(c)
+		if !active {
+			c.Next()
+			return
+		}
+		roleIDs, ok := contextRoleIDs(c)
+		if !ok {
+			abortWithError(c, apperrors.ErrUnauthorized.WithMessage("Role IDs not found in context"))
+			return
+		}
+
+		loaded := make([]models.Role, 0, len(roleIDs))
+		for _, roleID := range roleIDs {
+			role, err := roles.FindByID(roleID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			if err != nil {
+				abortWithError(c, apperrors.ErrInternal.WithMessage("Could not load role").Wrap(err))
+				return
+			}
+			loaded = append(loaded, *role)
+		}
+		if !models.HasPermission(loaded, permissionName) {
+			c.Set("organization_scope", organizationID)
+		}
+		c.Next()
+	}
+}
+
+// contextOrganization reads the active organization and the caller's role in it set by JWTAuth
+func contextOrganization(c *gin.Context) (organizationID, roleID uint, ok bool) {
+	value, exists := c.Get("org_id")
+	if !exists {
+		return 0, 0, false
+	}
+	organizationID, ok = value.(uint)
+	if !ok {
+		return 0, 0, false
+	}
+	value, _ = c.Get("org_role_id")
+	roleID, _ = value.(uint)
+	return organizationID, roleID, true
+}
+
+// permissionRoleIDs returns the caller's own roles and, while an organization is active,
+// their role in it. The role of a membership only counts inside its organization.
+func permissionRoleIDs(c *gin.Context) ([]uint, bool) {
+	roleIDs, ok := contextRoleIDs(c)
+	if !ok {
+		return nil, false
+	}
+	if _, roleID, active := contextOrganization(c); active && roleID != 0 {
+		roleIDs = append(append([]uint(nil), roleIDs...), roleID)
+	}
+	return roleIDs, true
+}
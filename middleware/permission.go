@@ -1,43 +1,158 @@
 package middleware
 
 import (
-	"net/http"
+	"errors"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/sukhantharot/go-service/config"
+	apperrors "github.com/sukhantharot/go-service/errors"
 	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
 )
 
-func RequirePermission(permissionName string) gin.HandlerFunc {
+// RoleFinder loads a role with its permissions and ancestors
+type RoleFinder interface {
+	FindByID(id uint) (*models.Role, error)
+}
+
+// PermissionChangeTracker is implemented by role finders that know when permissions last
+// changed, such as service.PermissionCache. Only with one are the permissions embedded in
+// access tokens trusted, and only in tokens issued after the last change.
+type PermissionChangeTracker interface {
+	ChangedAt() time.Time
+}
+
+// RequirePermission lets the request through when any of the caller's roles or their
+// ancestors grants the permission, directly or through a wildcard like users:*. Elevated
+// roles count until they expire. The caller's role in the active organization does not,
+// see RequireOrganizationPermission.
+func RequirePermission(roles RoleFinder, permissionName string) gin.HandlerFunc {
+	return RequireAnyPermission(roles, permissionName)
+}
+
+// RequireAnyPermission lets the request through when the caller has at least one of the permissions
+func RequireAnyPermission(roles RoleFinder, permissionNames ...string) gin.HandlerFunc {
+	return requirePermissions(roles, permissionNames, false, false)
+}
+
+// RequireAllPermissions lets the request through when the caller has every one of the permissions
+func RequireAllPermissions(roles RoleFinder, permissionNames ...string) gin.HandlerFunc {
+	return requirePermissions(roles, permissionNames, true, false)
+}
+
+// RequireOrganizationPermission is RequirePermission counting the caller's role in the
+// active organization too, for routes that only act on data of that organization
+func RequireOrganizationPermission(roles RoleFinder, permissionName string) gin.HandlerFunc {
+	return requirePermissions(roles, []string{permissionName}, false, true)
+}
+
+func requirePermissions(roles RoleFinder, permissionNames []string, all, organization bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		roleID, exists := c.Get("role_id")
-		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Role ID not found in context"})
-			c.Abort()
+		// Embedded permissions include those of the organization role
+		_, organizationRole, active := contextOrganization(c)
+		trustToken := organization || !active || organizationRole == 0
+		if permissions, ok := tokenPermissions(c, roles); ok && trustToken {
+			granted := func(name string) bool {
+				for _, permission := range permissions {
+					if models.MatchPermission(permission, name) {
+						return true
+					}
+				}
+				return false
+			}
+			if !hasPermissions(granted, permissionNames, all) {
+				abortWithError(c, apperrors.ErrPermissionDenied)
+				return
+			}
+			c.Next()
 			return
 		}
 
-		var role models.Role
-		if err := config.DB.Preload("Permissions").First(&role, roleID).Error; err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Role not found"})
-			c.Abort()
+		roleIDs, ok := contextRoleIDs(c)
+		if organization {
+			roleIDs, ok = permissionRoleIDs(c)
+		}
+		if !ok {
+			abortWithError(c, apperrors.ErrUnauthorized.WithMessage("Role IDs not found in context"))
 			return
 		}
 
-		hasPermission := false
-		for _, permission := range role.Permissions {
-			if permission.Name == permissionName {
-				hasPermission = true
-				break
+		loaded := make([]models.Role, 0, len(roleIDs))
+		for _, roleID := range roleIDs {
+			role, err := roles.FindByID(roleID)
+			// A role deleted after the token was issued grants nothing
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
 			}
+			if err != nil {
+				abortWithError(c, apperrors.ErrInternal.WithMessage("Could not load role").Wrap(err))
+				return
+			}
+			loaded = append(loaded, *role)
+		}
+		granted := func(name string) bool {
+			return models.HasPermission(loaded, name)
 		}
 
-		if !hasPermission {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
-			c.Abort()
+		if !hasPermissions(granted, permissionNames, all) {
+			abortWithError(c, apperrors.ErrPermissionDenied)
 			return
 		}
 
 		c.Next()
 	}
 }
+
+// hasPermissions checks granted for every permission when all is set, otherwise for any of them.
+// An empty list of permissions is never satisfied, a guard without permissions is a mistake.
+func hasPermissions(granted func(name string) bool, permissionNames []string, all bool) bool {
+	if len(permissionNames) == 0 {
+		return false
+	}
+	for _, name := range permissionNames {
+		has := granted(name)
+		if has && !all {
+			return true
+		}
+		if !has && all {
+			return false
+		}
+	}
+	return all
+}
+
+// tokenPermissions returns the permissions embedded in the access token when roles can
+// tell they did not change since the token was issued. They leave out elevated roles, so
+// tokens with an active elevation are checked against the roles.
+func tokenPermissions(c *gin.Context, roles RoleFinder) ([]string, bool) {
+	tracker, ok := roles.(PermissionChangeTracker)
+	if !ok {
+		return nil, false
+	}
+	if _, elevated := c.Get("elevated_role_ids"); elevated {
+		return nil, false
+	}
+	value, exists := c.Get("permissions")
+	if !exists {
+		return nil, false
+	}
+	permissions, ok := value.([]string)
+	if !ok {
+		return nil, false
+	}
+	issuedAt, _ := c.Get("token_issued_at")
+	if issuedAt, ok := issuedAt.(time.Time); !ok || !issuedAt.After(tracker.ChangedAt()) {
+		return nil, false
+	}
+	return permissions, true
+}
+
+// contextRoleIDs reads the role IDs set by JWTAuth
+func contextRoleIDs(c *gin.Context) ([]uint, bool) {
+	value, exists := c.Get("role_ids")
+	if !exists {
+		return nil, false
+	}
+	roleIDs, ok := value.([]uint)
+	return roleIDs, ok
+}
@@ -5,53 +5,47 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"github.com/sukhantharot/go-service/config"
 	"github.com/sukhantharot/go-service/models"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
+	"github.com/sukhantharot/go-service/repository/memory"
 )
 
-func setupTestDB(t *testing.T) *gorm.DB {
-	// Setup test database
-	db, err := gorm.Open(postgres.Open("host=localhost user=test password=test dbname=test_db port=5432 sslmode=disable"), &gorm.Config{})
-	require.NoError(t, err)
-
-	// Migrate schema
-	err = db.AutoMigrate(&models.User{}, &models.Role{}, &models.Permission{})
-	require.NoError(t, err)
-
-	return db
-}
-
-func setupTestRouter(db *gorm.DB) *gin.Engine {
+func setupPermissionTestRouter() *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	router := gin.Default()
-	config.DB = db
+	router.Use(RequestID(), ErrorHandler(false))
 	return router
 }
 
 func TestRequirePermission(t *testing.T) {
 	// Setup
-	db := setupTestDB(t)
-	router := setupTestRouter(db)
+	store := memory.NewStore()
+	roles := memory.NewRoleRepository(store)
 
 	// Create test role and permission
 	permission := models.Permission{
 		Name:        "admin",
 		Description: "Admin permission",
 	}
-	db.Create(&permission)
+	require.NoError(t, memory.NewPermissionRepository(store).Create(&permission))
 
 	role := models.Role{
 		Name:        "admin",
 		Description: "Admin role",
 		Permissions: []models.Permission{permission},
 	}
-	db.Create(&role)
+	require.NoError(t, roles.Create(&role))
+
+	// manager inherits from admin through lead
+	lead := models.Role{Name: "lead", Parents: []models.Role{role}}
+	require.NoError(t, roles.Create(&lead))
+	manager := models.Role{Name: "manager"}
+	require.NoError(t, roles.Create(&manager))
+	require.NoError(t, roles.AddParent(manager.ID, lead.ID))
 
 	// Test cases
 	tests := []struct {
@@ -63,25 +57,47 @@ func TestRequirePermission(t *testing.T) {
 		{
 			name: "user with required permission",
 			setupContext: func(c *gin.Context) {
-				c.Set("role_id", role.ID)
+				c.Set("role_ids", []uint{role.ID})
 			},
 			expectedStatus: http.StatusOK,
 		},
 		{
-			name: "user without role_id in context",
+			name: "user with the permission through a second role",
 			setupContext: func(c *gin.Context) {
-				// Do nothing, role_id not set
+				c.Set("role_ids", []uint{999, role.ID})
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "user with the permission through an ancestor role",
+			setupContext: func(c *gin.Context) {
+				c.Set("role_ids", []uint{manager.ID})
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "user without role_ids in context",
+			setupContext: func(c *gin.Context) {
+				// Do nothing, role_ids not set
 			},
 			expectedStatus: http.StatusUnauthorized,
-			expectedError:  "Role ID not found in context",
+			expectedError:  "Role IDs not found in context",
 		},
 		{
 			name: "user with non-existent role",
 			setupContext: func(c *gin.Context) {
-				c.Set("role_id", 999) // Non-existent role ID
+				c.Set("role_ids", []uint{999}) // Non-existent role ID
 			},
-			expectedStatus: http.StatusUnauthorized,
-			expectedError:  "Role not found",
+			expectedStatus: http.StatusForbidden,
+			expectedError:  "Permission denied",
+		},
+		{
+			name: "user without roles",
+			setupContext: func(c *gin.Context) {
+				c.Set("role_ids", []uint{})
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedError:  "Permission denied",
 		},
 		{
 			name: "user without required permission",
@@ -91,8 +107,8 @@ func TestRequirePermission(t *testing.T) {
 					Name:        "user",
 					Description: "Regular user role",
 				}
-				db.Create(&noPermissionRole)
-				c.Set("role_id", noPermissionRole.ID)
+				roles.Create(&noPermissionRole)
+				c.Set("role_ids", []uint{noPermissionRole.ID})
 			},
 			expectedStatus: http.StatusForbidden,
 			expectedError:  "Permission denied",
@@ -102,9 +118,10 @@ func TestRequirePermission(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Setup route with middleware
+			router := setupPermissionTestRouter()
 			router.GET("/test", func(c *gin.Context) {
 				tt.setupContext(c)
-			}, RequirePermission("admin"), func(c *gin.Context) {
+			}, RequirePermission(roles, "admin"), func(c *gin.Context) {
 				c.JSON(http.StatusOK, gin.H{"status": "ok"})
 			})
 
@@ -126,3 +143,96 @@ func TestRequirePermission(t *testing.T) {
 		})
 	}
 }
+
+func TestRequireAnyAndAllPermissions(t *testing.T) {
+	store := memory.NewStore()
+	roles := memory.NewRoleRepository(store)
+
+	reader := models.Role{Name: "reader", Permissions: []models.Permission{{Name: "*:read"}}}
+	require.NoError(t, roles.Create(&reader))
+	reports := models.Role{Name: "reports", Permissions: []models.Permission{{Name: "reports:*"}}}
+	require.NoError(t, roles.Create(&reports))
+
+	tests := []struct {
+		name           string
+		middleware     gin.HandlerFunc
+		roleIDs        []uint
+		expectedStatus int
+	}{
+		{"any with one match", RequireAnyPermission(roles, "users:write", "users:read"), []uint{reader.ID}, http.StatusOK},
+		{"any without a match", RequireAnyPermission(roles, "users:write", "users:delete"), []uint{reader.ID}, http.StatusForbidden},
+		{"all with every match", RequireAllPermissions(roles, "users:read", "reports:export"), []uint{reader.ID, reports.ID}, http.StatusOK},
+		{"all with one missing", RequireAllPermissions(roles, "users:read", "reports:export"), []uint{reader.ID}, http.StatusForbidden},
+		{"wildcard grant", RequirePermission(roles, "reports:delete"), []uint{reports.ID}, http.StatusOK},
+		{"no permissions listed", RequireAnyPermission(roles), []uint{reader.ID}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupPermissionTestRouter()
+			router.GET("/test", func(c *gin.Context) {
+				c.Set("role_ids", tt.roleIDs)
+			}, tt.middleware, func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{"status": "ok"})
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/test", nil))
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
+
+// trackedRoles reports a fixed time of the last permission change
+type trackedRoles struct {
+	RoleFinder
+	changedAt time.Time
+}
+
+func (r trackedRoles) ChangedAt() time.Time {
+	return r.changedAt
+}
+
+func TestRequirePermissionFromToken(t *testing.T) {
+	store := memory.NewStore()
+	roles := memory.NewRoleRepository(store)
+	reader := models.Role{Name: "reader", Permissions: []models.Permission{{Name: "users:read"}}}
+	require.NoError(t, roles.Create(&reader))
+
+	issuedAt := time.Now()
+	tests := []struct {
+		name           string
+		roles          RoleFinder
+		permissions    []string
+		elevated       bool
+		expectedStatus int
+	}{
+		{"embedded permissions decide", trackedRoles{roles, issuedAt.Add(-time.Minute)}, []string{"users:*"}, false, http.StatusOK},
+		{"embedded permissions can deny", trackedRoles{roles, issuedAt.Add(-time.Minute)}, []string{"reports:*"}, false, http.StatusForbidden},
+		// The role grants users:read, the stale token does not
+		{"stale token falls back to the roles", trackedRoles{roles, issuedAt.Add(time.Minute)}, []string{}, false, http.StatusOK},
+		{"untracked roles ignore the token", roles, []string{"reports:*"}, false, http.StatusOK},
+		// Embedded permissions leave out elevated roles
+		{"active elevation falls back to the roles", trackedRoles{roles, issuedAt.Add(-time.Minute)}, []string{}, true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupPermissionTestRouter()
+			router.GET("/test", func(c *gin.Context) {
+				c.Set("role_ids", []uint{reader.ID})
+				c.Set("permissions", tt.permissions)
+				c.Set("token_issued_at", issuedAt)
+				if tt.elevated {
+					c.Set("elevated_role_ids", []uint{reader.ID})
+				}
+			}, RequirePermission(tt.roles, "users:read"), func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{"status": "ok"})
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/test", nil))
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/policy"
+)
+
+// PolicyEnforcer evaluates attribute-based policies, such as service.PolicyService
+type PolicyEnforcer interface {
+	// Subject returns the attributes of the user with the given roles
+	Subject(userID uint, roleIDs []uint) (policy.Attributes, error)
+	Evaluate(req policy.Request) policy.Decision
+	// Applies reports whether any policy covers the action
+	Applies(action string) bool
+}
+
+// PolicyResourceFunc returns the attributes of the resource a request acts on. When it
+// cannot, it fails the request itself and returns false.
+type PolicyResourceFunc func(c *gin.Context) (policy.Attributes, bool)
+
+// RequirePolicy lets the request through only when a policy allows the action, so the
+// policies alone decide who may use the route. resource may be nil for routes that do
+// not act on one resource.
+func RequirePolicy(enforcer PolicyEnforcer, action string, resource PolicyResourceFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		decision, ok := evaluatePolicies(c, enforcer, action, resource)
+		if !ok {
+			return
+		}
+		if !decision.Allowed() {
+			abortWithError(c, apperrors.ErrPermissionDenied)
+			return
+		}
+		c.Next()
+	}
+}
+
+// EnforcePolicies rejects the request only when a policy denies the action, next to
+// RequirePermission or RequireResourcePermission that grant access in the first place.
+// Routes whose action no policy covers skip loading the resource.
+func EnforcePolicies(enforcer PolicyEnforcer, action string, resource PolicyResourceFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !enforcer.Applies(action) {
+			c.Next()
+			return
+		}
+		decision, ok := evaluatePolicies(c, enforcer, action, resource)
+		if !ok {
+			return
+		}
+		if decision.Effect == policy.Deny {
+			abortWithError(c, apperrors.ErrPermissionDenied.WithMessage("Denied by policy"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// PolicyResourceParam loads the attributes of the resource whose ID is the named path
+// parameter, e.g. service.PolicyService.UserAttributes
+func PolicyResourceParam(param string, load func(id string) (policy.Attributes, error)) PolicyResourceFunc {
+	return func(c *gin.Context) (policy.Attributes, bool) {
+		attributes, err := load(c.Param(param))
+		var appErr *apperrors.AppError
+		if errors.As(err, &appErr) {
+			abortWithError(c, appErr)
+			return nil, false
+		}
+		if err != nil {
+			abortWithError(c, apperrors.ErrInternal.WithMessage("Could not load resource").Wrap(err))
+			return nil, false
+		}
+		return attributes, true
+	}
+}
+
+// FilterPolicies lets handlers listing resources leave out those a policy denies the
+// action on. It sets policy_filter to a func reporting whether the caller may see the
+// resource with the given attributes, see handlers.UserHandler.GetAllUsers. Lists are not
+// filtered when no policy covers the action.
+func FilterPolicies(enforcer PolicyEnforcer, action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if enforcer.Applies(action) {
+			c.Set("policy_filter", func(resource policy.Attributes) (bool, error) {
+				req, err := policyRequest(c, enforcer, action, resource)
+				if err != nil {
+					return false, err
+				}
+				return enforcer.Evaluate(req).Effect != policy.Deny, nil
+			})
+		}
+		c.Next()
+	}
+}
+
+// evaluatePolicies builds the request from the caller, the resource and the request
+// context. When it fails it aborts the request and returns false.
+func evaluatePolicies(c *gin.Context, enforcer PolicyEnforcer, action string, resource PolicyResourceFunc) (policy.Decision, bool) {
+	target := policy.Attributes{}
+	if resource != nil {
+		var ok bool
+		if target, ok = resource(c); !ok {
+			c.Abort()
+			return policy.Decision{}, false
+		}
+	}
+	req, err := policyRequest(c, enforcer, action, target)
+	if err != nil {
+		abortWithError(c, err)
+		return policy.Decision{}, false
+	}
+	return enforcer.Evaluate(req), true
+}
+
+// policyRequest builds the request from the caller, the resource and the request context
+func policyRequest(c *gin.Context, enforcer PolicyEnforcer, action string, resource policy.Attributes) (policy.Request, error) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return policy.Request{}, apperrors.ErrUnauthorized.WithMessage("User ID not found in context")
+	}
+	roleIDs, ok := permissionRoleIDs(c)
+	if !ok {
+		return policy.Request{}, apperrors.ErrUnauthorized.WithMessage("Role IDs not found in context")
+	}
+
+	subject, err := enforcer.Subject(userID, roleIDs)
+	if err != nil {
+		return policy.Request{}, apperrors.ErrInternal.WithMessage("Could not load policy subject").Wrap(err)
+	}
+	// Claims of the access token describe how the caller authenticated
+	subject["email_verified"] = c.GetBool("email_verified")
+	subject["mfa"] = c.GetBool("mfa")
+	if organizationID, _, active := contextOrganization(c); active {
+		subject["organization_id"] = strconv.FormatUint(uint64(organizationID), 10)
+	}
+
+	return policy.Request{
+		Action:   action,
+		Subject:  subject,
+		Resource: resource,
+		Context: policy.Attributes{
+			"ip":         c.ClientIP(),
+			"method":     c.Request.Method,
+			"path":       c.FullPath(),
+			"request_id": c.GetString("request_id"),
+		},
+	}, nil
+}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/models"
+)
+
+// Authorizer decides whether a user may use a permission on one resource, such as
+// service.AccessService
+type Authorizer interface {
+	Authorize(userID uint, roleIDs []uint, permission string, resource models.Resource) (bool, error)
+}
+
+// OrganizationAuthorizer is implemented by authorizers that can tell whether the caller's
+// role in the active organization lets them use a permission on a resource of that
+// organization, such as service.AccessService
+type OrganizationAuthorizer interface {
+	AuthorizeInOrganization(organizationID, roleID uint, permission string, resource models.Resource) (bool, error)
+}
+
+// ResourceFunc returns the resource a request acts on. When it cannot tell, it fails the
+// request itself and returns false.
+type ResourceFunc func(c *gin.Context) (models.Resource, bool)
+
+// RequireResourcePermission lets the request through when the caller may use the
+// permission on the resource the request acts on, through a role or a grant covering it
+func RequireResourcePermission(authz Authorizer, permissionName string, resource ResourceFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		target, ok := resource(c)
+		if !ok {
+			c.Abort()
+			return
+		}
+		if Authorize(c, authz, permissionName, target) {
+			c.Next()
+		}
+	}
+}
+
+// Authorize is for handlers that only know the target resource once they loaded it. It
+// reports whether the caller may use the permission on resource, and when not it fails
+// the request and the handler returns. The caller's own roles and grants apply to every
+// resource, their role in the active organization only to resources of that organization.
+func Authorize(c *gin.Context, authz Authorizer, permissionName string, resource models.Resource) bool {
+	userID, ok := contextUserID(c)
+	if !ok {
+		abortWithError(c, apperrors.ErrUnauthorized.WithMessage("User ID not found in context"))
+		return false
+	}
+	roleIDs, ok := contextRoleIDs(c)
+	if !ok {
+		abortWithError(c, apperrors.ErrUnauthorized.WithMessage("Role IDs not found in context"))
+		return false
+	}
+
+	allowed, err := authz.Authorize(userID, roleIDs, permissionName, resource)
+	// The role in the active organization only reaches resources of that organization
+	if organizationID, roleID, active := contextOrganization(c); err == nil && !allowed && active && roleID != 0 {
+		if orgAuthz, ok := authz.(OrganizationAuthorizer); ok {
+			allowed, err = orgAuthz.AuthorizeInOrganization(organizationID, roleID, permissionName, resource)
+		}
+	}
+	if err != nil {
+		abortWithError(c, apperrors.ErrInternal.WithMessage("Could not check permission").Wrap(err))
+		return false
+	}
+	if !allowed {
+		abortWithError(c, apperrors.ErrPermissionDenied)
+		return false
+	}
+	return true
+}
+
+// UserResourceLoader returns the record of a user with the resources containing it, such
+// as service.AccessService
+type UserResourceLoader interface {
+	UserResource(id uint) (models.Resource, error)
+}
+
+// UserParam reads the user a route acts on from the named path parameter
+func UserParam(param string, users UserResourceLoader) ResourceFunc {
+	return func(c *gin.Context) (models.Resource, bool) {
+		id, err := strconv.ParseUint(c.Param(param), 10, 64)
+		if err != nil || id == 0 {
+			abortWithError(c, apperrors.ErrBadRequest.WithMessage("Invalid ID").Wrap(err))
+			return models.Resource{}, false
+		}
+		resource, err := users.UserResource(uint(id))
+		if err != nil {
+			abortWithError(c, apperrors.ErrInternal.WithMessage("Could not load resource").Wrap(err))
+			return models.Resource{}, false
+		}
+		return resource, true
+	}
+}
+
+// contextUserID reads the user ID set by JWTAuth, claims decode numbers as float64
+func contextUserID(c *gin.Context) (uint, bool) {
+	value, exists := c.Get("user_id")
+	if !exists {
+		return 0, false
+	}
+	switch id := value.(type) {
+	case float64:
+		return uint(id), true
+	case uint:
+		return id, true
+	case int:
+		return uint(id), true
+	}
+	return 0, false
+}
@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	apperrors "github.com/sukhantharot/go-service/errors"
+)
+
+// RequireVerifiedEmail rejects users whose token says they have not verified their email yet
+func RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool("email_verified") {
+			abortWithError(c, apperrors.ErrEmailNotVerified)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireVerifiedEmail(t *testing.T) {
+	tests := []struct {
+		name           string
+		setupContext   func(c *gin.Context)
+		expectedStatus int
+	}{
+		{
+			name: "verified email",
+			setupContext: func(c *gin.Context) {
+				c.Set("email_verified", true)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "unverified email",
+			setupContext: func(c *gin.Context) {
+				c.Set("email_verified", false)
+			},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "token without the claim",
+			setupContext:   func(c *gin.Context) {},
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupTestRouter()
+			router.GET("/test", func(c *gin.Context) {
+				tt.setupContext(c)
+			}, RequireVerifiedEmail(), func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{"status": "ok"})
+			})
+
+			req := httptest.NewRequest("GET", "/test", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
@@ -0,0 +1,418 @@
+// Package migrate applies versioned SQL migrations to Postgres. A migration is a pair of
+// files, NNN_name.up.sql and NNN_name.down.sql, applied in version order, each in its own
+// transaction. The versions applied are recorded in schema_migrations with a checksum of
+// the up file, so a migration edited after it ran is detected. A Postgres advisory lock
+// keeps replicas starting at the same time from applying the same migration twice.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrChecksumMismatch means an applied migration was edited afterwards
+	ErrChecksumMismatch = errors.New("migration was edited after it was applied")
+	// ErrUnknownVersion means the database has a migration the files do not, e.g. after
+	// running a newer release
+	ErrUnknownVersion = errors.New("applied migration has no file")
+	// ErrPending means the schema is behind the files
+	ErrPending = errors.New("schema has pending migrations")
+	// ErrIrreversible means a migration to roll back has no down file
+	ErrIrreversible = errors.New("migration has no down file")
+)
+
+// lockID identifies the advisory lock held while migrating
+const lockID int64 = 4_178_201_964
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one version of the schema
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	// Down undoes Up, empty when the migration cannot be rolled back
+	Down string
+	// Checksum is the SHA-256 of Up
+	Checksum string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%03d_%s", m.Version, m.Name)
+}
+
+// Record is a migration applied to the database
+type Record struct {
+	Version   int64
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// Status is a migration and whether, and how, it was applied
+type Status struct {
+	Migration Migration
+	// AppliedAt is nil while the migration is pending
+	AppliedAt *time.Time
+	// Modified is set when the up file changed after the migration was applied
+	Modified bool
+	// Missing is set when the migration was applied but has no file, only Version and
+	// Name of Migration are known then
+	Missing bool
+}
+
+// Load reads the migrations of fsys, sorted by version. Every version needs an up file.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("%s: migration files are named NNN_name.up.sql or NNN_name.down.sql", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("%s: invalid version", entry.Name())
+		}
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("version %d is used by both %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(data)
+			migration.Checksum = checksum(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("%s: no up file", migration)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Pending returns the migrations that were not applied, in order. It fails with
+// ErrChecksumMismatch or ErrUnknownVersion when applied does not match the migrations.
+func Pending(migrations []Migration, applied []Record) ([]Migration, error) {
+	byVersion := make(map[int64]Migration, len(migrations))
+	for _, migration := range migrations {
+		byVersion[migration.Version] = migration
+	}
+	done := make(map[int64]bool, len(applied))
+	for _, record := range applied {
+		migration, ok := byVersion[record.Version]
+		if !ok {
+			return nil, fmt.Errorf("%03d_%s: %w", record.Version, record.Name, ErrUnknownVersion)
+		}
+		if migration.Checksum != record.Checksum {
+			return nil, fmt.Errorf("%s: %w", migration, ErrChecksumMismatch)
+		}
+		done[record.Version] = true
+	}
+
+	var pending []Migration
+	for _, migration := range migrations {
+		if !done[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Statuses lists every migration with what applied says about it, followed by the applied
+// migrations that have no file
+func Statuses(migrations []Migration, applied []Record) []Status {
+	records := make(map[int64]Record, len(applied))
+	for _, record := range applied {
+		records[record.Version] = record
+	}
+
+	statuses := make([]Status, 0, len(migrations))
+	for _, migration := range migrations {
+		status := Status{Migration: migration}
+		if record, ok := records[migration.Version]; ok {
+			appliedAt := record.AppliedAt
+			status.AppliedAt = &appliedAt
+			status.Modified = record.Checksum != migration.Checksum
+			delete(records, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, record := range applied {
+		if _, missing := records[record.Version]; missing {
+			appliedAt := record.AppliedAt
+			statuses = append(statuses, Status{
+				Migration: Migration{Version: record.Version, Name: record.Name},
+				AppliedAt: &appliedAt,
+				Missing:   true,
+			})
+		}
+	}
+	return statuses
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Migrator applies migrations to one database
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func New(db *sql.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// Up applies the pending migrations up to version to, every one when to is zero, and
+// returns the ones it applied
+func (m *Migrator) Up(ctx context.Context, to int64) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := records(ctx, conn)
+		if err != nil {
+			return err
+		}
+		pending, err := Pending(m.migrations, applied)
+		if err != nil {
+			return err
+		}
+		for _, migration := range pending {
+			if to != 0 && migration.Version > to {
+				break
+			}
+			err := inTx(ctx, conn, migration.Up,
+				`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+				migration.Version, migration.Name, migration.Checksum)
+			if err != nil {
+				return fmt.Errorf("%s: %w", migration, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down rolls back the last steps applied migrations, newest first, and returns them
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := records(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if _, err := Pending(m.migrations, applied); err != nil {
+			return err
+		}
+		byVersion := make(map[int64]Migration, len(m.migrations))
+		for _, migration := range m.migrations {
+			byVersion[migration.Version] = migration
+		}
+
+		for i := len(applied) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := byVersion[applied[i].Version]
+			if migration.Down == "" {
+				return fmt.Errorf("%s: %w", migration, ErrIrreversible)
+			}
+			err := inTx(ctx, conn, migration.Down, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+			if err != nil {
+				return fmt.Errorf("%s: %w", migration, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Baseline records the migrations up to version to as applied without running them, for
+// databases whose schema was created another way. It returns the ones it recorded.
+func (m *Migrator) Baseline(ctx context.Context, to int64) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) (err error) {
+		done, err = baseline(ctx, conn, m.migrations, to)
+		return err
+	})
+	return done, err
+}
+
+// Adopt takes over a database whose schema was created another way. While no migration
+// is recorded, and holding the lock so replicas starting together adopt it once, it calls
+// adopt on the locked connection. When adopt reports the database needs it, the
+// migrations up to version to are recorded as applied, and Adopt returns them.
+func (m *Migrator) Adopt(ctx context.Context, to int64, adopt func(conn *sql.Conn) (bool, error)) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := records(ctx, conn)
+		if err != nil || len(applied) > 0 {
+			return err
+		}
+		adopted, err := adopt(conn)
+		if err != nil || !adopted {
+			return err
+		}
+		done, err = baseline(ctx, conn, m.migrations, to)
+		return err
+	})
+	return done, err
+}
+
+// Status lists every migration and whether it was applied, see Statuses
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := records(ctx, conn)
+		statuses = Statuses(m.migrations, applied)
+		return err
+	})
+	return statuses, err
+}
+
+// Check fails with ErrPending when migrations are pending, and like Pending when the
+// applied ones do not match the files
+func (m *Migrator) Check(ctx context.Context) error {
+	return m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := records(ctx, conn)
+		if err != nil {
+			return err
+		}
+		pending, err := Pending(m.migrations, applied)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%w: %d, the first is %s", ErrPending, len(pending), pending[0])
+		}
+		return nil
+	})
+}
+
+// Locked runs fn on a connection holding the migration lock, for other steps replicas
+// starting together must take one at a time, such as seeding
+func (m *Migrator) Locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	return m.locked(ctx, fn)
+}
+
+// locked runs fn on one connection holding the advisory lock, after creating
+// schema_migrations if needed. The lock is bound to the connection, not to a transaction.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("acquiring migration lock: %w", err)
+	}
+	defer func() {
+		// A fresh context, the lock must be released even when ctx was cancelled
+		if _, unlockErr := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID); unlockErr != nil && err == nil {
+			err = fmt.Errorf("releasing migration lock: %w", unlockErr)
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		checksum VARCHAR(64) NOT NULL,
+		applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// baseline records the migrations up to version to as applied, see Baseline
+func baseline(ctx context.Context, conn *sql.Conn, migrations []Migration, to int64) ([]Migration, error) {
+	var done []Migration
+	for _, migration := range migrations {
+		if migration.Version > to {
+			break
+		}
+		result, err := conn.ExecContext(ctx,
+			`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+			migration.Version, migration.Name, migration.Checksum)
+		if err != nil {
+			return done, err
+		}
+		if rows, _ := result.RowsAffected(); rows > 0 {
+			done = append(done, migration)
+		}
+	}
+	return done, nil
+}
+
+// records returns the applied migrations in version order
+func records(ctx context.Context, conn *sql.Conn) ([]Record, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var applied []Record
+	for rows.Next() {
+		var record Record
+		if err := rows.Scan(&record.Version, &record.Name, &record.Checksum, &record.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied = append(applied, record)
+	}
+	return applied, rows.Err()
+}
+
+// inTx runs script, then the bookkeeping statement with args, in one transaction. The
+// script is sent without arguments so it may hold several statements.
+func inTx(ctx context.Context, conn *sql.Conn, script, bookkeeping string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
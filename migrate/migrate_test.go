@@ -0,0 +1,95 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sukhantharot/go-service/migrations"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	all, err := Load(migrations.Files)
+	require.NoError(t, err)
+	require.NotEmpty(t, all)
+
+	for i, migration := range all {
+		assert.EqualValues(t, i+1, migration.Version, "versions have no gaps")
+		assert.NotEmpty(t, migration.Down, "%s has no down file", migration)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("sorted by version", func(t *testing.T) {
+		all, err := Load(fstest.MapFS{
+			"010_second.up.sql":  {Data: []byte("CREATE TABLE b ();")},
+			"002_first.up.sql":   {Data: []byte("CREATE TABLE a ();")},
+			"002_first.down.sql": {Data: []byte("DROP TABLE a;")},
+			"README.md":          {Data: []byte("not a migration")},
+		})
+		require.NoError(t, err)
+		require.Len(t, all, 2)
+		assert.Equal(t, "002_first", all[0].String())
+		assert.Equal(t, "DROP TABLE a;", all[0].Down)
+		assert.Len(t, all[0].Checksum, 64)
+		assert.Equal(t, "010_second", all[1].String())
+		assert.Empty(t, all[1].Down)
+	})
+
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		err   string
+	}{
+		{name: "bad name", files: fstest.MapFS{"001_init.sql": {}}, err: "001_init.sql: migration files are named NNN_name.up.sql or NNN_name.down.sql"},
+		{name: "version zero", files: fstest.MapFS{"000_init.up.sql": {}}, err: "000_init.up.sql: invalid version"},
+		{name: "no up file", files: fstest.MapFS{"001_init.down.sql": {Data: []byte("DROP TABLE a;")}}, err: "001_init: no up file"},
+		{name: "version taken", files: fstest.MapFS{"001_a.up.sql": {Data: []byte("x")}, "001_b.up.sql": {Data: []byte("y")}}, err: "version 1 is used by both a and b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.files)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestPending(t *testing.T) {
+	all, err := Load(fstest.MapFS{
+		"001_a.up.sql": {Data: []byte("CREATE TABLE a ();")},
+		"002_b.up.sql": {Data: []byte("CREATE TABLE b ();")},
+		"003_c.up.sql": {Data: []byte("CREATE TABLE c ();")},
+	})
+	require.NoError(t, err)
+	record := func(migration Migration) Record {
+		return Record{Version: migration.Version, Name: migration.Name, Checksum: migration.Checksum, AppliedAt: time.Now()}
+	}
+
+	pending, err := Pending(all, nil)
+	require.NoError(t, err)
+	assert.Len(t, pending, 3)
+
+	// A migration merged after a later one was applied still runs
+	pending, err = Pending(all, []Record{record(all[0]), record(all[2])})
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, "002_b", pending[0].String())
+
+	edited := record(all[1])
+	edited.Checksum = "edited"
+	_, err = Pending(all, []Record{record(all[0]), edited})
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	statuses := Statuses(all, []Record{record(all[0]), edited})
+	assert.True(t, statuses[1].Modified)
+	assert.Nil(t, statuses[2].AppliedAt)
+
+	unknown := Record{Version: 4, Name: "d", Checksum: "x"}
+	_, err = Pending(all, []Record{unknown})
+	assert.ErrorIs(t, err, ErrUnknownVersion)
+	statuses = Statuses(all, []Record{unknown})
+	require.Len(t, statuses, 4)
+	assert.True(t, statuses[3].Missing)
+	assert.Equal(t, "004_d", statuses[3].Migration.String())
+}
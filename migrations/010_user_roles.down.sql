@@ -0,0 +1,9 @@
+-- Users go back to a single role, the oldest one they hold. Users without any role keep
+-- none, so the column stays nullable.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role_id INTEGER;
+
+UPDATE users SET role_id = (SELECT MIN(role_id) FROM user_roles WHERE user_roles.user_id = users.id);
+
+ALTER TABLE users ADD CONSTRAINT fk_users_roles FOREIGN KEY (role_id) REFERENCES roles(id);
+
+DROP TABLE IF EXISTS user_roles;
@@ -0,0 +1,13 @@
+-- Roles can inherit the permissions of parent roles, e.g. manager inherits user.
+-- The application rejects links that would make a role its own ancestor.
+CREATE TABLE IF NOT EXISTS role_parents (
+    role_id INTEGER NOT NULL,
+    parent_id INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (role_id, parent_id),
+    FOREIGN KEY (role_id) REFERENCES roles(id) ON DELETE CASCADE,
+    FOREIGN KEY (parent_id) REFERENCES roles(id) ON DELETE CASCADE,
+    CHECK (role_id <> parent_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_role_parents_parent_id ON role_parents (parent_id);
@@ -0,0 +1,6 @@
+UPDATE permissions SET name = 'read:users'
+WHERE name = 'users:read' AND NOT EXISTS (SELECT 1 FROM permissions WHERE name = 'read:users');
+UPDATE permissions SET name = 'write:users'
+WHERE name = 'users:write' AND NOT EXISTS (SELECT 1 FROM permissions WHERE name = 'write:users');
+UPDATE permissions SET name = 'delete:users'
+WHERE name = 'users:delete' AND NOT EXISTS (SELECT 1 FROM permissions WHERE name = 'delete:users');
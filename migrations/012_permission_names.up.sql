@@ -0,0 +1,8 @@
+-- Permission names are resource:action so wildcards like users:* and *:read can cover them.
+-- A name already taken by a permission created by hand keeps the old permission as it is.
+UPDATE permissions SET name = 'users:read'
+WHERE name = 'read:users' AND NOT EXISTS (SELECT 1 FROM permissions WHERE name = 'users:read');
+UPDATE permissions SET name = 'users:write'
+WHERE name = 'write:users' AND NOT EXISTS (SELECT 1 FROM permissions WHERE name = 'users:write');
+UPDATE permissions SET name = 'users:delete'
+WHERE name = 'delete:users' AND NOT EXISTS (SELECT 1 FROM permissions WHERE name = 'users:delete');
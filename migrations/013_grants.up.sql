@@ -0,0 +1,20 @@
+-- Permissions granted to a user or a role on specific resources only, e.g. users:write on
+-- user 42, or on every record the user owns when resource_id is 'own'
+CREATE TABLE IF NOT EXISTS grants (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    permission_id INTEGER NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    role_id INTEGER REFERENCES roles(id) ON DELETE CASCADE,
+    resource_type VARCHAR(255) NOT NULL,
+    resource_id VARCHAR(255) NOT NULL,
+    CHECK ((user_id IS NULL) <> (role_id IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_grants_permission_id ON grants (permission_id);
+CREATE INDEX IF NOT EXISTS idx_grants_user_id ON grants (user_id);
+CREATE INDEX IF NOT EXISTS idx_grants_role_id ON grants (role_id);
+CREATE INDEX IF NOT EXISTS idx_grants_resource_type ON grants (resource_type);
+CREATE INDEX IF NOT EXISTS idx_grants_deleted_at ON grants (deleted_at);
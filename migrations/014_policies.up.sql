@@ -0,0 +1,12 @@
+-- Attribute-based access policies created through the API, definition holds the policy as
+-- JSON. Policies can also be loaded from files, see POLICY_FILES.
+CREATE TABLE IF NOT EXISTS policies (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    name VARCHAR(255) UNIQUE NOT NULL,
+    definition TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_policies_deleted_at ON policies (deleted_at);
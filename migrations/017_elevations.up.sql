@@ -0,0 +1,24 @@
+-- Temporary roles: requests for a role with a justification, and approved windows
+CREATE TABLE IF NOT EXISTS elevations (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    justification TEXT NOT NULL,
+    duration_minutes INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    reviewer_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    review_note TEXT,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    revoked_by_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_elevations_deleted_at ON elevations (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_elevations_user_id ON elevations (user_id);
+CREATE INDEX IF NOT EXISTS idx_elevations_role_id ON elevations (role_id);
+CREATE INDEX IF NOT EXISTS idx_elevations_status ON elevations (status);
+CREATE INDEX IF NOT EXISTS idx_elevations_expires_at ON elevations (expires_at);
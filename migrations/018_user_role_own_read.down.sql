@@ -0,0 +1,10 @@
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'user' AND p.name = 'users:read'
+ON CONFLICT DO NOTHING;
+
+DELETE FROM grants
+WHERE role_id IN (SELECT id FROM roles WHERE name = 'user')
+AND permission_id IN (SELECT id FROM permissions WHERE name = 'users:read')
+AND resource_type = 'users' AND resource_id = 'own';
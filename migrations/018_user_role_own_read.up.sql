@@ -0,0 +1,11 @@
+-- The user role granted users:read on every user, so anybody who registered could list them
+-- all. It only reads the records users own now, through a grant.
+INSERT INTO grants (permission_id, role_id, resource_type, resource_id)
+SELECT p.id, r.id, 'users', 'own'
+FROM roles r, permissions p
+WHERE r.name = 'user' AND p.name = 'users:read'
+AND EXISTS (SELECT 1 FROM role_permissions rp WHERE rp.role_id = r.id AND rp.permission_id = p.id);
+
+DELETE FROM role_permissions
+WHERE role_id IN (SELECT id FROM roles WHERE name = 'user')
+AND permission_id IN (SELECT id FROM permissions WHERE name = 'users:read');
@@ -0,0 +1,10 @@
+// Package migrations embeds the versioned SQL migrations of the schema, applied by package
+// migrate. Each version has an up file and a down file that undoes it.
+package migrations
+
+import "embed"
+
+// Files holds every NNN_name.up.sql and NNN_name.down.sql file of this directory
+//
+//go:embed *.sql
+var Files embed.FS
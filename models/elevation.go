@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Statuses of an elevation
+const (
+	ElevationPending  = "pending"
+	ElevationApproved = "approved"
+	ElevationDenied   = "denied"
+	ElevationRevoked  = "revoked"
+	ElevationExpired  = "expired"
+)
+
+// Elevation gives a user a role for a bounded window instead of for good. The user requests
+// it with a justification and an approver approves or denies it, or an approver grants it
+// right away. An approved elevation applies from ReviewedAt until ExpiresAt, unless it is
+// revoked before.
+type Elevation struct {
+	gorm.Model
+	UserID          uint       `gorm:"index;not null" json:"user_id"`
+	User            *User      `gorm:"constraint:OnDelete:CASCADE" json:"user,omitempty"`
+	RoleID          uint       `gorm:"index;not null" json:"role_id"`
+	Role            Role       `gorm:"constraint:OnDelete:CASCADE" json:"role"`
+	Justification   string     `gorm:"type:text;not null" json:"justification"`
+	DurationMinutes int        `gorm:"not null" json:"duration_minutes"`
+	Status          string     `gorm:"index;not null" json:"status"`
+	ReviewerID      *uint      `json:"reviewer_id,omitempty"`
+	ReviewedAt      *time.Time `json:"reviewed_at,omitempty"`
+	ReviewNote      string     `json:"review_note,omitempty"`
+	ExpiresAt       *time.Time `gorm:"index" json:"expires_at,omitempty"`
+	RevokedByID     *uint      `json:"revoked_by_id,omitempty"`
+	RevokedAt       *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Duration is the length of the window the elevation asks for or was approved for
+func (e *Elevation) Duration() time.Duration {
+	return time.Duration(e.DurationMinutes) * time.Minute
+}
+
+// IsActive reports whether the elevation grants its role at now
+func (e *Elevation) IsActive(now time.Time) bool {
+	return e.Status == ElevationApproved && e.ExpiresAt != nil && e.ExpiresAt.After(now)
+}
+
+// WithElevations returns a copy of the user that also has the roles of the elevations,
+// which must be loaded like the user's roles
+func (u *User) WithElevations(elevations []Elevation) *User {
+	elevated := *u
+	elevated.Roles = append([]Role(nil), u.Roles...)
+	for _, elevation := range elevations {
+		if !elevated.hasRoleID(elevation.RoleID) {
+			elevated.Roles = append(elevated.Roles, elevation.Role)
+		}
+	}
+	return &elevated
+}
+
+func (u *User) hasRoleID(id uint) bool {
+	for _, role := range u.Roles {
+		if role.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EmailChangeToken is a pending change of a user's email to NewEmail, redeemed from a link
+// sent to NewEmail. Only the SHA-256 hash of the token is stored.
+type EmailChangeToken struct {
+	gorm.Model
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	NewEmail  string     `gorm:"not null" json:"new_email"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
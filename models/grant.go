@@ -0,0 +1,90 @@
+package models
+
+import (
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// Resource types of user records and organizations
+const (
+	ResourceUsers         = "users"
+	ResourceOrganizations = "organizations"
+)
+
+// ResourceOwn as Grant.ResourceID covers every resource of the type that the user owns,
+// e.g. their own user record
+const ResourceOwn = "own"
+
+// Grant gives a user or a role one permission on specific resources only, while the
+// permissions of a role apply everywhere. Exactly one of UserID and RoleID is set.
+type Grant struct {
+	gorm.Model
+	PermissionID uint       `gorm:"index;not null" json:"permission_id"`
+	Permission   Permission `gorm:"constraint:OnDelete:CASCADE" json:"permission"`
+	UserID       *uint      `gorm:"index" json:"user_id,omitempty"`
+	User         *User      `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	RoleID       *uint      `gorm:"index" json:"role_id,omitempty"`
+	Role         *Role      `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	ResourceType string     `gorm:"index;not null" json:"resource_type"`
+	// ResourceID is the ID of one resource, or ResourceOwn
+	ResourceID string `gorm:"not null" json:"resource_id"`
+}
+
+// Resource is the target of an authorization check
+type Resource struct {
+	Type string
+	ID   string
+	// OwnerID is the user the resource belongs to, zero when it belongs to nobody
+	OwnerID uint
+	// Within lists the resources containing this one, grants on them cover it too,
+	// e.g. the organization of a user
+	Within []Resource
+}
+
+// UserResource is the record of the user with the given ID, owned by that user
+func UserResource(id uint) Resource {
+	return Resource{Type: ResourceUsers, ID: strconv.FormatUint(uint64(id), 10), OwnerID: id}
+}
+
+// OrganizationResource is the organization with the given ID
+func OrganizationResource(id uint) Resource {
+	return Resource{Type: ResourceOrganizations, ID: strconv.FormatUint(uint64(id), 10)}
+}
+
+// Types returns the resource type and the types of the resources containing it, each once
+func (r Resource) Types() []string {
+	seen := make(map[string]bool)
+	var types []string
+	var collect func(resource Resource)
+	collect = func(resource Resource) {
+		if !seen[resource.Type] {
+			seen[resource.Type] = true
+			types = append(types, resource.Type)
+		}
+		for _, container := range resource.Within {
+			collect(container)
+		}
+	}
+	collect(r)
+	return types
+}
+
+// Covers reports whether the grant's scope includes the resource or one containing it,
+// checked on behalf of userID
+func (g *Grant) Covers(resource Resource, userID uint) bool {
+	if g.ResourceType == resource.Type {
+		if g.ResourceID == resource.ID {
+			return true
+		}
+		if g.ResourceID == ResourceOwn && resource.OwnerID != 0 && resource.OwnerID == userID {
+			return true
+		}
+	}
+	for _, container := range resource.Within {
+		if g.Covers(container, userID) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrantCovers(t *testing.T) {
+	organization := Resource{Type: "organizations", ID: "7"}
+	user := UserResource(42)
+	user.Within = []Resource{organization}
+
+	tests := []struct {
+		name     string
+		grant    Grant
+		resource Resource
+		userID   uint
+		want     bool
+	}{
+		{"same resource", Grant{ResourceType: ResourceUsers, ResourceID: "42"}, user, 1, true},
+		{"other resource", Grant{ResourceType: ResourceUsers, ResourceID: "43"}, user, 1, false},
+		{"other type with the same ID", Grant{ResourceType: "reports", ResourceID: "42"}, user, 1, false},
+		{"own record", Grant{ResourceType: ResourceUsers, ResourceID: ResourceOwn}, user, 42, true},
+		{"someone else's record", Grant{ResourceType: ResourceUsers, ResourceID: ResourceOwn}, user, 1, false},
+		{"unowned resource", Grant{ResourceType: "organizations", ResourceID: ResourceOwn}, organization, 0, false},
+		{"containing resource", Grant{ResourceType: "organizations", ResourceID: "7"}, user, 1, true},
+		{"other container", Grant{ResourceType: "organizations", ResourceID: "8"}, user, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.grant.Covers(tt.resource, tt.userID))
+		})
+	}
+
+	assert.Equal(t, []string{ResourceUsers, "organizations"}, user.Types())
+}
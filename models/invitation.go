@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Invitation asks the owner of Email to join an organization with a role. It is accepted
+// from a link sent to Email, only the SHA-256 hash of its token is stored.
+type Invitation struct {
+	gorm.Model
+	OrganizationID uint          `gorm:"index;not null" json:"organization_id"`
+	Organization   *Organization `gorm:"constraint:OnDelete:CASCADE" json:"organization,omitempty"`
+	Email          string        `gorm:"index;not null" json:"email"`
+	RoleID         uint          `gorm:"not null" json:"role_id"`
+	Role           Role          `json:"role"`
+	InvitedByID    *uint         `json:"invited_by_id,omitempty"`
+	TokenHash      string        `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt      time.Time     `gorm:"not null" json:"expires_at"`
+	AcceptedAt     *time.Time    `json:"accepted_at,omitempty"`
+	RevokedAt      *time.Time    `json:"revoked_at,omitempty"`
+}
+
+// IsPending reports whether the invitation can still be accepted at now
+func (i *Invitation) IsPending(now time.Time) bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil && i.ExpiresAt.After(now)
+}
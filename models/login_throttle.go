@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// LoginThrottle counts recent failed logins for a key such as "email:alice@example.com" or "ip:203.0.113.7".
+// Rows are deleted on successful login or unlock, so there is no soft delete.
+type LoginThrottle struct {
+	ID            uint       `gorm:"primarykey" json:"id"`
+	Key           string     `gorm:"uniqueIndex;not null" json:"key"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `gorm:"not null" json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
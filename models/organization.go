@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Organization is a customer company the service runs for, a tenant. Users belong to
+// organizations through memberships.
+type Organization struct {
+	gorm.Model
+	Name        string `gorm:"unique;not null" json:"name"`
+	Description string `json:"description"`
+}
+
+// Membership puts a user in an organization with one role. The role applies only while
+// the organization is the active one of the user's access token, next to the user's own
+// roles that apply everywhere.
+type Membership struct {
+	ID             uint          `gorm:"primarykey" json:"id"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+	OrganizationID uint          `gorm:"uniqueIndex:idx_memberships_organization_user;not null" json:"organization_id"`
+	Organization   *Organization `gorm:"constraint:OnDelete:CASCADE" json:"organization,omitempty"`
+	UserID         uint          `gorm:"uniqueIndex:idx_memberships_organization_user;index;not null" json:"user_id"`
+	User           *User         `gorm:"constraint:OnDelete:CASCADE" json:"user,omitempty"`
+	RoleID         uint          `gorm:"index;not null" json:"role_id"`
+	Role           Role          `json:"role"`
+}
+
+// WithMembership returns a copy of the user that also has the role of membership, the
+// user of a token whose active organization is the one of membership. m.Role must be
+// loaded like the user's roles.
+func (u *User) WithMembership(m *Membership) *User {
+	member := *u
+	member.Roles = append([]Role(nil), u.Roles...)
+	for _, role := range u.Roles {
+		if role.ID == m.RoleID {
+			return &member
+		}
+	}
+	member.Roles = append(member.Roles, m.Role)
+	return &member
+}
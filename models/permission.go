@@ -1,9 +1,66 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
 
 type Permission struct {
 	gorm.Model
 	Name        string `gorm:"unique;not null" json:"name"`
 	Description string `json:"description"`
-} 
\ No newline at end of file
+}
+
+// PermissionAdmin guards the admin routes
+const PermissionAdmin = "admin"
+
+// PermissionWildcard is the segment that matches any segment of a permission name
+const PermissionWildcard = "*"
+
+var permissionNamePattern = regexp.MustCompile(`^([A-Za-z0-9_.-]+|\*)(:([A-Za-z0-9_.-]+|\*))*$`)
+
+// IsBuiltIn reports whether the routes depend on the permission, so it must keep its name
+func (p *Permission) IsBuiltIn() bool {
+	return p.Name == PermissionAdmin
+}
+
+// Grants reports whether holding the permission satisfies a check for name, see MatchPermission
+func (p *Permission) Grants(name string) bool {
+	return MatchPermission(p.Name, name)
+}
+
+// ValidPermissionName reports whether name follows the permission grammar: segments of
+// letters, digits, '_', '.' or '-', or a lone '*', separated by colons
+func ValidPermissionName(name string) bool {
+	return permissionNamePattern.MatchString(name)
+}
+
+// MatchPermission reports whether the granted permission covers the required one.
+// Names are resource:action, e.g. users:read. A '*' segment in granted matches any one
+// segment, so *:read covers users:read. A trailing '*' matches every remaining segment,
+// so users:* covers users:read and users:roles:write, and * alone covers everything.
+// Other segments must be equal.
+func MatchPermission(granted, required string) bool {
+	if granted == required {
+		return true
+	}
+	grantedSegments := strings.Split(granted, ":")
+	requiredSegments := strings.Split(required, ":")
+	for i, segment := range grantedSegments {
+		if i == len(requiredSegments) {
+			return false
+		}
+		if segment == PermissionWildcard {
+			if i == len(grantedSegments)-1 {
+				return true
+			}
+			continue
+		}
+		if segment != requiredSegments[i] {
+			return false
+		}
+	}
+	return len(grantedSegments) == len(requiredSegments)
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestMatchPermission(t *testing.T) {
+	tests := []struct {
+		granted  string
+		required string
+		want     bool
+	}{
+		{"users:read", "users:read", true},
+		{"users:read", "users:write", false},
+		{"users:read", "reports:read", false},
+		{"admin", "admin", true},
+		{"admin", "users:read", false},
+
+		// A trailing wildcard covers every remaining segment
+		{"users:*", "users:read", true},
+		{"users:*", "users:roles:write", true},
+		{"users:*", "users", false},
+		{"users:*", "reports:read", false},
+		{"*", "users:read", true},
+		{"*", "admin", true},
+
+		// An inner wildcard covers exactly one segment
+		{"*:read", "users:read", true},
+		{"*:read", "reports:read", true},
+		{"*:read", "users:write", false},
+		{"*:read", "users:roles:read", false},
+		{"users:*:write", "users:roles:write", true},
+		{"users:*:write", "users:roles:read", false},
+
+		// Longer grants never cover shorter requirements
+		{"users:read:own", "users:read", false},
+		{"users:read", "users:read:own", false},
+
+		// Wildcards in the requirement match themselves only
+		{"users:read", "users:*", false},
+		{"users:*", "users:*", true},
+		{"", "users:read", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, MatchPermission(tt.granted, tt.required), "%q covers %q", tt.granted, tt.required)
+	}
+}
+
+func TestValidPermissionName(t *testing.T) {
+	for _, name := range []string{"admin", "users:read", "users:*", "*:read", "*", "reports.v2:export-csv", "users:roles:write"} {
+		assert.True(t, ValidPermissionName(name), name)
+	}
+	for _, name := range []string{"", ":", "users:", ":read", "users::read", "users:re*d", "**", "users read", "users:read "} {
+		assert.False(t, ValidPermissionName(name), name)
+	}
+}
+
+func TestHasPermission(t *testing.T) {
+	base := Role{Model: gorm.Model{ID: 1}, Permissions: []Permission{{Name: "*:read"}}}
+	editor := Role{Model: gorm.Model{ID: 2}, Permissions: []Permission{{Name: "reports:*"}}, Parents: []Role{base}}
+
+	roles := []Role{editor}
+	assert.True(t, HasPermission(roles, "reports:export"))
+	assert.True(t, HasPermission(roles, "users:read"), "inherited wildcard")
+	assert.False(t, HasPermission(roles, "users:write"))
+	assert.False(t, HasPermission(nil, "users:read"))
+}
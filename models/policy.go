@@ -0,0 +1,11 @@
+package models
+
+import "gorm.io/gorm"
+
+// Policy is an attribute-based access policy managed through the API, next to the ones
+// loaded from files. Definition is the policy.Policy as JSON, Name is repeated in it.
+type Policy struct {
+	gorm.Model
+	Name       string `gorm:"unique;not null" json:"name"`
+	Definition string `gorm:"type:text;not null" json:"-"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken is an opaque, long-lived token that can be exchanged for a new access token.
+// Only the SHA-256 hash of the token is stored. Tokens minted from the same login share a
+// FamilyID so the whole chain can be revoked when a rotated token is presented again.
+type RefreshToken struct {
+	gorm.Model
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	FamilyID  string     `gorm:"index;not null" json:"family_id"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	// MFA records whether the login that started the family passed a second factor
+	MFA bool `gorm:"not null;default:false" json:"mfa"`
+	// OrganizationID is the active organization of the session, nil when it has none
+	OrganizationID *uint `json:"organization_id,omitempty"`
+}
+
+// IsActive reports whether the token can still be exchanged
+func (t *RefreshToken) IsActive(now time.Time) bool {
+	return t.RotatedAt == nil && t.RevokedAt == nil && now.Before(t.ExpiresAt)
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RevokedToken records the jti of an access token that was invalidated before it expired.
+// Rows can be purged once ExpiresAt has passed since the token is rejected anyway.
+type RevokedToken struct {
+	gorm.Model
+	JTI       string    `gorm:"column:jti;uniqueIndex;not null" json:"jti"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	ExpiresAt time.Time `gorm:"index;not null" json:"expires_at"`
+}
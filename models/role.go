@@ -7,4 +7,105 @@ type Role struct {
 	Name        string       `gorm:"unique;not null" json:"name"`
 	Description string       `json:"description"`
 	Permissions []Permission `gorm:"many2many:role_permissions;" json:"permissions"`
-} 
\ No newline at end of file
+	// Parents are the roles this role inherits permissions from. Repositories that load
+	// permissions load the whole hierarchy, each parent with its own Parents.
+	Parents []Role `gorm:"many2many:role_parents;joinForeignKey:RoleID;joinReferences:ParentID" json:"parents"`
+}
+
+// Roles of the default seed file, see seed.Default, new users get RoleUser
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// IsBuiltIn reports whether the role is seeded by default and must keep its name
+func (r *Role) IsBuiltIn() bool {
+	return r.Name == RoleAdmin || r.Name == RoleUser
+}
+
+// Lineage returns the role followed by its loaded ancestors, nearest first, each role once
+func (r *Role) Lineage() []*Role {
+	seen := map[uint]bool{r.ID: true}
+	lineage := []*Role{r}
+	for i := 0; i < len(lineage); i++ {
+		for j := range lineage[i].Parents {
+			parent := &lineage[i].Parents[j]
+			if !seen[parent.ID] {
+				seen[parent.ID] = true
+				lineage = append(lineage, parent)
+			}
+		}
+	}
+	return lineage
+}
+
+// IsDescendantOf reports whether id is one of the role's loaded ancestors
+func (r *Role) IsDescendantOf(id uint) bool {
+	for _, ancestor := range r.Lineage()[1:] {
+		if ancestor.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// GrantedPermission is a permission of a role and the role that grants it, the role
+// itself or the nearest ancestor that has it
+type GrantedPermission struct {
+	Permission Permission `json:"permission"`
+	RoleID     uint       `json:"granted_by_id"`
+	RoleName   string     `json:"granted_by"`
+	Inherited  bool       `json:"inherited"`
+}
+
+// GrantedPermissions returns the permissions of the role and its loaded ancestors, each once
+func (r *Role) GrantedPermissions() []GrantedPermission {
+	seen := make(map[uint]bool)
+	granted := []GrantedPermission{}
+	for _, role := range r.Lineage() {
+		for _, permission := range role.Permissions {
+			if seen[permission.ID] {
+				continue
+			}
+			seen[permission.ID] = true
+			granted = append(granted, GrantedPermission{
+				Permission: permission,
+				RoleID:     role.ID,
+				RoleName:   role.Name,
+				Inherited:  role != r,
+			})
+		}
+	}
+	return granted
+}
+
+// EffectivePermissions returns the union of the permissions of roles and their loaded
+// ancestors, each permission once
+func EffectivePermissions(roles []Role) []Permission {
+	seen := make(map[uint]bool)
+	var permissions []Permission
+	for i := range roles {
+		for _, granted := range roles[i].GrantedPermissions() {
+			if !seen[granted.Permission.ID] {
+				seen[granted.Permission.ID] = true
+				permissions = append(permissions, granted.Permission)
+			}
+		}
+	}
+	return permissions
+}
+
+// HasPermission reports whether any of roles or their loaded ancestors grants the
+// permission, directly or through a wildcard
+func HasPermission(roles []Role, name string) bool {
+	for i := range roles {
+		for _, role := range roles[i].Lineage() {
+			for _, permission := range role.Permissions {
+				if permission.Grants(name) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -1,8 +1,10 @@
 package models
 
 import (
-	"gorm.io/gorm"
+	"time"
+
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type User struct {
@@ -11,22 +13,67 @@ type User struct {
 	Password  string `json:"-"`
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
-	RoleID    uint   `json:"role_id"`
-	Role      Role   `json:"role"`
+	// Roles are granted through user_roles, the user has the permissions of all of them
+	Roles []Role `gorm:"many2many:user_roles;" json:"roles"`
+	// EmailVerifiedAt is set once the user confirmed they own Email
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// MFAEnabledAt is set once TOTP enrollment was confirmed with a valid code
+	MFAEnabledAt *time.Time `json:"mfa_enabled_at"`
+	TOTPSecret   string     `json:"-"`
+	// TOTPLastStep is the last time step a code was accepted for, so a code cannot be replayed
+	TOTPLastStep int64 `json:"-"`
+	// TokensRevokedAt invalidates every access token issued at or before it (logout everywhere)
+	TokensRevokedAt *time.Time `json:"-"`
+	// SuspendedAt is set while an admin suspended the account, suspended users cannot log in
+	SuspendedAt      *time.Time `json:"suspended_at"`
+	SuspensionReason string     `json:"suspension_reason,omitempty"`
 }
 
-func (u *User) BeforeSave(tx *gorm.DB) error {
-	if u.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
-		if err != nil {
-			return err
-		}
-		u.Password = string(hashedPassword)
+// HashPassword returns the bcrypt hash of a plain text password. User.Password is saved as
+// is, so whoever sets a password hashes it first.
+func HashPassword(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
 	}
-	return nil
+	return string(hashedPassword), nil
+}
+
+// IsEmailVerified reports whether the user confirmed their email address
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// IsMFAEnabled reports whether the user completed TOTP enrollment
+func (u *User) IsMFAEnabled() bool {
+	return u.MFAEnabledAt != nil
+}
+
+// IsSuspended reports whether an admin suspended the account
+func (u *User) IsSuspended() bool {
+	return u.SuspendedAt != nil
+}
+
+// RoleIDs returns the IDs of the user's roles
+func (u *User) RoleIDs() []uint {
+	ids := make([]uint, 0, len(u.Roles))
+	for _, role := range u.Roles {
+		ids = append(ids, role.ID)
+	}
+	return ids
+}
+
+// Permissions returns the union of the permissions of the user's roles, Roles.Permissions must be loaded
+func (u *User) Permissions() []Permission {
+	return EffectivePermissions(u.Roles)
+}
+
+// HasPermission reports whether any of the user's roles grants the permission, Roles.Permissions must be loaded
+func (u *User) HasPermission(name string) bool {
+	return HasPermission(u.Roles, name)
 }
 
 func (u *User) CheckPassword(password string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
 	return err == nil
-} 
\ No newline at end of file
+}
@@ -0,0 +1,355 @@
+package policy
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+)
+
+// Attributes are the named values of a subject, a resource or a request context.
+// Nested maps are reached with dotted paths
+type Attributes map[string]interface{}
+
+// Request is what an engine decides on: may Subject perform Action on Resource
+type Request struct {
+	Action   string     `json:"action" yaml:"action"`
+	Subject  Attributes `json:"subject,omitempty" yaml:"subject,omitempty"`
+	Resource Attributes `json:"resource,omitempty" yaml:"resource,omitempty"`
+	Context  Attributes `json:"context,omitempty" yaml:"context,omitempty"`
+}
+
+// Decision is the outcome of an evaluation and the policy that decided it
+type Decision struct {
+	Effect Effect `json:"effect"`
+	Policy string `json:"policy,omitempty"`
+}
+
+// Allowed reports whether a policy explicitly allowed the request
+func (d Decision) Allowed() bool {
+	return d.Effect == Allow
+}
+
+// Engine evaluates a set of policies. A deny wins over an allow, and a request no
+// policy applies to is NotApplicable. It is safe for concurrent use
+type Engine struct {
+	mu       sync.RWMutex
+	policies []Policy
+	// Location is the time zone of context.time, UTC when nil
+	Location *time.Location
+	// Now is the clock behind context.time, time.Now when nil
+	Now func() time.Time
+}
+
+// NewEngine returns an engine evaluating the given policies
+func NewEngine(policies []Policy) (*Engine, error) {
+	e := &Engine{}
+	if err := e.Replace(policies); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Replace validates the policies and swaps them in for the current ones
+func (e *Engine) Replace(policies []Policy) error {
+	names := make(map[string]bool, len(policies))
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			return err
+		}
+		if names[policies[i].Name] {
+			return fmt.Errorf("policy %s is defined twice", policies[i].Name)
+		}
+		names[policies[i].Name] = true
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.policies = append([]Policy(nil), policies...)
+	return nil
+}
+
+// Policies returns the policies the engine evaluates
+func (e *Engine) Policies() []Policy {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append([]Policy(nil), e.policies...)
+}
+
+// Applies reports whether any policy covers the action, so callers can skip
+// loading resource attributes nothing would look at
+func (e *Engine) Applies(action string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for i := range e.policies {
+		if e.policies[i].matchesAction(action) {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate decides the request
+func (e *Engine) Evaluate(req Request) Decision {
+	req.Context = e.context(req.Context)
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	decision := Decision{Effect: NotApplicable}
+	for i := range e.policies {
+		p := &e.policies[i]
+		if !p.applies(req) {
+			continue
+		}
+		if p.Effect == Deny {
+			return Decision{Effect: Deny, Policy: p.Name}
+		}
+		if decision.Effect == NotApplicable {
+			decision = Decision{Effect: Allow, Policy: p.Name}
+		}
+	}
+	return decision
+}
+
+// context fills context.time unless the caller set it
+func (e *Engine) context(ctx Attributes) Attributes {
+	if _, ok := ctx["time"]; ok {
+		return ctx
+	}
+	now := time.Now
+	if e.Now != nil {
+		now = e.Now
+	}
+	location := time.UTC
+	if e.Location != nil {
+		location = e.Location
+	}
+	t := now().In(location)
+
+	filled := make(Attributes, len(ctx)+1)
+	for key, value := range ctx {
+		filled[key] = value
+	}
+	filled["time"] = map[string]interface{}{
+		"hour":    t.Hour(),
+		"minute":  t.Minute(),
+		"weekday": strings.ToLower(t.Weekday().String()),
+		"date":    t.Format("2006-01-02"),
+	}
+	return filled
+}
+
+func (p *Policy) matchesAction(action string) bool {
+	for _, granted := range p.Actions {
+		if models.MatchPermission(granted, action) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) applies(req Request) bool {
+	if !p.matchesAction(req.Action) {
+		return false
+	}
+	if len(p.Resources) > 0 {
+		resourceType, _ := req.Resource["type"].(string)
+		matched := false
+		for _, t := range p.Resources {
+			if t == resourceType || t == models.PermissionWildcard {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for i := range p.Conditions {
+		if !p.Conditions[i].holds(req) {
+			return false
+		}
+	}
+	return true
+}
+
+// lookup resolves a path such as subject.roles in the request
+func lookup(req Request, path string) (interface{}, bool) {
+	segments := strings.Split(path, ".")
+	var current interface{}
+	switch segments[0] {
+	case "subject":
+		current = map[string]interface{}(req.Subject)
+	case "resource":
+		current = map[string]interface{}(req.Resource)
+	case "context":
+		current = map[string]interface{}(req.Context)
+	default:
+		return nil, false
+	}
+	for _, segment := range segments[1:] {
+		value, ok := field(current, segment)
+		if !ok {
+			return nil, false
+		}
+		current = value
+	}
+	return current, current != nil
+}
+
+func field(value interface{}, name string) (interface{}, bool) {
+	switch m := value.(type) {
+	case Attributes:
+		v, ok := m[name]
+		return v, ok
+	case map[string]interface{}:
+		v, ok := m[name]
+		return v, ok
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String {
+		found := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		if found.IsValid() {
+			return found.Interface(), true
+		}
+	}
+	return nil, false
+}
+
+func (c *Condition) holds(req Request) bool {
+	actual, found := lookup(req, c.Attribute)
+	if c.Operator == OpExists {
+		want, ok := c.Value.(bool)
+		if !ok {
+			want = true
+		}
+		return found == want
+	}
+
+	expected := c.Value
+	if c.ValueFrom != "" {
+		var ok bool
+		if expected, ok = lookup(req, c.ValueFrom); !ok {
+			return false
+		}
+	}
+	if !found {
+		// A missing attribute only satisfies the negated operators
+		return c.Operator == OpNotEqual || c.Operator == OpNotIn || c.Operator == OpNotContains
+	}
+
+	switch c.Operator {
+	case OpEqual:
+		return equal(actual, expected)
+	case OpNotEqual:
+		return !equal(actual, expected)
+	case OpIn:
+		return contains(expected, actual)
+	case OpNotIn:
+		return !contains(expected, actual)
+	case OpContains:
+		return contains(actual, expected)
+	case OpNotContains:
+		return !contains(actual, expected)
+	case OpGreater, OpGreaterOrEq, OpLess, OpLessOrEq:
+		return compare(c.Operator, actual, expected)
+	}
+	return false
+}
+
+func isList(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	kind := reflect.TypeOf(value).Kind()
+	return kind == reflect.Slice || kind == reflect.Array
+}
+
+// contains reports whether the list holds the value, or the string holds the substring
+func contains(list, value interface{}) bool {
+	if s, ok := list.(string); ok {
+		sub, ok := value.(string)
+		return ok && strings.Contains(s, sub)
+	}
+	if !isList(list) {
+		return false
+	}
+	v := reflect.ValueOf(list)
+	for i := 0; i < v.Len(); i++ {
+		if equal(v.Index(i).Interface(), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// equal compares numbers by value whatever their type, everything else with ==
+func equal(a, b interface{}) bool {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		return ok && x == y
+	}
+	if isList(a) || isList(b) {
+		return reflect.DeepEqual(a, b)
+	}
+	return a == b
+}
+
+func compare(operator string, a, b interface{}) bool {
+	x, ok := number(a)
+	if !ok {
+		return compareStrings(operator, a, b)
+	}
+	y, ok := number(b)
+	if !ok {
+		return false
+	}
+	switch operator {
+	case OpGreater:
+		return x > y
+	case OpGreaterOrEq:
+		return x >= y
+	case OpLess:
+		return x < y
+	}
+	return x <= y
+}
+
+// compareStrings orders strings, which suits ISO dates such as context.time.date
+func compareStrings(operator string, a, b interface{}) bool {
+	x, ok := a.(string)
+	if !ok {
+		return false
+	}
+	y, ok := b.(string)
+	if !ok {
+		return false
+	}
+	switch operator {
+	case OpGreater:
+		return x > y
+	case OpGreaterOrEq:
+		return x >= y
+	case OpLess:
+		return x < y
+	}
+	return x <= y
+}
+
+func number(value interface{}) (float64, bool) {
+	if value == nil {
+		return 0, false
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
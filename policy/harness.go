@@ -0,0 +1,66 @@
+package policy
+
+import (
+	"fmt"
+	"time"
+)
+
+// TestCase is a request and the effect the policies should decide for it
+type TestCase struct {
+	Name    string  `json:"name" yaml:"name"`
+	Request Request `json:"request" yaml:"request"`
+	Expect  Effect  `json:"expect" yaml:"expect"`
+	// Policy optionally names the policy that should decide the request
+	Policy string `json:"policy,omitempty" yaml:"policy,omitempty"`
+}
+
+// Failure is a test case whose decision differs from the expected one
+type Failure struct {
+	Test     string
+	Decision Decision
+	Err      error
+}
+
+func (f Failure) Error() string {
+	return fmt.Sprintf("%s: %v", f.Test, f.Err)
+}
+
+// Suite runs test cases against policies, so policy files can be unit tested:
+//
+//	file, _ := policy.LoadFile("policies/example.yaml")
+//	for _, failure := range policy.Suite{Policies: file.Policies, Tests: file.Tests}.Run() {
+//		t.Error(failure)
+//	}
+type Suite struct {
+	Policies []Policy
+	Tests    []TestCase
+	// Now fixes context.time for requests that do not set it, time.Now when nil
+	Now func() time.Time
+}
+
+// Run evaluates every test case and returns the failing ones
+func (s Suite) Run() []Failure {
+	engine, err := NewEngine(s.Policies)
+	if err != nil {
+		return []Failure{{Test: "policies", Err: err}}
+	}
+	engine.Now = s.Now
+
+	var failures []Failure
+	for i, test := range s.Tests {
+		name := test.Name
+		if name == "" {
+			name = fmt.Sprintf("test %d", i+1)
+		}
+		decision := engine.Evaluate(test.Request)
+		switch {
+		case decision.Effect != test.Expect:
+			failures = append(failures, Failure{Test: name, Decision: decision,
+				Err: fmt.Errorf("expected %s, got %s (policy %q)", test.Expect, decision.Effect, decision.Policy)})
+		case test.Policy != "" && decision.Policy != test.Policy:
+			failures = append(failures, Failure{Test: name, Decision: decision,
+				Err: fmt.Errorf("expected policy %q to decide, got %q", test.Policy, decision.Policy)})
+		}
+	}
+	return failures
+}
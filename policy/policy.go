@@ -0,0 +1,170 @@
+// Package policy evaluates declarative attribute-based access policies. A policy allows
+// or denies actions on resources when all of its conditions hold over the attributes of
+// the subject (the caller), the resource and the request context. Policies are written
+// in YAML or JSON, see Parse, and can be unit tested with a Suite.
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Effect is what a policy does when it applies, and the outcome of an evaluation
+type Effect string
+
+const (
+	Allow Effect = "allow"
+	Deny  Effect = "deny"
+	// NotApplicable is the outcome when no policy applies to the request
+	NotApplicable Effect = "not_applicable"
+)
+
+// Operators a condition can use
+const (
+	OpEqual       = "eq"
+	OpNotEqual    = "ne"
+	OpIn          = "in"
+	OpNotIn       = "not_in"
+	OpContains    = "contains"
+	OpNotContains = "not_contains"
+	OpGreater     = "gt"
+	OpGreaterOrEq = "gte"
+	OpLess        = "lt"
+	OpLessOrEq    = "lte"
+	OpExists      = "exists"
+)
+
+var operators = map[string]bool{
+	OpEqual: true, OpNotEqual: true, OpIn: true, OpNotIn: true, OpContains: true, OpNotContains: true,
+	OpGreater: true, OpGreaterOrEq: true, OpLess: true, OpLessOrEq: true, OpExists: true,
+}
+
+// Policy allows or denies Actions on Resources when every condition holds
+type Policy struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Effect      Effect `json:"effect" yaml:"effect"`
+	// Actions are permission names, wildcards match like models.MatchPermission
+	Actions []string `json:"actions" yaml:"actions"`
+	// Resources are resource types, an empty list matches every type
+	Resources  []string    `json:"resources,omitempty" yaml:"resources,omitempty"`
+	Conditions []Condition `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+}
+
+// Condition compares the attribute at a path such as subject.roles or context.time.hour
+// with Value, or with the attribute at the path ValueFrom
+type Condition struct {
+	Attribute string      `json:"attribute" yaml:"attribute"`
+	Operator  string      `json:"operator" yaml:"operator"`
+	Value     interface{} `json:"value,omitempty" yaml:"value,omitempty"`
+	ValueFrom string      `json:"value_from,omitempty" yaml:"value_from,omitempty"`
+}
+
+// File is the content of a policy file: policies and, optionally, tests of them
+type File struct {
+	Policies []Policy   `json:"policies" yaml:"policies"`
+	Tests    []TestCase `json:"tests,omitempty" yaml:"tests,omitempty"`
+}
+
+// Validate reports the first problem of the policy, nil when it can be evaluated
+func (p *Policy) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("policy has no name")
+	}
+	if p.Effect != Allow && p.Effect != Deny {
+		return fmt.Errorf("policy %s: effect must be %q or %q", p.Name, Allow, Deny)
+	}
+	if len(p.Actions) == 0 {
+		return fmt.Errorf("policy %s: no actions", p.Name)
+	}
+	for i, condition := range p.Conditions {
+		if err := condition.validate(); err != nil {
+			return fmt.Errorf("policy %s: condition %d: %w", p.Name, i+1, err)
+		}
+	}
+	return nil
+}
+
+func (c *Condition) validate() error {
+	if !validPath(c.Attribute) {
+		return fmt.Errorf("attribute %q must start with subject., resource. or context.", c.Attribute)
+	}
+	if !operators[c.Operator] {
+		return fmt.Errorf("unknown operator %q", c.Operator)
+	}
+	if c.Operator == OpExists {
+		return nil
+	}
+	if c.ValueFrom != "" {
+		if c.Value != nil {
+			return fmt.Errorf("value and value_from are exclusive")
+		}
+		if !validPath(c.ValueFrom) {
+			return fmt.Errorf("value_from %q must start with subject., resource. or context.", c.ValueFrom)
+		}
+		return nil
+	}
+	if c.Value == nil {
+		return fmt.Errorf("operator %q needs a value or value_from", c.Operator)
+	}
+	if (c.Operator == OpIn || c.Operator == OpNotIn) && !isList(c.Value) {
+		return fmt.Errorf("operator %q needs a list value", c.Operator)
+	}
+	return nil
+}
+
+func validPath(path string) bool {
+	root, rest, ok := strings.Cut(path, ".")
+	return ok && rest != "" && (root == "subject" || root == "resource" || root == "context")
+}
+
+// Parse reads a policy file, JSON when format is "json" and YAML otherwise, and validates its policies
+func Parse(data []byte, format string) (*File, error) {
+	var file File
+	var err error
+	if format == "json" {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range file.Policies {
+		if err := file.Policies[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &file, nil
+}
+
+// LoadFile reads a policy file, its extension tells JSON (.json) from YAML
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := Parse(data, strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), "."))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return file, nil
+}
+
+// LoadFiles reads the policies of every file, in order
+func LoadFiles(paths []string) ([]Policy, error) {
+	var policies []Policy
+	for _, path := range paths {
+		file, err := LoadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, file.Policies...)
+	}
+	return policies, nil
+}
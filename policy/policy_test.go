@@ -0,0 +1,101 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExamplePolicies(t *testing.T) {
+	file, err := LoadFile("../policies/example.yaml")
+	require.NoError(t, err)
+	require.NotEmpty(t, file.Tests)
+
+	for _, failure := range (Suite{Policies: file.Policies, Tests: file.Tests}).Run() {
+		t.Error(failure)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	policies := []Policy{
+		{Name: "owner", Effect: Allow, Actions: []string{"documents:*"}, Resources: []string{"documents"},
+			Conditions: []Condition{{Attribute: "resource.owner_id", Operator: OpEqual, ValueFrom: "subject.id"}}},
+		{Name: "senior", Effect: Allow, Actions: []string{"documents:read"},
+			Conditions: []Condition{{Attribute: "subject.level", Operator: OpGreaterOrEq, Value: 3}}},
+		{Name: "locked", Effect: Deny, Actions: []string{"documents:write"},
+			Conditions: []Condition{{Attribute: "resource.locked", Operator: OpEqual, Value: true}}},
+		{Name: "office", Effect: Deny, Actions: []string{"*"},
+			Conditions: []Condition{{Attribute: "context.ip", Operator: OpExists}, {Attribute: "context.ip", Operator: OpNotIn, Value: []interface{}{"10.0.0.1"}}}},
+	}
+
+	tests := []struct {
+		name   string
+		req    Request
+		effect Effect
+		policy string
+	}{
+		{name: "owner writes", req: Request{Action: "documents:write", Subject: Attributes{"id": 1}, Resource: Attributes{"type": "documents", "owner_id": uint(1)}}, effect: Allow, policy: "owner"},
+		{name: "owner of another resource type", req: Request{Action: "documents:write", Subject: Attributes{"id": 1}, Resource: Attributes{"type": "notes", "owner_id": 1}}, effect: NotApplicable},
+		{name: "stranger", req: Request{Action: "documents:read", Subject: Attributes{"id": 2, "level": 1}, Resource: Attributes{"type": "documents", "owner_id": 1}}, effect: NotApplicable},
+		{name: "senior reads", req: Request{Action: "documents:read", Subject: Attributes{"id": 2, "level": 3.0}, Resource: Attributes{"type": "documents", "owner_id": 1}}, effect: Allow, policy: "senior"},
+		{name: "missing attribute", req: Request{Action: "documents:read", Subject: Attributes{"id": 2}}, effect: NotApplicable},
+		{name: "deny overrides allow", req: Request{Action: "documents:write", Subject: Attributes{"id": 1}, Resource: Attributes{"type": "documents", "owner_id": 1, "locked": true}}, effect: Deny, policy: "locked"},
+		{name: "known ip", req: Request{Action: "documents:write", Subject: Attributes{"id": 1}, Resource: Attributes{"type": "documents", "owner_id": 1}, Context: Attributes{"ip": "10.0.0.1"}}, effect: Allow},
+		{name: "unknown ip", req: Request{Action: "documents:read", Context: Attributes{"ip": "192.0.2.1"}}, effect: Deny, policy: "office"},
+	}
+
+	engine, err := NewEngine(policies)
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := engine.Evaluate(tt.req)
+			assert.Equal(t, tt.effect, decision.Effect)
+			if tt.policy != "" {
+				assert.Equal(t, tt.policy, decision.Policy)
+			}
+		})
+	}
+
+	assert.True(t, engine.Applies("documents:delete"))
+	assert.False(t, (&Engine{}).Applies("documents:delete"))
+}
+
+func TestEvaluateTime(t *testing.T) {
+	engine, err := NewEngine([]Policy{{Name: "mornings", Effect: Allow, Actions: []string{"reports:read"},
+		Conditions: []Condition{{Attribute: "context.time.hour", Operator: OpLess, Value: 12}}}})
+	require.NoError(t, err)
+	engine.Location = time.FixedZone("UTC+7", 7*60*60)
+
+	engine.Now = func() time.Time { return time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC) }
+	assert.True(t, engine.Evaluate(Request{Action: "reports:read"}).Allowed())
+
+	engine.Now = func() time.Time { return time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC) }
+	assert.False(t, engine.Evaluate(Request{Action: "reports:read"}).Allowed())
+}
+
+func TestParse(t *testing.T) {
+	file, err := Parse([]byte(`{"policies":[{"name":"p","effect":"allow","actions":["users:read"]}]}`), "json")
+	require.NoError(t, err)
+	assert.Len(t, file.Policies, 1)
+
+	invalid := []string{
+		`policies: [{name: p, effect: maybe, actions: [users:read]}]`,
+		`policies: [{name: p, effect: allow}]`,
+		`policies: [{name: p, effect: allow, actions: [x], conditions: [{attribute: roles, operator: eq, value: 1}]}]`,
+		`policies: [{name: p, effect: allow, actions: [x], conditions: [{attribute: subject.roles, operator: like, value: 1}]}]`,
+		`policies: [{name: p, effect: allow, actions: [x], conditions: [{attribute: subject.roles, operator: in, value: admin}]}]`,
+		`policies: [{name: p, effect: allow, actions: [x], conditions: [{attribute: subject.roles, operator: eq}]}]`,
+	}
+	for _, data := range invalid {
+		_, err := Parse([]byte(data), "yaml")
+		assert.Error(t, err, data)
+	}
+
+	_, err = NewEngine([]Policy{
+		{Name: "p", Effect: Allow, Actions: []string{"x"}},
+		{Name: "p", Effect: Deny, Actions: []string{"x"}},
+	})
+	assert.Error(t, err)
+}
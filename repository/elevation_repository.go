@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+)
+
+type GormElevationRepository struct {
+	db *gorm.DB
+}
+
+func NewElevationRepository(db *gorm.DB) *GormElevationRepository {
+	return &GormElevationRepository{
+		db: db,
+	}
+}
+
+func (r *GormElevationRepository) Create(elevation *models.Elevation) error {
+	return r.db.Omit("User", "Role").Create(elevation).Error
+}
+
+func (r *GormElevationRepository) FindByID(id uint) (*models.Elevation, error) {
+	var elevation models.Elevation
+	err := r.db.Preload("Role").First(&elevation, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &elevation, nil
+}
+
+func (r *GormElevationRepository) List(filter ElevationFilter) ([]models.Elevation, error) {
+	query := r.db.Preload("User").Preload("Role")
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	var elevations []models.Elevation
+	err := query.Order("id").Find(&elevations).Error
+	return elevations, err
+}
+
+func (r *GormElevationRepository) ListActive(userID uint, now time.Time) ([]models.Elevation, error) {
+	var elevations []models.Elevation
+	err := r.db.Preload("Role.Permissions").
+		Where("user_id = ? AND status = ? AND expires_at > ?", userID, models.ElevationApproved, now).
+		Order("id").
+		Find(&elevations).Error
+	if err != nil {
+		return nil, err
+	}
+	roles := make([]models.Role, len(elevations))
+	for i := range elevations {
+		roles[i] = elevations[i].Role
+	}
+	if err := loadAncestors(r.db, roles); err != nil {
+		return nil, err
+	}
+	for i := range elevations {
+		elevations[i].Role = roles[i]
+	}
+	return elevations, nil
+}
+
+func (r *GormElevationRepository) Review(elevation *models.Elevation, from string) (bool, error) {
+	result := r.db.Model(&models.Elevation{}).
+		Where("id = ? AND status = ?", elevation.ID, from).
+		Updates(map[string]interface{}{
+			"status":           elevation.Status,
+			"duration_minutes": elevation.DurationMinutes,
+			"reviewer_id":      elevation.ReviewerID,
+			"reviewed_at":      elevation.ReviewedAt,
+			"review_note":      elevation.ReviewNote,
+			"expires_at":       elevation.ExpiresAt,
+			"revoked_by_id":    elevation.RevokedByID,
+			"revoked_at":       elevation.RevokedAt,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *GormElevationRepository) Expire(now, pendingBefore time.Time) (int64, error) {
+	result := r.db.Model(&models.Elevation{}).
+		Where("(status = ? AND expires_at <= ?) OR (status = ? AND created_at < ?)",
+			models.ElevationApproved, now, models.ElevationPending, pendingBefore).
+		Update("status", models.ElevationExpired)
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+)
+
+type GormEmailChangeRepository struct {
+	db *gorm.DB
+}
+
+func NewEmailChangeRepository(db *gorm.DB) *GormEmailChangeRepository {
+	return &GormEmailChangeRepository{
+		db: db,
+	}
+}
+
+func (r *GormEmailChangeRepository) Create(token *models.EmailChangeToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *GormEmailChangeRepository) FindByHash(hash string) (*models.EmailChangeToken, error) {
+	var token models.EmailChangeToken
+	err := r.db.Where("token_hash = ?", hash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Consume marks an unused, unexpired token as used, returning false if it cannot be redeemed
+func (r *GormEmailChangeRepository) Consume(id uint, now time.Time) (bool, error) {
+	result := r.db.Model(&models.EmailChangeToken{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", id, now).
+		Update("used_at", now)
+	return result.RowsAffected == 1, result.Error
+}
+
+// InvalidateForUser marks every pending email change of the user as used
+func (r *GormEmailChangeRepository) InvalidateForUser(userID uint) error {
+	return r.db.Model(&models.EmailChangeToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+)
+
+type GormEmailVerificationRepository struct {
+	db *gorm.DB
+}
+
+func NewEmailVerificationRepository(db *gorm.DB) *GormEmailVerificationRepository {
+	return &GormEmailVerificationRepository{
+		db: db,
+	}
+}
+
+func (r *GormEmailVerificationRepository) Create(token *models.EmailVerificationToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *GormEmailVerificationRepository) FindByHash(hash string) (*models.EmailVerificationToken, error) {
+	var token models.EmailVerificationToken
+	err := r.db.Where("token_hash = ?", hash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Consume marks an unused, unexpired token as used, returning false if it cannot be redeemed
+func (r *GormEmailVerificationRepository) Consume(id uint, now time.Time) (bool, error) {
+	result := r.db.Model(&models.EmailVerificationToken{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", id, now).
+		Update("used_at", now)
+	return result.RowsAffected == 1, result.Error
+}
+
+// InvalidateForUser marks every outstanding verification token of the user as used
+func (r *GormEmailVerificationRepository) InvalidateForUser(userID uint) error {
+	return r.db.Model(&models.EmailVerificationToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
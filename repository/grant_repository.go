@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+)
+
+type GormGrantRepository struct {
+	db *gorm.DB
+}
+
+func NewGrantRepository(db *gorm.DB) *GormGrantRepository {
+	return &GormGrantRepository{
+		db: db,
+	}
+}
+
+// Create links the existing permission, user or role without upserting them
+func (r *GormGrantRepository) Create(grant *models.Grant) error {
+	return r.db.Omit("Permission", "User", "Role").Create(grant).Error
+}
+
+func (r *GormGrantRepository) FindByID(id uint) (*models.Grant, error) {
+	var grant models.Grant
+	err := r.db.Preload("Permission").First(&grant, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+func (r *GormGrantRepository) List(filter GrantFilter) ([]models.Grant, error) {
+	query := r.db.Preload("Permission").Order("id")
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.RoleID != 0 {
+		query = query.Where("role_id = ?", filter.RoleID)
+	}
+	var grants []models.Grant
+	err := query.Find(&grants).Error
+	return grants, err
+}
+
+// Delete removes the grant for good, a revoked grant has nothing worth keeping
+func (r *GormGrantRepository) Delete(id uint) error {
+	result := r.db.Unscoped().Delete(&models.Grant{}, id)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+func (r *GormGrantRepository) FindForSubject(userID uint, roleIDs []uint, resourceTypes []string) ([]models.Grant, error) {
+	subject := r.db.Where("user_id = ?", userID)
+	if len(roleIDs) > 0 {
+		subject = subject.Or("role_id IN ?", roleIDs)
+	}
+	var grants []models.Grant
+	err := r.db.Preload("Permission").
+		Where(subject).
+		Where("resource_type IN ?", resourceTypes).
+		Order("id").
+		Find(&grants).Error
+	return grants, err
+}
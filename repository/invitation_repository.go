@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+)
+
+type GormInvitationRepository struct {
+	db *gorm.DB
+}
+
+func NewInvitationRepository(db *gorm.DB) *GormInvitationRepository {
+	return &GormInvitationRepository{
+		db: db,
+	}
+}
+
+func (r *GormInvitationRepository) Create(invitation *models.Invitation) error {
+	return r.db.Omit("Organization", "Role").Create(invitation).Error
+}
+
+func (r *GormInvitationRepository) FindByHash(hash string) (*models.Invitation, error) {
+	var invitation models.Invitation
+	err := r.db.Preload("Organization").Preload("Role").Where("token_hash = ?", hash).First(&invitation).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *GormInvitationRepository) ListPending(organizationID uint, now time.Time) ([]models.Invitation, error) {
+	var invitations []models.Invitation
+	err := r.db.Preload("Role").
+		Where("organization_id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", organizationID, now).
+		Order("id").Find(&invitations).Error
+	return invitations, err
+}
+
+func (r *GormInvitationRepository) Accept(id uint, user *models.User, now time.Time) (bool, error) {
+	accepted := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Invitation{}).
+			Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", id, now).
+			Update("accepted_at", now)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		var invitation models.Invitation
+		if err := tx.First(&invitation, id).Error; err != nil {
+			return err
+		}
+
+		if user.ID == 0 {
+			if err := tx.Omit("Roles.*").Create(user).Error; err != nil {
+				return err
+			}
+		} else {
+			err := tx.Model(&models.User{}).
+				Where("id = ? AND email = ? AND email_verified_at IS NULL", user.ID, invitation.Email).
+				UpdateColumn("email_verified_at", now).Error
+			if err != nil {
+				return err
+			}
+		}
+		membership := &models.Membership{OrganizationID: invitation.OrganizationID, UserID: user.ID, RoleID: invitation.RoleID}
+		if err := setMember(tx, membership); err != nil {
+			return err
+		}
+		accepted = true
+		return nil
+	})
+	return accepted, err
+}
+
+func (r *GormInvitationRepository) Revoke(id uint, now time.Time) (bool, error) {
+	result := r.db.Model(&models.Invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", id, now).
+		Update("revoked_at", now)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *GormInvitationRepository) RevokePending(organizationID uint, email string, now time.Time) error {
+	return r.db.Model(&models.Invitation{}).
+		Where("organization_id = ? AND email = ? AND accepted_at IS NULL AND revoked_at IS NULL", organizationID, email).
+		Update("revoked_at", now).Error
+}
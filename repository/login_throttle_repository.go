@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GormLoginThrottleRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginThrottleRepository(db *gorm.DB) *GormLoginThrottleRepository {
+	return &GormLoginThrottleRepository{
+		db: db,
+	}
+}
+
+// Find returns the throttle of the key, nil when there were no recent failures
+func (r *GormLoginThrottleRepository) Find(key string) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	err := r.db.Where("key = ?", key).First(&throttle).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+// RecordFailure atomically counts a failed attempt, restarting the count when the previous
+// failure is older than window, and returns the updated row
+func (r *GormLoginThrottleRepository) RecordFailure(key string, now time.Time, window time.Duration) (*models.LoginThrottle, error) {
+	throttle := models.LoginThrottle{Key: key, Failures: 1, LastFailureAt: now}
+	err := r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "key"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"failures":        gorm.Expr("CASE WHEN login_throttles.last_failure_at < ? THEN 1 ELSE login_throttles.failures + 1 END", now.Add(-window)),
+			"last_failure_at": now,
+			"updated_at":      now,
+		}),
+	}).Create(&throttle).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.Find(key)
+}
+
+func (r *GormLoginThrottleRepository) Lock(key string, until time.Time) error {
+	return r.db.Model(&models.LoginThrottle{}).Where("key = ?", key).Update("locked_until", until).Error
+}
+
+// Reset forgets every failure of the key, used on successful login and unlock
+func (r *GormLoginThrottleRepository) Reset(key string) error {
+	return r.db.Where("key = ?", key).Delete(&models.LoginThrottle{}).Error
+}
+
+// DeleteStale removes throttles without failures since before and no active lock
+func (r *GormLoginThrottleRepository) DeleteStale(before time.Time) error {
+	return r.db.Where("last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)", before, time.Now()).
+		Delete(&models.LoginThrottle{}).Error
+}
@@ -0,0 +1,132 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository"
+	"gorm.io/gorm"
+)
+
+type ElevationRepository struct {
+	store *Store
+}
+
+func NewElevationRepository(store *Store) *ElevationRepository {
+	return &ElevationRepository{
+		store: store,
+	}
+}
+
+func (r *ElevationRepository) Create(elevation *models.Elevation) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The foreign keys of elevations reject links to missing rows
+	_, userExists := s.users[elevation.UserID]
+	_, roleExists := s.roles[elevation.RoleID]
+	if !userExists || !roleExists {
+		return gorm.ErrForeignKeyViolated
+	}
+	s.insert("elevations", &elevation.Model, time.Now())
+	stored := *elevation
+	stored.User = nil
+	stored.Role = models.Role{}
+	s.elevations[elevation.ID] = stored
+	return nil
+}
+
+func (r *ElevationRepository) FindByID(id uint) (*models.Elevation, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elevation, ok := s.elevations[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	elevation.Role = s.roles[elevation.RoleID]
+	return &elevation, nil
+}
+
+func (r *ElevationRepository) List(filter repository.ElevationFilter) ([]models.Elevation, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elevations := []models.Elevation{}
+	for _, id := range sortedIDs(s.elevations) {
+		elevation := s.elevations[id]
+		if filter.UserID != 0 && elevation.UserID != filter.UserID {
+			continue
+		}
+		if filter.Status != "" && elevation.Status != filter.Status {
+			continue
+		}
+		user := s.users[elevation.UserID]
+		elevation.User = &user
+		elevation.Role = s.roles[elevation.RoleID]
+		elevations = append(elevations, elevation)
+	}
+	return elevations, nil
+}
+
+func (r *ElevationRepository) ListActive(userID uint, now time.Time) ([]models.Elevation, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elevations := []models.Elevation{}
+	for _, id := range sortedIDs(s.elevations) {
+		elevation := s.elevations[id]
+		if elevation.UserID != userID || !elevation.IsActive(now) {
+			continue
+		}
+		elevation.Role = s.preloadHierarchy(s.roles[elevation.RoleID])
+		elevations = append(elevations, elevation)
+	}
+	return elevations, nil
+}
+
+func (r *ElevationRepository) Review(elevation *models.Elevation, from string) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.elevations[elevation.ID]
+	if !ok || stored.Status != from {
+		return false, nil
+	}
+	stored.Status = elevation.Status
+	stored.DurationMinutes = elevation.DurationMinutes
+	stored.ReviewerID = elevation.ReviewerID
+	stored.ReviewedAt = elevation.ReviewedAt
+	stored.ReviewNote = elevation.ReviewNote
+	stored.ExpiresAt = elevation.ExpiresAt
+	stored.RevokedByID = elevation.RevokedByID
+	stored.RevokedAt = elevation.RevokedAt
+	stored.UpdatedAt = time.Now()
+	s.elevations[elevation.ID] = stored
+	return true, nil
+}
+
+func (r *ElevationRepository) Expire(now, pendingBefore time.Time) (int64, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expired int64
+	for id, elevation := range s.elevations {
+		ended := elevation.Status == models.ElevationApproved && elevation.ExpiresAt != nil && !elevation.ExpiresAt.After(now)
+		stale := elevation.Status == models.ElevationPending && elevation.CreatedAt.Before(pendingBefore)
+		if !ended && !stale {
+			continue
+		}
+		elevation.Status = models.ElevationExpired
+		elevation.UpdatedAt = now
+		s.elevations[id] = elevation
+		expired++
+	}
+	return expired, nil
+}
@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+)
+
+type EmailChangeRepository struct {
+	store *Store
+}
+
+func NewEmailChangeRepository(store *Store) *EmailChangeRepository {
+	return &EmailChangeRepository{
+		store: store,
+	}
+}
+
+func (r *EmailChangeRepository) Create(token *models.EmailChangeToken) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.emailChanges {
+		if existing.TokenHash == token.TokenHash {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+	s.insert("email_change_tokens", &token.Model, time.Now())
+	s.emailChanges[token.ID] = *token
+	return nil
+}
+
+func (r *EmailChangeRepository) FindByHash(hash string) (*models.EmailChangeToken, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.emailChanges {
+		if token.TokenHash == hash {
+			return &token, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// Consume marks an unused, unexpired token as used, returning false if it cannot be redeemed
+func (r *EmailChangeRepository) Consume(id uint, now time.Time) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.emailChanges[id]
+	if !ok || token.UsedAt != nil || !token.ExpiresAt.After(now) {
+		return false, nil
+	}
+	token.UsedAt = &now
+	token.UpdatedAt = now
+	s.emailChanges[id] = token
+	return true, nil
+}
+
+func (r *EmailChangeRepository) InvalidateForUser(userID uint) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, token := range s.emailChanges {
+		if token.UserID == userID && token.UsedAt == nil {
+			token.UsedAt = &now
+			token.UpdatedAt = now
+			s.emailChanges[id] = token
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+)
+
+type EmailVerificationRepository struct {
+	store *Store
+}
+
+func NewEmailVerificationRepository(store *Store) *EmailVerificationRepository {
+	return &EmailVerificationRepository{
+		store: store,
+	}
+}
+
+func (r *EmailVerificationRepository) Create(token *models.EmailVerificationToken) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.emailVerifications {
+		if existing.TokenHash == token.TokenHash {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+	s.insert("email_verification_tokens", &token.Model, time.Now())
+	s.emailVerifications[token.ID] = *token
+	return nil
+}
+
+func (r *EmailVerificationRepository) FindByHash(hash string) (*models.EmailVerificationToken, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.emailVerifications {
+		if token.TokenHash == hash {
+			return &token, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// Consume marks an unused, unexpired token as used, returning false if it cannot be redeemed
+func (r *EmailVerificationRepository) Consume(id uint, now time.Time) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.emailVerifications[id]
+	if !ok || token.UsedAt != nil || !token.ExpiresAt.After(now) {
+		return false, nil
+	}
+	token.UsedAt = &now
+	token.UpdatedAt = now
+	s.emailVerifications[id] = token
+	return true, nil
+}
+
+func (r *EmailVerificationRepository) InvalidateForUser(userID uint) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, token := range s.emailVerifications {
+		if token.UserID == userID && token.UsedAt == nil {
+			token.UsedAt = &now
+			token.UpdatedAt = now
+			s.emailVerifications[id] = token
+		}
+	}
+	return nil
+}
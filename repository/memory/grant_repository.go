@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository"
+	"gorm.io/gorm"
+)
+
+type GrantRepository struct {
+	store *Store
+}
+
+func NewGrantRepository(store *Store) *GrantRepository {
+	return &GrantRepository{
+		store: store,
+	}
+}
+
+func (r *GrantRepository) Create(grant *models.Grant) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The foreign keys of grants reject links to missing rows
+	if _, ok := s.permissions[grant.PermissionID]; !ok {
+		return gorm.ErrForeignKeyViolated
+	}
+	if grant.UserID != nil {
+		if _, ok := s.users[*grant.UserID]; !ok {
+			return gorm.ErrForeignKeyViolated
+		}
+	}
+	if grant.RoleID != nil {
+		if _, ok := s.roles[*grant.RoleID]; !ok {
+			return gorm.ErrForeignKeyViolated
+		}
+	}
+
+	s.insert("grants", &grant.Model, time.Now())
+	stored := *grant
+	stored.Permission = models.Permission{}
+	stored.User = nil
+	stored.Role = nil
+	s.grants[grant.ID] = stored
+	return nil
+}
+
+func (r *GrantRepository) FindByID(id uint) (*models.Grant, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	grant, ok := s.grants[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	grant = s.preloadGrant(grant)
+	return &grant, nil
+}
+
+func (r *GrantRepository) List(filter repository.GrantFilter) ([]models.Grant, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	grants := []models.Grant{}
+	for _, id := range sortedIDs(s.grants) {
+		grant := s.grants[id]
+		if filter.UserID != 0 && (grant.UserID == nil || *grant.UserID != filter.UserID) {
+			continue
+		}
+		if filter.RoleID != 0 && (grant.RoleID == nil || *grant.RoleID != filter.RoleID) {
+			continue
+		}
+		grants = append(grants, s.preloadGrant(grant))
+	}
+	return grants, nil
+}
+
+func (r *GrantRepository) Delete(id uint) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.grants[id]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(s.grants, id)
+	return nil
+}
+
+func (r *GrantRepository) FindForSubject(userID uint, roleIDs []uint, resourceTypes []string) ([]models.Grant, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	roles := make(map[uint]bool, len(roleIDs))
+	for _, id := range roleIDs {
+		roles[id] = true
+	}
+	types := make(map[string]bool, len(resourceTypes))
+	for _, resourceType := range resourceTypes {
+		types[resourceType] = true
+	}
+
+	var grants []models.Grant
+	for _, id := range sortedIDs(s.grants) {
+		grant := s.grants[id]
+		forSubject := (grant.UserID != nil && *grant.UserID == userID) || (grant.RoleID != nil && roles[*grant.RoleID])
+		if forSubject && types[grant.ResourceType] {
+			grants = append(grants, s.preloadGrant(grant))
+		}
+	}
+	return grants, nil
+}
+
+func (s *Store) preloadGrant(grant models.Grant) models.Grant {
+	grant.Permission = s.permissions[grant.PermissionID]
+	return grant
+}
+
+// deleteGrants removes the grants matching match, like the cascading foreign keys of grants
+func (s *Store) deleteGrants(match func(grant models.Grant) bool) {
+	for id, grant := range s.grants {
+		if match(grant) {
+			delete(s.grants, id)
+		}
+	}
+}
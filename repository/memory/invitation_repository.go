@@ -0,0 +1,139 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+)
+
+type InvitationRepository struct {
+	store *Store
+}
+
+func NewInvitationRepository(store *Store) *InvitationRepository {
+	return &InvitationRepository{
+		store: store,
+	}
+}
+
+func (r *InvitationRepository) Create(invitation *models.Invitation) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The foreign keys of invitations reject links to missing rows
+	_, organizationExists := s.organizations[invitation.OrganizationID]
+	_, roleExists := s.roles[invitation.RoleID]
+	if !organizationExists || !roleExists {
+		return gorm.ErrForeignKeyViolated
+	}
+	for _, existing := range s.invitations {
+		if existing.TokenHash == invitation.TokenHash {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+	s.insert("invitations", &invitation.Model, time.Now())
+	stored := *invitation
+	stored.Organization = nil
+	stored.Role = models.Role{}
+	s.invitations[invitation.ID] = stored
+	return nil
+}
+
+func (r *InvitationRepository) FindByHash(hash string) (*models.Invitation, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, invitation := range s.invitations {
+		if invitation.TokenHash == hash {
+			organization := s.organizations[invitation.OrganizationID]
+			invitation.Organization = &organization
+			invitation.Role = s.roles[invitation.RoleID]
+			return &invitation, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *InvitationRepository) ListPending(organizationID uint, now time.Time) ([]models.Invitation, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invitations := []models.Invitation{}
+	for _, id := range sortedIDs(s.invitations) {
+		invitation := s.invitations[id]
+		if invitation.OrganizationID != organizationID || !invitation.IsPending(now) {
+			continue
+		}
+		invitation.Role = s.roles[invitation.RoleID]
+		invitations = append(invitations, invitation)
+	}
+	return invitations, nil
+}
+
+// Accept marks a pending invitation as accepted, returning false if it cannot be redeemed
+func (r *InvitationRepository) Accept(id uint, user *models.User, now time.Time) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invitation, ok := s.invitations[id]
+	if !ok || !invitation.IsPending(now) {
+		return false, nil
+	}
+	// Fail before changing anything, like the transaction rolling back
+	_, organizationExists := s.organizations[invitation.OrganizationID]
+	_, roleExists := s.roles[invitation.RoleID]
+	if !organizationExists || !roleExists {
+		return false, gorm.ErrForeignKeyViolated
+	}
+	if user.ID == 0 {
+		if err := s.createUser(user); err != nil {
+			return false, err
+		}
+	} else if stored, ok := s.activeUser(user.ID); !ok {
+		return false, gorm.ErrForeignKeyViolated
+	} else if stored.Email == invitation.Email && stored.EmailVerifiedAt == nil {
+		stored.EmailVerifiedAt = &now
+		s.users[user.ID] = stored
+	}
+
+	invitation.AcceptedAt = &now
+	invitation.UpdatedAt = now
+	s.invitations[id] = invitation
+	return true, s.setMember(&models.Membership{OrganizationID: invitation.OrganizationID, UserID: user.ID, RoleID: invitation.RoleID})
+}
+
+func (r *InvitationRepository) Revoke(id uint, now time.Time) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invitation, ok := s.invitations[id]
+	if !ok || !invitation.IsPending(now) {
+		return false, nil
+	}
+	invitation.RevokedAt = &now
+	invitation.UpdatedAt = now
+	s.invitations[id] = invitation
+	return true, nil
+}
+
+func (r *InvitationRepository) RevokePending(organizationID uint, email string, now time.Time) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, invitation := range s.invitations {
+		if invitation.OrganizationID == organizationID && invitation.Email == email &&
+			invitation.AcceptedAt == nil && invitation.RevokedAt == nil {
+			invitation.RevokedAt = &now
+			invitation.UpdatedAt = now
+			s.invitations[id] = invitation
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+)
+
+func TestInvitationRepositoryAccept(t *testing.T) {
+	store := NewStore()
+	users := NewUserRepository(store)
+	roles := NewRoleRepository(store)
+	organizations := NewOrganizationRepository(store)
+	invitations := NewInvitationRepository(store)
+
+	role := models.Role{Name: "member"}
+	require.NoError(t, roles.Create(&role))
+	organization := models.Organization{Name: "Acme"}
+	require.NoError(t, organizations.Create(&organization))
+	now := time.Now()
+	invitation := models.Invitation{
+		OrganizationID: organization.ID,
+		Email:          "new@example.com",
+		RoleID:         role.ID,
+		TokenHash:      "hash",
+		ExpiresAt:      now.Add(time.Hour),
+	}
+	require.NoError(t, invitations.Create(&invitation))
+
+	// Someone registered the email in the meantime
+	require.NoError(t, users.Create(&models.User{Email: "new@example.com", Password: "password123"}))
+	user := models.User{Email: "new@example.com", Password: "password123"}
+	accepted, err := invitations.Accept(invitation.ID, &user, now)
+	assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+	assert.False(t, accepted)
+
+	pending, err := invitations.ListPending(organization.ID, now)
+	require.NoError(t, err)
+	assert.Len(t, pending, 1, "a failed acceptance leaves the invitation pending")
+	members, err := organizations.ListMembers(organization.ID)
+	require.NoError(t, err)
+	assert.Empty(t, members)
+
+	existing, err := users.FindByEmail("new@example.com")
+	require.NoError(t, err)
+	accepted, err = invitations.Accept(invitation.ID, existing, now)
+	require.NoError(t, err)
+	assert.True(t, accepted)
+	members, err = organizations.ListMembers(organization.ID)
+	require.NoError(t, err)
+	assert.Len(t, members, 1)
+	existing, err = users.FindByEmail("new@example.com")
+	require.NoError(t, err)
+	assert.True(t, existing.IsEmailVerified())
+
+	accepted, err = invitations.Accept(invitation.ID, existing, now)
+	require.NoError(t, err)
+	assert.False(t, accepted)
+}
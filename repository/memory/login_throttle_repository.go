@@ -0,0 +1,91 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+)
+
+type LoginThrottleRepository struct {
+	store *Store
+}
+
+func NewLoginThrottleRepository(store *Store) *LoginThrottleRepository {
+	return &LoginThrottleRepository{
+		store: store,
+	}
+}
+
+// Find returns the throttle of the key, nil when there were no recent failures
+func (r *LoginThrottleRepository) Find(key string) (*models.LoginThrottle, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	throttle, ok := s.loginThrottles[key]
+	if !ok {
+		return nil, nil
+	}
+	return &throttle, nil
+}
+
+// RecordFailure counts a failed attempt, restarting the count when the previous
+// failure is older than window, and returns the updated throttle
+func (r *LoginThrottleRepository) RecordFailure(key string, now time.Time, window time.Duration) (*models.LoginThrottle, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	throttle, ok := s.loginThrottles[key]
+	switch {
+	case !ok:
+		s.sequences["login_throttles"]++
+		throttle = models.LoginThrottle{ID: s.sequences["login_throttles"], Key: key, Failures: 1, CreatedAt: now}
+	case throttle.LastFailureAt.Before(now.Add(-window)):
+		throttle.Failures = 1
+	default:
+		throttle.Failures++
+	}
+	throttle.LastFailureAt = now
+	throttle.UpdatedAt = now
+	s.loginThrottles[key] = throttle
+	return &throttle, nil
+}
+
+func (r *LoginThrottleRepository) Lock(key string, until time.Time) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if throttle, ok := s.loginThrottles[key]; ok {
+		throttle.LockedUntil = &until
+		throttle.UpdatedAt = time.Now()
+		s.loginThrottles[key] = throttle
+	}
+	return nil
+}
+
+// Reset forgets every failure of the key, used on successful login and unlock
+func (r *LoginThrottleRepository) Reset(key string) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.loginThrottles, key)
+	return nil
+}
+
+// DeleteStale removes throttles without failures since before and no active lock
+func (r *LoginThrottleRepository) DeleteStale(before time.Time) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, throttle := range s.loginThrottles {
+		if throttle.LastFailureAt.Before(before) && (throttle.LockedUntil == nil || throttle.LockedUntil.Before(now)) {
+			delete(s.loginThrottles, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+)
+
+type MFARepository struct {
+	store *Store
+}
+
+func NewMFARepository(store *Store) *MFARepository {
+	return &MFARepository{
+		store: store,
+	}
+}
+
+// SetPendingSecret stores a TOTP secret that is not active until EnableTOTP is called
+func (r *MFARepository) SetPendingSecret(userID uint, secret string) error {
+	r.store.updateUser(userID, func(user *models.User) bool {
+		if user.MFAEnabledAt != nil {
+			return false
+		}
+		user.TOTPSecret = secret
+		user.TOTPLastStep = 0
+		return true
+	})
+	return nil
+}
+
+// EnableTOTP activates the pending secret and replaces the recovery codes
+func (r *MFARepository) EnableTOTP(userID uint, step int64, codeHashes []string) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkRecoveryCodes(userID, codeHashes); err != nil {
+		return err
+	}
+	if user, ok := s.activeUser(userID); ok {
+		now := time.Now()
+		user.MFAEnabledAt = &now
+		user.TOTPLastStep = step
+		s.users[userID] = user
+	}
+	s.replaceRecoveryCodes(userID, codeHashes)
+	return nil
+}
+
+// DisableTOTP removes the secret and every recovery code
+func (r *MFARepository) DisableTOTP(userID uint) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, ok := s.activeUser(userID); ok {
+		user.MFAEnabledAt = nil
+		user.TOTPSecret = ""
+		user.TOTPLastStep = 0
+		s.users[userID] = user
+	}
+	s.replaceRecoveryCodes(userID, nil)
+	return nil
+}
+
+// AdvanceTOTPStep records step as used, returning false if it or a later step was used already
+func (r *MFARepository) AdvanceTOTPStep(userID uint, step int64) (bool, error) {
+	return r.store.updateUser(userID, func(user *models.User) bool {
+		if user.TOTPLastStep >= step {
+			return false
+		}
+		user.TOTPLastStep = step
+		return true
+	}), nil
+}
+
+func (r *MFARepository) ReplaceRecoveryCodes(userID uint, codeHashes []string) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkRecoveryCodes(userID, codeHashes); err != nil {
+		return err
+	}
+	s.replaceRecoveryCodes(userID, codeHashes)
+	return nil
+}
+
+// ConsumeRecoveryCode marks an unused recovery code of the user as used, returning false if there is none
+func (r *MFARepository) ConsumeRecoveryCode(userID uint, codeHash string) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, code := range s.recoveryCodes {
+		if code.UserID == userID && code.CodeHash == codeHash && code.UsedAt == nil {
+			now := time.Now()
+			code.UsedAt = &now
+			code.UpdatedAt = now
+			s.recoveryCodes[id] = code
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *MFARepository) CountUnusedRecoveryCodes(userID uint) (int64, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	for _, code := range s.recoveryCodes {
+		if code.UserID == userID && code.UsedAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *MFARepository) CreateChallenge(challenge *models.MFAChallenge) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.mfaChallenges {
+		if existing.TokenHash == challenge.TokenHash {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+	s.insert("mfa_challenges", &challenge.Model, time.Now())
+	s.mfaChallenges[challenge.ID] = *challenge
+	return nil
+}
+
+func (r *MFARepository) FindChallengeByHash(hash string) (*models.MFAChallenge, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, challenge := range s.mfaChallenges {
+		if challenge.TokenHash == hash {
+			return &challenge, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// RecordChallengeAttempt counts an attempt on an open challenge, returning false once
+// the challenge is used, expired or out of attempts
+func (r *MFARepository) RecordChallengeAttempt(id uint, maxAttempts int, now time.Time) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	challenge, ok := s.mfaChallenges[id]
+	if !ok || challenge.UsedAt != nil || !challenge.ExpiresAt.After(now) || challenge.Attempts >= maxAttempts {
+		return false, nil
+	}
+	challenge.Attempts++
+	s.mfaChallenges[id] = challenge
+	return true, nil
+}
+
+// ConsumeChallenge marks the challenge as used, returning false if it was used already
+func (r *MFARepository) ConsumeChallenge(id uint) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	challenge, ok := s.mfaChallenges[id]
+	if !ok || challenge.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	challenge.UsedAt = &now
+	challenge.UpdatedAt = now
+	s.mfaChallenges[id] = challenge
+	return true, nil
+}
+
+// checkRecoveryCodes fails like the unique index would if a hash belongs to another user,
+// so a failed replacement leaves the old codes in place
+func (s *Store) checkRecoveryCodes(userID uint, codeHashes []string) error {
+	seen := make(map[string]bool, len(codeHashes))
+	for _, code := range s.recoveryCodes {
+		if code.UserID != userID {
+			seen[code.CodeHash] = true
+		}
+	}
+	for _, hash := range codeHashes {
+		if seen[hash] {
+			return gorm.ErrDuplicatedKey
+		}
+		seen[hash] = true
+	}
+	return nil
+}
+
+func (s *Store) replaceRecoveryCodes(userID uint, codeHashes []string) {
+	for id, code := range s.recoveryCodes {
+		if code.UserID == userID {
+			delete(s.recoveryCodes, id)
+		}
+	}
+	now := time.Now()
+	for _, hash := range codeHashes {
+		code := models.RecoveryCode{UserID: userID, CodeHash: hash}
+		s.insert("recovery_codes", &code.Model, now)
+		s.recoveryCodes[code.ID] = code
+	}
+}
@@ -0,0 +1,221 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+)
+
+type OrganizationRepository struct {
+	store *Store
+}
+
+func NewOrganizationRepository(store *Store) *OrganizationRepository {
+	return &OrganizationRepository{
+		store: store,
+	}
+}
+
+func (r *OrganizationRepository) Create(organization *models.Organization) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.organizationNameTaken(organization.Name, 0) {
+		return gorm.ErrDuplicatedKey
+	}
+	s.insert("organizations", &organization.Model, time.Now())
+	s.organizations[organization.ID] = *organization
+	return nil
+}
+
+func (r *OrganizationRepository) FindByID(id uint) (*models.Organization, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	organization, ok := s.organizations[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &organization, nil
+}
+
+func (r *OrganizationRepository) List() ([]models.Organization, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	organizations := make([]models.Organization, 0, len(s.organizations))
+	for _, id := range sortedIDs(s.organizations) {
+		organizations = append(organizations, s.organizations[id])
+	}
+	return organizations, nil
+}
+
+func (r *OrganizationRepository) Update(organization *models.Organization) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.organizations[organization.ID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	if s.organizationNameTaken(organization.Name, organization.ID) {
+		return gorm.ErrDuplicatedKey
+	}
+	stored.Name = organization.Name
+	stored.Description = organization.Description
+	stored.UpdatedAt = time.Now()
+	s.organizations[organization.ID] = stored
+	return nil
+}
+
+func (r *OrganizationRepository) Delete(id uint) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.organizations[id]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(s.organizations, id)
+	// The foreign keys of memberships and invitations cascade
+	for membershipID, membership := range s.memberships {
+		if membership.OrganizationID == id {
+			delete(s.memberships, membershipID)
+		}
+	}
+	for invitationID, invitation := range s.invitations {
+		if invitation.OrganizationID == id {
+			delete(s.invitations, invitationID)
+		}
+	}
+	return nil
+}
+
+func (r *OrganizationRepository) SetMember(membership *models.Membership) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.setMember(membership)
+}
+
+// setMember adds or updates a membership, the caller holds mu
+func (s *Store) setMember(membership *models.Membership) error {
+	// The foreign keys of memberships reject links to missing rows
+	_, organizationExists := s.organizations[membership.OrganizationID]
+	_, userExists := s.users[membership.UserID]
+	_, roleExists := s.roles[membership.RoleID]
+	if !organizationExists || !userExists || !roleExists {
+		return gorm.ErrForeignKeyViolated
+	}
+
+	now := time.Now()
+	if existing := s.findMembership(membership.OrganizationID, membership.UserID); existing != nil {
+		existing.RoleID = membership.RoleID
+		existing.UpdatedAt = now
+		s.memberships[existing.ID] = *existing
+		membership.ID = existing.ID
+		membership.CreatedAt = existing.CreatedAt
+		membership.UpdatedAt = now
+		return nil
+	}
+
+	model := gorm.Model{ID: membership.ID}
+	s.insert("memberships", &model, now)
+	membership.ID = model.ID
+	membership.CreatedAt = model.CreatedAt
+	membership.UpdatedAt = model.UpdatedAt
+	stored := *membership
+	stored.Organization = nil
+	stored.User = nil
+	stored.Role = models.Role{}
+	s.memberships[membership.ID] = stored
+	return nil
+}
+
+func (r *OrganizationRepository) RemoveMember(organizationID, userID uint) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	membership := s.findMembership(organizationID, userID)
+	if membership == nil {
+		return gorm.ErrRecordNotFound
+	}
+	delete(s.memberships, membership.ID)
+	return nil
+}
+
+func (r *OrganizationRepository) FindMembership(organizationID, userID uint) (*models.Membership, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	membership := s.findMembership(organizationID, userID)
+	if membership == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	membership.Role = s.preloadHierarchy(s.roles[membership.RoleID])
+	return membership, nil
+}
+
+func (r *OrganizationRepository) ListMembers(organizationID uint) ([]models.Membership, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	memberships := []models.Membership{}
+	for _, id := range sortedIDs(s.memberships) {
+		membership := s.memberships[id]
+		user, ok := s.users[membership.UserID]
+		if membership.OrganizationID != organizationID || !ok || user.DeletedAt.Valid {
+			continue
+		}
+		membership.User = &user
+		membership.Role = s.roles[membership.RoleID]
+		memberships = append(memberships, membership)
+	}
+	return memberships, nil
+}
+
+func (r *OrganizationRepository) ListForUser(userID uint) ([]models.Membership, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	memberships := []models.Membership{}
+	for _, id := range sortedIDs(s.memberships) {
+		membership := s.memberships[id]
+		if membership.UserID != userID {
+			continue
+		}
+		organization := s.organizations[membership.OrganizationID]
+		membership.Organization = &organization
+		membership.Role = s.roles[membership.RoleID]
+		memberships = append(memberships, membership)
+	}
+	return memberships, nil
+}
+
+// findMembership returns a copy of the membership of the user in the organization, nil when there is none
+func (s *Store) findMembership(organizationID, userID uint) *models.Membership {
+	for _, membership := range s.memberships {
+		if membership.OrganizationID == organizationID && membership.UserID == userID {
+			return &membership
+		}
+	}
+	return nil
+}
+
+func (s *Store) organizationNameTaken(name string, exceptID uint) bool {
+	for id, organization := range s.organizations {
+		if id != exceptID && organization.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/sukhantharot/go-service/repository"
+)
+
+// paginate sorts items and cuts out the requested page like the GORM repositories do
+func paginate[T any](items []T, fields map[string]repository.SortField[T], page repository.PageRequest, id func(item *T) uint) (*repository.Page[T], error) {
+	page = page.Normalized()
+	field, ok := fields[page.Sort]
+	if !ok {
+		return nil, repository.ErrInvalidSort
+	}
+
+	// less orders by the sort field, then by id, in the page's direction
+	less := func(a, b *T) bool {
+		if c := compareValues(field.Value(a), field.Value(b)); c != 0 {
+			return (c < 0) != page.Desc
+		}
+		return (id(a) < id(b)) != page.Desc
+	}
+	sort.SliceStable(items, func(i, j int) bool { return less(&items[i], &items[j]) })
+
+	result := &repository.Page[T]{Total: int64(len(items))}
+	start := page.Offset
+	if page.Cursor != "" {
+		value, lastID, err := repository.DecodeCursor(page, field)
+		if err != nil {
+			return nil, err
+		}
+		start = sort.Search(len(items), func(i int) bool {
+			c := compareValues(field.Value(&items[i]), value)
+			if c == 0 {
+				return (id(&items[i]) > lastID) != page.Desc && id(&items[i]) != lastID
+			}
+			return (c > 0) != page.Desc
+		})
+	}
+	if start > len(items) {
+		start = len(items)
+	}
+	items = items[start:]
+
+	if len(items) > page.Limit {
+		items = items[:page.Limit]
+		last := &items[len(items)-1]
+		result.NextCursor = repository.EncodeCursor(page, field, last, id(last))
+	}
+	result.Items = items
+	return result, nil
+}
+
+// compareValues compares two values of a sort field, see repository.SortField
+func compareValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case string:
+		b := b.(string)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	case uint:
+		b := b.(uint)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	}
+	return 0
+}
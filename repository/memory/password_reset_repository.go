@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+)
+
+type PasswordResetRepository struct {
+	store *Store
+}
+
+func NewPasswordResetRepository(store *Store) *PasswordResetRepository {
+	return &PasswordResetRepository{
+		store: store,
+	}
+}
+
+func (r *PasswordResetRepository) Create(token *models.PasswordResetToken) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.passwordResets {
+		if existing.TokenHash == token.TokenHash {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+	s.insert("password_reset_tokens", &token.Model, time.Now())
+	s.passwordResets[token.ID] = *token
+	return nil
+}
+
+func (r *PasswordResetRepository) FindByHash(hash string) (*models.PasswordResetToken, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.passwordResets {
+		if token.TokenHash == hash {
+			return &token, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// Consume marks an unused, unexpired token as used, returning false if it cannot be redeemed
+func (r *PasswordResetRepository) Consume(id uint, now time.Time) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.passwordResets[id]
+	if !ok || token.UsedAt != nil || !token.ExpiresAt.After(now) {
+		return false, nil
+	}
+	token.UsedAt = &now
+	token.UpdatedAt = now
+	s.passwordResets[id] = token
+	return true, nil
+}
+
+func (r *PasswordResetRepository) InvalidateForUser(userID uint) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, token := range s.passwordResets {
+		if token.UserID == userID && token.UsedAt == nil {
+			token.UsedAt = &now
+			token.UpdatedAt = now
+			s.passwordResets[id] = token
+		}
+	}
+	return nil
+}
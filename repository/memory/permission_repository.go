@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+)
+
+type PermissionRepository struct {
+	store *Store
+}
+
+func NewPermissionRepository(store *Store) *PermissionRepository {
+	return &PermissionRepository{
+		store: store,
+	}
+}
+
+func (r *PermissionRepository) Create(permission *models.Permission) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.permissions[permission.ID]; exists && permission.ID != 0 {
+		return gorm.ErrDuplicatedKey
+	}
+	return s.createPermission(permission, time.Now())
+}
+
+func (r *PermissionRepository) FindByID(id uint) (*models.Permission, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	permission, ok := s.permissions[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &permission, nil
+}
+
+func (r *PermissionRepository) List() ([]models.Permission, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	permissions := make([]models.Permission, 0, len(s.permissions))
+	for _, id := range sortedIDs(s.permissions) {
+		permissions = append(permissions, s.permissions[id])
+	}
+	return permissions, nil
+}
+
+func (r *PermissionRepository) Update(permission *models.Permission) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.permissions[permission.ID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	for id, existing := range s.permissions {
+		if id != permission.ID && existing.Name == permission.Name {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+	stored.Name = permission.Name
+	stored.Description = permission.Description
+	stored.UpdatedAt = time.Now()
+	s.permissions[permission.ID] = stored
+	permission.UpdatedAt = stored.UpdatedAt
+	return nil
+}
+
+// Delete removes the permission for good and unlinks it from every role
+func (r *PermissionRepository) Delete(id uint) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.permissions[id]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(s.permissions, id)
+	for roleID := range s.rolePermissions {
+		s.unlinkPermission(roleID, id)
+	}
+	// The foreign key of grants cascades
+	s.deleteGrants(func(grant models.Grant) bool { return grant.PermissionID == id })
+	return nil
+}
+
+func (s *Store) createPermission(permission *models.Permission, now time.Time) error {
+	for _, existing := range s.permissions {
+		if existing.Name == permission.Name {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+	s.insert("permissions", &permission.Model, now)
+	s.permissions[permission.ID] = *permission
+	return nil
+}
@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+)
+
+type PolicyRepository struct {
+	store *Store
+}
+
+func NewPolicyRepository(store *Store) *PolicyRepository {
+	return &PolicyRepository{
+		store: store,
+	}
+}
+
+func (r *PolicyRepository) Create(policy *models.Policy) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.policies {
+		if existing.Name == policy.Name {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+	s.insert("policies", &policy.Model, time.Now())
+	s.policies[policy.ID] = *policy
+	return nil
+}
+
+func (r *PolicyRepository) FindByID(id uint) (*models.Policy, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	policy, ok := s.policies[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &policy, nil
+}
+
+func (r *PolicyRepository) List() ([]models.Policy, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	policies := make([]models.Policy, 0, len(s.policies))
+	for _, id := range sortedIDs(s.policies) {
+		policies = append(policies, s.policies[id])
+	}
+	return policies, nil
+}
+
+func (r *PolicyRepository) Delete(id uint) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.policies[id]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(s.policies, id)
+	return nil
+}
@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+)
+
+type RefreshTokenRepository struct {
+	store *Store
+}
+
+func NewRefreshTokenRepository(store *Store) *RefreshTokenRepository {
+	return &RefreshTokenRepository{
+		store: store,
+	}
+}
+
+func (r *RefreshTokenRepository) Create(token *models.RefreshToken) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.createRefreshToken(token, time.Now())
+}
+
+func (r *RefreshTokenRepository) FindByHash(hash string) (*models.RefreshToken, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.refreshTokens {
+		if token.TokenHash == hash {
+			return &token, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// Rotate marks current as rotated and stores next, or does neither when current
+// was already rotated or revoked
+func (r *RefreshTokenRepository) Rotate(current *models.RefreshToken, next *models.RefreshToken) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.refreshTokens[current.ID]
+	if !ok || stored.RotatedAt != nil || stored.RevokedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	if err := s.createRefreshToken(next, now); err != nil {
+		return false, err
+	}
+	stored.RotatedAt = &now
+	stored.UpdatedAt = now
+	s.refreshTokens[current.ID] = stored
+	current.RotatedAt = &now
+	return true, nil
+}
+
+func (r *RefreshTokenRepository) RevokeFamily(familyID string) error {
+	r.store.revokeRefreshTokens(func(token models.RefreshToken) bool {
+		return token.FamilyID == familyID
+	})
+	return nil
+}
+
+func (r *RefreshTokenRepository) RevokeAllForUser(userID uint) error {
+	r.store.revokeRefreshTokens(func(token models.RefreshToken) bool {
+		return token.UserID == userID
+	})
+	return nil
+}
+
+func (s *Store) createRefreshToken(token *models.RefreshToken, now time.Time) error {
+	for _, existing := range s.refreshTokens {
+		if existing.TokenHash == token.TokenHash {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+	s.insert("refresh_tokens", &token.Model, now)
+	s.refreshTokens[token.ID] = *token
+	return nil
+}
+
+// revokeRefreshTokens revokes every unrevoked token that matches
+func (s *Store) revokeRefreshTokens(match func(token models.RefreshToken) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, token := range s.refreshTokens {
+		if token.RevokedAt == nil && match(token) {
+			token.RevokedAt = &now
+			token.UpdatedAt = now
+			s.refreshTokens[id] = token
+		}
+	}
+}
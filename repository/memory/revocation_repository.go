@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+)
+
+type RevocationRepository struct {
+	store *Store
+}
+
+func NewRevocationRepository(store *Store) *RevocationRepository {
+	return &RevocationRepository{
+		store: store,
+	}
+}
+
+// RevokeToken stores the jti of an access token, revoking it twice is a no-op
+func (r *RevocationRepository) RevokeToken(token *models.RevokedToken) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.revokedTokens {
+		if existing.JTI == token.JTI {
+			return nil
+		}
+	}
+	s.insert("revoked_tokens", &token.Model, time.Now())
+	s.revokedTokens[token.ID] = *token
+	return nil
+}
+
+func (r *RevocationRepository) IsTokenRevoked(jti string) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.revokedTokens {
+		if token.JTI == jti {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *RevocationRepository) RevokeUserTokens(userID uint, before time.Time) error {
+	r.store.updateUser(userID, func(user *models.User) bool {
+		user.TokensRevokedAt = &before
+		user.UpdatedAt = time.Now()
+		return true
+	})
+	return nil
+}
+
+func (r *RevocationRepository) UserTokenState(userID uint) (*time.Time, bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.activeUser(userID)
+	if !ok {
+		return nil, false, gorm.ErrRecordNotFound
+	}
+	return user.TokensRevokedAt, user.IsSuspended(), nil
+}
+
+func (r *RevocationRepository) DeleteExpired(now time.Time) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, token := range s.revokedTokens {
+		if token.ExpiresAt.Before(now) {
+			delete(s.revokedTokens, id)
+		}
+	}
+	return nil
+}
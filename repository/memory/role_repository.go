@@ -0,0 +1,319 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+)
+
+type RoleRepository struct {
+	store *Store
+}
+
+func NewRoleRepository(store *Store) *RoleRepository {
+	return &RoleRepository{
+		store: store,
+	}
+}
+
+// Create stores the role and links its Permissions and Parents. Like GORM it creates the
+// permissions that have no ID yet and links existing ones by ID, parents must exist.
+func (r *RoleRepository) Create(role *models.Role) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.roles[role.ID]; exists && role.ID != 0 {
+		return gorm.ErrDuplicatedKey
+	}
+	if s.roleNameTaken(role.Name, 0) {
+		return gorm.ErrDuplicatedKey
+	}
+
+	now := time.Now()
+	permissionIDs := make([]uint, 0, len(role.Permissions))
+	for i := range role.Permissions {
+		permission := &role.Permissions[i]
+		if _, exists := s.permissions[permission.ID]; !exists {
+			if err := s.createPermission(permission, now); err != nil {
+				return err
+			}
+		}
+		permissionIDs = append(permissionIDs, permission.ID)
+	}
+	parentIDs := make([]uint, 0, len(role.Parents))
+	for _, parent := range role.Parents {
+		if _, exists := s.roles[parent.ID]; !exists {
+			return gorm.ErrForeignKeyViolated
+		}
+		parentIDs = append(parentIDs, parent.ID)
+	}
+
+	s.insert("roles", &role.Model, now)
+	stored := *role
+	stored.Permissions = nil
+	stored.Parents = nil
+	s.roles[role.ID] = stored
+	s.rolePermissions[role.ID] = permissionIDs
+	s.roleParents[role.ID] = parentIDs
+	return nil
+}
+
+// FindByID returns the role with its permissions and ancestors
+func (r *RoleRepository) FindByID(id uint) (*models.Role, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	role, ok := s.roles[id]
+	if !ok || role.DeletedAt.Valid {
+		return nil, gorm.ErrRecordNotFound
+	}
+	role = s.preloadHierarchy(role)
+	return &role, nil
+}
+
+// FindByName returns the role with its permissions and ancestors
+func (r *RoleRepository) FindByName(name string) (*models.Role, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range sortedIDs(s.roles) {
+		if role := s.roles[id]; role.Name == name && !role.DeletedAt.Valid {
+			role = s.preloadHierarchy(role)
+			return &role, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *RoleRepository) List() ([]models.Role, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	roles := make([]models.Role, 0, len(s.roles))
+	for _, id := range sortedIDs(s.roles) {
+		roles = append(roles, s.preloadHierarchy(s.roles[id]))
+	}
+	return roles, nil
+}
+
+func (r *RoleRepository) Update(role *models.Role) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.roles[role.ID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	if s.roleNameTaken(role.Name, role.ID) {
+		return gorm.ErrDuplicatedKey
+	}
+	stored.Name = role.Name
+	stored.Description = role.Description
+	stored.UpdatedAt = time.Now()
+	s.roles[role.ID] = stored
+	role.UpdatedAt = stored.UpdatedAt
+	return nil
+}
+
+// Delete removes the role for good, like the GORM implementation
+func (r *RoleRepository) Delete(id uint) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.roles[id]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(s.roles, id)
+	delete(s.rolePermissions, id)
+	delete(s.roleParents, id)
+	for roleID := range s.roleParents {
+		s.unlinkParent(roleID, id)
+	}
+	s.deleteGrants(func(grant models.Grant) bool { return grant.RoleID != nil && *grant.RoleID == id })
+	// The foreign key of elevations cascades
+	for elevationID, elevation := range s.elevations {
+		if elevation.RoleID == id {
+			delete(s.elevations, elevationID)
+		}
+	}
+	for invitationID, invitation := range s.invitations {
+		if invitation.RoleID == id {
+			delete(s.invitations, invitationID)
+		}
+	}
+	return nil
+}
+
+func (r *RoleRepository) AttachPermission(roleID, permissionID uint) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The foreign keys of role_permissions reject links to missing rows
+	_, roleExists := s.roles[roleID]
+	_, permissionExists := s.permissions[permissionID]
+	if !roleExists || !permissionExists {
+		return gorm.ErrForeignKeyViolated
+	}
+	for _, id := range s.rolePermissions[roleID] {
+		if id == permissionID {
+			return nil
+		}
+	}
+	s.rolePermissions[roleID] = append(s.rolePermissions[roleID], permissionID)
+	return nil
+}
+
+func (r *RoleRepository) DetachPermission(roleID, permissionID uint) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.unlinkPermission(roleID, permissionID)
+	return nil
+}
+
+func (r *RoleRepository) AddParent(roleID, parentID uint) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The foreign keys of role_parents reject links to missing roles
+	_, roleExists := s.roles[roleID]
+	_, parentExists := s.roles[parentID]
+	if !roleExists || !parentExists {
+		return gorm.ErrForeignKeyViolated
+	}
+	for _, id := range s.roleParents[roleID] {
+		if id == parentID {
+			return nil
+		}
+	}
+	s.roleParents[roleID] = append(s.roleParents[roleID], parentID)
+	return nil
+}
+
+func (r *RoleRepository) RemoveParent(roleID, parentID uint) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.unlinkParent(roleID, parentID)
+	return nil
+}
+
+// CountUsers includes soft deleted users, they keep their roles and memberships
+func (r *RoleRepository) CountUsers(roleID uint) (int64, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	holders := make(map[uint]bool)
+	for userID := range s.userRoles {
+		if s.hasRole(userID, roleID) {
+			holders[userID] = true
+		}
+	}
+	for _, membership := range s.memberships {
+		if membership.RoleID == roleID {
+			holders[membership.UserID] = true
+		}
+	}
+	return int64(len(holders)), nil
+}
+
+func (r *RoleRepository) CountMemberships(roleID uint) (int64, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	for _, membership := range s.memberships {
+		if membership.RoleID == roleID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *RoleRepository) CountPendingInvitations(roleID uint, now time.Time) (int64, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	for _, invitation := range s.invitations {
+		if invitation.RoleID == roleID && invitation.IsPending(now) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) roleNameTaken(name string, exceptID uint) bool {
+	for id, role := range s.roles {
+		if id != exceptID && role.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) unlinkPermission(roleID, permissionID uint) {
+	ids := s.rolePermissions[roleID]
+	for i, id := range ids {
+		if id == permissionID {
+			s.rolePermissions[roleID] = append(ids[:i:i], ids[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *Store) unlinkParent(roleID, parentID uint) {
+	ids := s.roleParents[roleID]
+	for i, id := range ids {
+		if id == parentID {
+			s.roleParents[roleID] = append(ids[:i:i], ids[i+1:]...)
+			return
+		}
+	}
+}
+
+// preloadHierarchy loads the permissions of role and fills Parents with its ancestors
+// like the GORM implementation does
+func (s *Store) preloadHierarchy(role models.Role) models.Role {
+	return s.preloadAncestors(role, map[uint]bool{role.ID: true})
+}
+
+// preloadAncestors skips links back to a role in path, so a cycle cannot recurse forever
+func (s *Store) preloadAncestors(role models.Role, path map[uint]bool) models.Role {
+	role.Permissions = s.preloadPermissions(role.ID)
+	role.Parents = nil
+	for _, parentID := range s.roleParents[role.ID] {
+		parent, ok := s.roles[parentID]
+		if !ok || path[parentID] {
+			continue
+		}
+		path[parentID] = true
+		role.Parents = append(role.Parents, s.preloadAncestors(parent, path))
+		delete(path, parentID)
+	}
+	return role
+}
+
+func (s *Store) preloadPermissions(roleID uint) []models.Permission {
+	permissions := make([]models.Permission, 0, len(s.rolePermissions[roleID]))
+	for _, id := range s.rolePermissions[roleID] {
+		if permission, ok := s.permissions[id]; ok && !permission.DeletedAt.Valid {
+			permissions = append(permissions, permission)
+		}
+	}
+	return permissions
+}
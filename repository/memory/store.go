@@ -0,0 +1,119 @@
+// Package memory implements the repository interfaces in process, for tests and local
+// development without Postgres. It mirrors the behaviour of the GORM implementation that
+// callers rely on: missing rows fail with gorm.ErrRecordNotFound, unique columns fail with
+// gorm.ErrDuplicatedKey, users are soft deleted and associations are preloaded the same way.
+package memory
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository"
+	"gorm.io/gorm"
+)
+
+// Store holds the tables of every in-memory repository. Repositories built on the same
+// Store see each other's writes, like repositories sharing one database.
+type Store struct {
+	mu        sync.Mutex
+	sequences map[string]uint
+
+	// Rows are stored by value and copied on the way in and out, so callers never share
+	// memory with the store. Roles are stored without Permissions and Parents, see
+	// rolePermissions and roleParents, users without Roles, see userRoles, and grants
+	// without their Permission, memberships without their Organization, User and Role,
+	// invitations without their Organization and Role and elevations without their User and Role.
+	users              map[uint]models.User
+	userRoles          map[uint][]uint
+	roles              map[uint]models.Role
+	permissions        map[uint]models.Permission
+	rolePermissions    map[uint][]uint
+	roleParents        map[uint][]uint
+	grants             map[uint]models.Grant
+	policies           map[uint]models.Policy
+	organizations      map[uint]models.Organization
+	memberships        map[uint]models.Membership
+	invitations        map[uint]models.Invitation
+	elevations         map[uint]models.Elevation
+	refreshTokens      map[uint]models.RefreshToken
+	revokedTokens      map[uint]models.RevokedToken
+	passwordResets     map[uint]models.PasswordResetToken
+	emailVerifications map[uint]models.EmailVerificationToken
+	emailChanges       map[uint]models.EmailChangeToken
+	recoveryCodes      map[uint]models.RecoveryCode
+	mfaChallenges      map[uint]models.MFAChallenge
+	loginThrottles     map[string]models.LoginThrottle
+}
+
+func NewStore() *Store {
+	return &Store{
+		sequences:          make(map[string]uint),
+		users:              make(map[uint]models.User),
+		userRoles:          make(map[uint][]uint),
+		roles:              make(map[uint]models.Role),
+		permissions:        make(map[uint]models.Permission),
+		rolePermissions:    make(map[uint][]uint),
+		roleParents:        make(map[uint][]uint),
+		grants:             make(map[uint]models.Grant),
+		policies:           make(map[uint]models.Policy),
+		organizations:      make(map[uint]models.Organization),
+		memberships:        make(map[uint]models.Membership),
+		invitations:        make(map[uint]models.Invitation),
+		elevations:         make(map[uint]models.Elevation),
+		refreshTokens:      make(map[uint]models.RefreshToken),
+		revokedTokens:      make(map[uint]models.RevokedToken),
+		passwordResets:     make(map[uint]models.PasswordResetToken),
+		emailVerifications: make(map[uint]models.EmailVerificationToken),
+		emailChanges:       make(map[uint]models.EmailChangeToken),
+		recoveryCodes:      make(map[uint]models.RecoveryCode),
+		mfaChallenges:      make(map[uint]models.MFAChallenge),
+		loginThrottles:     make(map[string]models.LoginThrottle),
+	}
+}
+
+// insert assigns the next ID of table and the timestamps to model like an INSERT would.
+// An explicit ID is kept, the sequence moves past it so later inserts do not collide.
+func (s *Store) insert(table string, model *gorm.Model, now time.Time) {
+	if model.ID == 0 {
+		s.sequences[table]++
+		model.ID = s.sequences[table]
+	} else if model.ID > s.sequences[table] {
+		s.sequences[table] = model.ID
+	}
+	if model.CreatedAt.IsZero() {
+		model.CreatedAt = now
+	}
+	if model.UpdatedAt.IsZero() {
+		model.UpdatedAt = now
+	}
+}
+
+// sortedIDs returns the keys of a table in insertion order
+func sortedIDs[T any](rows map[uint]T) []uint {
+	ids := make([]uint, 0, len(rows))
+	for id := range rows {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+var (
+	_ repository.UserRepository              = (*UserRepository)(nil)
+	_ repository.RoleRepository              = (*RoleRepository)(nil)
+	_ repository.PermissionRepository        = (*PermissionRepository)(nil)
+	_ repository.GrantRepository             = (*GrantRepository)(nil)
+	_ repository.PolicyRepository            = (*PolicyRepository)(nil)
+	_ repository.OrganizationRepository      = (*OrganizationRepository)(nil)
+	_ repository.InvitationRepository        = (*InvitationRepository)(nil)
+	_ repository.ElevationRepository         = (*ElevationRepository)(nil)
+	_ repository.RefreshTokenRepository      = (*RefreshTokenRepository)(nil)
+	_ repository.RevocationRepository        = (*RevocationRepository)(nil)
+	_ repository.PasswordResetRepository     = (*PasswordResetRepository)(nil)
+	_ repository.EmailVerificationRepository = (*EmailVerificationRepository)(nil)
+	_ repository.EmailChangeRepository       = (*EmailChangeRepository)(nil)
+	_ repository.MFARepository               = (*MFARepository)(nil)
+	_ repository.LoginThrottleRepository     = (*LoginThrottleRepository)(nil)
+)
@@ -0,0 +1,364 @@
+package memory
+
+import (
+	"strings"
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository"
+	"gorm.io/gorm"
+)
+
+type UserRepository struct {
+	store *Store
+}
+
+func NewUserRepository(store *Store) *UserRepository {
+	return &UserRepository{
+		store: store,
+	}
+}
+
+// Create links roles by ID, create them through RoleRepository first
+func (r *UserRepository) Create(user *models.User) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.createUser(user)
+}
+
+// createUser stores a user, the caller holds mu
+func (s *Store) createUser(user *models.User) error {
+	if _, exists := s.users[user.ID]; exists && user.ID != 0 {
+		return gorm.ErrDuplicatedKey
+	}
+	if s.emailTaken(user.Email, 0) {
+		return gorm.ErrDuplicatedKey
+	}
+	roleIDs := user.RoleIDs()
+	for _, roleID := range roleIDs {
+		if _, exists := s.roles[roleID]; !exists {
+			return gorm.ErrForeignKeyViolated
+		}
+	}
+	s.insert("users", &user.Model, time.Now())
+	s.users[user.ID] = storedUser(*user)
+	s.userRoles[user.ID] = roleIDs
+	return nil
+}
+
+func (r *UserRepository) FindByEmail(email string) (*models.User, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range sortedIDs(s.users) {
+		user := s.users[id]
+		if user.Email == email && !user.DeletedAt.Valid {
+			user.Roles = s.preloadRoles(user.ID, true)
+			return &user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *UserRepository) FindByID(id uint) (*models.User, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.activeUser(id)
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	user.Roles = s.preloadRoles(user.ID, true)
+	return &user, nil
+}
+
+// Update saves every column like GORM's Save. Roles are left alone.
+func (r *UserRepository) Update(user *models.User) error {
+	if user.ID == 0 {
+		return r.Create(user)
+	}
+
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.emailTaken(user.Email, user.ID) {
+		return gorm.ErrDuplicatedKey
+	}
+	now := time.Now()
+	if _, exists := s.users[user.ID]; !exists {
+		s.insert("users", &user.Model, now)
+	}
+	user.UpdatedAt = now
+	s.users[user.ID] = storedUser(*user)
+	return nil
+}
+
+func (r *UserRepository) UpdateProfile(user *models.User) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.activeUser(user.ID)
+	if !ok {
+		return nil
+	}
+	if s.emailTaken(user.Email, user.ID) {
+		return gorm.ErrDuplicatedKey
+	}
+	stored.Email = user.Email
+	stored.FirstName = user.FirstName
+	stored.LastName = user.LastName
+	stored.EmailVerifiedAt = user.EmailVerifiedAt
+	stored.UpdatedAt = time.Now()
+	s.users[user.ID] = stored
+	return nil
+}
+
+func (r *UserRepository) SetRoles(id uint, roleIDs []uint) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, roleID := range roleIDs {
+		if err := s.checkUserRole(id, roleID); err != nil {
+			return err
+		}
+	}
+	s.userRoles[id] = nil
+	for _, roleID := range roleIDs {
+		s.linkRole(id, roleID)
+	}
+	return nil
+}
+
+func (r *UserRepository) AddRole(id, roleID uint) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkUserRole(id, roleID); err != nil {
+		return err
+	}
+	s.linkRole(id, roleID)
+	return nil
+}
+
+func (r *UserRepository) RemoveRole(id, roleID uint) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := s.userRoles[id]
+	for i, linked := range ids {
+		if linked == roleID {
+			s.userRoles[id] = append(ids[:i:i], ids[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (r *UserRepository) UpdatePassword(id uint, hashedPassword string) error {
+	r.store.updateUser(id, func(user *models.User) bool {
+		user.Password = hashedPassword
+		return true
+	})
+	return nil
+}
+
+func (r *UserRepository) MarkEmailVerified(id uint, email string, at time.Time) (bool, error) {
+	return r.store.updateUser(id, func(user *models.User) bool {
+		if user.Email != email {
+			return false
+		}
+		user.EmailVerifiedAt = &at
+		return true
+	}), nil
+}
+
+func (r *UserRepository) Suspend(id uint, reason string, at time.Time) error {
+	r.store.updateUser(id, func(user *models.User) bool {
+		user.SuspendedAt = &at
+		user.SuspensionReason = reason
+		user.UpdatedAt = time.Now()
+		return true
+	})
+	return nil
+}
+
+func (r *UserRepository) Reactivate(id uint) error {
+	r.store.updateUser(id, func(user *models.User) bool {
+		user.SuspendedAt = nil
+		user.SuspensionReason = ""
+		user.UpdatedAt = time.Now()
+		return true
+	})
+	return nil
+}
+
+func (r *UserRepository) Delete(id uint) error {
+	r.store.updateUser(id, func(user *models.User) bool {
+		user.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+		return true
+	})
+	return nil
+}
+
+func (r *UserRepository) Restore(id uint) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok || !user.DeletedAt.Valid {
+		return gorm.ErrRecordNotFound
+	}
+	user.DeletedAt = gorm.DeletedAt{}
+	s.users[id] = user
+	return nil
+}
+
+// List returns users with their Roles but, like Preload("Roles"), without their permissions
+func (r *UserRepository) List(filter repository.UserFilter, page repository.PageRequest) (*repository.Page[models.User], error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := make([]models.User, 0, len(s.users))
+	for _, id := range sortedIDs(s.users) {
+		user := s.users[id]
+		if !matchesUserFilter(&user, filter) {
+			continue
+		}
+		if filter.RoleID != 0 && !s.hasRole(id, filter.RoleID) {
+			continue
+		}
+		if filter.OrganizationID != 0 && s.findMembership(filter.OrganizationID, id) == nil {
+			continue
+		}
+		user.Roles = s.preloadRoles(id, false)
+		users = append(users, user)
+	}
+	return paginate(users, repository.UserSortFields, page, func(user *models.User) uint { return user.ID })
+}
+
+// matchesUserFilter mirrors the WHERE clause of the GORM List, ILIKE included. The
+// role and the organization are checked by List, they live in userRoles and memberships.
+func matchesUserFilter(user *models.User, filter repository.UserFilter) bool {
+	if user.DeletedAt.Valid != (filter.Status == repository.UserStatusDeleted) {
+		return false
+	}
+	switch filter.Status {
+	case repository.UserStatusActive:
+		if user.SuspendedAt != nil {
+			return false
+		}
+	case repository.UserStatusSuspended:
+		if user.SuspendedAt == nil {
+			return false
+		}
+	}
+	if filter.EmailDomain != "" && !strings.HasSuffix(strings.ToLower(user.Email), "@"+strings.ToLower(filter.EmailDomain)) {
+		return false
+	}
+	if filter.CreatedAfter != nil && user.CreatedAt.Before(*filter.CreatedAfter) {
+		return false
+	}
+	if filter.CreatedBefore != nil && !user.CreatedAt.Before(*filter.CreatedBefore) {
+		return false
+	}
+	if filter.Search != "" {
+		search := strings.ToLower(filter.Search)
+		name := strings.ToLower(user.FirstName + " " + user.LastName)
+		if !strings.Contains(strings.ToLower(user.Email), search) && !strings.Contains(name, search) {
+			return false
+		}
+	}
+	return true
+}
+
+// emailTaken reports whether another user has email. Soft deleted users count
+// since the unique index in Postgres covers them too.
+func (s *Store) emailTaken(email string, exceptID uint) bool {
+	for id, user := range s.users {
+		if id != exceptID && user.Email == email {
+			return true
+		}
+	}
+	return false
+}
+
+// activeUser returns a copy of the user unless it is missing or soft deleted
+func (s *Store) activeUser(id uint) (models.User, bool) {
+	user, ok := s.users[id]
+	if !ok || user.DeletedAt.Valid {
+		return models.User{}, false
+	}
+	return user, true
+}
+
+// updateUser applies update to an active user under the lock, update reports whether the
+// row matched. It returns whether a row was changed, like RowsAffected.
+func (s *Store) updateUser(id uint, update func(user *models.User) bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.activeUser(id)
+	if !ok || !update(&user) {
+		return false
+	}
+	s.users[id] = user
+	return true
+}
+
+// storedUser strips the associations, they are loaded from their own tables
+func storedUser(user models.User) models.User {
+	user.Roles = nil
+	return user
+}
+
+// checkUserRole fails like the foreign keys of user_roles when the user or role is missing
+func (s *Store) checkUserRole(userID, roleID uint) error {
+	_, userExists := s.users[userID]
+	_, roleExists := s.roles[roleID]
+	if !userExists || !roleExists {
+		return gorm.ErrForeignKeyViolated
+	}
+	return nil
+}
+
+// linkRole grants a role once, like the primary key of user_roles
+func (s *Store) linkRole(userID, roleID uint) {
+	if !s.hasRole(userID, roleID) {
+		s.userRoles[userID] = append(s.userRoles[userID], roleID)
+	}
+}
+
+func (s *Store) hasRole(userID, roleID uint) bool {
+	for _, id := range s.userRoles[userID] {
+		if id == roleID {
+			return true
+		}
+	}
+	return false
+}
+
+// preloadRoles returns the roles of a user in the order they were granted
+func (s *Store) preloadRoles(userID uint, withPermissions bool) []models.Role {
+	roles := make([]models.Role, 0, len(s.userRoles[userID]))
+	for _, id := range s.userRoles[userID] {
+		role, ok := s.roles[id]
+		if !ok || role.DeletedAt.Valid {
+			continue
+		}
+		if withPermissions {
+			role = s.preloadHierarchy(role)
+		}
+		roles = append(roles, role)
+	}
+	return roles
+}
@@ -0,0 +1,263 @@
+package memory
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository"
+	"gorm.io/gorm"
+)
+
+func TestUserRepository(t *testing.T) {
+	store := NewStore()
+	users := NewUserRepository(store)
+	roles := NewRoleRepository(store)
+
+	role := models.Role{
+		Name:        "admin",
+		Permissions: []models.Permission{{Name: "admin"}},
+	}
+	require.NoError(t, roles.Create(&role))
+
+	hashedPassword, err := models.HashPassword("password123")
+	require.NoError(t, err)
+	user := models.User{Email: "test@example.com", Password: hashedPassword, Roles: []models.Role{role}}
+	require.NoError(t, users.Create(&user))
+	assert.NotZero(t, user.ID)
+	assert.True(t, user.CheckPassword("password123"))
+
+	t.Run("saving a loaded user keeps the password", func(t *testing.T) {
+		loaded, err := users.FindByID(user.ID)
+		require.NoError(t, err)
+		require.NoError(t, users.Update(loaded))
+		loaded, err = users.FindByID(user.ID)
+		require.NoError(t, err)
+		assert.True(t, loaded.CheckPassword("password123"))
+	})
+
+	t.Run("unique email", func(t *testing.T) {
+		err := users.Create(&models.User{Email: user.Email, Password: "password123"})
+		assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+	})
+
+	t.Run("unknown roles are rejected", func(t *testing.T) {
+		err := users.Create(&models.User{Email: "other@example.com", Password: "password123", Roles: []models.Role{{Model: gorm.Model{ID: 999}}}})
+		assert.ErrorIs(t, err, gorm.ErrForeignKeyViolated)
+		assert.ErrorIs(t, users.AddRole(user.ID, 999), gorm.ErrForeignKeyViolated)
+	})
+
+	t.Run("roles are preloaded", func(t *testing.T) {
+		found, err := users.FindByEmail(user.Email)
+		require.NoError(t, err)
+		require.Len(t, found.Roles, 1)
+		assert.Equal(t, "admin", found.Roles[0].Name)
+		assert.True(t, found.HasPermission("admin"))
+
+		found, err = users.FindByID(user.ID)
+		require.NoError(t, err)
+		assert.True(t, found.HasPermission("admin"))
+
+		listed, err := users.List(repository.UserFilter{}, repository.PageRequest{})
+		require.NoError(t, err)
+		require.Len(t, listed.Items, 1)
+		assert.Equal(t, "admin", listed.Items[0].Roles[0].Name)
+		assert.Empty(t, listed.Items[0].Roles[0].Permissions, "List only preloads Roles")
+	})
+
+	t.Run("permissions add up across roles", func(t *testing.T) {
+		support := models.Role{Name: "support", Permissions: []models.Permission{{Name: "users:read"}}}
+		require.NoError(t, roles.Create(&support))
+		require.NoError(t, users.AddRole(user.ID, support.ID))
+		require.NoError(t, users.AddRole(user.ID, support.ID), "granting twice is a no-op")
+
+		found, err := users.FindByID(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []uint{role.ID, support.ID}, found.RoleIDs())
+		assert.True(t, found.HasPermission("admin"))
+		assert.True(t, found.HasPermission("users:read"))
+		assert.Len(t, found.Permissions(), 2)
+
+		require.NoError(t, users.RemoveRole(user.ID, support.ID))
+		found, err = users.FindByID(user.ID)
+		require.NoError(t, err)
+		assert.False(t, found.HasPermission("users:read"))
+
+		require.NoError(t, users.SetRoles(user.ID, []uint{support.ID}))
+		found, err = users.FindByID(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []uint{support.ID}, found.RoleIDs())
+		require.NoError(t, users.SetRoles(user.ID, []uint{role.ID}))
+	})
+
+	t.Run("returned users are copies", func(t *testing.T) {
+		found, err := users.FindByID(user.ID)
+		require.NoError(t, err)
+		found.FirstName = "Changed"
+
+		again, err := users.FindByID(user.ID)
+		require.NoError(t, err)
+		assert.Empty(t, again.FirstName)
+	})
+
+	t.Run("soft delete", func(t *testing.T) {
+		require.NoError(t, users.Delete(user.ID))
+
+		_, err := users.FindByID(user.ID)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		_, err = users.FindByEmail(user.Email)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		listed, err := users.List(repository.UserFilter{}, repository.PageRequest{})
+		require.NoError(t, err)
+		assert.Empty(t, listed.Items)
+		listed, err = users.List(repository.UserFilter{Status: repository.UserStatusDeleted}, repository.PageRequest{})
+		require.NoError(t, err)
+		assert.Len(t, listed.Items, 1)
+
+		// The unique index still covers deleted users
+		err = users.Create(&models.User{Email: user.Email, Password: "password123"})
+		assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+	})
+}
+
+func TestUserRepositoryConcurrentCreate(t *testing.T) {
+	users := NewUserRepository(NewStore())
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Every other goroutine races for the same email
+			email := fmt.Sprintf("user%d@example.com", i)
+			if i%2 == 0 {
+				email = "same@example.com"
+			}
+			errs <- users.Create(&models.User{Email: email})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	duplicates := 0
+	for err := range errs {
+		if err != nil {
+			assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+			duplicates++
+		}
+	}
+	assert.Equal(t, 9, duplicates)
+
+	listed, err := users.List(repository.UserFilter{}, repository.PageRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), listed.Total)
+}
+
+func TestUserRepositoryList(t *testing.T) {
+	store := NewStore()
+	users := NewUserRepository(store)
+	roles := NewRoleRepository(store)
+
+	admin := models.Role{Name: "admin"}
+	require.NoError(t, roles.Create(&admin))
+	member := models.Role{Name: "user"}
+	require.NoError(t, roles.Create(&member))
+
+	names := []string{"Carol", "Alice", "Bob", "Dave", "Erin"}
+	for i, name := range names {
+		user := models.User{
+			Email:     strings.ToLower(name) + "@example.com",
+			Password:  "password123",
+			FirstName: name,
+			LastName:  "Smith_" + fmt.Sprint(i),
+			Roles:     []models.Role{member},
+		}
+		if i == 0 {
+			user.Email = "carol@corp.test"
+			user.Roles = []models.Role{admin}
+		}
+		require.NoError(t, users.Create(&user))
+	}
+	require.NoError(t, users.Suspend(3, "spam", time.Now()))
+	require.NoError(t, users.Delete(4))
+
+	emails := func(page *repository.Page[models.User]) []string {
+		var emails []string
+		for _, user := range page.Items {
+			emails = append(emails, user.Email)
+		}
+		return emails
+	}
+	list := func(filter repository.UserFilter, page repository.PageRequest) *repository.Page[models.User] {
+		result, err := users.List(filter, page)
+		require.NoError(t, err)
+		return result
+	}
+
+	t.Run("filters", func(t *testing.T) {
+		assert.Equal(t, int64(4), list(repository.UserFilter{}, repository.PageRequest{}).Total)
+		assert.Equal(t, []string{"carol@corp.test"}, emails(list(repository.UserFilter{RoleID: admin.ID}, repository.PageRequest{})))
+		assert.Equal(t, []string{"carol@corp.test"}, emails(list(repository.UserFilter{EmailDomain: "CORP.test"}, repository.PageRequest{})))
+		assert.Equal(t, []string{"bob@example.com"}, emails(list(repository.UserFilter{Status: repository.UserStatusSuspended}, repository.PageRequest{})))
+		assert.Equal(t, []string{"dave@example.com"}, emails(list(repository.UserFilter{Status: repository.UserStatusDeleted}, repository.PageRequest{})))
+		assert.Len(t, list(repository.UserFilter{Status: repository.UserStatusActive}, repository.PageRequest{}).Items, 3)
+
+		future := time.Now().Add(time.Hour)
+		assert.Empty(t, list(repository.UserFilter{CreatedAfter: &future}, repository.PageRequest{}).Items)
+		assert.Len(t, list(repository.UserFilter{CreatedBefore: &future}, repository.PageRequest{}).Items, 4)
+	})
+
+	t.Run("search", func(t *testing.T) {
+		assert.Equal(t, []string{"alice@example.com"}, emails(list(repository.UserFilter{Search: "ALICE"}, repository.PageRequest{})))
+		assert.Equal(t, []string{"erin@example.com"}, emails(list(repository.UserFilter{Search: "erin smith"}, repository.PageRequest{})))
+		// LIKE wildcards match literally
+		assert.Len(t, list(repository.UserFilter{Search: "_"}, repository.PageRequest{}).Items, 4)
+		assert.Empty(t, list(repository.UserFilter{Search: "%"}, repository.PageRequest{}).Items)
+	})
+
+	t.Run("sort and offset", func(t *testing.T) {
+		page := list(repository.UserFilter{}, repository.PageRequest{Sort: "first_name", Desc: true, Limit: 2, Offset: 1})
+		assert.Equal(t, []string{"carol@corp.test", "bob@example.com"}, emails(page))
+		assert.Equal(t, int64(4), page.Total)
+
+		_, err := users.List(repository.UserFilter{}, repository.PageRequest{Sort: "password"})
+		assert.ErrorIs(t, err, repository.ErrInvalidSort)
+	})
+
+	t.Run("cursor", func(t *testing.T) {
+		request := repository.PageRequest{Sort: "email", Limit: 3}
+		first := list(repository.UserFilter{}, request)
+		assert.Equal(t, []string{"alice@example.com", "bob@example.com", "carol@corp.test"}, emails(first))
+		require.NotEmpty(t, first.NextCursor)
+
+		request.Cursor = first.NextCursor
+		second := list(repository.UserFilter{}, request)
+		assert.Equal(t, []string{"erin@example.com"}, emails(second))
+		assert.Empty(t, second.NextCursor)
+
+		// Descending pages follow each other without gaps, ties broken by id
+		var collected []string
+		request = repository.PageRequest{Sort: "created_at", Desc: true, Limit: 2}
+		for {
+			page := list(repository.UserFilter{}, request)
+			collected = append(collected, emails(page)...)
+			if page.NextCursor == "" {
+				break
+			}
+			request.Cursor = page.NextCursor
+		}
+		assert.Equal(t, []string{"erin@example.com", "bob@example.com", "alice@example.com", "carol@corp.test"}, collected)
+
+		// A cursor only continues the order it was made for
+		_, err := users.List(repository.UserFilter{}, repository.PageRequest{Sort: "id", Cursor: first.NextCursor})
+		assert.ErrorIs(t, err, repository.ErrInvalidCursor)
+		_, err = users.List(repository.UserFilter{}, repository.PageRequest{Cursor: "not a cursor"})
+		assert.ErrorIs(t, err, repository.ErrInvalidCursor)
+	})
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+)
+
+type GormMFARepository struct {
+	db *gorm.DB
+}
+
+func NewMFARepository(db *gorm.DB) *GormMFARepository {
+	return &GormMFARepository{
+		db: db,
+	}
+}
+
+// SetPendingSecret stores a TOTP secret that is not active until EnableTOTP is called
+func (r *GormMFARepository) SetPendingSecret(userID uint, secret string) error {
+	return r.db.Model(&models.User{}).Where("id = ? AND mfa_enabled_at IS NULL", userID).
+		UpdateColumns(map[string]interface{}{"totp_secret": secret, "totp_last_step": 0}).Error
+}
+
+// EnableTOTP activates the pending secret and replaces the recovery codes in one transaction
+func (r *GormMFARepository) EnableTOTP(userID uint, step int64, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.User{}).Where("id = ?", userID).
+			UpdateColumns(map[string]interface{}{"mfa_enabled_at": time.Now(), "totp_last_step": step}).Error
+		if err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+// DisableTOTP removes the secret and every recovery code
+func (r *GormMFARepository) DisableTOTP(userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.User{}).Where("id = ?", userID).
+			UpdateColumns(map[string]interface{}{"mfa_enabled_at": nil, "totp_secret": "", "totp_last_step": 0}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// AdvanceTOTPStep records step as used, returning false if it or a later step was used already
+func (r *GormMFARepository) AdvanceTOTPStep(userID uint, step int64) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		UpdateColumn("totp_last_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *GormMFARepository) ReplaceRecoveryCodes(userID uint, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+// ConsumeRecoveryCode marks an unused recovery code of the user as used, returning false if there is none
+func (r *GormMFARepository) ConsumeRecoveryCode(userID uint, codeHash string) (bool, error) {
+	result := r.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *GormMFARepository) CountUnusedRecoveryCodes(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+func (r *GormMFARepository) CreateChallenge(challenge *models.MFAChallenge) error {
+	return r.db.Create(challenge).Error
+}
+
+func (r *GormMFARepository) FindChallengeByHash(hash string) (*models.MFAChallenge, error) {
+	var challenge models.MFAChallenge
+	err := r.db.Where("token_hash = ?", hash).First(&challenge).Error
+	if err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// RecordChallengeAttempt counts an attempt on an open challenge, returning false once
+// the challenge is used, expired or out of attempts
+func (r *GormMFARepository) RecordChallengeAttempt(id uint, maxAttempts int, now time.Time) (bool, error) {
+	result := r.db.Model(&models.MFAChallenge{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ? AND attempts < ?", id, now, maxAttempts).
+		UpdateColumn("attempts", gorm.Expr("attempts + 1"))
+	return result.RowsAffected == 1, result.Error
+}
+
+// ConsumeChallenge marks the challenge as used, returning false if it was used already
+func (r *GormMFARepository) ConsumeChallenge(id uint) (bool, error) {
+	result := r.db.Model(&models.MFAChallenge{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, codeHashes []string) error {
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	codes := make([]models.RecoveryCode, 0, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes = append(codes, models.RecoveryCode{UserID: userID, CodeHash: hash})
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	return tx.Create(&codes).Error
+}
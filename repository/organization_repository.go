@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GormOrganizationRepository struct {
+	db *gorm.DB
+}
+
+func NewOrganizationRepository(db *gorm.DB) *GormOrganizationRepository {
+	return &GormOrganizationRepository{
+		db: db,
+	}
+}
+
+func (r *GormOrganizationRepository) Create(organization *models.Organization) error {
+	return r.db.Create(organization).Error
+}
+
+func (r *GormOrganizationRepository) FindByID(id uint) (*models.Organization, error) {
+	var organization models.Organization
+	err := r.db.First(&organization, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &organization, nil
+}
+
+func (r *GormOrganizationRepository) List() ([]models.Organization, error) {
+	var organizations []models.Organization
+	err := r.db.Order("id").Find(&organizations).Error
+	return organizations, err
+}
+
+func (r *GormOrganizationRepository) Update(organization *models.Organization) error {
+	result := r.db.Model(&models.Organization{}).Where("id = ?", organization.ID).Updates(map[string]interface{}{
+		"name":        organization.Name,
+		"description": organization.Description,
+	})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// Delete removes the organization for good, its memberships cascade
+func (r *GormOrganizationRepository) Delete(id uint) error {
+	result := r.db.Unscoped().Delete(&models.Organization{}, id)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// SetMember inserts the membership or changes the role of the existing one
+func (r *GormOrganizationRepository) SetMember(membership *models.Membership) error {
+	return setMember(r.db, membership)
+}
+
+func setMember(db *gorm.DB, membership *models.Membership) error {
+	membership.UpdatedAt = time.Now()
+	return db.Omit("Organization", "User", "Role").Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "organization_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role_id", "updated_at"}),
+	}).Create(membership).Error
+}
+
+func (r *GormOrganizationRepository) RemoveMember(organizationID, userID uint) error {
+	result := r.db.Where("organization_id = ? AND user_id = ?", organizationID, userID).Delete(&models.Membership{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+func (r *GormOrganizationRepository) FindMembership(organizationID, userID uint) (*models.Membership, error) {
+	var membership models.Membership
+	err := r.db.Preload("Role.Permissions").
+		Where("organization_id = ? AND user_id = ?", organizationID, userID).
+		First(&membership).Error
+	if err != nil {
+		return nil, err
+	}
+	roles := []models.Role{membership.Role}
+	if err := loadAncestors(r.db, roles); err != nil {
+		return nil, err
+	}
+	membership.Role = roles[0]
+	return &membership, nil
+}
+
+// ListMembers leaves out soft deleted users, they keep their memberships until restored
+func (r *GormOrganizationRepository) ListMembers(organizationID uint) ([]models.Membership, error) {
+	var memberships []models.Membership
+	err := r.db.Preload("User").Preload("Role").
+		Where("organization_id = ?", organizationID).
+		Where("user_id IN (?)", r.db.Model(&models.User{}).Select("id")).
+		Order("id").
+		Find(&memberships).Error
+	return memberships, err
+}
+
+func (r *GormOrganizationRepository) ListForUser(userID uint) ([]models.Membership, error) {
+	var memberships []models.Membership
+	err := r.db.Preload("Organization").Preload("Role").
+		Where("user_id = ?", userID).
+		Order("id").
+		Find(&memberships).Error
+	return memberships, err
+}
@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("invalid sort field")
+)
+
+// PageRequest selects one page of a sorted list. With a Cursor from a previous page the
+// list continues after that page's last item and Offset is ignored; a cursor stays
+// stable when rows are inserted or deleted, an offset does not.
+type PageRequest struct {
+	Limit  int
+	Offset int
+	Cursor string
+	// Sort is one of the list's sort fields, ties are broken by ID
+	Sort string
+	Desc bool
+}
+
+// Normalized applies the default and maximum page size and sorts by ID when Sort is empty
+func (p PageRequest) Normalized() PageRequest {
+	if p.Limit <= 0 {
+		p.Limit = DefaultPageSize
+	}
+	if p.Limit > MaxPageSize {
+		p.Limit = MaxPageSize
+	}
+	if p.Offset < 0 {
+		p.Offset = 0
+	}
+	if p.Sort == "" {
+		p.Sort = "id"
+	}
+	return p
+}
+
+// Page is one page of a list. Total counts every item matching the filter, NextCursor
+// is empty on the last page.
+type Page[T any] struct {
+	Items      []T
+	Total      int64
+	NextCursor string
+}
+
+// SortField is a field a list can be sorted by. Value returns the field of an item and
+// must return a string, uint or time.Time.
+type SortField[T any] struct {
+	Column string
+	Value  func(item *T) interface{}
+}
+
+// User statuses UserFilter.Status accepts. Deleted users are only listed with UserStatusDeleted.
+const (
+	UserStatusActive    = "active"
+	UserStatusSuspended = "suspended"
+	UserStatusDeleted   = "deleted"
+)
+
+// UserFilter narrows a user list, zero fields match every user
+type UserFilter struct {
+	RoleID uint
+	// OrganizationID matches the members of the organization
+	OrganizationID uint
+	// EmailDomain matches the part after the @, case-insensitively
+	EmailDomain string
+	// CreatedAfter is inclusive, CreatedBefore exclusive
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// Status is empty for every user that is not deleted, or one of the UserStatus values
+	Status string
+	// Search matches a substring of the email or full name, case-insensitively
+	Search string
+}
+
+// UserSortFields are the fields users can be sorted by
+var UserSortFields = map[string]SortField[models.User]{
+	"id":         {Column: "id", Value: func(u *models.User) interface{} { return u.ID }},
+	"email":      {Column: "email", Value: func(u *models.User) interface{} { return u.Email }},
+	"first_name": {Column: "first_name", Value: func(u *models.User) interface{} { return u.FirstName }},
+	"last_name":  {Column: "last_name", Value: func(u *models.User) interface{} { return u.LastName }},
+	"created_at": {Column: "created_at", Value: func(u *models.User) interface{} { return u.CreatedAt }},
+	"updated_at": {Column: "updated_at", Value: func(u *models.User) interface{} { return u.UpdatedAt }},
+}
+
+// cursor is the position after the last item of a page. It records the sort it was
+// made for, so it cannot be replayed against a different order.
+type cursor struct {
+	Sort  string          `json:"s"`
+	Desc  bool            `json:"d,omitempty"`
+	Value json.RawMessage `json:"v"`
+	ID    uint            `json:"i"`
+}
+
+// EncodeCursor returns the opaque cursor of item for the page's sort
+func EncodeCursor[T any](page PageRequest, field SortField[T], item *T, id uint) string {
+	value, err := json.Marshal(field.Value(item))
+	if err != nil {
+		return ""
+	}
+	raw, err := json.Marshal(cursor{Sort: page.Sort, Desc: page.Desc, Value: value, ID: id})
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeCursor returns the sort value and ID stored in the page's cursor, typed like the
+// values of field. It fails with ErrInvalidCursor when the cursor was not made for this sort.
+func DecodeCursor[T any](page PageRequest, field SortField[T]) (interface{}, uint, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(page.Cursor)
+	if err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.Sort != page.Sort || c.Desc != page.Desc {
+		return nil, 0, ErrInvalidCursor
+	}
+
+	var value interface{}
+	switch field.Value(new(T)).(type) {
+	case string:
+		var s string
+		err = json.Unmarshal(c.Value, &s)
+		value = s
+	case uint:
+		var u uint
+		err = json.Unmarshal(c.Value, &u)
+		value = u
+	case time.Time:
+		var t time.Time
+		err = json.Unmarshal(c.Value, &t)
+		value = t
+	default:
+		err = ErrInvalidSort
+	}
+	if err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+	return value, c.ID, nil
+}
+
+// paginate counts and loads the page of query's rows, loading preloads on the page only.
+// Cursors continue with a keyset condition on the sort column and id.
+func paginate[T any](query *gorm.DB, fields map[string]SortField[T], page PageRequest, id func(item *T) uint, preloads ...string) (*Page[T], error) {
+	page = page.Normalized()
+	field, ok := fields[page.Sort]
+	if !ok {
+		return nil, ErrInvalidSort
+	}
+
+	result := &Page[T]{}
+	if err := query.Session(&gorm.Session{}).Model(new(T)).Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+
+	direction, operator := "ASC", ">"
+	if page.Desc {
+		direction, operator = "DESC", "<"
+	}
+	query = query.Session(&gorm.Session{})
+	if page.Cursor != "" {
+		value, lastID, err := DecodeCursor(page, field)
+		if err != nil {
+			return nil, err
+		}
+		if field.Column == "id" {
+			query = query.Where("id "+operator+" ?", lastID)
+		} else {
+			query = query.Where(fmt.Sprintf("(%s, id) %s (?, ?)", field.Column, operator), value, lastID)
+		}
+	} else {
+		query = query.Offset(page.Offset)
+	}
+	for _, preload := range preloads {
+		query = query.Preload(preload)
+	}
+
+	var items []T
+	err := query.Order(fmt.Sprintf("%s %s, id %s", field.Column, direction, direction)).
+		Limit(page.Limit + 1).
+		Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(items) > page.Limit {
+		items = items[:page.Limit]
+		last := &items[len(items)-1]
+		result.NextCursor = EncodeCursor(page, field, last, id(last))
+	}
+	result.Items = items
+	return result, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike makes s match itself literally in a LIKE pattern
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+)
+
+type GormPermissionRepository struct {
+	db *gorm.DB
+}
+
+func NewPermissionRepository(db *gorm.DB) *GormPermissionRepository {
+	return &GormPermissionRepository{
+		db: db,
+	}
+}
+
+// Create runs in a savepoint inside a transaction, so a taken name leaves it usable
+func (r *GormPermissionRepository) Create(permission *models.Permission) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(permission).Error
+	})
+}
+
+func (r *GormPermissionRepository) FindByID(id uint) (*models.Permission, error) {
+	var permission models.Permission
+	err := r.db.First(&permission, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &permission, nil
+}
+
+func (r *GormPermissionRepository) List() ([]models.Permission, error) {
+	var permissions []models.Permission
+	err := r.db.Order("id").Find(&permissions).Error
+	return permissions, err
+}
+
+func (r *GormPermissionRepository) Update(permission *models.Permission) error {
+	result := r.db.Model(permission).Select("Name", "Description").Updates(permission)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// Delete removes the permission for good, a soft deleted permission would keep its name taken
+func (r *GormPermissionRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM role_permissions WHERE permission_id = ?", id).Error; err != nil {
+			return err
+		}
+		result := tx.Unscoped().Delete(&models.Permission{}, id)
+		if result.Error == nil && result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return result.Error
+	})
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+)
+
+type GormPolicyRepository struct {
+	db *gorm.DB
+}
+
+func NewPolicyRepository(db *gorm.DB) *GormPolicyRepository {
+	return &GormPolicyRepository{
+		db: db,
+	}
+}
+
+func (r *GormPolicyRepository) Create(policy *models.Policy) error {
+	return r.db.Create(policy).Error
+}
+
+func (r *GormPolicyRepository) FindByID(id uint) (*models.Policy, error) {
+	var policy models.Policy
+	err := r.db.First(&policy, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r *GormPolicyRepository) List() ([]models.Policy, error) {
+	var policies []models.Policy
+	err := r.db.Order("id").Find(&policies).Error
+	return policies, err
+}
+
+// Delete removes the policy for good, so its name can be used again
+func (r *GormPolicyRepository) Delete(id uint) error {
+	result := r.db.Unscoped().Delete(&models.Policy{}, id)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
@@ -0,0 +1,253 @@
+// Package repository defines the data access interfaces of the service. The Gorm*
+// types implement them on top of Postgres, package memory implements them in process.
+//
+// Lookups of missing rows fail with gorm.ErrRecordNotFound and unique constraint
+// violations with gorm.ErrDuplicatedKey, whatever the implementation.
+package repository
+
+import (
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+)
+
+type UserRepository interface {
+	// Create stores the user and grants the existing roles in user.Roles, a missing role
+	// fails with gorm.ErrForeignKeyViolated
+	Create(user *models.User) error
+	// FindByEmail returns the user with the permissions and ancestors of its Roles loaded
+	FindByEmail(email string) (*models.User, error)
+	// FindByID returns the user with the permissions and ancestors of its Roles loaded
+	FindByID(id uint) (*models.User, error)
+	// Update saves every column of user, roles only change through SetRoles, AddRole and RemoveRole
+	Update(user *models.User) error
+	// UpdateProfile saves email, names and email verification without touching the password
+	UpdateProfile(user *models.User) error
+	// SetRoles replaces the roles of the user, a missing role fails with gorm.ErrForeignKeyViolated
+	SetRoles(id uint, roleIDs []uint) error
+	// AddRole grants a role, granting a role twice is a no-op
+	AddRole(id, roleID uint) error
+	RemoveRole(id, roleID uint) error
+	UpdatePassword(id uint, hashedPassword string) error
+	MarkEmailVerified(id uint, email string, at time.Time) (bool, error)
+	Suspend(id uint, reason string, at time.Time) error
+	Reactivate(id uint) error
+	// Delete soft deletes the user, the email stays taken
+	Delete(id uint) error
+	// Restore undoes Delete, it fails with gorm.ErrRecordNotFound unless the user is soft deleted
+	Restore(id uint) error
+	// List returns a page of the users matching filter with Roles loaded. It fails with
+	// ErrInvalidSort or ErrInvalidCursor when the page asks for an unknown order.
+	List(filter UserFilter, page PageRequest) (*Page[models.User], error)
+}
+
+type RoleRepository interface {
+	// Create stores the role and links the existing permissions in role.Permissions
+	Create(role *models.Role) error
+	// FindByID returns the role with its permissions and its ancestors in Parents
+	FindByID(id uint) (*models.Role, error)
+	// FindByName returns the role with its permissions and its ancestors in Parents
+	FindByName(name string) (*models.Role, error)
+	// List returns every role with its permissions and ancestors
+	List() ([]models.Role, error)
+	// Update saves the name and description of the role
+	Update(role *models.Role) error
+	// Delete removes the role with its permission and parent links and the invitations
+	// offering it for good, so its name can be reused
+	Delete(id uint) error
+	// AttachPermission links a permission to a role, linking it twice is a no-op
+	AttachPermission(roleID, permissionID uint) error
+	DetachPermission(roleID, permissionID uint) error
+	// AddParent lets a role inherit the permissions of parent, adding it twice is a no-op.
+	// Callers check for cycles, the repository does not.
+	AddParent(roleID, parentID uint) error
+	RemoveParent(roleID, parentID uint) error
+	// CountUsers counts the users assigned to the role, globally or in an organization, soft
+	// deleted ones included
+	CountUsers(roleID uint) (int64, error)
+	// CountMemberships counts the memberships with the role, of soft deleted users included
+	CountMemberships(roleID uint) (int64, error)
+	// CountPendingInvitations counts the invitations offering the role that can still be accepted at now
+	CountPendingInvitations(roleID uint, now time.Time) (int64, error)
+}
+
+type OrganizationRepository interface {
+	Create(organization *models.Organization) error
+	FindByID(id uint) (*models.Organization, error)
+	// List returns every organization, oldest first
+	List() ([]models.Organization, error)
+	// Update saves the name and description of the organization
+	Update(organization *models.Organization) error
+	// Delete removes the organization and its memberships for good
+	Delete(id uint) error
+	// SetMember adds the user to the organization or changes their role there. A missing
+	// organization, user or role fails with gorm.ErrForeignKeyViolated
+	SetMember(membership *models.Membership) error
+	RemoveMember(organizationID, userID uint) error
+	// FindMembership returns the membership with the permissions and ancestors of its Role
+	FindMembership(organizationID, userID uint) (*models.Membership, error)
+	// ListMembers returns the memberships of users that are not deleted with their User and
+	// Role, oldest first
+	ListMembers(organizationID uint) ([]models.Membership, error)
+	// ListForUser returns the memberships of the user with their Organization and Role, oldest first
+	ListForUser(userID uint) ([]models.Membership, error)
+}
+
+type InvitationRepository interface {
+	Create(invitation *models.Invitation) error
+	// FindByHash returns the invitation with its Organization and Role
+	FindByHash(hash string) (*models.Invitation, error)
+	// ListPending returns the invitations to the organization that can still be accepted at
+	// now with their Role, oldest first
+	ListPending(organizationID uint, now time.Time) ([]models.Invitation, error)
+	// Accept marks a pending invitation as accepted and makes user a member of its organization
+	// with its role, in one transaction. A user without an ID is created first, an existing one
+	// has the invited email marked as verified. It returns false and changes nothing when the
+	// invitation cannot be redeemed.
+	Accept(id uint, user *models.User, now time.Time) (bool, error)
+	// Revoke marks a pending invitation as revoked, returning false when there is none
+	Revoke(id uint, now time.Time) (bool, error)
+	// RevokePending revokes every invitation of email to the organization that is not accepted yet
+	RevokePending(organizationID uint, email string, now time.Time) error
+}
+
+// ElevationFilter narrows an elevation list, zero fields match every elevation
+type ElevationFilter struct {
+	UserID uint
+	Status string
+}
+
+type ElevationRepository interface {
+	Create(elevation *models.Elevation) error
+	// FindByID returns the elevation with its Role
+	FindByID(id uint) (*models.Elevation, error)
+	// List returns the matching elevations with their User and Role, oldest first
+	List(filter ElevationFilter) ([]models.Elevation, error)
+	// ListActive returns the elevations of the user that grant their role at now, with the
+	// permissions and ancestors of their Role
+	ListActive(userID uint, now time.Time) ([]models.Elevation, error)
+	// Review saves the status, duration, review, expiry and revocation of the elevation if its
+	// stored status is still from, returning false when another change came first
+	Review(elevation *models.Elevation, from string) (bool, error)
+	// Expire marks the approved elevations that ended by now, and the pending ones requested
+	// before pendingBefore, as expired and returns how many it marked
+	Expire(now, pendingBefore time.Time) (int64, error)
+}
+
+type PermissionRepository interface {
+	Create(permission *models.Permission) error
+	FindByID(id uint) (*models.Permission, error)
+	List() ([]models.Permission, error)
+	// Update saves the name and description of the permission
+	Update(permission *models.Permission) error
+	// Delete removes the permission and unlinks it from every role
+	Delete(id uint) error
+}
+
+// GrantFilter narrows a grant list, zero fields match every grant
+type GrantFilter struct {
+	UserID uint
+	RoleID uint
+}
+
+type GrantRepository interface {
+	// Create stores the grant, a missing permission, user or role fails with gorm.ErrForeignKeyViolated
+	Create(grant *models.Grant) error
+	// FindByID returns the grant with its permission
+	FindByID(id uint) (*models.Grant, error)
+	// List returns the grants matching filter with their permissions, oldest first
+	List(filter GrantFilter) ([]models.Grant, error)
+	// Delete removes the grant for good
+	Delete(id uint) error
+	// FindForSubject returns the grants of the user or of any of roleIDs on resources of the
+	// given types, with their permissions
+	FindForSubject(userID uint, roleIDs []uint, resourceTypes []string) ([]models.Grant, error)
+}
+
+type PolicyRepository interface {
+	Create(policy *models.Policy) error
+	FindByID(id uint) (*models.Policy, error)
+	// List returns every policy, oldest first
+	List() ([]models.Policy, error)
+	// Delete removes the policy for good
+	Delete(id uint) error
+}
+
+type RefreshTokenRepository interface {
+	Create(token *models.RefreshToken) error
+	FindByHash(hash string) (*models.RefreshToken, error)
+	Rotate(current *models.RefreshToken, next *models.RefreshToken) (bool, error)
+	RevokeFamily(familyID string) error
+	RevokeAllForUser(userID uint) error
+}
+
+type RevocationRepository interface {
+	RevokeToken(token *models.RevokedToken) error
+	IsTokenRevoked(jti string) (bool, error)
+	RevokeUserTokens(userID uint, before time.Time) error
+	// UserTokenState returns the logout-everywhere cutoff of a user and whether the user is suspended
+	UserTokenState(userID uint) (revokedAt *time.Time, suspended bool, err error)
+	DeleteExpired(now time.Time) error
+}
+
+type PasswordResetRepository interface {
+	Create(token *models.PasswordResetToken) error
+	FindByHash(hash string) (*models.PasswordResetToken, error)
+	Consume(id uint, now time.Time) (bool, error)
+	InvalidateForUser(userID uint) error
+}
+
+type EmailVerificationRepository interface {
+	Create(token *models.EmailVerificationToken) error
+	FindByHash(hash string) (*models.EmailVerificationToken, error)
+	Consume(id uint, now time.Time) (bool, error)
+	InvalidateForUser(userID uint) error
+}
+
+type EmailChangeRepository interface {
+	Create(token *models.EmailChangeToken) error
+	FindByHash(hash string) (*models.EmailChangeToken, error)
+	Consume(id uint, now time.Time) (bool, error)
+	InvalidateForUser(userID uint) error
+}
+
+type MFARepository interface {
+	SetPendingSecret(userID uint, secret string) error
+	EnableTOTP(userID uint, step int64, codeHashes []string) error
+	DisableTOTP(userID uint) error
+	AdvanceTOTPStep(userID uint, step int64) (bool, error)
+	ReplaceRecoveryCodes(userID uint, codeHashes []string) error
+	ConsumeRecoveryCode(userID uint, codeHash string) (bool, error)
+	CountUnusedRecoveryCodes(userID uint) (int64, error)
+	CreateChallenge(challenge *models.MFAChallenge) error
+	FindChallengeByHash(hash string) (*models.MFAChallenge, error)
+	RecordChallengeAttempt(id uint, maxAttempts int, now time.Time) (bool, error)
+	ConsumeChallenge(id uint) (bool, error)
+}
+
+type LoginThrottleRepository interface {
+	// Find returns nil without an error when the key has no throttle
+	Find(key string) (*models.LoginThrottle, error)
+	RecordFailure(key string, now time.Time, window time.Duration) (*models.LoginThrottle, error)
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+	DeleteStale(before time.Time) error
+}
+
+var (
+	_ UserRepository              = (*GormUserRepository)(nil)
+	_ RoleRepository              = (*GormRoleRepository)(nil)
+	_ PermissionRepository        = (*GormPermissionRepository)(nil)
+	_ GrantRepository             = (*GormGrantRepository)(nil)
+	_ PolicyRepository            = (*GormPolicyRepository)(nil)
+	_ OrganizationRepository      = (*GormOrganizationRepository)(nil)
+	_ InvitationRepository        = (*GormInvitationRepository)(nil)
+	_ ElevationRepository         = (*GormElevationRepository)(nil)
+	_ RefreshTokenRepository      = (*GormRefreshTokenRepository)(nil)
+	_ RevocationRepository        = (*GormRevocationRepository)(nil)
+	_ PasswordResetRepository     = (*GormPasswordResetRepository)(nil)
+	_ EmailVerificationRepository = (*GormEmailVerificationRepository)(nil)
+	_ EmailChangeRepository       = (*GormEmailChangeRepository)(nil)
+	_ MFARepository               = (*GormMFARepository)(nil)
+	_ LoginThrottleRepository     = (*GormLoginThrottleRepository)(nil)
+)
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GormRevocationRepository struct {
+	db *gorm.DB
+}
+
+func NewRevocationRepository(db *gorm.DB) *GormRevocationRepository {
+	return &GormRevocationRepository{
+		db: db,
+	}
+}
+
+// RevokeToken stores the jti of an access token, revoking it twice is a no-op
+func (r *GormRevocationRepository) RevokeToken(token *models.RevokedToken) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(token).Error
+}
+
+func (r *GormRevocationRepository) IsTokenRevoked(jti string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
+
+// RevokeUserTokens invalidates every access token of the user issued at or before the given time
+func (r *GormRevocationRepository) RevokeUserTokens(userID uint, before time.Time) error {
+	return r.db.Model(&models.User{}).Where("id = ?", userID).Update("tokens_revoked_at", before).Error
+}
+
+// UserTokenState returns the logout-everywhere cutoff of a user, nil when never set, and whether the user is suspended
+func (r *GormRevocationRepository) UserTokenState(userID uint) (*time.Time, bool, error) {
+	var user models.User
+	err := r.db.Select("id", "tokens_revoked_at", "suspended_at").First(&user, userID).Error
+	if err != nil {
+		return nil, false, err
+	}
+	return user.TokensRevokedAt, user.IsSuspended(), nil
+}
+
+// DeleteExpired removes revocations of tokens that have expired on their own
+func (r *GormRevocationRepository) DeleteExpired(now time.Time) error {
+	return r.db.Unscoped().Where("expires_at < ?", now).Delete(&models.RevokedToken{}).Error
+}
@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"gorm.io/gorm"
+)
+
+type GormRoleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) *GormRoleRepository {
+	return &GormRoleRepository{
+		db: db,
+	}
+}
+
+// Create runs in a savepoint inside a transaction, so a taken name leaves it usable
+func (r *GormRoleRepository) Create(role *models.Role) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(role).Error
+	})
+}
+
+// FindByID returns the role with its permissions and ancestors
+func (r *GormRoleRepository) FindByID(id uint) (*models.Role, error) {
+	var role models.Role
+	err := r.db.Preload("Permissions").First(&role, id).Error
+	if err != nil {
+		return nil, err
+	}
+	roles := []models.Role{role}
+	if err := loadAncestors(r.db, roles); err != nil {
+		return nil, err
+	}
+	return &roles[0], nil
+}
+
+// FindByName returns the role with its permissions and ancestors
+func (r *GormRoleRepository) FindByName(name string) (*models.Role, error) {
+	var role models.Role
+	err := r.db.Preload("Permissions").Where("name = ?", name).First(&role).Error
+	if err != nil {
+		return nil, err
+	}
+	roles := []models.Role{role}
+	if err := loadAncestors(r.db, roles); err != nil {
+		return nil, err
+	}
+	return &roles[0], nil
+}
+
+func (r *GormRoleRepository) List() ([]models.Role, error) {
+	var roles []models.Role
+	if err := r.db.Preload("Permissions").Order("id").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	if err := loadAncestors(r.db, roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r *GormRoleRepository) Update(role *models.Role) error {
+	result := r.db.Model(role).Select("Name", "Description").Updates(role)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// Delete removes the role for good, a soft deleted role would keep its name taken
+func (r *GormRoleRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM role_permissions WHERE role_id = ?", id).Error; err != nil {
+			return err
+		}
+		// Roles inheriting from this one lose what it granted
+		if err := tx.Exec("DELETE FROM role_parents WHERE role_id = ? OR parent_id = ?", id, id).Error; err != nil {
+			return err
+		}
+		// Callers refuse roles of pending invitations, the settled ones only keep the history
+		if err := tx.Exec("DELETE FROM invitations WHERE role_id = ?", id).Error; err != nil {
+			return err
+		}
+		result := tx.Unscoped().Delete(&models.Role{}, id)
+		if result.Error == nil && result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return result.Error
+	})
+}
+
+func (r *GormRoleRepository) AttachPermission(roleID, permissionID uint) error {
+	return r.db.Exec("INSERT INTO role_permissions (role_id, permission_id) VALUES (?, ?) ON CONFLICT DO NOTHING",
+		roleID, permissionID).Error
+}
+
+func (r *GormRoleRepository) DetachPermission(roleID, permissionID uint) error {
+	return r.db.Exec("DELETE FROM role_permissions WHERE role_id = ? AND permission_id = ?", roleID, permissionID).Error
+}
+
+func (r *GormRoleRepository) AddParent(roleID, parentID uint) error {
+	return r.db.Exec("INSERT INTO role_parents (role_id, parent_id) VALUES (?, ?) ON CONFLICT DO NOTHING",
+		roleID, parentID).Error
+}
+
+func (r *GormRoleRepository) RemoveParent(roleID, parentID uint) error {
+	return r.db.Exec("DELETE FROM role_parents WHERE role_id = ? AND parent_id = ?", roleID, parentID).Error
+}
+
+// CountUsers includes soft deleted users, they keep their roles and memberships
+func (r *GormRoleRepository) CountUsers(roleID uint) (int64, error) {
+	var count int64
+	err := r.db.Raw(`SELECT COUNT(*) FROM (
+		SELECT user_id FROM user_roles WHERE role_id = ?
+		UNION SELECT user_id FROM memberships WHERE role_id = ?
+	) AS holders`, roleID, roleID).Scan(&count).Error
+	return count, err
+}
+
+func (r *GormRoleRepository) CountMemberships(roleID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Membership{}).Where("role_id = ?", roleID).Count(&count).Error
+	return count, err
+}
+
+func (r *GormRoleRepository) CountPendingInvitations(roleID uint, now time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Invitation{}).
+		Where("role_id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", roleID, now).
+		Count(&count).Error
+	return count, err
+}
+
+type roleParent struct {
+	RoleID   uint
+	ParentID uint
+}
+
+// loadAncestors fills the Parents of roles with their ancestors, permissions loaded. The
+// links are read with one recursive query, the ancestors with one more.
+func loadAncestors(db *gorm.DB, roles []models.Role) error {
+	if len(roles) == 0 {
+		return nil
+	}
+	ids := make([]uint, 0, len(roles))
+	for _, role := range roles {
+		ids = append(ids, role.ID)
+	}
+
+	var links []roleParent
+	err := db.Raw(`WITH RECURSIVE lineage (role_id, parent_id) AS (
+			SELECT role_id, parent_id FROM role_parents WHERE role_id IN ?
+			UNION
+			SELECT rp.role_id, rp.parent_id FROM role_parents rp JOIN lineage l ON rp.role_id = l.parent_id
+		)
+		SELECT role_id, parent_id FROM lineage ORDER BY role_id, parent_id`, ids).Scan(&links).Error
+	if err != nil || len(links) == 0 {
+		return err
+	}
+
+	parents := make(map[uint][]uint)
+	var parentIDs []uint
+	for _, link := range links {
+		parents[link.RoleID] = append(parents[link.RoleID], link.ParentID)
+		parentIDs = append(parentIDs, link.ParentID)
+	}
+	var ancestors []models.Role
+	if err := db.Preload("Permissions").Find(&ancestors, parentIDs).Error; err != nil {
+		return err
+	}
+	byID := make(map[uint]models.Role, len(ancestors))
+	for _, ancestor := range ancestors {
+		byID[ancestor.ID] = ancestor
+	}
+
+	for i := range roles {
+		roles[i].Parents = buildParents(roles[i].ID, parents, byID, map[uint]bool{roles[i].ID: true})
+	}
+	return nil
+}
+
+// buildParents returns the parents of a role with their own parents filled in. path holds
+// the roles above in the tree, a link back to one of them is skipped instead of recursing forever.
+func buildParents(id uint, parents map[uint][]uint, byID map[uint]models.Role, path map[uint]bool) []models.Role {
+	var result []models.Role
+	for _, parentID := range parents[id] {
+		parent, ok := byID[parentID]
+		if !ok || path[parentID] {
+			continue
+		}
+		path[parentID] = true
+		parent.Parents = buildParents(parentID, parents, byID, path)
+		delete(path, parentID)
+		result = append(result, parent)
+	}
+	return result
+}
@@ -1,53 +1,161 @@
 package repository
 
 import (
-	"github.com/sukhantharot/go-service/config"
+	"time"
+
 	"github.com/sukhantharot/go-service/models"
 	"gorm.io/gorm"
 )
 
-type UserRepository struct {
+type GormUserRepository struct {
 	db *gorm.DB
 }
 
-func NewUserRepository() *UserRepository {
-	return &UserRepository{
-		db: config.DB,
+func NewUserRepository(db *gorm.DB) *GormUserRepository {
+	return &GormUserRepository{
+		db: db,
 	}
 }
 
-func (r *UserRepository) Create(user *models.User) error {
-	return r.db.Create(user).Error
+// Create links the roles in user.Roles without upserting them
+func (r *GormUserRepository) Create(user *models.User) error {
+	return r.db.Omit("Roles.*").Create(user).Error
 }
 
-func (r *UserRepository) FindByEmail(email string) (*models.User, error) {
+func (r *GormUserRepository) FindByEmail(email string) (*models.User, error) {
 	var user models.User
-	err := r.db.Where("email = ?", email).First(&user).Error
+	err := r.db.Preload("Roles.Permissions").Where("email = ?", email).First(&user).Error
 	if err != nil {
 		return nil, err
 	}
+	if err := loadAncestors(r.db, user.Roles); err != nil {
+		return nil, err
+	}
 	return &user, nil
 }
 
-func (r *UserRepository) FindByID(id uint) (*models.User, error) {
+func (r *GormUserRepository) FindByID(id uint) (*models.User, error) {
 	var user models.User
-	err := r.db.Preload("Role").Preload("Role.Permissions").First(&user, id).Error
+	err := r.db.Preload("Roles.Permissions").First(&user, id).Error
 	if err != nil {
 		return nil, err
 	}
+	if err := loadAncestors(r.db, user.Roles); err != nil {
+		return nil, err
+	}
 	return &user, nil
 }
 
-func (r *UserRepository) Update(user *models.User) error {
-	return r.db.Save(user).Error
+func (r *GormUserRepository) Update(user *models.User) error {
+	return r.db.Omit("Roles").Save(user).Error
+}
+
+func (r *GormUserRepository) UpdateProfile(user *models.User) error {
+	return r.db.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"email":             user.Email,
+		"first_name":        user.FirstName,
+		"last_name":         user.LastName,
+		"email_verified_at": user.EmailVerifiedAt,
+	}).Error
+}
+
+func (r *GormUserRepository) SetRoles(id uint, roleIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM user_roles WHERE user_id = ?", id).Error; err != nil {
+			return err
+		}
+		for _, roleID := range roleIDs {
+			if err := addRole(tx, id, roleID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *GormUserRepository) AddRole(id, roleID uint) error {
+	return addRole(r.db, id, roleID)
+}
+
+func (r *GormUserRepository) RemoveRole(id, roleID uint) error {
+	return r.db.Exec("DELETE FROM user_roles WHERE user_id = ? AND role_id = ?", id, roleID).Error
+}
+
+func addRole(db *gorm.DB, id, roleID uint) error {
+	return db.Exec("INSERT INTO user_roles (user_id, role_id) VALUES (?, ?) ON CONFLICT DO NOTHING", id, roleID).Error
+}
+
+// UpdatePassword stores an already hashed password without running the model hooks
+func (r *GormUserRepository) UpdatePassword(id uint, hashedPassword string) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).UpdateColumn("password", hashedPassword).Error
 }
 
-func (r *UserRepository) Delete(id uint) error {
+// MarkEmailVerified records that the user confirmed email, unless it changed in the meantime
+func (r *GormUserRepository) MarkEmailVerified(id uint, email string, at time.Time) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND email = ?", id, email).
+		UpdateColumn("email_verified_at", at)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *GormUserRepository) Suspend(id uint, reason string, at time.Time) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"suspended_at":      at,
+		"suspension_reason": reason,
+	}).Error
+}
+
+func (r *GormUserRepository) Reactivate(id uint) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"suspended_at":      nil,
+		"suspension_reason": "",
+	}).Error
+}
+
+func (r *GormUserRepository) Delete(id uint) error {
 	return r.db.Delete(&models.User{}, id).Error
 }
 
-func (r *UserRepository) List() ([]models.User, error) {
-	var users []models.User
-	err := r.db.Preload("Role").Find(&users).Error
-	return users, err
+// Restore clears deleted_at of a soft deleted user
+func (r *GormUserRepository) Restore(id uint) error {
+	result := r.db.Unscoped().Model(&models.User{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+func (r *GormUserRepository) List(filter UserFilter, page PageRequest) (*Page[models.User], error) {
+	query := r.db.Model(&models.User{})
+	switch filter.Status {
+	case UserStatusActive:
+		query = query.Where("suspended_at IS NULL")
+	case UserStatusSuspended:
+		query = query.Where("suspended_at IS NOT NULL")
+	case UserStatusDeleted:
+		query = query.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+	if filter.RoleID != 0 {
+		query = query.Where("id IN (SELECT user_id FROM user_roles WHERE role_id = ?)", filter.RoleID)
+	}
+	if filter.OrganizationID != 0 {
+		query = query.Where("id IN (SELECT user_id FROM memberships WHERE organization_id = ?)", filter.OrganizationID)
+	}
+	if filter.EmailDomain != "" {
+		query = query.Where("email ILIKE ?", "%@"+escapeLike(filter.EmailDomain))
+	}
+	if filter.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *filter.CreatedBefore)
+	}
+	if filter.Search != "" {
+		pattern := "%" + escapeLike(filter.Search) + "%"
+		query = query.Where("(email ILIKE ? OR first_name || ' ' || last_name ILIKE ?)", pattern, pattern)
+	}
+
+	return paginate(query, UserSortFields, page, func(user *models.User) uint { return user.ID }, "Roles")
 }
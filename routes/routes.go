@@ -4,14 +4,22 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/sukhantharot/go-service/handlers"
+	"github.com/sukhantharot/go-service/app"
 	"github.com/sukhantharot/go-service/middleware"
-	"gorm.io/gorm"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/service"
 )
 
-func SetupRoutes(router *gin.Engine, db *gorm.DB) {
-	// Add logging middleware
+func SetupRoutes(router *gin.Engine, a *app.App) {
+	db := a.DB
+	h := a.Handlers
+	roles := a.Services.Permissions
+	unverifiedPolicy := a.Config.Verification.Policy
+
+	// Tag every request with an ID, log it and render errors added with c.Error
+	router.Use(middleware.RequestID())
 	router.Use(middleware.LoggingMiddleware())
+	router.Use(middleware.ErrorHandler(a.Config.ProblemDetails))
 	router.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
 			"status": "healthy",
@@ -19,6 +27,14 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB) {
 	})
 	// Health check endpoint
 	router.GET("/api/health", func(c *gin.Context) {
+		if db == nil {
+			c.JSON(http.StatusOK, gin.H{
+				"status":   "healthy",
+				"database": "in-memory",
+			})
+			return
+		}
+
 		// Check database connection
 		sqlDB, err := db.DB()
 		if err != nil {
@@ -47,24 +63,145 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB) {
 		})
 	})
 
+	// Public verification keys for services that validate our tokens
+	router.GET("/.well-known/jwks.json", h.Keys.JWKS)
+
 	// Public routes
-	router.POST("/api/auth/register", handlers.Register)
-	router.POST("/api/auth/login", handlers.Login)
+	router.POST("/api/auth/register", h.Auth.Register)
+	router.POST("/api/auth/login", h.Auth.Login)
+	router.POST("/api/auth/login/mfa", h.MFA.LoginMFA)
+	router.POST("/api/auth/refresh", h.Auth.Refresh)
+	router.POST("/api/auth/password/forgot", h.Passwords.ForgotPassword)
+	router.POST("/api/auth/password/reset", h.Passwords.ResetPassword)
+	router.POST("/api/auth/verify-email", h.Verification.VerifyEmail)
+	router.POST("/api/auth/verify-email/resend", h.Verification.ResendVerification)
+	router.POST("/api/auth/email-change/confirm", h.Verification.ConfirmEmailChange)
+	router.POST("/api/invitations/accept", h.Invitations.AcceptInvitation)
 
 	// Protected routes
 	protected := router.Group("/api")
-	protected.Use(middleware.JWTAuth())
+	protected.Use(middleware.JWTAuth(a.Services.Keys.Keyfunc, a.Services.Revocations))
+	if unverifiedPolicy == service.UnverifiedBlock {
+		// Tokens issued before the policy was switched on are still around
+		protected.Use(middleware.RequireVerifiedEmail())
+	}
 	{
+		// Session routes
+		protected.POST("/auth/logout", h.Auth.Logout)
+		protected.POST("/auth/logout-all", h.Auth.LogoutAll)
+		protected.POST("/auth/switch-organization", h.Organizations.SwitchOrganization)
+
 		// User routes
-		protected.GET("/users/me", handlers.GetCurrentUser)
+		protected.GET("/users/me", h.Users.GetCurrentUser)
+		protected.PATCH("/users/me", h.Users.UpdateCurrentUser)
+		protected.POST("/users/me/password", h.Passwords.ChangePassword)
+		protected.POST("/users/me/email", h.Verification.RequestEmailChange)
+		protected.GET("/users/me/organizations", h.Organizations.ListMyOrganizations)
+		protected.GET("/users/me/elevations", h.Elevations.ListMyElevations)
+		protected.POST("/users/me/elevations", h.Elevations.RequestElevation)
+
+		// Users with a grant on a user, e.g. users:write on their own record, need no admin rights
+		access := a.Services.Access
+		// Attribute-based policies can still deny what roles and grants allow
+		policies := a.Services.Policies
+		userPolicies := func(action string) gin.HandlerFunc {
+			return middleware.EnforcePolicies(policies, action, middleware.PolicyResourceParam("id", policies.UserAttributes))
+		}
+		protected.GET("/users/:id", middleware.RequireResourcePermission(access, "users:read", middleware.UserParam("id", access)), userPolicies("users:read"), h.Users.GetUser)
+		protected.PUT("/users/:id", middleware.RequireResourcePermission(access, "users:write", middleware.UserParam("id", access)), userPolicies("users:write"), h.Users.UpdateGrantedUser)
+
+		// Two-factor enrollment stays reachable for users that still have to enroll
+		protected.POST("/users/me/mfa/totp", h.MFA.StartTOTPEnrollment)
+		protected.POST("/users/me/mfa/totp/confirm", h.MFA.ConfirmTOTPEnrollment)
+		protected.POST("/users/me/mfa/totp/disable", h.MFA.DisableTOTP)
+		protected.POST("/users/me/mfa/recovery-codes", h.MFA.RegenerateRecoveryCodes)
+
+		// The active organization of the token, members act on it with the role of their membership
+		organization := protected.Group("/organization")
+		organization.Use(middleware.RequireOrganization())
+		{
+			organization.GET("", h.Organizations.GetCurrentOrganization)
+			organization.GET("/members", middleware.RequireOrganizationPermission(roles, "members:read"), h.Organizations.ListCurrentMembers)
+			organization.PUT("/members/:user_id", middleware.RequireOrganizationPermission(roles, "members:write"), h.Organizations.AssignCurrentMember)
+			organization.DELETE("/members/:user_id", middleware.RequireOrganizationPermission(roles, "members:write"), h.Organizations.RemoveCurrentMember)
+		}
+
+		// Approvers decide on temporary roles without holding the admin permission themselves
+		elevations := protected.Group("/elevations")
+		elevations.Use(middleware.RequireMFA())
+		elevations.Use(middleware.RequireAnyPermission(roles, "elevations:approve", models.PermissionAdmin))
+		{
+			elevations.GET("", h.Elevations.ListElevations)
+			elevations.POST("", h.Elevations.GrantElevation)
+			elevations.POST("/:id/approve", h.Elevations.ApproveElevation)
+			elevations.POST("/:id/deny", h.Elevations.DenyElevation)
+			elevations.POST("/:id/revoke", h.Elevations.RevokeElevation)
+		}
 
 		// Admin routes (example of role-based access)
 		admin := protected.Group("/admin")
-		admin.Use(middleware.RequirePermission("admin"))
+		if unverifiedPolicy == service.UnverifiedRestrict {
+			admin.Use(middleware.RequireVerifiedEmail())
+		}
+		admin.Use(middleware.RequireMFA())
 		{
-			admin.GET("/users", handlers.GetAllUsers)
-			admin.POST("/roles", handlers.CreateRole)
-			admin.POST("/permissions", handlers.CreatePermission)
+			// User management needs only the users:* permission of the route. A role in the
+			// active organization only lists its members, it does not reach single users.
+			canRead := middleware.RequirePermission(roles, "users:read")
+			canWrite := middleware.RequirePermission(roles, "users:write")
+			canDelete := middleware.RequirePermission(roles, "users:delete")
+			admin.GET("/users", middleware.RequireOrganizationPermission(roles, "users:read"), middleware.ScopeToOrganization(roles, "users:read"), middleware.FilterPolicies(policies, "users:read"), h.Users.GetAllUsers)
+			admin.GET("/users/:id", canRead, userPolicies("users:read"), h.Users.GetUser)
+			admin.PUT("/users/:id", canWrite, userPolicies("users:write"), h.Users.UpdateUser)
+			admin.POST("/users/:id/suspend", canWrite, userPolicies("users:write"), h.Users.SuspendUser)
+			admin.POST("/users/:id/reactivate", canWrite, userPolicies("users:write"), h.Users.ReactivateUser)
+			admin.POST("/users/:id/unlock", canWrite, userPolicies("users:write"), h.Users.UnlockAccount)
+			admin.DELETE("/users/:id", canDelete, userPolicies("users:delete"), h.Users.DeleteUser)
+			admin.POST("/users/:id/restore", canDelete, h.Users.RestoreUser)
+
+			// Everything else needs the admin permission, role assignments included as they hand out permissions
+			manage := admin.Group("", middleware.RequirePermission(roles, models.PermissionAdmin))
+			manage.PUT("/users/:id/roles", canWrite, userPolicies("users:write"), h.Users.SetRoles)
+			manage.POST("/users/:id/roles/:role_id", canWrite, userPolicies("users:write"), h.Users.AddRole)
+			manage.DELETE("/users/:id/roles/:role_id", canWrite, userPolicies("users:write"), h.Users.RemoveRole)
+
+			manage.GET("/roles", h.RBAC.ListRoles)
+			manage.POST("/roles", h.RBAC.CreateRole)
+			manage.GET("/roles/:id", h.RBAC.GetRole)
+			manage.PUT("/roles/:id", h.RBAC.UpdateRole)
+			manage.DELETE("/roles/:id", h.RBAC.DeleteRole)
+			manage.POST("/roles/:id/permissions/:permission_id", h.RBAC.AttachPermission)
+			manage.DELETE("/roles/:id/permissions/:permission_id", h.RBAC.DetachPermission)
+			manage.GET("/roles/:id/effective-permissions", h.RBAC.EffectivePermissions)
+			manage.POST("/roles/:id/parents/:parent_id", h.RBAC.AddParent)
+			manage.DELETE("/roles/:id/parents/:parent_id", h.RBAC.RemoveParent)
+
+			manage.GET("/permissions", h.RBAC.ListPermissions)
+			manage.POST("/permissions", h.RBAC.CreatePermission)
+			manage.GET("/permissions/:id", h.RBAC.GetPermission)
+			manage.PUT("/permissions/:id", h.RBAC.UpdatePermission)
+			manage.DELETE("/permissions/:id", h.RBAC.DeletePermission)
+
+			manage.GET("/grants", h.Grants.ListGrants)
+			manage.POST("/grants", h.Grants.CreateGrant)
+			manage.DELETE("/grants/:id", h.Grants.DeleteGrant)
+
+			manage.GET("/organizations", h.Organizations.ListOrganizations)
+			manage.POST("/organizations", h.Organizations.CreateOrganization)
+			manage.GET("/organizations/:id", h.Organizations.GetOrganization)
+			manage.PUT("/organizations/:id", h.Organizations.UpdateOrganization)
+			manage.DELETE("/organizations/:id", h.Organizations.DeleteOrganization)
+			manage.GET("/organizations/:id/members", h.Organizations.ListMembers)
+			manage.PUT("/organizations/:id/members/:user_id", h.Organizations.SetMember)
+			manage.DELETE("/organizations/:id/members/:user_id", h.Organizations.RemoveMember)
+			manage.GET("/organizations/:id/invitations", h.Invitations.ListInvitations)
+			manage.POST("/organizations/:id/invitations", h.Invitations.CreateInvitation)
+			manage.DELETE("/invitations/:id", h.Invitations.RevokeInvitation)
+
+			manage.GET("/policies", h.Policies.ListPolicies)
+			manage.POST("/policies", h.Policies.CreatePolicy)
+			manage.DELETE("/policies/:id", h.Policies.DeletePolicy)
+			manage.POST("/policies/evaluate", h.Policies.EvaluatePolicies)
 		}
 	}
 }
@@ -0,0 +1,331 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sukhantharot/go-service/app"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/policy"
+	"github.com/sukhantharot/go-service/service"
+)
+
+// testApp serves every route on top of memory repositories with the default roles seeded
+type testApp struct {
+	*app.App
+	t      *testing.T
+	router *gin.Engine
+}
+
+func newTestApp(t *testing.T) *testApp {
+	gin.SetMode(gin.TestMode)
+	a, err := app.NewWithRepositories(app.NewMemoryRepositories(), app.Config{
+		JWTSecret: "test-secret",
+		Token:     service.TokenConfig{AccessTTL: time.Hour, RefreshTTL: time.Hour},
+		Policy:    service.PolicyConfig{Location: time.UTC},
+	})
+	require.NoError(t, err)
+	_, err = a.SeedRBAC("", service.SeedOptions{})
+	require.NoError(t, err)
+	router := gin.New()
+	SetupRoutes(router, a)
+	return &testApp{App: a, t: t, router: router}
+}
+
+// createUser creates a user with the named global roles
+func (a *testApp) createUser(email string, roles ...string) models.User {
+	hashedPassword, err := models.HashPassword("password123")
+	require.NoError(a.t, err)
+	user := models.User{Email: email, Password: hashedPassword}
+	for _, name := range roles {
+		role, err := a.Repositories.Roles.FindByName(name)
+		require.NoError(a.t, err)
+		user.Roles = append(user.Roles, *role)
+	}
+	require.NoError(a.t, a.Repositories.Users.Create(&user))
+	return user
+}
+
+func (a *testApp) tokenFor(user models.User) string {
+	// Membership changes revoke tokens issued up to the same millisecond
+	time.Sleep(time.Millisecond)
+	pair, err := a.Services.Tokens.IssueTokenPair(&user, false)
+	require.NoError(a.t, err)
+	return pair.AccessToken
+}
+
+func (a *testApp) request(method, url, token string) (int, map[string]interface{}) {
+	return a.send(method, url, token, nil)
+}
+
+// send is request with body encoded as JSON, none when it is nil
+func (a *testApp) send(method, url, token string, body interface{}) (int, map[string]interface{}) {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		require.NoError(a.t, err)
+		reader = bytes.NewReader(encoded)
+	}
+	req := httptest.NewRequest(method, url, reader)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, req)
+	var response map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
+
+// permissionIDs returns the IDs of the named permissions
+func (a *testApp) permissionIDs(names ...string) []uint {
+	permissions, err := a.Repositories.Permissions.List()
+	require.NoError(a.t, err)
+	var ids []uint
+	for _, name := range names {
+		for _, permission := range permissions {
+			if permission.Name == name {
+				ids = append(ids, permission.ID)
+			}
+		}
+	}
+	require.Len(a.t, ids, len(names))
+	return ids
+}
+
+func TestAdminRoutesPermissions(t *testing.T) {
+	a := newTestApp(t)
+	status := func(method, url, token string) int {
+		code, _ := a.request(method, url, token)
+		return code
+	}
+
+	t.Run("registered users cannot read other users", func(t *testing.T) {
+		token := a.tokenFor(a.createUser("user@example.com", models.RoleUser))
+		assert.Equal(t, http.StatusForbidden, status("GET", "/api/admin/users", token))
+		assert.Equal(t, http.StatusForbidden, status("GET", "/api/admin/users/1", token))
+	})
+
+	t.Run("users:read is enough to list users", func(t *testing.T) {
+		reader, err := a.Services.RBAC.CreateRole("reader", "", a.permissionIDs("users:read"))
+		require.NoError(t, err)
+		token := a.tokenFor(a.createUser("reader@example.com", reader.Name))
+		assert.Equal(t, http.StatusOK, status("GET", "/api/admin/users", token))
+		assert.Equal(t, http.StatusForbidden, status("DELETE", "/api/admin/users/1", token))
+		assert.Equal(t, http.StatusForbidden, status("GET", "/api/admin/roles", token))
+		assert.Equal(t, http.StatusForbidden, status("PUT", "/api/admin/users/1/roles", token))
+	})
+
+	t.Run("the rest needs admin", func(t *testing.T) {
+		token := a.tokenFor(a.createUser("admin@example.com", models.RoleAdmin))
+		assert.Equal(t, http.StatusOK, status("GET", "/api/admin/users", token))
+		assert.Equal(t, http.StatusOK, status("GET", "/api/admin/roles", token))
+	})
+}
+
+func TestListUsersInOrganization(t *testing.T) {
+	a := newTestApp(t)
+	acme, err := a.Services.Organizations.Create("Acme", "")
+	require.NoError(t, err)
+	globex, err := a.Services.Organizations.Create("Globex", "")
+	require.NoError(t, err)
+	reader, err := a.Services.RBAC.CreateRole("org_reader", "", a.permissionIDs("users:read"))
+	require.NoError(t, err)
+
+	// Manager reads users through the membership only, auditor through a global role
+	manager := a.createUser("manager@example.com")
+	auditor := a.createUser("auditor@example.com", reader.Name)
+	outsider := a.createUser("outsider@example.com")
+	for _, m := range []struct {
+		organization uint
+		user         models.User
+	}{{acme.ID, manager}, {acme.ID, auditor}, {globex.ID, outsider}} {
+		_, err := a.Services.Organizations.SetMember(m.organization, m.user.ID, reader.ID)
+		require.NoError(t, err)
+	}
+	emails := func(response map[string]interface{}) []string {
+		var emails []string
+		for _, user := range response["users"].([]interface{}) {
+			emails = append(emails, user.(map[string]interface{})["email"].(string))
+		}
+		return emails
+	}
+
+	t.Run("membership keeps the list to the organization", func(t *testing.T) {
+		token := a.tokenFor(manager)
+		code, response := a.request("GET", "/api/admin/users", token)
+		require.Equal(t, http.StatusOK, code)
+		assert.ElementsMatch(t, []string{manager.Email, auditor.Email}, emails(response))
+
+		code, _ = a.request("GET", fmt.Sprintf("/api/admin/users?organization_id=%d", acme.ID), token)
+		assert.Equal(t, http.StatusOK, code)
+		code, _ = a.request("GET", fmt.Sprintf("/api/admin/users?organization_id=%d", globex.ID), token)
+		assert.Equal(t, http.StatusForbidden, code)
+	})
+
+	t.Run("global rights reach other organizations", func(t *testing.T) {
+		token := a.tokenFor(auditor)
+		code, response := a.request("GET", "/api/admin/users", token)
+		require.Equal(t, http.StatusOK, code)
+		assert.ElementsMatch(t, []string{manager.Email, auditor.Email}, emails(response))
+
+		code, response = a.request("GET", fmt.Sprintf("/api/admin/users?organization_id=%d", globex.ID), token)
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []string{outsider.Email}, emails(response))
+	})
+}
+
+func TestOrganizationRoleStaysInOrganization(t *testing.T) {
+	a := newTestApp(t)
+	acme, err := a.Services.Organizations.Create("Acme", "")
+	require.NoError(t, err)
+	manager, err := a.Services.RBAC.CreateRole("org_manager", "", a.permissionIDs("users:write", "users:delete"))
+	require.NoError(t, err)
+
+	member := a.createUser("manager@example.com")
+	colleague := a.createUser("colleague@example.com")
+	outsider := a.createUser("outsider@example.com")
+	admin := a.createUser("admin@example.com", models.RoleAdmin)
+	for _, user := range []models.User{member, colleague} {
+		_, err := a.Services.Organizations.SetMember(acme.ID, user.ID, manager.ID)
+		require.NoError(t, err)
+	}
+	token := a.tokenFor(member)
+	update := func(user models.User) map[string]string {
+		return map[string]string{"email": user.Email, "first_name": "New", "last_name": "Name"}
+	}
+
+	t.Run("admin routes ignore the organization role", func(t *testing.T) {
+		for _, target := range []models.User{admin, outsider, colleague} {
+			url := fmt.Sprintf("/api/admin/users/%d", target.ID)
+			code, _ := a.send("PUT", url, token, map[string]string{"email": "attacker@example.com", "first_name": "A", "last_name": "B"})
+			assert.Equal(t, http.StatusForbidden, code, target.Email)
+			code, _ = a.request("POST", url+"/suspend", token)
+			assert.Equal(t, http.StatusForbidden, code, target.Email)
+			code, _ = a.request("DELETE", url, token)
+			assert.Equal(t, http.StatusForbidden, code, target.Email)
+		}
+		stored, err := a.Repositories.Users.FindByID(admin.ID)
+		require.NoError(t, err)
+		assert.Equal(t, admin.Email, stored.Email)
+	})
+
+	t.Run("grant routes reach members only", func(t *testing.T) {
+		code, _ := a.send("PUT", fmt.Sprintf("/api/users/%d", outsider.ID), token, update(outsider))
+		assert.Equal(t, http.StatusForbidden, code)
+		code, _ = a.send("PUT", fmt.Sprintf("/api/users/%d", admin.ID), token, update(admin))
+		assert.Equal(t, http.StatusForbidden, code)
+		code, _ = a.send("PUT", fmt.Sprintf("/api/users/%d", colleague.ID), token, update(colleague))
+		assert.Equal(t, http.StatusOK, code)
+	})
+}
+
+func TestMembershipRolesStayBelowAdmin(t *testing.T) {
+	a := newTestApp(t)
+	acme, err := a.Services.Organizations.Create("Acme", "")
+	require.NoError(t, err)
+	base, err := a.Services.RBAC.CreateRole("org_base", "", nil)
+	require.NoError(t, err)
+	child, err := a.Services.RBAC.CreateRole("org_child", "", nil)
+	require.NoError(t, err)
+	_, err = a.Services.RBAC.AddParent(child.ID, base.ID)
+	require.NoError(t, err)
+	reports, err := a.Services.RBAC.CreatePermission("reports:read", "")
+	require.NoError(t, err)
+	_, err = a.Services.RBAC.AttachPermission(base.ID, reports.ID)
+	require.NoError(t, err)
+	adminRole, err := a.Repositories.Roles.FindByName(models.RoleAdmin)
+	require.NoError(t, err)
+	wildcard, err := a.Services.RBAC.CreatePermission("*:read", "")
+	require.NoError(t, err)
+
+	// Only the child role is used, by a membership
+	member := a.createUser("member@example.com")
+	_, err = a.Services.Organizations.SetMember(acme.ID, member.ID, child.ID)
+	require.NoError(t, err)
+	token := a.tokenFor(a.createUser("admin@example.com", models.RoleAdmin))
+	adminPermission := a.permissionIDs(models.PermissionAdmin)[0]
+
+	for _, url := range []string{
+		fmt.Sprintf("/api/admin/roles/%d/permissions/%d", base.ID, adminPermission),
+		fmt.Sprintf("/api/admin/roles/%d/permissions/%d", child.ID, adminPermission),
+		fmt.Sprintf("/api/admin/roles/%d/parents/%d", base.ID, adminRole.ID),
+	} {
+		code, response := a.request("POST", url, token)
+		assert.Equal(t, http.StatusBadRequest, code, url)
+		assert.Equal(t, "admin_membership_role", response["code"], url)
+	}
+	code, response := a.send("PUT", fmt.Sprintf("/api/admin/permissions/%d", reports.ID), token, map[string]string{"name": "*"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "admin_membership_role", response["code"])
+	code, _ = a.request("POST", fmt.Sprintf("/api/admin/roles/%d/permissions/%d", base.ID, wildcard.ID), token)
+	assert.Equal(t, http.StatusOK, code, "permissions short of admin are fine")
+
+	require.NoError(t, a.Services.Organizations.RemoveMember(acme.ID, member.ID))
+	code, _ = a.request("POST", fmt.Sprintf("/api/admin/roles/%d/parents/%d", base.ID, adminRole.ID), token)
+	assert.Equal(t, http.StatusOK, code, "unused roles can get admin")
+}
+
+func TestOrganizationGrantCoversMembers(t *testing.T) {
+	a := newTestApp(t)
+	acme, err := a.Services.Organizations.Create("Acme", "")
+	require.NoError(t, err)
+	memberRole, err := a.Services.RBAC.CreateRole("org_member", "", nil)
+	require.NoError(t, err)
+	member := a.createUser("member@example.com")
+	_, err = a.Services.Organizations.SetMember(acme.ID, member.ID, memberRole.ID)
+	require.NoError(t, err)
+	outsider := a.createUser("outsider@example.com")
+
+	support := a.createUser("support@example.com")
+	_, err = a.Services.Access.CreateGrant(a.permissionIDs("users:write")[0], support.ID, 0, models.ResourceOrganizations, fmt.Sprint(acme.ID))
+	require.NoError(t, err)
+	token := a.tokenFor(support)
+	update := func(user models.User) map[string]string {
+		return map[string]string{"email": user.Email, "first_name": "New", "last_name": "Name"}
+	}
+
+	code, _ := a.send("PUT", fmt.Sprintf("/api/users/%d", member.ID), token, update(member))
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = a.send("PUT", fmt.Sprintf("/api/users/%d", outsider.ID), token, update(outsider))
+	assert.Equal(t, http.StatusForbidden, code)
+}
+
+func TestListUsersFollowsPolicies(t *testing.T) {
+	a := newTestApp(t)
+	_, err := a.Services.Policies.Create(policy.Policy{
+		Name:      "only-admins-touch-admins",
+		Effect:    policy.Deny,
+		Actions:   []string{"users:*"},
+		Resources: []string{models.ResourceUsers},
+		Conditions: []policy.Condition{
+			{Attribute: "resource.roles", Operator: policy.OpContains, Value: models.RoleAdmin},
+			{Attribute: "subject.roles", Operator: policy.OpNotContains, Value: models.RoleAdmin},
+		},
+	})
+	require.NoError(t, err)
+	reader, err := a.Services.RBAC.CreateRole("reader", "", a.permissionIDs("users:read"))
+	require.NoError(t, err)
+	readerUser := a.createUser("reader@example.com", reader.Name)
+	admin := a.createUser("admin@example.com", models.RoleAdmin)
+	emails := func(token string) []string {
+		code, response := a.request("GET", "/api/admin/users", token)
+		require.Equal(t, http.StatusOK, code)
+		var emails []string
+		for _, user := range response["users"].([]interface{}) {
+			emails = append(emails, user.(map[string]interface{})["email"].(string))
+		}
+		return emails
+	}
+
+	assert.Equal(t, []string{readerUser.Email}, emails(a.tokenFor(readerUser)))
+	assert.Equal(t, []string{readerUser.Email, admin.Email}, emails(a.tokenFor(admin)))
+}
@@ -0,0 +1,141 @@
+// Package seed reads declarative definitions of roles, permissions and the links between
+// them from YAML or JSON, see Parse. service.RBACService.Seed brings the database in line
+// with a definition.
+package seed
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sukhantharot/go-service/models"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed default.yaml
+var defaultFile []byte
+
+// Permission declares a permission by name
+type Permission struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// Role declares a role, the permissions it grants and the roles it inherits from, all by name
+type Role struct {
+	Name        string   `json:"name" yaml:"name"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Permissions []string `json:"permissions,omitempty" yaml:"permissions,omitempty"`
+	Parents     []string `json:"parents,omitempty" yaml:"parents,omitempty"`
+}
+
+// File is the content of a seed file
+type File struct {
+	Permissions []Permission `json:"permissions" yaml:"permissions"`
+	Roles       []Role       `json:"roles" yaml:"roles"`
+}
+
+// Validate reports the first problem of the file, nil when it can be applied. Roles may only
+// name permissions and parents declared in the same file, and must not inherit in a cycle.
+func (f *File) Validate() error {
+	permissions := make(map[string]bool, len(f.Permissions))
+	for _, permission := range f.Permissions {
+		if !models.ValidPermissionName(permission.Name) {
+			return fmt.Errorf("invalid permission name %q", permission.Name)
+		}
+		if permissions[permission.Name] {
+			return fmt.Errorf("permission %s is declared twice", permission.Name)
+		}
+		permissions[permission.Name] = true
+	}
+
+	roles := make(map[string]*Role, len(f.Roles))
+	for i := range f.Roles {
+		role := &f.Roles[i]
+		if role.Name == "" {
+			return fmt.Errorf("role %d has no name", i+1)
+		}
+		if roles[role.Name] != nil {
+			return fmt.Errorf("role %s is declared twice", role.Name)
+		}
+		roles[role.Name] = role
+	}
+	for _, role := range f.Roles {
+		for _, name := range role.Permissions {
+			if !permissions[name] {
+				return fmt.Errorf("role %s: permission %s is not declared", role.Name, name)
+			}
+		}
+		for _, name := range role.Parents {
+			if roles[name] == nil {
+				return fmt.Errorf("role %s: parent %s is not declared", role.Name, name)
+			}
+		}
+	}
+
+	// Walk up from every role, meeting a role again on the way up is a cycle
+	var visit func(role *Role, path map[string]bool) error
+	visit = func(role *Role, path map[string]bool) error {
+		if path[role.Name] {
+			return fmt.Errorf("role %s inherits from itself", role.Name)
+		}
+		path[role.Name] = true
+		defer delete(path, role.Name)
+		for _, name := range role.Parents {
+			if err := visit(roles[name], path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for i := range f.Roles {
+		if err := visit(&f.Roles[i], map[string]bool{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Parse reads a seed file, JSON when format is "json" and YAML otherwise, and validates it
+func Parse(data []byte, format string) (*File, error) {
+	var file File
+	var err error
+	if format == "json" {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Validate(); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// LoadFile reads a seed file, its extension tells JSON (.json) from YAML
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := Parse(data, strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), "."))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return file, nil
+}
+
+// Default returns the built-in definition: the admin and user roles and the permissions
+// the routes check, see default.yaml
+func Default() *File {
+	file, err := Parse(defaultFile, "yaml")
+	if err != nil {
+		panic(fmt.Sprintf("seed: invalid default.yaml: %v", err))
+	}
+	return file
+}
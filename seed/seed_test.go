@@ -0,0 +1,52 @@
+package seed
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sukhantharot/go-service/models"
+)
+
+func TestDefault(t *testing.T) {
+	file := Default()
+
+	var roles []string
+	for _, role := range file.Roles {
+		roles = append(roles, role.Name)
+	}
+	assert.Contains(t, roles, models.RoleAdmin)
+	assert.Contains(t, roles, models.RoleUser)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format string
+		err    string
+	}{
+		{name: "yaml", data: "permissions: [{name: 'docs:read'}]\nroles: [{name: reader, permissions: ['docs:read']}, {name: editor, parents: [reader]}]"},
+		{name: "json", format: "json", data: `{"permissions": [{"name": "docs:read"}], "roles": [{"name": "reader", "permissions": ["docs:read"]}]}`},
+		{name: "invalid permission name", data: "permissions: [{name: 'docs read'}]", err: `invalid permission name "docs read"`},
+		{name: "duplicate permission", data: "permissions: [{name: a}, {name: a}]", err: "permission a is declared twice"},
+		{name: "nameless role", data: "roles: [{description: x}]", err: "role 1 has no name"},
+		{name: "duplicate role", data: "roles: [{name: a}, {name: a}]", err: "role a is declared twice"},
+		{name: "undeclared permission", data: "roles: [{name: a, permissions: ['docs:read']}]", err: "role a: permission docs:read is not declared"},
+		{name: "undeclared parent", data: "roles: [{name: a, parents: [b]}]", err: "role a: parent b is not declared"},
+		{name: "cycle", data: "roles: [{name: a, parents: [b]}, {name: b, parents: [c]}, {name: c, parents: [a]}]", err: "role a inherits from itself"},
+		{name: "self parent", data: "roles: [{name: a, parents: [a]}]", err: "role a inherits from itself"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := Parse([]byte(tt.data), tt.format)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotEmpty(t, file.Roles)
+		})
+	}
+}
@@ -2,75 +2,178 @@ package service
 
 import (
 	"errors"
+	"net/http"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/logger"
 	"github.com/sukhantharot/go-service/models"
 	"github.com/sukhantharot/go-service/repository"
+	"gorm.io/gorm"
 )
 
+var (
+	ErrEmailTaken = apperrors.New(http.StatusBadRequest, "email_taken", "Email already registered")
+	// ErrDefaultRoleMissing means the user role was never seeded, see RBACService.Seed
+	ErrDefaultRoleMissing = apperrors.New(http.StatusInternalServerError, "default_role_missing", "The default role does not exist")
+)
+
+// LoginResult is either a finished login with tokens or a second factor challenge
+type LoginResult struct {
+	User      *models.User
+	Tokens    *TokenPair
+	Challenge *MFAChallenge
+	// MFAEnrollmentRequired tells clients to enroll before privileged routes work
+	MFAEnrollmentRequired bool
+}
+
 type AuthService struct {
-	userRepo  *repository.UserRepository
-	jwtSecret string
+	userRepo     repository.UserRepository
+	roles        repository.RoleRepository
+	tokens       *TokenService
+	verification *VerificationService
+	mfa          *MFAService
+	throttle     *LoginThrottle
 }
 
-func NewAuthService(userRepo *repository.UserRepository, jwtSecret string) *AuthService {
+func NewAuthService(userRepo repository.UserRepository, roles repository.RoleRepository, tokens *TokenService, verification *VerificationService, mfa *MFAService, throttle *LoginThrottle) *AuthService {
 	return &AuthService{
-		userRepo:  userRepo,
-		jwtSecret: jwtSecret,
+		userRepo:     userRepo,
+		roles:        roles,
+		tokens:       tokens,
+		verification: verification,
+		mfa:          mfa,
+		throttle:     throttle,
 	}
 }
 
+// Register creates a user with the default role and emails a verification link
 func (s *AuthService) Register(email, password, firstName, lastName string) (*models.User, error) {
 	// Check if user already exists
 	existingUser, err := s.userRepo.FindByEmail(email)
 	if err == nil && existingUser != nil {
-		return nil, errors.New("email already registered")
+		return nil, ErrEmailTaken
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	roles, err := defaultRoles(s.roles)
+	if err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := models.HashPassword(password)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create new user
 	user := &models.User{
 		Email:     email,
-		Password:  password,
+		Password:  hashedPassword,
 		FirstName: firstName,
 		LastName:  lastName,
-		RoleID:    1, // Default role ID
+		Roles:     roles,
 	}
 
 	// Save user
-	err = s.userRepo.Create(user)
-	if err != nil {
+	if err := s.userRepo.Create(user); err != nil {
+		// Lost a race with another registration of the same email
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrEmailTaken
+		}
 		return nil, err
 	}
 
+	// The account exists either way, the user can ask for a new email later
+	if err := s.verification.SendVerification(user); err != nil {
+		logger.Error("Could not send verification email", err, logger.Fields{"user_id": user.ID})
+	}
+
 	return user, nil
 }
 
-func (s *AuthService) Login(email, password string) (string, *models.User, error) {
+// Login checks the password of a client at ip. Users with a second factor get a challenge
+// to complete with MFAService.CompleteChallenge instead of tokens.
+func (s *AuthService) Login(email, password, ip string) (*LoginResult, error) {
+	// Locked accounts and throttled clients are refused before the password is checked
+	if err := s.throttle.Check(email, ip); err != nil {
+		return nil, err
+	}
+
 	// Find user by email
 	user, err := s.userRepo.FindByEmail(email)
 	if err != nil {
-		return "", nil, errors.New("invalid credentials")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.recordFailure(email, ip)
+			return nil, apperrors.ErrInvalidCredentials
+		}
+		return nil, err
 	}
 
 	// Check password
 	if !user.CheckPassword(password) {
-		return "", nil, errors.New("invalid credentials")
+		s.recordFailure(email, ip)
+		return nil, apperrors.ErrInvalidCredentials
+	}
+
+	if err := s.throttle.RecordSuccess(email); err != nil {
+		logger.Error("Could not reset failed login attempts", err, logger.Fields{"user_id": user.ID})
 	}
 
-	// Generate JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID,
-		"role_id": user.RoleID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
-	})
+	// Only reveal the suspension to someone who knows the password
+	if user.IsSuspended() {
+		return nil, apperrors.ErrAccountSuspended
+	}
 
-	tokenString, err := token.SignedString([]byte(s.jwtSecret))
+	if err := s.verification.CheckLogin(user); err != nil {
+		return nil, err
+	}
+
+	if user.IsMFAEnabled() {
+		challenge, err := s.mfa.Challenge(user)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{User: user, Challenge: challenge}, nil
+	}
+
+	// Issue access and refresh tokens
+	pair, err := s.tokens.IssueTokenPair(user, false)
 	if err != nil {
-		return "", nil, err
+		return nil, err
 	}
 
-	return tokenString, user, nil
+	return &LoginResult{
+		User:                  user,
+		Tokens:                pair,
+		MFAEnrollmentRequired: s.mfa.RequiresMFA(user),
+	}, nil
+}
+
+func (s *AuthService) Refresh(refreshToken string) (*TokenPair, *models.User, error) {
+	return s.tokens.Refresh(refreshToken)
+}
+
+// Logout revokes one access token and, if given, its refresh token
+func (s *AuthService) Logout(jti string, userID uint, expiresAt time.Time, refreshToken string) error {
+	return s.tokens.Logout(jti, userID, expiresAt, refreshToken)
+}
+
+// LogoutAll revokes every access and refresh token of the user
+func (s *AuthService) LogoutAll(userID uint) error {
+	return s.tokens.LogoutAll(userID)
+}
+
+// Unlock lifts a lockout of the user's account caused by failed logins
+func (s *AuthService) Unlock(userID uint) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	return s.throttle.Unlock(user.Email)
 }
 
 func (s *AuthService) GetUserByID(id uint) (*models.User, error) {
@@ -78,10 +181,27 @@ func (s *AuthService) GetUserByID(id uint) (*models.User, error) {
 }
 
 func (s *AuthService) ValidateToken(tokenString string) (*jwt.Token, error) {
-	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
-		}
-		return []byte(s.jwtSecret), nil
-	})
+	return s.tokens.ValidateAccessToken(tokenString)
+}
+
+// roleNameFinder looks roles up by name, like RoleRepository and PermissionCache
+type roleNameFinder interface {
+	FindByName(name string) (*models.Role, error)
+}
+
+// defaultRoles are the roles of a new user, the user role. It is found by name, its ID
+// depends on the order roles were created in.
+func defaultRoles(roles roleNameFinder) ([]models.Role, error) {
+	role, err := roles.FindByName(models.RoleUser)
+	if err != nil {
+		return nil, notFoundAs(err, ErrDefaultRoleMissing)
+	}
+	return []models.Role{{Model: gorm.Model{ID: role.ID}}}, nil
+}
+
+// recordFailure counts a failed login, a storage error must not change the response
+func (s *AuthService) recordFailure(email, ip string) {
+	if err := s.throttle.RecordFailure(email, ip); err != nil {
+		logger.Error("Could not record failed login", err, logger.Fields{"ip": ip})
+	}
 }
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/logger"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrElevationNotFound   = apperrors.New(http.StatusNotFound, "elevation_not_found", "Elevation not found")
+	ErrElevationNotPending = apperrors.New(http.StatusConflict, "elevation_not_pending", "The elevation was already reviewed")
+	ErrElevationNotActive  = apperrors.New(http.StatusConflict, "elevation_not_active", "The elevation is not active")
+	ErrElevationExists     = apperrors.New(http.StatusConflict, "elevation_exists", "The user already has a pending or active elevation to this role")
+	ErrElevationTooLong    = apperrors.New(http.StatusBadRequest, "elevation_too_long", "The elevation is longer than allowed")
+	ErrRoleAlreadyHeld     = apperrors.New(http.StatusBadRequest, "role_already_held", "The user already has this role")
+	ErrElevationEscalation = apperrors.New(http.StatusForbidden, "elevation_escalation", "You cannot grant a role with permissions you do not have")
+)
+
+// ElevationConfig bounds temporary roles
+type ElevationConfig struct {
+	// MaxDuration is the longest window an elevation can be approved for
+	MaxDuration time.Duration
+	// RequestTTL is how long a request waits for a review before it expires
+	RequestTTL time.Duration
+	// SweepInterval is how often ended elevations are marked as expired
+	SweepInterval time.Duration
+}
+
+// ElevationService manages temporary roles: users request a role for a while with a
+// justification, approvers approve or deny, and approved elevations end by themselves.
+// Access tokens carry the roles of active elevations with their expiry.
+type ElevationService struct {
+	elevations  repository.ElevationRepository
+	users       repository.UserRepository
+	roles       *PermissionCache
+	revocations *RevocationStore
+	config      ElevationConfig
+}
+
+func NewElevationService(elevations repository.ElevationRepository, users repository.UserRepository, roles *PermissionCache, revocations *RevocationStore, config ElevationConfig) *ElevationService {
+	return &ElevationService{
+		elevations:  elevations,
+		users:       users,
+		roles:       roles,
+		revocations: revocations,
+		config:      config,
+	}
+}
+
+func (s *ElevationService) List(filter repository.ElevationFilter) ([]models.Elevation, error) {
+	return s.elevations.List(filter)
+}
+
+// Request asks for the role for minutes, pending until an approver reviews it
+func (s *ElevationService) Request(userID, roleID uint, minutes int, justification string) (*models.Elevation, error) {
+	elevation, err := s.newElevation(userID, roleID, minutes, justification)
+	if err != nil {
+		return nil, err
+	}
+	elevation.Status = models.ElevationPending
+	if err := s.elevations.Create(elevation); err != nil {
+		return nil, err
+	}
+	return elevation, nil
+}
+
+// Grant gives the user the role for minutes right away, on the approver's own account
+func (s *ElevationService) Grant(actorID, userID, roleID uint, minutes int, justification string) (*models.Elevation, error) {
+	if actorID == userID {
+		return nil, ErrSelfAction.WithMessage("You cannot elevate your own account")
+	}
+	if err := s.checkEscalation(actorID, roleID); err != nil {
+		return nil, err
+	}
+	elevation, err := s.newElevation(userID, roleID, minutes, justification)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	expiresAt := now.Add(elevation.Duration())
+	elevation.Status = models.ElevationApproved
+	elevation.ReviewerID = &actorID
+	elevation.ReviewedAt = &now
+	elevation.ExpiresAt = &expiresAt
+	if err := s.elevations.Create(elevation); err != nil {
+		return nil, err
+	}
+	return elevation, nil
+}
+
+// Approve starts the window of a pending request. minutes can shorten the requested
+// window, zero keeps it.
+func (s *ElevationService) Approve(actorID, id uint, minutes int, note string) (*models.Elevation, error) {
+	elevation, err := s.reviewable(actorID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkEscalation(actorID, elevation.RoleID); err != nil {
+		return nil, err
+	}
+	if minutes > elevation.DurationMinutes {
+		return nil, ErrElevationTooLong.WithMessage("An approval cannot be longer than the request")
+	}
+	if minutes > 0 {
+		elevation.DurationMinutes = minutes
+	}
+	now := time.Now()
+	expiresAt := now.Add(elevation.Duration())
+	elevation.Status = models.ElevationApproved
+	elevation.ReviewerID = &actorID
+	elevation.ReviewedAt = &now
+	elevation.ReviewNote = note
+	elevation.ExpiresAt = &expiresAt
+	if err := s.save(elevation, models.ElevationPending, ErrElevationNotPending); err != nil {
+		return nil, err
+	}
+	return elevation, nil
+}
+
+func (s *ElevationService) Deny(actorID, id uint, note string) (*models.Elevation, error) {
+	elevation, err := s.reviewable(actorID, id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	elevation.Status = models.ElevationDenied
+	elevation.ReviewerID = &actorID
+	elevation.ReviewedAt = &now
+	elevation.ReviewNote = note
+	if err := s.save(elevation, models.ElevationPending, ErrElevationNotPending); err != nil {
+		return nil, err
+	}
+	return elevation, nil
+}
+
+// Revoke ends an active elevation early. The user's access tokens carry the role until
+// its expiry, so they are revoked.
+func (s *ElevationService) Revoke(actorID, id uint) (*models.Elevation, error) {
+	elevation, err := s.elevations.FindByID(id)
+	if err != nil {
+		return nil, notFoundAs(err, ErrElevationNotFound)
+	}
+	now := time.Now()
+	if !elevation.IsActive(now) {
+		return nil, ErrElevationNotActive
+	}
+	elevation.Status = models.ElevationRevoked
+	elevation.RevokedByID = &actorID
+	elevation.RevokedAt = &now
+	if err := s.save(elevation, models.ElevationApproved, ErrElevationNotActive); err != nil {
+		return nil, err
+	}
+	if err := s.revocations.RevokeAll(elevation.UserID); err != nil {
+		return nil, err
+	}
+	return elevation, nil
+}
+
+// checkEscalation keeps approvers from handing out permissions their own roles lack, like
+// OrganizationService.AssignMember does for members. Approvers with admin may grant any role.
+func (s *ElevationService) checkEscalation(actorID, roleID uint) error {
+	actor, err := s.users.FindByID(actorID)
+	if err != nil {
+		return notFoundAs(err, ErrUserNotFound)
+	}
+	held := make([]models.Role, 0, len(actor.Roles))
+	for _, actorRole := range actor.Roles {
+		role, err := s.roles.FindByID(actorRole.ID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		held = append(held, *role)
+	}
+	if models.HasPermission(held, models.PermissionAdmin) {
+		return nil
+	}
+
+	role, err := s.roles.FindByID(roleID)
+	if err != nil {
+		return notFoundAs(err, ErrRoleNotFound)
+	}
+	for _, permission := range models.EffectivePermissions([]models.Role{*role}) {
+		if !models.HasPermission(held, permission.Name) {
+			return ErrElevationEscalation.WithDetails(permission.Name)
+		}
+	}
+	return nil
+}
+
+// ExpireDue marks elevations whose window ended, and requests nobody reviewed within
+// RequestTTL, as expired. Tokens stop using an elevated role at its expiry by themselves,
+// this keeps the status of the elevations in line.
+func (s *ElevationService) ExpireDue() (int64, error) {
+	now := time.Now()
+	return s.elevations.Expire(now, now.Add(-s.config.RequestTTL))
+}
+
+// RunSweeper calls ExpireDue every SweepInterval until ctx is done
+func (s *ElevationService) RunSweeper(ctx context.Context) {
+	ticker := time.NewTicker(s.config.SweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		expired, err := s.ExpireDue()
+		if err != nil {
+			logger.Error("Could not expire elevations", err, nil)
+			continue
+		}
+		if expired > 0 {
+			logger.Info("Expired elevations", logger.Fields{"count": expired})
+		}
+	}
+}
+
+// newElevation validates a new elevation of the user to the role
+func (s *ElevationService) newElevation(userID, roleID uint, minutes int, justification string) (*models.Elevation, error) {
+	if time.Duration(minutes)*time.Minute > s.config.MaxDuration {
+		return nil, ErrElevationTooLong.WithDetails(fmt.Sprintf("at most %s", s.config.MaxDuration))
+	}
+	user, err := s.users.FindByID(userID)
+	if err != nil {
+		return nil, notFoundAs(err, ErrUserNotFound)
+	}
+	if _, err := s.roles.FindByID(roleID); err != nil {
+		return nil, notFoundAs(err, ErrRoleNotFound)
+	}
+	for _, role := range user.Roles {
+		if role.ID == roleID {
+			return nil, ErrRoleAlreadyHeld
+		}
+	}
+
+	existing, err := s.elevations.List(repository.ElevationFilter{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, elevation := range existing {
+		if elevation.RoleID == roleID && (elevation.Status == models.ElevationPending || elevation.IsActive(now)) {
+			return nil, ErrElevationExists
+		}
+	}
+
+	return &models.Elevation{
+		UserID:          userID,
+		RoleID:          roleID,
+		Justification:   justification,
+		DurationMinutes: minutes,
+	}, nil
+}
+
+// reviewable loads a pending request that the actor, who did not make it, may review
+func (s *ElevationService) reviewable(actorID, id uint) (*models.Elevation, error) {
+	elevation, err := s.elevations.FindByID(id)
+	if err != nil {
+		return nil, notFoundAs(err, ErrElevationNotFound)
+	}
+	if elevation.UserID == actorID {
+		return nil, ErrSelfAction.WithMessage("You cannot review your own elevation")
+	}
+	if elevation.Status != models.ElevationPending {
+		return nil, ErrElevationNotPending
+	}
+	return elevation, nil
+}
+
+// save stores the changes to elevation if it still has status from, otherwise it fails with conflict
+func (s *ElevationService) save(elevation *models.Elevation, from string, conflict *apperrors.AppError) error {
+	saved, err := s.elevations.Review(elevation, from)
+	if err != nil {
+		return err
+	}
+	if !saved {
+		return conflict
+	}
+	return nil
+}
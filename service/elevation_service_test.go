@@ -0,0 +1,24 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRunSweeperStopsWithContext(t *testing.T) {
+	elevations := NewElevationService(nil, nil, nil, nil, ElevationConfig{SweepInterval: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		elevations.RunSweeper(ctx)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("RunSweeper did not return after its context was cancelled")
+	}
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/logger"
+	"github.com/sukhantharot/go-service/mail"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidInvitation  = apperrors.New(http.StatusBadRequest, "invalid_invitation", "Invalid or expired invitation")
+	ErrInvitationNotFound = apperrors.New(http.StatusNotFound, "invitation_not_found", "Invitation not found or no longer pending")
+	ErrAlreadyMember      = apperrors.New(http.StatusConflict, "already_member", "User is already a member of this organization")
+	ErrNameRequired       = apperrors.New(http.StatusBadRequest, "name_required", "first_name and last_name are required to create an account")
+)
+
+// InvitationConfig controls invitations to organizations
+type InvitationConfig struct {
+	TTL time.Duration
+	// URL is the frontend page that accepts an invitation, it receives the token as ?token=
+	URL string
+}
+
+// InvitationAcceptance is the outcome of accepting an invitation
+type InvitationAcceptance struct {
+	User       *models.User
+	Membership *models.Membership
+	// Registered is true when the invitation created the user
+	Registered bool
+}
+
+// InvitationService invites people by email to join an organization with a role. Accepting
+// registers them, or adds their existing account, without going through Register.
+type InvitationService struct {
+	invitations   repository.InvitationRepository
+	organizations *OrganizationService
+	users         repository.UserRepository
+	roles         *PermissionCache
+	throttle      *LoginThrottle
+	mailer        mail.Sender
+	config        InvitationConfig
+}
+
+func NewInvitationService(invitations repository.InvitationRepository, organizations *OrganizationService, users repository.UserRepository, roles *PermissionCache, throttle *LoginThrottle, mailer mail.Sender, config InvitationConfig) *InvitationService {
+	return &InvitationService{
+		invitations:   invitations,
+		organizations: organizations,
+		users:         users,
+		roles:         roles,
+		throttle:      throttle,
+		mailer:        mailer,
+		config:        config,
+	}
+}
+
+// List returns the invitations to the organization that can still be accepted
+func (s *InvitationService) List(organizationID uint) ([]models.Invitation, error) {
+	if _, err := s.organizations.Get(organizationID); err != nil {
+		return nil, err
+	}
+	return s.invitations.ListPending(organizationID, time.Now())
+}
+
+// Create emails email an invitation to the organization with the role. Earlier invitations
+// of the same email to the organization stop working, so sending a new one is how an
+// invitation is resent.
+func (s *InvitationService) Create(actorID, organizationID uint, email string, roleID uint) (*models.Invitation, error) {
+	organization, err := s.organizations.Get(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	role, err := s.roles.FindByID(roleID)
+	if err != nil {
+		return nil, notFoundAs(err, ErrRoleNotFound)
+	}
+	// Checked again on acceptance, the role can change in between
+	if models.HasPermission([]models.Role{*role}, models.PermissionAdmin) {
+		return nil, ErrAdminMembershipRole
+	}
+	user, err := s.users.FindByEmail(email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if user != nil {
+		_, err := s.organizations.Membership(organizationID, user.ID)
+		if err == nil {
+			return nil, ErrAlreadyMember
+		}
+		if !errors.Is(err, ErrMembershipNotFound) {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	if err := s.invitations.RevokePending(organizationID, email, now); err != nil {
+		return nil, err
+	}
+	raw, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	invitation := &models.Invitation{
+		OrganizationID: organizationID,
+		Email:          email,
+		RoleID:         roleID,
+		InvitedByID:    &actorID,
+		TokenHash:      hashToken(raw),
+		ExpiresAt:      now.Add(s.config.TTL),
+	}
+	if err := s.invitations.Create(invitation); err != nil {
+		return nil, err
+	}
+
+	if err := s.mailer.Send(mail.Message{
+		To:      email,
+		Subject: fmt.Sprintf("You are invited to join %s", organization.Name),
+		Body: fmt.Sprintf("Hi,\n\nYou are invited to join %s. Open the link below to accept, with your password if you already have an account or a new one otherwise. It expires in %s and can only be used once.\n\n%s?token=%s\n\nIf you were not expecting this invitation you can ignore this email.\n",
+			organization.Name, s.config.TTL, s.config.URL, raw),
+	}); err != nil {
+		return nil, err
+	}
+	invitation.Role = *role
+	return invitation, nil
+}
+
+// Revoke stops a pending invitation from working
+func (s *InvitationService) Revoke(id uint) error {
+	revoked, err := s.invitations.Revoke(id, time.Now())
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrInvitationNotFound
+	}
+	return nil
+}
+
+// Accept redeems an invitation. When its email belongs to a user, password must be theirs
+// and the names are ignored, wrong passwords from ip count as failed logins. Otherwise a user
+// is registered with the email, password and names. Either way the link proves the email, so
+// it counts as verified.
+func (s *InvitationService) Accept(rawToken, password, firstName, lastName, ip string) (*InvitationAcceptance, error) {
+	invitation, err := s.invitations.FindByHash(hashToken(rawToken))
+	if err != nil {
+		return nil, notFoundAs(err, ErrInvalidInvitation)
+	}
+	now := time.Now()
+	if !invitation.IsPending(now) {
+		return nil, ErrInvalidInvitation
+	}
+
+	user, err := s.users.FindByEmail(invitation.Email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	registered := user == nil
+	var roles []models.Role
+	if registered {
+		if firstName == "" || lastName == "" {
+			return nil, ErrNameRequired
+		}
+		if roles, err = defaultRoles(s.roles); err != nil {
+			return nil, err
+		}
+	} else {
+		// The password of an existing account is throttled like a login
+		if err := s.throttle.Check(user.Email, ip); err != nil {
+			return nil, err
+		}
+		if !user.CheckPassword(password) {
+			if err := s.throttle.RecordFailure(user.Email, ip); err != nil {
+				logger.Error("Could not record failed login", err, logger.Fields{"ip": ip})
+			}
+			return nil, apperrors.ErrInvalidCredentials
+		}
+		if err := s.throttle.RecordSuccess(user.Email); err != nil {
+			logger.Error("Could not reset failed login attempts", err, logger.Fields{"user_id": user.ID})
+		}
+		if user.IsSuspended() {
+			return nil, apperrors.ErrAccountSuspended
+		}
+	}
+
+	// The role may have changed since the invitation was sent
+	if err := s.organizations.checkMemberRole(invitation.RoleID); err != nil {
+		return nil, err
+	}
+
+	if registered {
+		hashedPassword, err := models.HashPassword(password)
+		if err != nil {
+			return nil, err
+		}
+		user = &models.User{
+			Email:           invitation.Email,
+			Password:        hashedPassword,
+			FirstName:       firstName,
+			LastName:        lastName,
+			EmailVerifiedAt: &now,
+			Roles:           roles,
+		}
+	}
+	accepted, err := s.invitations.Accept(invitation.ID, user, now)
+	if err != nil {
+		// Lost a race with a registration of the same email
+		return nil, duplicateAs(err, ErrEmailTaken)
+	}
+	if !accepted {
+		return nil, ErrInvalidInvitation
+	}
+	if user.EmailVerifiedAt == nil && user.Email == invitation.Email {
+		user.EmailVerifiedAt = &now
+	}
+
+	membership, err := s.organizations.memberChanged(invitation.OrganizationID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &InvitationAcceptance{User: user, Membership: membership, Registered: registered}, nil
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrUnknownSigningKey = errors.New("unknown signing key")
+
+// SigningKey is an asymmetric key used to sign or verify access tokens
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// JWK is the JSON Web Key representation of a public verification key (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// KeySet holds the key used to sign new tokens and every key that is still accepted for verification.
+// Rotation works by publishing the next key as a verification key first, switching the signing key
+// once every instance knows it, and dropping the old key after the access token TTL has passed.
+type KeySet struct {
+	signing      *SigningKey
+	verification map[string]*SigningKey
+	hmacSecret   []byte
+}
+
+// NewHMACKeySet signs and verifies tokens with a shared HS256 secret, as before asymmetric keys were supported
+func NewHMACKeySet(secret string) *KeySet {
+	return &KeySet{
+		verification: map[string]*SigningKey{},
+		hmacSecret:   []byte(secret),
+	}
+}
+
+// LoadKeySet reads the signing key and additional verification keys from PEM files.
+// Without a signing key it falls back to HS256 with hmacSecret. When both are set, HS256 tokens
+// are still accepted so existing sessions survive the switch; unset the secret to stop that.
+func LoadKeySet(signingKeyPath string, verificationKeyPaths []string, hmacSecret string) (*KeySet, error) {
+	keys := &KeySet{verification: map[string]*SigningKey{}}
+	if hmacSecret != "" {
+		keys.hmacSecret = []byte(hmacSecret)
+	}
+
+	if signingKeyPath != "" {
+		key, err := loadKeyFile(signingKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		if key.Private == nil {
+			return nil, fmt.Errorf("signing key %s does not contain a private key", signingKeyPath)
+		}
+		keys.signing = key
+		keys.verification[key.ID] = key
+	}
+
+	for _, path := range verificationKeyPaths {
+		key, err := loadKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := keys.verification[key.ID]; !exists {
+			keys.verification[key.ID] = key
+		}
+	}
+
+	if keys.signing == nil && keys.hmacSecret == nil {
+		return nil, errors.New("either a signing key or a JWT secret is required")
+	}
+	return keys, nil
+}
+
+// Sign signs the claims with the current signing key and sets the kid header
+func (k *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if k.signing == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(k.hmacSecret)
+	}
+
+	token := jwt.NewWithClaims(k.signing.Method, claims)
+	token.Header["kid"] = k.signing.ID
+	return token.SignedString(k.signing.Private)
+}
+
+// Keyfunc resolves the verification key of a token from its kid header, pinning the algorithm to the key type
+func (k *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || k.hmacSecret == nil {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return k.hmacSecret, nil
+	}
+
+	key, ok := k.verification[kid]
+	if !ok {
+		return nil, ErrUnknownSigningKey
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, jwt.ErrSignatureInvalid
+	}
+	return key.Public, nil
+}
+
+// JWKS returns the public verification keys, HMAC secrets are never published
+func (k *KeySet) JWKS() []JWK {
+	jwks := make([]JWK, 0, len(k.verification))
+	for _, key := range k.verification {
+		jwks = append(jwks, key.JWK())
+	}
+	sort.Slice(jwks, func(i, j int) bool { return jwks[i].Kid < jwks[j].Kid })
+	return jwks
+}
+
+// JWK returns the public part of the key
+func (key *SigningKey) JWK() JWK {
+	jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+	switch pub := key.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+	return jwk
+}
+
+// NewSigningKey wraps an RSA or Ed25519 private or public key, deriving its kid from the public key
+func NewSigningKey(key interface{}) (*SigningKey, error) {
+	signingKey := &SigningKey{}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		signingKey.Private, signingKey.Public, signingKey.Method = k, &k.PublicKey, jwt.SigningMethodRS256
+	case *rsa.PublicKey:
+		signingKey.Public, signingKey.Method = k, jwt.SigningMethodRS256
+	case ed25519.PrivateKey:
+		signingKey.Private, signingKey.Public, signingKey.Method = k, k.Public(), jwt.SigningMethodEdDSA
+	case ed25519.PublicKey:
+		signingKey.Public, signingKey.Method = k, jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, only RSA and Ed25519 keys are supported", key)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(signingKey.Public)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+	signingKey.ID = base64.RawURLEncoding.EncodeToString(sum[:12])
+	return signingKey, nil
+}
+
+func loadKeyFile(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key %s: %w", path, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %s is not PEM encoded", path)
+	}
+
+	key, err := parseKey(block)
+	if err != nil {
+		return nil, fmt.Errorf("parse key %s: %w", path, err)
+	}
+	return NewSigningKey(key)
+}
+
+func parseKey(block *pem.Block) (interface{}, error) {
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	path := filepath.Join(t.TempDir(), "key.pem")
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600)
+	require.NoError(t, err)
+	return path
+}
+
+func writeRSAKey(t *testing.T) (string, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	return writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)), writePEM(t, "PUBLIC KEY", pub)
+}
+
+func writeEd25519Key(t *testing.T) (string, string) {
+	pubKey, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	priv, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	pub, err := x509.MarshalPKIXPublicKey(pubKey)
+	require.NoError(t, err)
+	return writePEM(t, "PRIVATE KEY", priv), writePEM(t, "PUBLIC KEY", pub)
+}
+
+func testClaims() jwt.MapClaims {
+	return jwt.MapClaims{"user_id": 1, "exp": time.Now().Add(time.Minute).Unix()}
+}
+
+func TestKeySetSignAndVerify(t *testing.T) {
+	rsaKey, _ := writeRSAKey(t)
+	edKey, _ := writeEd25519Key(t)
+
+	tests := []struct {
+		name string
+		path string
+		alg  string
+		kty  string
+	}{
+		{name: "RSA", path: rsaKey, alg: "RS256", kty: "RSA"},
+		{name: "Ed25519", path: edKey, alg: "EdDSA", kty: "OKP"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := LoadKeySet(tt.path, nil, "")
+			require.NoError(t, err)
+
+			tokenString, err := keys.Sign(testClaims())
+			require.NoError(t, err)
+
+			token, err := jwt.Parse(tokenString, keys.Keyfunc)
+			require.NoError(t, err)
+			assert.True(t, token.Valid)
+			assert.Equal(t, tt.alg, token.Method.Alg())
+			assert.NotEmpty(t, token.Header["kid"])
+
+			jwks := keys.JWKS()
+			require.Len(t, jwks, 1)
+			assert.Equal(t, token.Header["kid"], jwks[0].Kid)
+			assert.Equal(t, tt.kty, jwks[0].Kty)
+			assert.Equal(t, tt.alg, jwks[0].Alg)
+		})
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	oldKey, oldPublic := writeRSAKey(t)
+	newKey, _ := writeEd25519Key(t)
+
+	oldKeys, err := LoadKeySet(oldKey, nil, "")
+	require.NoError(t, err)
+	oldToken, err := oldKeys.Sign(testClaims())
+	require.NoError(t, err)
+
+	// After rotation the old public key is still accepted for verification
+	rotated, err := LoadKeySet(newKey, []string{oldPublic}, "")
+	require.NoError(t, err)
+	assert.Len(t, rotated.JWKS(), 2)
+
+	_, err = jwt.Parse(oldToken, rotated.Keyfunc)
+	assert.NoError(t, err)
+
+	newToken, err := rotated.Sign(testClaims())
+	require.NoError(t, err)
+	_, err = jwt.Parse(newToken, rotated.Keyfunc)
+	assert.NoError(t, err)
+
+	// Once the old key is dropped its tokens are rejected
+	dropped, err := LoadKeySet(newKey, nil, "")
+	require.NoError(t, err)
+	_, err = jwt.Parse(oldToken, dropped.Keyfunc)
+	assert.ErrorIs(t, err, ErrUnknownSigningKey)
+}
+
+func TestKeySetRejectsAlgorithmConfusion(t *testing.T) {
+	rsaKey, _ := writeRSAKey(t)
+	keys, err := LoadKeySet(rsaKey, nil, "")
+	require.NoError(t, err)
+	kid := keys.JWKS()[0].Kid
+
+	// HS256 token with the RSA kid must not be verified with anything
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+	forged.Header["kid"] = kid
+	tokenString, err := forged.SignedString([]byte("guess"))
+	require.NoError(t, err)
+	_, err = jwt.Parse(tokenString, keys.Keyfunc)
+	assert.Error(t, err)
+
+	// Without a JWT secret HS256 tokens are not accepted at all
+	legacy, err := NewHMACKeySet("secret").Sign(testClaims())
+	require.NoError(t, err)
+	_, err = jwt.Parse(legacy, keys.Keyfunc)
+	assert.Error(t, err)
+}
+
+func TestKeySetHMACFallback(t *testing.T) {
+	rsaKey, _ := writeRSAKey(t)
+	legacy, err := NewHMACKeySet("secret").Sign(testClaims())
+	require.NoError(t, err)
+
+	// HS256 tokens keep working while JWT_SECRET is still configured
+	keys, err := LoadKeySet(rsaKey, nil, "secret")
+	require.NoError(t, err)
+	_, err = jwt.Parse(legacy, keys.Keyfunc)
+	assert.NoError(t, err)
+
+	// The shared secret is never published
+	assert.Len(t, keys.JWKS(), 1)
+	assert.Empty(t, NewHMACKeySet("secret").JWKS())
+
+	_, err = LoadKeySet("", nil, "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository"
+)
+
+var (
+	ErrAccountLocked        = apperrors.ErrAccountLocked
+	ErrTooManyLoginAttempts = apperrors.ErrTooManyRequests
+)
+
+// ThrottleError refuses a login, RetryAfter is how long the client has to wait
+type ThrottleError struct {
+	Err        *apperrors.AppError
+	RetryAfter time.Duration
+}
+
+func (e *ThrottleError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ThrottleError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAfterSeconds rounds RetryAfter up for the Retry-After header
+func (e *ThrottleError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+func newThrottleError(err *apperrors.AppError, wait time.Duration) *ThrottleError {
+	e := &ThrottleError{RetryAfter: wait}
+	e.Err = err.WithDetails(fmt.Sprintf("Try again in %d seconds", e.RetryAfterSeconds()))
+	return e
+}
+
+// ThrottleConfig controls how failed logins slow down and lock out further attempts.
+// Failures older than Window are forgotten.
+type ThrottleConfig struct {
+	// MaxAccountFailures locks the account for LockoutDuration, 0 disables the lockout
+	MaxAccountFailures int
+	// MaxIPFailures blocks the client IP for LockoutDuration, 0 disables the block
+	MaxIPFailures   int
+	LockoutDuration time.Duration
+	Window          time.Duration
+	// BackoffAfter failures each further attempt has to wait BackoffBase, doubled per failure up to BackoffMax
+	BackoffAfter int
+	BackoffBase  time.Duration
+	BackoffMax   time.Duration
+}
+
+// LoginThrottle tracks failed logins per account and per client IP.
+// Accounts are keyed by the normalized email, so unknown emails are throttled exactly like
+// registered ones and the responses do not reveal which accounts exist.
+type LoginThrottle struct {
+	repo   repository.LoginThrottleRepository
+	config ThrottleConfig
+}
+
+func NewLoginThrottle(repo repository.LoginThrottleRepository, config ThrottleConfig) *LoginThrottle {
+	return &LoginThrottle{
+		repo:   repo,
+		config: config,
+	}
+}
+
+// Check returns a ThrottleError wrapping ErrAccountLocked or ErrTooManyLoginAttempts when a
+// login for email from ip must be refused without looking at the password
+func (t *LoginThrottle) Check(email, ip string) error {
+	now := time.Now()
+
+	account, err := t.repo.Find(accountKey(email))
+	if err != nil {
+		return err
+	}
+	if wait := lockRemaining(account, now); wait > 0 {
+		return newThrottleError(ErrAccountLocked, wait)
+	}
+	if wait := t.backoffRemaining(account, now); wait > 0 {
+		return newThrottleError(ErrTooManyLoginAttempts, wait)
+	}
+
+	client, err := t.repo.Find(ipKey(ip))
+	if err != nil {
+		return err
+	}
+	if wait := lockRemaining(client, now); wait > 0 {
+		return newThrottleError(ErrTooManyLoginAttempts, wait)
+	}
+	if wait := t.backoffRemaining(client, now); wait > 0 {
+		return newThrottleError(ErrTooManyLoginAttempts, wait)
+	}
+	return nil
+}
+
+// RecordFailure counts a failed login and locks the account or IP once its threshold is reached
+func (t *LoginThrottle) RecordFailure(email, ip string) error {
+	if err := t.recordFailure(accountKey(email), t.config.MaxAccountFailures); err != nil {
+		return err
+	}
+	return t.recordFailure(ipKey(ip), t.config.MaxIPFailures)
+}
+
+// RecordSuccess clears the account's failures. The IP keeps its count so an attacker cannot
+// reset it by logging into an account of their own.
+func (t *LoginThrottle) RecordSuccess(email string) error {
+	return t.repo.Reset(accountKey(email))
+}
+
+// Unlock lifts a lockout of the account and forgets its failures
+func (t *LoginThrottle) Unlock(email string) error {
+	return t.repo.Reset(accountKey(email))
+}
+
+// PurgeStale removes the bookkeeping of keys without recent failures
+func (t *LoginThrottle) PurgeStale() error {
+	return t.repo.DeleteStale(time.Now().Add(-t.config.Window))
+}
+
+func (t *LoginThrottle) recordFailure(key string, maxFailures int) error {
+	now := time.Now()
+	throttle, err := t.repo.RecordFailure(key, now, t.config.Window)
+	if err != nil {
+		return err
+	}
+	if maxFailures > 0 && throttle != nil && throttle.Failures >= maxFailures && lockRemaining(throttle, now) == 0 {
+		return t.repo.Lock(key, now.Add(t.config.LockoutDuration))
+	}
+	return nil
+}
+
+// backoffRemaining is how long the next attempt has to wait after the latest failure
+func (t *LoginThrottle) backoffRemaining(throttle *models.LoginThrottle, now time.Time) time.Duration {
+	if throttle == nil || t.config.BackoffBase <= 0 || throttle.Failures < t.config.BackoffAfter {
+		return 0
+	}
+	if now.Sub(throttle.LastFailureAt) > t.config.Window {
+		return 0
+	}
+
+	delay := t.config.BackoffBase
+	for i := t.config.BackoffAfter; i < throttle.Failures && delay < t.config.BackoffMax; i++ {
+		delay *= 2
+	}
+	if t.config.BackoffMax > 0 && delay > t.config.BackoffMax {
+		delay = t.config.BackoffMax
+	}
+	return throttle.LastFailureAt.Add(delay).Sub(now)
+}
+
+func lockRemaining(throttle *models.LoginThrottle, now time.Time) time.Duration {
+	if throttle == nil || throttle.LockedUntil == nil || !now.Before(*throttle.LockedUntil) {
+		return 0
+	}
+	return throttle.LockedUntil.Sub(now)
+}
+
+func accountKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sukhantharot/go-service/models"
+)
+
+func TestLoginThrottleBackoff(t *testing.T) {
+	throttle := NewLoginThrottle(nil, ThrottleConfig{
+		Window:       15 * time.Minute,
+		BackoffAfter: 3,
+		BackoffBase:  time.Second,
+		BackoffMax:   10 * time.Second,
+	})
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		failures int
+		since    time.Duration
+		expected time.Duration
+	}{
+		{name: "below threshold", failures: 2, expected: 0},
+		{name: "first backoff", failures: 3, expected: time.Second},
+		{name: "doubles per failure", failures: 5, expected: 4 * time.Second},
+		{name: "capped", failures: 20, expected: 10 * time.Second},
+		{name: "partly waited", failures: 5, since: 3 * time.Second, expected: time.Second},
+		{name: "fully waited", failures: 5, since: 5 * time.Second, expected: 0},
+		{name: "outside window", failures: 20, since: 20 * time.Minute, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := &models.LoginThrottle{Failures: tt.failures, LastFailureAt: now.Add(-tt.since)}
+			wait := throttle.backoffRemaining(record, now)
+			if wait < 0 {
+				wait = 0
+			}
+			assert.Equal(t, tt.expected, wait)
+		})
+	}
+}
+
+func TestLockRemaining(t *testing.T) {
+	now := time.Now()
+	future := now.Add(time.Minute)
+	past := now.Add(-time.Minute)
+
+	assert.Zero(t, lockRemaining(nil, now))
+	assert.Zero(t, lockRemaining(&models.LoginThrottle{}, now))
+	assert.Zero(t, lockRemaining(&models.LoginThrottle{LockedUntil: &past}, now))
+	assert.Equal(t, time.Minute, lockRemaining(&models.LoginThrottle{LockedUntil: &future}, now))
+}
@@ -0,0 +1,324 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository"
+	"github.com/sukhantharot/go-service/totp"
+)
+
+var (
+	ErrMFAAlreadyEnabled   = apperrors.New(http.StatusConflict, "mfa_already_enabled", "Two-factor authentication is already enabled")
+	ErrMFANotEnrolled      = apperrors.New(http.StatusBadRequest, "mfa_not_enrolled", "Two-factor enrollment has not been started")
+	ErrMFANotEnabled       = apperrors.New(http.StatusBadRequest, "mfa_not_enabled", "Two-factor authentication is not enabled")
+	ErrMFARequiredByRole   = apperrors.New(http.StatusForbidden, "mfa_required_by_role", "Two-factor authentication is required for your role")
+	ErrInvalidMFACode      = apperrors.New(http.StatusUnauthorized, "invalid_mfa_code", "Invalid two-factor code")
+	ErrInvalidMFAChallenge = apperrors.New(http.StatusUnauthorized, "invalid_mfa_challenge", "Invalid or expired two-factor challenge")
+	ErrMFARequired         = apperrors.ErrMFARequired
+)
+
+// MFAPolicy decides which users must use a second factor
+type MFAPolicy struct {
+	// RequiredPermissions lists permissions whose holders must enable MFA, e.g. "admin"
+	RequiredPermissions []string
+}
+
+// Requires reports whether the user's role makes MFA mandatory, Role.Permissions must be loaded
+func (p MFAPolicy) Requires(user *models.User) bool {
+	for _, permission := range p.RequiredPermissions {
+		if user.HasPermission(permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// MFAConfig controls TOTP enrollment and the second login step
+type MFAConfig struct {
+	// Issuer is shown by authenticator apps next to the account name
+	Issuer        string
+	ChallengeTTL  time.Duration
+	MaxAttempts   int
+	RecoveryCodes int
+	Policy        MFAPolicy
+}
+
+// TOTPEnrollment is returned when enrollment starts, the secret is shown to the user once
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// MFAChallenge is returned by login instead of tokens when a second factor is needed
+type MFAChallenge struct {
+	Token     string    `json:"mfa_token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type MFAService struct {
+	userRepo repository.UserRepository
+	mfaRepo  repository.MFARepository
+	// attempts counts wrong codes of signed in users, keyed by mfaKey
+	attempts repository.LoginThrottleRepository
+	tokens   *TokenService
+	config   MFAConfig
+}
+
+func NewMFAService(userRepo repository.UserRepository, mfaRepo repository.MFARepository, attempts repository.LoginThrottleRepository, tokens *TokenService, config MFAConfig) *MFAService {
+	return &MFAService{
+		userRepo: userRepo,
+		mfaRepo:  mfaRepo,
+		attempts: attempts,
+		tokens:   tokens,
+		config:   config,
+	}
+}
+
+// StartEnrollment creates a new pending TOTP secret, replacing any earlier unconfirmed one
+func (s *MFAService) StartEnrollment(userID uint) (*TOTPEnrollment, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsMFAEnabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.SetPendingSecret(user.ID, secret); err != nil {
+		return nil, err
+	}
+
+	return &TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.ProvisioningURI(s.config.Issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmEnrollment enables MFA once the user proves their authenticator works and returns
+// the recovery codes, which are only ever shown here
+func (s *MFAService) ConfirmEnrollment(userID uint, code string) ([]string, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsMFAEnabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrMFANotEnrolled
+	}
+
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := s.newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.EnableTOTP(user.ID, step, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable turns MFA off after checking a current code, unless the user's role requires it
+func (s *MFAService) Disable(userID uint, code string) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.IsMFAEnabled() {
+		return ErrMFANotEnabled
+	}
+	if s.config.Policy.Requires(user) {
+		return ErrMFARequiredByRole
+	}
+	if err := s.checkSecondFactor(user, code); err != nil {
+		return err
+	}
+	return s.mfaRepo.DisableTOTP(user.ID)
+}
+
+// RegenerateRecoveryCodes replaces every recovery code after checking a current code
+func (s *MFAService) RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsMFAEnabled() {
+		return nil, ErrMFANotEnabled
+	}
+	if err := s.checkSecondFactor(user, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := s.newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.ReplaceRecoveryCodes(user.ID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Challenge starts the second login step for a user whose password was already checked
+func (s *MFAService) Challenge(user *models.User) (*MFAChallenge, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	challenge := &models.MFAChallenge{
+		UserID:    user.ID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(s.config.ChallengeTTL),
+	}
+	if err := s.mfaRepo.CreateChallenge(challenge); err != nil {
+		return nil, err
+	}
+	return &MFAChallenge{Token: raw, ExpiresAt: challenge.ExpiresAt}, nil
+}
+
+// CompleteChallenge checks the TOTP or recovery code for a challenge and issues the real tokens.
+// A challenge allows MaxAttempts guesses and can only be completed once.
+func (s *MFAService) CompleteChallenge(challengeToken, code string) (*TokenPair, *models.User, error) {
+	challenge, err := s.mfaRepo.FindChallengeByHash(hashToken(challengeToken))
+	if err != nil {
+		return nil, nil, ErrInvalidMFAChallenge
+	}
+
+	allowed, err := s.mfaRepo.RecordChallengeAttempt(challenge.ID, s.config.MaxAttempts, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	if !allowed {
+		return nil, nil, ErrInvalidMFAChallenge
+	}
+
+	user, err := s.userRepo.FindByID(challenge.UserID)
+	if err != nil {
+		return nil, nil, ErrInvalidMFAChallenge
+	}
+	if err := s.verifySecondFactor(user, code); err != nil {
+		return nil, nil, err
+	}
+
+	consumed, err := s.mfaRepo.ConsumeChallenge(challenge.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !consumed {
+		return nil, nil, ErrInvalidMFAChallenge
+	}
+
+	pair, err := s.tokens.IssueTokenPair(user, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pair, user, nil
+}
+
+// RequiresMFA reports whether the user's role makes MFA mandatory
+func (s *MFAService) RequiresMFA(user *models.User) bool {
+	return s.config.Policy.Requires(user)
+}
+
+// checkSecondFactor verifies a code outside of login. Like a login challenge it allows MaxAttempts
+// wrong codes, after that every code is refused with a ThrottleError until ChallengeTTL has passed.
+func (s *MFAService) checkSecondFactor(user *models.User, code string) error {
+	key := mfaKey(user.ID)
+	now := time.Now()
+
+	attempts, err := s.attempts.Find(key)
+	if err != nil {
+		return err
+	}
+	if wait := lockRemaining(attempts, now); wait > 0 {
+		return newThrottleError(ErrTooManyLoginAttempts, wait)
+	}
+
+	err = s.verifySecondFactor(user, code)
+	if err == nil {
+		return s.attempts.Reset(key)
+	}
+	if !errors.Is(err, ErrInvalidMFACode) {
+		return err
+	}
+
+	attempts, recordErr := s.attempts.RecordFailure(key, now, s.config.ChallengeTTL)
+	if recordErr != nil {
+		return recordErr
+	}
+	if attempts != nil && attempts.Failures >= s.config.MaxAttempts {
+		if lockErr := s.attempts.Lock(key, now.Add(s.config.ChallengeTTL)); lockErr != nil {
+			return lockErr
+		}
+	}
+	return err
+}
+
+// verifySecondFactor accepts a current TOTP code that was not used before, or an unused recovery code
+func (s *MFAService) verifySecondFactor(user *models.User, code string) error {
+	if !user.IsMFAEnabled() {
+		return ErrMFANotEnabled
+	}
+
+	if step, ok := totp.Validate(user.TOTPSecret, code, time.Now()); ok {
+		fresh, err := s.mfaRepo.AdvanceTOTPStep(user.ID, step)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	consumed, err := s.mfaRepo.ConsumeRecoveryCode(user.ID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// newRecoveryCodes returns codes formatted as xxxxx-xxxxx and their hashes
+func (s *MFAService) newRecoveryCodes() ([]string, []string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, 0, s.config.RecoveryCodes)
+	hashes := make([]string, 0, s.config.RecoveryCodes)
+	for i := 0; i < s.config.RecoveryCodes; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(b))[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashToken(code))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+func mfaKey(userID uint) string {
+	return fmt.Sprintf("mfa:%d", userID)
+}
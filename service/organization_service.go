@@ -0,0 +1,193 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOrganizationNotFound = apperrors.New(http.StatusNotFound, "organization_not_found", "Organization not found")
+	ErrOrganizationExists   = apperrors.New(http.StatusConflict, "organization_exists", "Organization already exists")
+	ErrMembershipNotFound   = apperrors.New(http.StatusNotFound, "membership_not_found", "User is not a member of this organization")
+	ErrAdminMembershipRole  = apperrors.New(http.StatusBadRequest, "admin_membership_role", "The role of a membership cannot grant the admin permission")
+	ErrMembershipEscalation = apperrors.New(http.StatusForbidden, "membership_escalation", "You cannot give a member permissions you do not have")
+)
+
+// OrganizationService manages organizations, the tenants, and the memberships that put
+// users in them with a role
+type OrganizationService struct {
+	organizations repository.OrganizationRepository
+	users         repository.UserRepository
+	roles         *PermissionCache
+	tokens        *TokenService
+	revocations   *RevocationStore
+}
+
+func NewOrganizationService(organizations repository.OrganizationRepository, users repository.UserRepository, roles *PermissionCache, tokens *TokenService, revocations *RevocationStore) *OrganizationService {
+	return &OrganizationService{
+		organizations: organizations,
+		users:         users,
+		roles:         roles,
+		tokens:        tokens,
+		revocations:   revocations,
+	}
+}
+
+func (s *OrganizationService) List() ([]models.Organization, error) {
+	return s.organizations.List()
+}
+
+func (s *OrganizationService) Get(id uint) (*models.Organization, error) {
+	organization, err := s.organizations.FindByID(id)
+	if err != nil {
+		return nil, notFoundAs(err, ErrOrganizationNotFound)
+	}
+	return organization, nil
+}
+
+func (s *OrganizationService) Create(name, description string) (*models.Organization, error) {
+	organization := &models.Organization{Name: name, Description: description}
+	if err := s.organizations.Create(organization); err != nil {
+		return nil, duplicateAs(err, ErrOrganizationExists)
+	}
+	return organization, nil
+}
+
+func (s *OrganizationService) Update(id uint, name, description string) (*models.Organization, error) {
+	organization := &models.Organization{Model: gorm.Model{ID: id}, Name: name, Description: description}
+	if err := s.organizations.Update(organization); err != nil {
+		return nil, duplicateAs(notFoundAs(err, ErrOrganizationNotFound), ErrOrganizationExists)
+	}
+	return s.Get(id)
+}
+
+// Delete removes the organization with its memberships. Access tokens of its members
+// carry their role in it, so they are revoked.
+func (s *OrganizationService) Delete(id uint) error {
+	members, err := s.organizations.ListMembers(id)
+	if err != nil {
+		return err
+	}
+	if err := s.organizations.Delete(id); err != nil {
+		return notFoundAs(err, ErrOrganizationNotFound)
+	}
+	for _, member := range members {
+		if err := s.revocations.RevokeAll(member.UserID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListMembers returns the memberships of the organization with their users and roles
+func (s *OrganizationService) ListMembers(organizationID uint) ([]models.Membership, error) {
+	if _, err := s.Get(organizationID); err != nil {
+		return nil, err
+	}
+	return s.organizations.ListMembers(organizationID)
+}
+
+// Membership returns the membership of the user in the organization with its role
+func (s *OrganizationService) Membership(organizationID, userID uint) (*models.Membership, error) {
+	membership, err := s.organizations.FindMembership(organizationID, userID)
+	if err != nil {
+		return nil, notFoundAs(err, ErrMembershipNotFound)
+	}
+	return membership, nil
+}
+
+// SetMember adds the user to the organization with the role, or gives an existing member
+// the role. Access tokens carry the role, so the user's current ones are revoked and the
+// next refresh picks up the change.
+func (s *OrganizationService) SetMember(organizationID, userID, roleID uint) (*models.Membership, error) {
+	if _, err := s.Get(organizationID); err != nil {
+		return nil, err
+	}
+	if _, err := s.users.FindByID(userID); err != nil {
+		return nil, notFoundAs(err, ErrUserNotFound)
+	}
+	if err := s.checkMemberRole(roleID); err != nil {
+		return nil, err
+	}
+
+	membership := &models.Membership{OrganizationID: organizationID, UserID: userID, RoleID: roleID}
+	if err := s.organizations.SetMember(membership); err != nil {
+		return nil, err
+	}
+	return s.memberChanged(organizationID, userID)
+}
+
+// checkMemberRole fails unless the role exists and can be given to members
+func (s *OrganizationService) checkMemberRole(roleID uint) error {
+	role, err := s.roles.FindByID(roleID)
+	if err != nil {
+		return notFoundAs(err, ErrRoleNotFound)
+	}
+	// Admin routes are not scoped to an organization, a member must not reach them
+	if models.HasPermission([]models.Role{*role}, models.PermissionAdmin) {
+		return ErrAdminMembershipRole
+	}
+	return nil
+}
+
+// memberChanged revokes the access tokens of a user who joined the organization or got
+// another role in it, and returns the membership
+func (s *OrganizationService) memberChanged(organizationID, userID uint) (*models.Membership, error) {
+	if err := s.revocations.RevokeAll(userID); err != nil {
+		return nil, err
+	}
+	return s.organizations.FindMembership(organizationID, userID)
+}
+
+// AssignMember is SetMember on behalf of a member of the organization, who can neither
+// change their own role nor give a role with a permission their own role lacks
+func (s *OrganizationService) AssignMember(actorID, organizationID, userID, roleID uint) (*models.Membership, error) {
+	if actorID == userID {
+		return nil, ErrSelfAction
+	}
+	actor, err := s.organizations.FindMembership(organizationID, actorID)
+	if err != nil {
+		return nil, notFoundAs(err, ErrNotMember)
+	}
+	role, err := s.roles.FindByID(roleID)
+	if err != nil {
+		return nil, notFoundAs(err, ErrRoleNotFound)
+	}
+	for _, permission := range models.EffectivePermissions([]models.Role{*role}) {
+		if !models.HasPermission([]models.Role{actor.Role}, permission.Name) {
+			return nil, ErrMembershipEscalation.WithDetails(permission.Name)
+		}
+	}
+	return s.SetMember(organizationID, userID, roleID)
+}
+
+// RemoveMember takes the user out of the organization and revokes their access tokens
+func (s *OrganizationService) RemoveMember(organizationID, userID uint) error {
+	if err := s.organizations.RemoveMember(organizationID, userID); err != nil {
+		return notFoundAs(err, ErrMembershipNotFound)
+	}
+	return s.revocations.RevokeAll(userID)
+}
+
+// ListForUser returns the memberships of the user with their organizations and roles
+func (s *OrganizationService) ListForUser(userID uint) ([]models.Membership, error) {
+	return s.organizations.ListForUser(userID)
+}
+
+// Switch returns new tokens with the organization as the active one, zero for none. mfa
+// carries over whether the current session passed a second factor.
+func (s *OrganizationService) Switch(userID uint, mfa bool, organizationID uint) (*TokenPair, error) {
+	user, err := s.users.FindByID(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apperrors.ErrUnauthorized.Wrap(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.tokens.SwitchOrganization(user, mfa, organizationID)
+}
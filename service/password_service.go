@@ -0,0 +1,134 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/mail"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidResetToken = apperrors.New(http.StatusBadRequest, "invalid_reset_token", "Invalid or expired reset token")
+	// ErrIncorrectPassword is returned when a signed in user confirms a change with the wrong password
+	ErrIncorrectPassword = apperrors.New(http.StatusBadRequest, "incorrect_password", "Current password is incorrect")
+)
+
+// PasswordConfig controls the password reset flow
+type PasswordConfig struct {
+	ResetTTL time.Duration
+	// ResetURL is the frontend page that receives the token as ?token=
+	ResetURL string
+}
+
+type PasswordService struct {
+	userRepo  repository.UserRepository
+	resetRepo repository.PasswordResetRepository
+	tokens    *TokenService
+	mailer    mail.Sender
+	config    PasswordConfig
+}
+
+func NewPasswordService(userRepo repository.UserRepository, resetRepo repository.PasswordResetRepository, tokens *TokenService, mailer mail.Sender, config PasswordConfig) *PasswordService {
+	return &PasswordService{
+		userRepo:  userRepo,
+		resetRepo: resetRepo,
+		tokens:    tokens,
+		mailer:    mailer,
+		config:    config,
+	}
+}
+
+// ForgotPassword emails a reset link when the email belongs to a user.
+// Unknown emails are not an error so callers cannot tell which emails are registered.
+func (s *PasswordService) ForgotPassword(email string) error {
+	user, err := s.userRepo.FindByEmail(email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	raw, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+	token := &models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(s.config.ResetTTL),
+	}
+	if err := s.resetRepo.Create(token); err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password. It expires in %s and can only be used once.\n\n%s?token=%s\n\nIf you did not ask for a password reset you can ignore this email.\n",
+			user.FirstName, s.config.ResetTTL, s.config.ResetURL, raw),
+	})
+}
+
+// ResetPassword sets a new password using a reset token and signs the user out everywhere
+func (s *PasswordService) ResetPassword(rawToken, newPassword string) error {
+	token, err := s.resetRepo.FindByHash(hashToken(rawToken))
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+
+	consumed, err := s.resetRepo.Consume(token.ID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return ErrInvalidResetToken
+	}
+
+	hashedPassword, err := models.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdatePassword(token.UserID, hashedPassword); err != nil {
+		return err
+	}
+
+	// Older reset links and existing sessions must not outlive the new password
+	if err := s.resetRepo.InvalidateForUser(token.UserID); err != nil {
+		return err
+	}
+	return s.tokens.LogoutAll(token.UserID)
+}
+
+// ChangePassword sets a new password after checking the current one. Every other session
+// ends, the caller continues with the returned tokens. mfa tells whether the caller's
+// session passed a second factor.
+func (s *PasswordService) ChangePassword(userID uint, currentPassword, newPassword string, mfa bool) (*TokenPair, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.CheckPassword(currentPassword) {
+		return nil, ErrIncorrectPassword
+	}
+
+	hashedPassword, err := models.HashPassword(newPassword)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.UpdatePassword(userID, hashedPassword); err != nil {
+		return nil, err
+	}
+
+	// A reset link sent for the old password must not undo the change
+	if err := s.resetRepo.InvalidateForUser(userID); err != nil {
+		return nil, err
+	}
+	return s.tokens.LogoutOthers(user, mfa)
+}
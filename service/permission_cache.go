@@ -0,0 +1,100 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository"
+)
+
+type roleEntry struct {
+	role  models.Role
+	until time.Time
+}
+
+// PermissionCache loads roles with their permissions and ancestors for permission checks,
+// caching them in process. RBACService invalidates it on every change to roles and
+// permissions, so changes through this instance apply immediately; changes made by
+// another replica become visible after cacheTTL.
+type PermissionCache struct {
+	roles    repository.RoleRepository
+	cacheTTL time.Duration
+
+	mu        sync.RWMutex
+	entries   map[uint]roleEntry
+	changedAt time.Time
+}
+
+func NewPermissionCache(roles repository.RoleRepository, cacheTTL time.Duration) *PermissionCache {
+	return &PermissionCache{
+		roles:    roles,
+		cacheTTL: cacheTTL,
+		entries:  make(map[uint]roleEntry),
+	}
+}
+
+// FindByID returns the role like RoleRepository.FindByID, from the cache while it is
+// fresh. Missing roles are not cached.
+func (c *PermissionCache) FindByID(id uint) (*models.Role, error) {
+	now := time.Now()
+
+	c.mu.RLock()
+	entry, ok := c.entries[id]
+	c.mu.RUnlock()
+	if ok && now.Before(entry.until) {
+		role := cloneRole(entry.role)
+		return &role, nil
+	}
+
+	c.mu.RLock()
+	generation := c.changedAt
+	c.mu.RUnlock()
+	role, err := c.roles.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	// A role loaded while an invalidation ran may already be stale
+	if c.changedAt.Equal(generation) {
+		c.entries[id] = roleEntry{role: cloneRole(*role), until: now.Add(c.cacheTTL)}
+	}
+	c.mu.Unlock()
+	return role, nil
+}
+
+// FindByName returns the role like RoleRepository.FindByName, without caching it
+func (c *PermissionCache) FindByName(name string) (*models.Role, error) {
+	return c.roles.FindByName(name)
+}
+
+// Invalidate drops every cached role. A change to one role can change the permissions of
+// every role that inherits from it, so the whole cache goes.
+func (c *PermissionCache) Invalidate() {
+	c.mu.Lock()
+	c.entries = make(map[uint]roleEntry)
+	c.changedAt = time.Now()
+	c.mu.Unlock()
+}
+
+// ChangedAt returns when roles or permissions last changed through this instance, the
+// zero time before the first change
+func (c *PermissionCache) ChangedAt() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.changedAt
+}
+
+// cloneRole copies the permissions and ancestors of role, so callers cannot change a cached role
+func cloneRole(role models.Role) models.Role {
+	role.Permissions = append([]models.Permission(nil), role.Permissions...)
+	if role.Parents != nil {
+		parents := make([]models.Role, len(role.Parents))
+		for i, parent := range role.Parents {
+			parents[i] = cloneRole(parent)
+		}
+		role.Parents = parents
+	}
+	return role
+}
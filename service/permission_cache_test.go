@@ -0,0 +1,95 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository"
+	"github.com/sukhantharot/go-service/repository/memory"
+	"gorm.io/gorm"
+)
+
+// countingRoles counts the roles loaded from the repository
+type countingRoles struct {
+	repository.RoleRepository
+	loads int
+}
+
+func (r *countingRoles) FindByID(id uint) (*models.Role, error) {
+	r.loads++
+	return r.RoleRepository.FindByID(id)
+}
+
+func TestPermissionCache(t *testing.T) {
+	store := memory.NewStore()
+	roles := &countingRoles{RoleRepository: memory.NewRoleRepository(store)}
+	permissions := memory.NewPermissionRepository(store)
+	cache := NewPermissionCache(roles, time.Minute)
+	rbac := NewRBACService(roles, permissions, cache)
+
+	base := models.Role{Name: "base", Permissions: []models.Permission{{Name: "users:read"}}}
+	require.NoError(t, roles.Create(&base))
+	editor := models.Role{Name: "editor", Parents: []models.Role{base}}
+	require.NoError(t, roles.Create(&editor))
+
+	t.Run("roles are loaded once", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			role, err := cache.FindByID(editor.ID)
+			require.NoError(t, err)
+			assert.True(t, models.HasPermission([]models.Role{*role}, "users:read"))
+		}
+		assert.Equal(t, 1, roles.loads)
+	})
+
+	t.Run("cached roles are copies", func(t *testing.T) {
+		role, err := cache.FindByID(editor.ID)
+		require.NoError(t, err)
+		role.Parents[0].Permissions[0].Name = "changed"
+
+		role, err = cache.FindByID(editor.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "users:read", role.Parents[0].Permissions[0].Name)
+	})
+
+	t.Run("changes through RBACService apply immediately", func(t *testing.T) {
+		before := cache.ChangedAt()
+		write := models.Permission{Name: "users:write"}
+		require.NoError(t, permissions.Create(&write))
+
+		// A change to the parent reaches the cached child
+		_, err := rbac.AttachPermission(base.ID, write.ID)
+		require.NoError(t, err)
+		assert.True(t, cache.ChangedAt().After(before))
+		role, err := cache.FindByID(editor.ID)
+		require.NoError(t, err)
+		assert.True(t, models.HasPermission([]models.Role{*role}, "users:write"))
+
+		_, err = rbac.RemoveParent(editor.ID, base.ID)
+		require.NoError(t, err)
+		role, err = cache.FindByID(editor.ID)
+		require.NoError(t, err)
+		assert.False(t, models.HasPermission([]models.Role{*role}, "users:read"))
+	})
+
+	t.Run("missing roles are not cached", func(t *testing.T) {
+		_, err := cache.FindByID(999)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		loads := roles.loads
+		_, err = cache.FindByID(999)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		assert.Equal(t, loads+1, roles.loads)
+	})
+
+	t.Run("entries expire", func(t *testing.T) {
+		uncached := NewPermissionCache(roles, 0)
+		loads := roles.loads
+		_, err := uncached.FindByID(base.ID)
+		require.NoError(t, err)
+		_, err = uncached.FindByID(base.ID)
+		require.NoError(t, err)
+		assert.Equal(t, loads+2, roles.loads)
+	})
+}
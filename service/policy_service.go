@@ -0,0 +1,259 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/logger"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/policy"
+	"github.com/sukhantharot/go-service/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPolicyNotFound = apperrors.New(http.StatusNotFound, "policy_not_found", "Policy not found")
+	ErrPolicyExists   = apperrors.New(http.StatusConflict, "policy_exists", "Policy already exists")
+	ErrInvalidPolicy  = apperrors.New(http.StatusBadRequest, "invalid_policy", "Invalid policy")
+)
+
+// Sources of the policies PolicyService evaluates
+const (
+	PolicySourceFile     = "file"
+	PolicySourceDatabase = "database"
+)
+
+// PolicyConfig configures attribute-based policies
+type PolicyConfig struct {
+	// Files are YAML or JSON policy files, loaded once at startup
+	Files []string
+	// Location is the time zone of context.time, UTC when nil
+	Location *time.Location
+	// RefreshInterval bounds how long policies created or deleted by another replica go unseen
+	RefreshInterval time.Duration
+}
+
+// StoredPolicy is a policy with where it comes from, ID is zero for file policies
+type StoredPolicy struct {
+	ID     uint   `json:"id,omitempty"`
+	Source string `json:"source"`
+	policy.Policy
+}
+
+// PolicyService evaluates the policies of the configured files together with the ones
+// managed through the API, and builds the subject and resource attributes they look at
+type PolicyService struct {
+	policies repository.PolicyRepository
+	users    repository.UserRepository
+	roles    *PermissionCache
+	config   PolicyConfig
+	files    []policy.Policy
+	engine   *policy.Engine
+
+	mu       sync.Mutex
+	loadedAt time.Time
+}
+
+// NewPolicyService loads the policy files and the stored policies
+func NewPolicyService(policies repository.PolicyRepository, users repository.UserRepository, roles *PermissionCache, config PolicyConfig) (*PolicyService, error) {
+	files, err := policy.LoadFiles(config.Files)
+	if err != nil {
+		return nil, err
+	}
+	s := &PolicyService{
+		policies: policies,
+		users:    users,
+		roles:    roles,
+		config:   config,
+		files:    files,
+		engine:   &policy.Engine{Location: config.Location},
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload reads the stored policies again
+func (s *PolicyService) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reload()
+}
+
+func (s *PolicyService) reload() error {
+	stored, err := s.stored()
+	if err != nil {
+		return err
+	}
+	policies := append([]policy.Policy(nil), s.files...)
+	for _, p := range stored {
+		policies = append(policies, p.Policy)
+	}
+	if err := s.engine.Replace(policies); err != nil {
+		return err
+	}
+	s.loadedAt = time.Now()
+	return nil
+}
+
+// current returns the engine, reloading the stored policies once RefreshInterval passed.
+// A failed reload keeps the policies loaded before.
+func (s *PolicyService) current() *policy.Engine {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.config.RefreshInterval > 0 && time.Since(s.loadedAt) >= s.config.RefreshInterval {
+		if err := s.reload(); err != nil {
+			logger.Error("Could not reload policies", err, nil)
+		}
+	}
+	return s.engine
+}
+
+func (s *PolicyService) stored() ([]StoredPolicy, error) {
+	records, err := s.policies.List()
+	if err != nil {
+		return nil, err
+	}
+	policies := make([]StoredPolicy, 0, len(records))
+	for _, record := range records {
+		var p policy.Policy
+		if err := json.Unmarshal([]byte(record.Definition), &p); err != nil {
+			return nil, err
+		}
+		policies = append(policies, StoredPolicy{ID: record.ID, Source: PolicySourceDatabase, Policy: p})
+	}
+	return policies, nil
+}
+
+// List returns the file policies followed by the stored ones
+func (s *PolicyService) List() ([]StoredPolicy, error) {
+	stored, err := s.stored()
+	if err != nil {
+		return nil, err
+	}
+	policies := make([]StoredPolicy, 0, len(s.files)+len(stored))
+	for _, p := range s.files {
+		policies = append(policies, StoredPolicy{Source: PolicySourceFile, Policy: p})
+	}
+	return append(policies, stored...), nil
+}
+
+// Create stores the policy, it takes effect on this instance immediately
+func (s *PolicyService) Create(p policy.Policy) (*StoredPolicy, error) {
+	if err := p.Validate(); err != nil {
+		return nil, ErrInvalidPolicy.WithMessage(err.Error())
+	}
+	for _, file := range s.files {
+		if file.Name == p.Name {
+			return nil, ErrPolicyExists
+		}
+	}
+	definition, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &models.Policy{Name: p.Name, Definition: string(definition)}
+	if err := s.policies.Create(record); err != nil {
+		return nil, duplicateAs(err, ErrPolicyExists)
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return &StoredPolicy{ID: record.ID, Source: PolicySourceDatabase, Policy: p}, nil
+}
+
+// Delete removes a stored policy, file policies cannot be deleted
+func (s *PolicyService) Delete(id uint) error {
+	if err := s.policies.Delete(id); err != nil {
+		return notFoundAs(err, ErrPolicyNotFound)
+	}
+	return s.Reload()
+}
+
+// Evaluate decides the request with every policy
+func (s *PolicyService) Evaluate(req policy.Request) policy.Decision {
+	return s.current().Evaluate(req)
+}
+
+// Applies reports whether any policy covers the action
+func (s *PolicyService) Applies(action string) bool {
+	return s.current().Applies(action)
+}
+
+// Subject returns the attributes of the user with the given roles: id, role_ids, roles
+// with the names of the roles and every role they inherit from, and permissions
+func (s *PolicyService) Subject(userID uint, roleIDs []uint) (policy.Attributes, error) {
+	roles := make([]models.Role, 0, len(roleIDs))
+	for _, roleID := range roleIDs {
+		role, err := s.roles.FindByID(roleID)
+		// A role deleted after the token was issued grants nothing
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, *role)
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for i := range roles {
+		for _, role := range roles[i].Lineage() {
+			if !seen[role.Name] {
+				seen[role.Name] = true
+				names = append(names, role.Name)
+			}
+		}
+	}
+	return policy.Attributes{
+		"id":          strconv.FormatUint(uint64(userID), 10),
+		"role_ids":    roleIDs,
+		"roles":       names,
+		"permissions": permissionNames(models.EffectivePermissions(roles)),
+	}, nil
+}
+
+// UserAttributes returns the attributes of the user with the given ID as a resource of
+// type users, see UserPolicyAttributes
+func (s *PolicyService) UserAttributes(id string) (policy.Attributes, error) {
+	userID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, ErrUserNotFound.Wrap(err)
+	}
+	user, err := s.users.FindByID(uint(userID))
+	if err != nil {
+		return nil, notFoundAs(err, ErrUserNotFound)
+	}
+	return UserPolicyAttributes(user), nil
+}
+
+// UserPolicyAttributes returns the attributes of user as a resource of type users: id,
+// email, email_domain, roles, email_verified, mfa_enabled and suspended. user.Roles must be loaded.
+func UserPolicyAttributes(user *models.User) policy.Attributes {
+	id := strconv.FormatUint(uint64(user.ID), 10)
+	roles := make([]string, 0, len(user.Roles))
+	for _, role := range user.Roles {
+		roles = append(roles, role.Name)
+	}
+	_, domain, _ := strings.Cut(user.Email, "@")
+	return policy.Attributes{
+		"type":           models.ResourceUsers,
+		"id":             id,
+		"owner_id":       id,
+		"email":          user.Email,
+		"email_domain":   domain,
+		"roles":          roles,
+		"email_verified": user.IsEmailVerified(),
+		"mfa_enabled":    user.MFAEnabledAt != nil,
+		"suspended":      user.SuspendedAt != nil,
+	}
+}
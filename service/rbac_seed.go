@@ -0,0 +1,286 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/seed"
+)
+
+// SeedOptions tune RBACService.Seed
+type SeedOptions struct {
+	// DryRun only computes the changes, nothing is written
+	DryRun bool
+	// Prune deletes roles and permissions missing from the file, and takes away permissions
+	// and parents of declared roles that the file does not list. Built-in roles and the
+	// admin permission stay, and so does the admin permission of the admin role.
+	Prune bool
+}
+
+// Seed change actions
+const (
+	SeedCreate       = "create"
+	SeedUpdate       = "update"
+	SeedDelete       = "delete"
+	SeedAttach       = "attach"
+	SeedDetach       = "detach"
+	SeedAddParent    = "add_parent"
+	SeedRemoveParent = "remove_parent"
+)
+
+// SeedChange is one change Seed makes to bring the database in line with a seed file
+type SeedChange struct {
+	Action string `json:"action"`
+	// Kind is "role" or "permission"
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Target is the permission or parent role of a link
+	Target string `json:"target,omitempty"`
+}
+
+// String renders the change as a line of a diff, e.g. "+ role editor permission docs:write"
+func (c SeedChange) String() string {
+	sign := map[string]string{
+		SeedCreate: "+", SeedAttach: "+", SeedAddParent: "+",
+		SeedDelete: "-", SeedDetach: "-", SeedRemoveParent: "-",
+		SeedUpdate: "~",
+	}[c.Action]
+	line := []string{sign, c.Kind, c.Name}
+	switch c.Action {
+	case SeedUpdate:
+		line = append(line, "description")
+	case SeedAttach, SeedDetach:
+		line = append(line, "permission", c.Target)
+	case SeedAddParent, SeedRemoveParent:
+		line = append(line, "parent", c.Target)
+	}
+	return strings.Join(line, " ")
+}
+
+// Seed brings roles, permissions and their links in line with file and returns the changes,
+// in the order they are made. Seeding the same file again changes nothing. Without
+// options.Prune it only adds and updates, so roles and links created through the API stay.
+// Pruning a role that users still have fails with ErrRoleInUse before anything is written.
+func (s *RBACService) Seed(file *seed.File, options SeedOptions) ([]SeedChange, error) {
+	if err := file.Validate(); err != nil {
+		return nil, err
+	}
+	changes, err := s.planSeed(file, options.Prune)
+	if err != nil || options.DryRun {
+		return changes, err
+	}
+
+	roles, permissions, err := s.seedIndex()
+	if err != nil {
+		return nil, err
+	}
+	descriptions := make(map[string]string)
+	for _, permission := range file.Permissions {
+		descriptions["permission "+permission.Name] = permission.Description
+	}
+	for _, role := range file.Roles {
+		descriptions["role "+role.Name] = role.Description
+	}
+
+	for _, change := range changes {
+		description := descriptions[change.Kind+" "+change.Name]
+		var err error
+		switch {
+		case change.Kind == "permission" && change.Action == SeedCreate:
+			var permission *models.Permission
+			permission, err = s.CreatePermission(change.Name, description)
+			if errors.Is(err, ErrPermissionNameTaken) {
+				// Another instance seeding at the same time created it
+				permission, err = s.permissionByName(change.Name)
+			}
+			if err == nil {
+				permissions[change.Name] = permission
+			}
+		case change.Kind == "permission" && change.Action == SeedUpdate:
+			_, err = s.UpdatePermission(permissions[change.Name].ID, change.Name, description)
+		case change.Kind == "permission" && change.Action == SeedDelete:
+			err = s.DeletePermission(permissions[change.Name].ID)
+		case change.Action == SeedCreate:
+			var role *models.Role
+			role, err = s.CreateRole(change.Name, description, nil)
+			if errors.Is(err, ErrRoleNameTaken) {
+				role, err = s.roles.FindByName(change.Name)
+			}
+			if err == nil {
+				roles[change.Name] = role
+			}
+		case change.Action == SeedUpdate:
+			_, err = s.UpdateRole(roles[change.Name].ID, change.Name, description)
+		case change.Action == SeedDelete:
+			err = s.DeleteRole(roles[change.Name].ID)
+		case change.Action == SeedAttach:
+			_, err = s.AttachPermission(roles[change.Name].ID, permissions[change.Target].ID)
+		case change.Action == SeedDetach:
+			_, err = s.DetachPermission(roles[change.Name].ID, permissions[change.Target].ID)
+		case change.Action == SeedAddParent:
+			_, err = s.AddParent(roles[change.Name].ID, roles[change.Target].ID)
+		case change.Action == SeedRemoveParent:
+			_, err = s.RemoveParent(roles[change.Name].ID, roles[change.Target].ID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", change, err)
+		}
+	}
+	return changes, nil
+}
+
+// planSeed compares file with the database. Changes come in an order that always works:
+// permissions and roles are created before they are linked, links are removed before new
+// parents could close a cycle, and deletions come last.
+func (s *RBACService) planSeed(file *seed.File, prune bool) ([]SeedChange, error) {
+	roles, permissions, err := s.seedIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	var creates, unlinks, links, deletes []SeedChange
+	declaredPermissions := make(map[string]bool, len(file.Permissions))
+	for _, declared := range file.Permissions {
+		declaredPermissions[declared.Name] = true
+		permission, exists := permissions[declared.Name]
+		if !exists {
+			creates = append(creates, SeedChange{Action: SeedCreate, Kind: "permission", Name: declared.Name})
+		} else if permission.Description != declared.Description {
+			creates = append(creates, SeedChange{Action: SeedUpdate, Kind: "permission", Name: declared.Name})
+		}
+	}
+
+	declaredRoles := make(map[string]bool, len(file.Roles))
+	for _, declared := range file.Roles {
+		declaredRoles[declared.Name] = true
+		role, exists := roles[declared.Name]
+		if !exists {
+			creates = append(creates, SeedChange{Action: SeedCreate, Kind: "role", Name: declared.Name})
+			role = &models.Role{Name: declared.Name}
+		} else if role.Description != declared.Description {
+			creates = append(creates, SeedChange{Action: SeedUpdate, Kind: "role", Name: declared.Name})
+		}
+
+		held := make(map[string]bool, len(role.Permissions))
+		for _, permission := range role.Permissions {
+			held[permission.Name] = true
+		}
+		wanted := make(map[string]bool, len(declared.Permissions))
+		for _, name := range declared.Permissions {
+			wanted[name] = true
+			if !held[name] {
+				links = append(links, SeedChange{Action: SeedAttach, Kind: "role", Name: declared.Name, Target: name})
+			}
+		}
+		for _, permission := range role.Permissions {
+			protected := role.Name == models.RoleAdmin && permission.IsBuiltIn()
+			if prune && !wanted[permission.Name] && !protected {
+				unlinks = append(unlinks, SeedChange{Action: SeedDetach, Kind: "role", Name: declared.Name, Target: permission.Name})
+			}
+		}
+
+		inherited := make(map[string]bool, len(role.Parents))
+		for _, parent := range role.Parents {
+			inherited[parent.Name] = true
+		}
+		wanted = make(map[string]bool, len(declared.Parents))
+		for _, name := range declared.Parents {
+			wanted[name] = true
+			if !inherited[name] {
+				links = append(links, SeedChange{Action: SeedAddParent, Kind: "role", Name: declared.Name, Target: name})
+			}
+		}
+		for _, parent := range role.Parents {
+			if prune && !wanted[parent.Name] {
+				unlinks = append(unlinks, SeedChange{Action: SeedRemoveParent, Kind: "role", Name: declared.Name, Target: parent.Name})
+			}
+		}
+	}
+
+	if prune {
+		for _, role := range sortedByName(roles) {
+			if declaredRoles[role.Name] || role.IsBuiltIn() {
+				continue
+			}
+			users, err := s.roles.CountUsers(role.ID)
+			if err != nil {
+				return nil, err
+			}
+			if users > 0 {
+				return nil, ErrRoleInUse.WithDetails(fmt.Sprintf("%d users have the role %s, which the seed file does not declare", users, role.Name))
+			}
+			invitations, err := s.roles.CountPendingInvitations(role.ID, time.Now())
+			if err != nil {
+				return nil, err
+			}
+			if invitations > 0 {
+				return nil, ErrRoleInUse.WithDetails(fmt.Sprintf("%d pending invitations offer the role %s, which the seed file does not declare", invitations, role.Name))
+			}
+			deletes = append(deletes, SeedChange{Action: SeedDelete, Kind: "role", Name: role.Name})
+		}
+		for _, permission := range sortedByName(permissions) {
+			if !declaredPermissions[permission.Name] && !permission.IsBuiltIn() {
+				deletes = append(deletes, SeedChange{Action: SeedDelete, Kind: "permission", Name: permission.Name})
+			}
+		}
+	}
+
+	changes := make([]SeedChange, 0, len(creates)+len(unlinks)+len(links)+len(deletes))
+	changes = append(changes, creates...)
+	changes = append(changes, unlinks...)
+	changes = append(changes, links...)
+	return append(changes, deletes...), nil
+}
+
+// seedIndex loads every role and permission by name
+func (s *RBACService) seedIndex() (map[string]*models.Role, map[string]*models.Permission, error) {
+	roleList, err := s.roles.List()
+	if err != nil {
+		return nil, nil, err
+	}
+	permissionList, err := s.permissions.List()
+	if err != nil {
+		return nil, nil, err
+	}
+	roles := make(map[string]*models.Role, len(roleList))
+	for i := range roleList {
+		roles[roleList[i].Name] = &roleList[i]
+	}
+	permissions := make(map[string]*models.Permission, len(permissionList))
+	for i := range permissionList {
+		permissions[permissionList[i].Name] = &permissionList[i]
+	}
+	return roles, permissions, nil
+}
+
+// permissionByName loads the permission with the given name
+func (s *RBACService) permissionByName(name string) (*models.Permission, error) {
+	permissions, err := s.permissions.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := range permissions {
+		if permissions[i].Name == name {
+			return &permissions[i], nil
+		}
+	}
+	return nil, ErrPermissionNotFound
+}
+
+// sortedByName returns the values of byName ordered by name, so plans come out the same every time
+func sortedByName[T any](byName map[string]*T) []*T {
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := make([]*T, 0, len(names))
+	for _, name := range names {
+		values = append(values, byName[name])
+	}
+	return values
+}
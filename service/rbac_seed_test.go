@@ -0,0 +1,156 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository/memory"
+	"github.com/sukhantharot/go-service/seed"
+)
+
+func TestSeed(t *testing.T) {
+	store := memory.NewStore()
+	roles := memory.NewRoleRepository(store)
+	permissions := memory.NewPermissionRepository(store)
+	users := memory.NewUserRepository(store)
+	rbac := NewRBACService(roles, permissions, NewPermissionCache(roles, time.Minute))
+
+	lines := func(changes []SeedChange) []string {
+		rendered := make([]string, 0, len(changes))
+		for _, change := range changes {
+			rendered = append(rendered, change.String())
+		}
+		return rendered
+	}
+
+	t.Run("dry run on an empty database", func(t *testing.T) {
+		changes, err := rbac.Seed(seed.Default(), SeedOptions{DryRun: true})
+		require.NoError(t, err)
+		assert.Contains(t, lines(changes), "+ role admin permission users:read")
+
+		all, err := roles.List()
+		require.NoError(t, err)
+		assert.Empty(t, all)
+	})
+
+	t.Run("default roles", func(t *testing.T) {
+		changes, err := rbac.Seed(seed.Default(), SeedOptions{})
+		require.NoError(t, err)
+		assert.NotEmpty(t, changes)
+
+		// Users read their own record through a grant, not every user
+		role, err := roles.FindByName(models.RoleUser)
+		require.NoError(t, err)
+		assert.False(t, models.HasPermission([]models.Role{*role}, "users:read"))
+		admin, err := roles.FindByName(models.RoleAdmin)
+		require.NoError(t, err)
+		assert.True(t, models.HasPermission([]models.Role{*admin}, models.PermissionAdmin))
+
+		// Seeding again changes nothing
+		changes, err = rbac.Seed(seed.Default(), SeedOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, changes)
+	})
+
+	file, err := seed.Parse([]byte(`
+permissions:
+  - {name: admin}
+  - {name: "docs:read", description: Read documents}
+  - {name: "docs:write"}
+roles:
+  - {name: admin, permissions: [admin]}
+  - {name: user}
+  - {name: reader, permissions: ["docs:read"]}
+  - {name: editor, permissions: ["docs:write"], parents: [reader]}
+`), "yaml")
+	require.NoError(t, err)
+
+	t.Run("adds without pruning", func(t *testing.T) {
+		changes, err := rbac.Seed(file, SeedOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"~ permission admin description",
+			"+ permission docs:read",
+			"+ permission docs:write",
+			"~ role admin description",
+			"~ role user description",
+			"+ role reader",
+			"+ role editor",
+			"+ role reader permission docs:read",
+			"+ role editor permission docs:write",
+			"+ role editor parent reader",
+		}, lines(changes))
+
+		editor, err := roles.FindByName("editor")
+		require.NoError(t, err)
+		assert.True(t, models.HasPermission([]models.Role{*editor}, "docs:read"))
+		// users:read was not pruned
+		admin, err := roles.FindByName(models.RoleAdmin)
+		require.NoError(t, err)
+		assert.Contains(t, permissionNames(admin.Permissions), "users:read")
+	})
+
+	t.Run("prune", func(t *testing.T) {
+		changes, err := rbac.Seed(file, SeedOptions{Prune: true, DryRun: true})
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"- role admin permission users:read",
+			"- role admin permission users:write",
+			"- role admin permission users:delete",
+			"- permission elevations:approve",
+			"- permission members:read",
+			"- permission members:write",
+			"- permission users:delete",
+			"- permission users:read",
+			"- permission users:write",
+		}, lines(changes))
+
+		_, err = rbac.Seed(file, SeedOptions{Prune: true})
+		require.NoError(t, err)
+		all, err := permissions.List()
+		require.NoError(t, err)
+		assert.Len(t, all, 3)
+	})
+
+	t.Run("pruning a role in use fails before any change", func(t *testing.T) {
+		temp, err := rbac.CreateRole("temp", "", nil)
+		require.NoError(t, err)
+		require.NoError(t, users.Create(&models.User{Email: "temp@example.com", Password: "password123", Roles: []models.Role{*temp}}))
+		extra, err := rbac.CreatePermission("docs:delete", "")
+		require.NoError(t, err)
+
+		_, err = rbac.Seed(file, SeedOptions{Prune: true})
+		assert.ErrorIs(t, err, ErrRoleInUse)
+		_, err = rbac.GetPermission(extra.ID)
+		assert.NoError(t, err)
+	})
+}
+
+// racingPermissions creates every permission once more right before it is created, like
+// another instance seeding at the same time
+type racingPermissions struct {
+	*memory.PermissionRepository
+}
+
+func (r racingPermissions) Create(permission *models.Permission) error {
+	other := *permission
+	if err := r.PermissionRepository.Create(&other); err != nil {
+		return err
+	}
+	return r.PermissionRepository.Create(permission)
+}
+
+func TestSeedConcurrently(t *testing.T) {
+	store := memory.NewStore()
+	roles := memory.NewRoleRepository(store)
+	rbac := NewRBACService(roles, racingPermissions{memory.NewPermissionRepository(store)}, NewPermissionCache(roles, time.Minute))
+
+	_, err := rbac.Seed(seed.Default(), SeedOptions{})
+	require.NoError(t, err)
+	admin, err := roles.FindByName(models.RoleAdmin)
+	require.NoError(t, err)
+	assert.True(t, models.HasPermission([]models.Role{*admin}, models.PermissionAdmin))
+}
@@ -0,0 +1,343 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRoleNotFound          = apperrors.New(http.StatusNotFound, "role_not_found", "Role not found")
+	ErrPermissionNotFound    = apperrors.New(http.StatusNotFound, "permission_not_found", "Permission not found")
+	ErrRoleNameTaken         = apperrors.New(http.StatusConflict, "role_name_taken", "Role name already exists")
+	ErrPermissionNameTaken   = apperrors.New(http.StatusConflict, "permission_name_taken", "Permission name already exists")
+	ErrRoleInUse             = apperrors.New(http.StatusConflict, "role_in_use", "Role is still assigned to users")
+	ErrBuiltInRole           = apperrors.New(http.StatusForbidden, "role_protected", "Built-in roles cannot be renamed or deleted")
+	ErrBuiltInPermission     = apperrors.New(http.StatusForbidden, "permission_protected", "Built-in permissions cannot be renamed or deleted")
+	ErrInvalidPermissionName = apperrors.New(http.StatusBadRequest, "invalid_permission_name", "Permission names are colon separated segments like users:read, * matches any segment")
+	ErrRoleCycle             = apperrors.New(http.StatusConflict, "role_cycle", "A role cannot inherit from itself or its descendants")
+)
+
+// RBACService manages roles, permissions and the links between them. Every change
+// invalidates the permission cache used by the permission checks.
+type RBACService struct {
+	roles       repository.RoleRepository
+	permissions repository.PermissionRepository
+	cache       *PermissionCache
+}
+
+func NewRBACService(roles repository.RoleRepository, permissions repository.PermissionRepository, cache *PermissionCache) *RBACService {
+	return &RBACService{
+		roles:       roles,
+		permissions: permissions,
+		cache:       cache,
+	}
+}
+
+func (s *RBACService) ListRoles() ([]models.Role, error) {
+	return s.roles.List()
+}
+
+func (s *RBACService) GetRole(id uint) (*models.Role, error) {
+	role, err := s.roles.FindByID(id)
+	if err != nil {
+		return nil, notFoundAs(err, ErrRoleNotFound)
+	}
+	return role, nil
+}
+
+// CreateRole creates a role granting the given permissions, which must all exist
+func (s *RBACService) CreateRole(name, description string, permissionIDs []uint) (*models.Role, error) {
+	role := &models.Role{Name: name, Description: description}
+	seen := make(map[uint]bool, len(permissionIDs))
+	for _, id := range permissionIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		permission, err := s.GetPermission(id)
+		if err != nil {
+			return nil, err
+		}
+		role.Permissions = append(role.Permissions, *permission)
+	}
+
+	if err := s.roles.Create(role); err != nil {
+		return nil, duplicateAs(err, ErrRoleNameTaken)
+	}
+	return role, nil
+}
+
+// UpdateRole changes the name and description, built-in roles keep their name
+func (s *RBACService) UpdateRole(id uint, name, description string) (*models.Role, error) {
+	role, err := s.GetRole(id)
+	if err != nil {
+		return nil, err
+	}
+	if role.IsBuiltIn() && role.Name != name {
+		return nil, ErrBuiltInRole
+	}
+
+	role.Name = name
+	role.Description = description
+	if err := s.roles.Update(role); err != nil {
+		return nil, duplicateAs(notFoundAs(err, ErrRoleNotFound), ErrRoleNameTaken)
+	}
+	s.cache.Invalidate()
+	return role, nil
+}
+
+// DeleteRole deletes a role that is neither built in nor assigned to any user
+func (s *RBACService) DeleteRole(id uint) error {
+	role, err := s.GetRole(id)
+	if err != nil {
+		return err
+	}
+	if role.IsBuiltIn() {
+		return ErrBuiltInRole
+	}
+
+	users, err := s.roles.CountUsers(id)
+	if err != nil {
+		return err
+	}
+	if users > 0 {
+		return ErrRoleInUse.WithDetails(fmt.Sprintf("%d users have this role", users))
+	}
+	invitations, err := s.roles.CountPendingInvitations(id, time.Now())
+	if err != nil {
+		return err
+	}
+	if invitations > 0 {
+		return ErrRoleInUse.WithDetails(fmt.Sprintf("%d pending invitations offer this role", invitations))
+	}
+
+	if err := s.roles.Delete(id); err != nil {
+		return notFoundAs(err, ErrRoleNotFound)
+	}
+	s.cache.Invalidate()
+	return nil
+}
+
+// AttachPermission grants a permission to a role and returns the updated role. Roles used
+// by memberships cannot get the admin permission, see checkMemberRoles.
+func (s *RBACService) AttachPermission(roleID, permissionID uint) (*models.Role, error) {
+	if _, err := s.GetRole(roleID); err != nil {
+		return nil, err
+	}
+	permission, err := s.GetPermission(permissionID)
+	if err != nil {
+		return nil, err
+	}
+	if permission.Grants(models.PermissionAdmin) {
+		if err := s.checkMemberRoles(func(role *models.Role) bool { return role.ID == roleID }); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.roles.AttachPermission(roleID, permissionID); err != nil {
+		return nil, err
+	}
+	s.cache.Invalidate()
+	return s.GetRole(roleID)
+}
+
+// DetachPermission revokes a permission from a role and returns the updated role.
+// The admin role keeps the admin permission so the admin routes stay reachable.
+func (s *RBACService) DetachPermission(roleID, permissionID uint) (*models.Role, error) {
+	role, err := s.GetRole(roleID)
+	if err != nil {
+		return nil, err
+	}
+	permission, err := s.GetPermission(permissionID)
+	if err != nil {
+		return nil, err
+	}
+	if role.Name == models.RoleAdmin && permission.IsBuiltIn() {
+		return nil, ErrBuiltInRole.WithMessage("The admin role must keep the admin permission")
+	}
+
+	if err := s.roles.DetachPermission(roleID, permissionID); err != nil {
+		return nil, err
+	}
+	s.cache.Invalidate()
+	return s.GetRole(roleID)
+}
+
+// AddParent lets a role inherit every permission of parent and its ancestors. It fails
+// with ErrRoleCycle when the role already is an ancestor of parent.
+func (s *RBACService) AddParent(roleID, parentID uint) (*models.Role, error) {
+	if _, err := s.GetRole(roleID); err != nil {
+		return nil, err
+	}
+	parent, err := s.GetRole(parentID)
+	if err != nil {
+		return nil, err
+	}
+	if roleID == parentID || parent.IsDescendantOf(roleID) {
+		return nil, ErrRoleCycle
+	}
+	if models.HasPermission([]models.Role{*parent}, models.PermissionAdmin) {
+		if err := s.checkMemberRoles(func(role *models.Role) bool { return role.ID == roleID }); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.roles.AddParent(roleID, parentID); err != nil {
+		return nil, err
+	}
+	s.cache.Invalidate()
+	return s.GetRole(roleID)
+}
+
+// checkMemberRoles guards changes that give the admin permission to the roles gains
+// reports, and so to their child roles. Admin routes are not scoped to an organization, so
+// it fails with ErrAdminMembershipRole while a membership or a pending invitation uses
+// any of them, like OrganizationService.SetMember would refuse.
+func (s *RBACService) checkMemberRoles(gains func(role *models.Role) bool) error {
+	roles, err := s.roles.List()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for i := range roles {
+		affected := false
+		for _, role := range roles[i].Lineage() {
+			affected = affected || gains(role)
+		}
+		if !affected {
+			continue
+		}
+		memberships, err := s.roles.CountMemberships(roles[i].ID)
+		if err != nil {
+			return err
+		}
+		invitations, err := s.roles.CountPendingInvitations(roles[i].ID, now)
+		if err != nil {
+			return err
+		}
+		if memberships > 0 || invitations > 0 {
+			return ErrAdminMembershipRole.WithDetails(fmt.Sprintf("%d memberships and %d pending invitations use the role %s", memberships, invitations, roles[i].Name))
+		}
+	}
+	return nil
+}
+
+// RemoveParent stops a role from inheriting from parent and returns the updated role
+func (s *RBACService) RemoveParent(roleID, parentID uint) (*models.Role, error) {
+	if _, err := s.GetRole(roleID); err != nil {
+		return nil, err
+	}
+
+	if err := s.roles.RemoveParent(roleID, parentID); err != nil {
+		return nil, err
+	}
+	s.cache.Invalidate()
+	return s.GetRole(roleID)
+}
+
+// EffectivePermissions returns every permission the role grants, its own and inherited
+// ones, with the role each one comes from
+func (s *RBACService) EffectivePermissions(roleID uint) ([]models.GrantedPermission, error) {
+	role, err := s.GetRole(roleID)
+	if err != nil {
+		return nil, err
+	}
+	return role.GrantedPermissions(), nil
+}
+
+func (s *RBACService) ListPermissions() ([]models.Permission, error) {
+	return s.permissions.List()
+}
+
+func (s *RBACService) GetPermission(id uint) (*models.Permission, error) {
+	permission, err := s.permissions.FindByID(id)
+	if err != nil {
+		return nil, notFoundAs(err, ErrPermissionNotFound)
+	}
+	return permission, nil
+}
+
+func (s *RBACService) CreatePermission(name, description string) (*models.Permission, error) {
+	if !models.ValidPermissionName(name) {
+		return nil, ErrInvalidPermissionName
+	}
+	permission := &models.Permission{Name: name, Description: description}
+	if err := s.permissions.Create(permission); err != nil {
+		return nil, duplicateAs(err, ErrPermissionNameTaken)
+	}
+	return permission, nil
+}
+
+// UpdatePermission changes the name and description, built-in permissions keep their name
+func (s *RBACService) UpdatePermission(id uint, name, description string) (*models.Permission, error) {
+	permission, err := s.GetPermission(id)
+	if err != nil {
+		return nil, err
+	}
+	if permission.IsBuiltIn() && permission.Name != name {
+		return nil, ErrBuiltInPermission
+	}
+	if !models.ValidPermissionName(name) {
+		return nil, ErrInvalidPermissionName
+	}
+	if models.MatchPermission(name, models.PermissionAdmin) && !permission.Grants(models.PermissionAdmin) {
+		err := s.checkMemberRoles(func(role *models.Role) bool {
+			for _, held := range role.Permissions {
+				if held.ID == id {
+					return true
+				}
+			}
+			return false
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	permission.Name = name
+	permission.Description = description
+	if err := s.permissions.Update(permission); err != nil {
+		return nil, duplicateAs(notFoundAs(err, ErrPermissionNotFound), ErrPermissionNameTaken)
+	}
+	s.cache.Invalidate()
+	return permission, nil
+}
+
+// DeletePermission deletes a permission and revokes it from every role
+func (s *RBACService) DeletePermission(id uint) error {
+	permission, err := s.GetPermission(id)
+	if err != nil {
+		return err
+	}
+	if permission.IsBuiltIn() {
+		return ErrBuiltInPermission
+	}
+	if err := s.permissions.Delete(id); err != nil {
+		return notFoundAs(err, ErrPermissionNotFound)
+	}
+	s.cache.Invalidate()
+	return nil
+}
+
+// notFoundAs replaces a record-not-found error with target, other errors pass through
+func notFoundAs(err error, target *apperrors.AppError) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return target.Wrap(err)
+	}
+	return err
+}
+
+// duplicateAs replaces a unique violation with target, other errors pass through
+func duplicateAs(err error, target *apperrors.AppError) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return target.Wrap(err)
+	}
+	return err
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository"
+	"gorm.io/gorm"
+)
+
+type revokedEntry struct {
+	revoked bool
+	until   time.Time
+}
+
+type cutoffEntry struct {
+	revokedAt *time.Time
+	suspended bool
+	until     time.Time
+}
+
+// RevocationStore answers "was this access token revoked?" from the database,
+// caching answers in process. Revocations made through this store are visible
+// immediately; revocations made by another replica become visible after cacheTTL.
+type RevocationStore struct {
+	repo     repository.RevocationRepository
+	cacheTTL time.Duration
+
+	mu      sync.RWMutex
+	tokens  map[string]revokedEntry
+	cutoffs map[uint]cutoffEntry
+}
+
+func NewRevocationStore(repo repository.RevocationRepository, cacheTTL time.Duration) *RevocationStore {
+	return &RevocationStore{
+		repo:     repo,
+		cacheTTL: cacheTTL,
+		tokens:   make(map[string]revokedEntry),
+		cutoffs:  make(map[uint]cutoffEntry),
+	}
+}
+
+// IsRevoked reports whether the token with the given jti, issued to userID at issuedAt, was revoked.
+// Tokens of suspended users fail with apperrors.ErrAccountSuspended.
+func (s *RevocationStore) IsRevoked(jti string, userID uint, issuedAt time.Time) (bool, error) {
+	entry, err := s.userCutoff(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return true, nil
+		}
+		return false, err
+	}
+	if entry.suspended {
+		return true, apperrors.ErrAccountSuspended
+	}
+	if revokedAt := entry.revokedAt; revokedAt != nil && !issuedAt.After(*revokedAt) {
+		return true, nil
+	}
+
+	if jti == "" {
+		return false, nil
+	}
+	return s.tokenRevoked(jti)
+}
+
+// Revoke invalidates a single access token until it expires
+func (s *RevocationStore) Revoke(jti string, userID uint, expiresAt time.Time) error {
+	err := s.repo.RevokeToken(&models.RevokedToken{
+		JTI:       jti,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.tokens[jti] = revokedEntry{revoked: true, until: expiresAt}
+	s.mu.Unlock()
+	return nil
+}
+
+// RevokeAll invalidates every access token issued to the user so far
+func (s *RevocationStore) RevokeAll(userID uint) error {
+	return s.RevokeAllUntil(userID, time.Now())
+}
+
+// RevokeAllUntil invalidates every access token issued to the user up to and including cutoff
+func (s *RevocationStore) RevokeAllUntil(userID uint, cutoff time.Time) error {
+	if err := s.repo.RevokeUserTokens(userID, cutoff); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.cutoffs[userID] = cutoffEntry{revokedAt: &cutoff, until: time.Now().Add(s.cacheTTL)}
+	s.mu.Unlock()
+	return nil
+}
+
+// Forget drops what is cached about the user, so a suspension or reactivation applies immediately
+func (s *RevocationStore) Forget(userID uint) {
+	s.mu.Lock()
+	delete(s.cutoffs, userID)
+	s.mu.Unlock()
+}
+
+// PurgeExpired drops revocations of tokens that have expired anyway, in the database and in the cache
+func (s *RevocationStore) PurgeExpired() error {
+	now := time.Now()
+
+	s.mu.Lock()
+	for jti, entry := range s.tokens {
+		if now.After(entry.until) {
+			delete(s.tokens, jti)
+		}
+	}
+	for userID, entry := range s.cutoffs {
+		if now.After(entry.until) {
+			delete(s.cutoffs, userID)
+		}
+	}
+	s.mu.Unlock()
+
+	return s.repo.DeleteExpired(now)
+}
+
+func (s *RevocationStore) tokenRevoked(jti string) (bool, error) {
+	now := time.Now()
+
+	s.mu.RLock()
+	entry, ok := s.tokens[jti]
+	s.mu.RUnlock()
+	if ok && now.Before(entry.until) {
+		return entry.revoked, nil
+	}
+
+	revoked, err := s.repo.IsTokenRevoked(jti)
+	if err != nil {
+		return false, err
+	}
+
+	// Revoked tokens stay revoked, so only negative answers need a short TTL
+	until := now.Add(s.cacheTTL)
+	if revoked {
+		until = now.Add(24 * time.Hour)
+	}
+	s.mu.Lock()
+	s.tokens[jti] = revokedEntry{revoked: revoked, until: until}
+	s.mu.Unlock()
+	return revoked, nil
+}
+
+func (s *RevocationStore) userCutoff(userID uint) (cutoffEntry, error) {
+	now := time.Now()
+
+	s.mu.RLock()
+	entry, ok := s.cutoffs[userID]
+	s.mu.RUnlock()
+	if ok && now.Before(entry.until) {
+		return entry, nil
+	}
+
+	revokedAt, suspended, err := s.repo.UserTokenState(userID)
+	if err != nil {
+		return cutoffEntry{}, err
+	}
+
+	entry = cutoffEntry{revokedAt: revokedAt, suspended: suspended, until: now.Add(s.cacheTTL)}
+	s.mu.Lock()
+	s.cutoffs[userID] = entry
+	s.mu.Unlock()
+	return entry, nil
+}
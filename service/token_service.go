@@ -0,0 +1,355 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidRefreshToken = apperrors.New(http.StatusUnauthorized, "invalid_refresh_token", "Invalid refresh token")
+	ErrRefreshTokenReused  = apperrors.New(http.StatusUnauthorized, "refresh_token_reused", "Refresh token reuse detected, please log in again")
+	ErrNotMember           = apperrors.New(http.StatusForbidden, "not_a_member", "You are not a member of this organization")
+)
+
+// TokenConfig controls how access and refresh tokens are issued
+type TokenConfig struct {
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	MFA        MFAPolicy
+	// EmbedPermissions adds the user's effective permissions to access tokens, so permission
+	// checks need no database lookup. A permission change made on another replica reaches
+	// tokens issued before it only when they expire.
+	EmbedPermissions bool
+}
+
+// TokenPair is returned by login and refresh
+type TokenPair struct {
+	AccessToken      string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+type TokenService struct {
+	refreshRepo   repository.RefreshTokenRepository
+	userRepo      repository.UserRepository
+	organizations repository.OrganizationRepository
+	elevations    repository.ElevationRepository
+	revocations   *RevocationStore
+	keys          *KeySet
+	config        TokenConfig
+}
+
+func NewTokenService(refreshRepo repository.RefreshTokenRepository, userRepo repository.UserRepository, organizations repository.OrganizationRepository, elevations repository.ElevationRepository, revocations *RevocationStore, keys *KeySet, config TokenConfig) *TokenService {
+	return &TokenService{
+		refreshRepo:   refreshRepo,
+		userRepo:      userRepo,
+		organizations: organizations,
+		elevations:    elevations,
+		revocations:   revocations,
+		keys:          keys,
+		config:        config,
+	}
+}
+
+// IssueAccessToken signs a short-lived JWT for the user, mfa tells whether the login passed a second factor.
+// Role.Permissions must be loaded so the mfa_required claim reflects the MFA policy. A membership
+// makes its organization the active one: the token carries org_id and the role of the membership
+// in org_role_id, apart from the user's own roles in role_ids. Roles of active elevations
+// are listed in elevations, each with its own expiry.
+func (s *TokenService) IssueAccessToken(user *models.User, mfa bool, membership *models.Membership) (string, time.Time, error) {
+	return s.issueAccessToken(user, mfa, membership, time.Now())
+}
+
+func (s *TokenService) issueAccessToken(user *models.User, mfa bool, membership *models.Membership, now time.Time) (string, time.Time, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	elevations, err := s.elevations.ListActive(user.ID, now)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	// The role of the membership counts for the MFA policy and embedded permissions
+	member := user
+	if membership != nil {
+		member = user.WithMembership(membership)
+	}
+
+	expiresAt := now.Add(s.config.AccessTTL)
+	claims := jwt.MapClaims{
+		"jti":      jti,
+		"user_id":  user.ID,
+		"role_ids": user.RoleIDs(),
+		// Lets middleware enforce the unverified account policy without a lookup
+		"email_verified": user.IsEmailVerified(),
+		// Lets middleware keep users whose role requires MFA out until they used it
+		"mfa":          mfa,
+		"mfa_required": s.config.MFA.Requires(member.WithElevations(elevations)),
+		// Milliseconds so a logout everywhere does not also reject the next login in the same second
+		"iat": float64(now.UnixMilli()) / 1000,
+		"exp": expiresAt.Unix(),
+	}
+	if membership != nil {
+		claims["org_id"] = membership.OrganizationID
+		claims["org_role_id"] = membership.RoleID
+	}
+	if len(elevations) > 0 {
+		claims["elevations"] = elevationClaims(elevations)
+	}
+	// Elevated roles are left out, they may end before the token does
+	if s.config.EmbedPermissions {
+		claims["permissions"] = permissionNames(member.Permissions())
+	}
+	tokenString, err := s.keys.Sign(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return tokenString, expiresAt, nil
+}
+
+// IssueTokenPair starts a new refresh token family, used on login. The oldest membership of
+// the user, if any, makes its organization the active one. Suspended users get ErrAccountSuspended.
+func (s *TokenService) IssueTokenPair(user *models.User, mfa bool) (*TokenPair, error) {
+	return s.issueLoginPair(user, mfa, time.Now())
+}
+
+func (s *TokenService) issueLoginPair(user *models.User, mfa bool, issuedAt time.Time) (*TokenPair, error) {
+	memberships, err := s.organizations.ListForUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(memberships) == 0 {
+		return s.issueTokenPair(user, mfa, nil, issuedAt)
+	}
+	membership, err := s.organizations.FindMembership(memberships[0].OrganizationID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return s.issueTokenPair(user, mfa, membership, issuedAt)
+}
+
+// SwitchOrganization starts a new refresh token family with the organization as the active
+// one, organizationID zero for none. Users that are not members get ErrNotMember.
+func (s *TokenService) SwitchOrganization(user *models.User, mfa bool, organizationID uint) (*TokenPair, error) {
+	if organizationID == 0 {
+		return s.issueTokenPair(user, mfa, nil, time.Now())
+	}
+	membership, err := s.organizations.FindMembership(organizationID, user.ID)
+	if err != nil {
+		return nil, notFoundAs(err, ErrNotMember)
+	}
+	return s.issueTokenPair(user, mfa, membership, time.Now())
+}
+
+func (s *TokenService) issueTokenPair(user *models.User, mfa bool, membership *models.Membership, issuedAt time.Time) (*TokenPair, error) {
+	if user.IsSuspended() {
+		return nil, apperrors.ErrAccountSuspended
+	}
+
+	familyID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, record, err := s.newRefreshToken(user.ID, familyID, mfa, membership)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.refreshRepo.Create(record); err != nil {
+		return nil, err
+	}
+
+	return s.pairFor(user, refreshToken, record, membership, issuedAt)
+}
+
+// Refresh exchanges a refresh token for a new access token and a rotated refresh token.
+// Presenting a token that was already rotated revokes its whole family.
+func (s *TokenService) Refresh(refreshToken string) (*TokenPair, *models.User, error) {
+	current, err := s.refreshRepo.FindByHash(hashToken(refreshToken))
+	if err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	if current.RevokedAt != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	if current.RotatedAt != nil {
+		if err := s.refreshRepo.RevokeFamily(current.FamilyID); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrRefreshTokenReused
+	}
+	if !current.IsActive(time.Now()) {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.userRepo.FindByID(current.UserID)
+	if err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	if user.IsSuspended() {
+		return nil, nil, apperrors.ErrAccountSuspended
+	}
+
+	// A session whose membership ended goes on without an organization
+	var membership *models.Membership
+	if current.OrganizationID != nil {
+		membership, err = s.organizations.FindMembership(*current.OrganizationID, user.ID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			membership = nil
+		} else if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	nextToken, next, err := s.newRefreshToken(user.ID, current.FamilyID, current.MFA, membership)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rotated, err := s.refreshRepo.Rotate(current, next)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !rotated {
+		// Another request rotated this token first, treat it as reuse
+		if err := s.refreshRepo.RevokeFamily(current.FamilyID); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrRefreshTokenReused
+	}
+
+	pair, err := s.pairFor(user, nextToken, next, membership, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	return pair, user, nil
+}
+
+// Logout revokes the access token identified by jti and, when given, the refresh token family it belongs to
+func (s *TokenService) Logout(jti string, userID uint, expiresAt time.Time, refreshToken string) error {
+	if err := s.revocations.Revoke(jti, userID, expiresAt); err != nil {
+		return err
+	}
+	if refreshToken == "" {
+		return nil
+	}
+
+	current, err := s.refreshRepo.FindByHash(hashToken(refreshToken))
+	if err != nil || current.UserID != userID {
+		// Nothing to revoke, don't leak whether the token exists
+		return nil
+	}
+	return s.refreshRepo.RevokeFamily(current.FamilyID)
+}
+
+// LogoutAll revokes every access and refresh token issued to the user
+func (s *TokenService) LogoutAll(userID uint) error {
+	return s.logoutAll(userID, time.Now())
+}
+
+// LogoutOthers revokes every token of the user like LogoutAll and returns a new pair for
+// the session that asked, mfa carries over whether that session passed a second factor
+func (s *TokenService) LogoutOthers(user *models.User, mfa bool) (*TokenPair, error) {
+	// iat has millisecond precision, so the cutoff covers the current millisecond
+	// and the new pair is issued at the next one, strictly after it
+	cutoff := time.Now().Truncate(time.Millisecond)
+	if err := s.logoutAll(user.ID, cutoff); err != nil {
+		return nil, err
+	}
+	return s.issueLoginPair(user, mfa, cutoff.Add(time.Millisecond))
+}
+
+func (s *TokenService) logoutAll(userID uint, cutoff time.Time) error {
+	if err := s.refreshRepo.RevokeAllForUser(userID); err != nil {
+		return err
+	}
+	return s.revocations.RevokeAllUntil(userID, cutoff)
+}
+
+// ValidateAccessToken parses and verifies an access token
+func (s *TokenService) ValidateAccessToken(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, s.keys.Keyfunc)
+}
+
+func (s *TokenService) newRefreshToken(userID uint, familyID string, mfa bool, membership *models.Membership) (string, *models.RefreshToken, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	record := &models.RefreshToken{
+		UserID:    userID,
+		TokenHash: hashToken(raw),
+		FamilyID:  familyID,
+		MFA:       mfa,
+		ExpiresAt: time.Now().Add(s.config.RefreshTTL),
+	}
+	if membership != nil {
+		organizationID := membership.OrganizationID
+		record.OrganizationID = &organizationID
+	}
+	return raw, record, nil
+}
+
+func (s *TokenService) pairFor(user *models.User, refreshToken string, record *models.RefreshToken, membership *models.Membership, issuedAt time.Time) (*TokenPair, error) {
+	accessToken, expiresAt, err := s.issueAccessToken(user, record.MFA, membership, issuedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:      accessToken,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: record.ExpiresAt,
+	}, nil
+}
+
+// elevationClaims lists the role and expiry of each elevation, so middleware can drop the
+// role once it expired even if the token is still valid
+func elevationClaims(elevations []models.Elevation) []map[string]interface{} {
+	claims := make([]map[string]interface{}, 0, len(elevations))
+	for _, elevation := range elevations {
+		claims = append(claims, map[string]interface{}{
+			"role_id":    elevation.RoleID,
+			"expires_at": elevation.ExpiresAt.Unix(),
+		})
+	}
+	return claims
+}
+
+// randomToken returns n random bytes encoded as URL-safe base64
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hex encoded SHA-256 of an opaque token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func permissionNames(permissions []models.Permission) []string {
+	names := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		names = append(names, permission.Name)
+	}
+	return names
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository"
+)
+
+var (
+	ErrUserNotFound = apperrors.New(http.StatusNotFound, "user_not_found", "User not found")
+	// ErrSelfAction keeps admins from locking themselves out
+	ErrSelfAction = apperrors.New(http.StatusForbidden, "self_action_forbidden", "You cannot suspend, delete or change the roles of your own account")
+	// ErrEmailChangeForbidden keeps grants from moving an account to an address its owner never confirmed
+	ErrEmailChangeForbidden = apperrors.New(http.StatusForbidden, "email_change_forbidden", "The email can only be changed by an admin or by confirming the new address")
+)
+
+// UserService manages user accounts, mostly for admins managing other users
+type UserService struct {
+	userRepo    repository.UserRepository
+	roleRepo    repository.RoleRepository
+	tokens      *TokenService
+	revocations *RevocationStore
+}
+
+func NewUserService(userRepo repository.UserRepository, roleRepo repository.RoleRepository, tokens *TokenService, revocations *RevocationStore) *UserService {
+	return &UserService{
+		userRepo:    userRepo,
+		roleRepo:    roleRepo,
+		tokens:      tokens,
+		revocations: revocations,
+	}
+}
+
+// List returns a page of users. Unknown sort fields and cursors that do not belong to
+// the requested order are bad requests.
+func (s *UserService) List(filter repository.UserFilter, page repository.PageRequest) (*repository.Page[models.User], error) {
+	users, err := s.userRepo.List(filter, page)
+	switch {
+	case errors.Is(err, repository.ErrInvalidSort):
+		return nil, apperrors.ErrBadRequest.WithMessage("Invalid sort field").Wrap(err)
+	case errors.Is(err, repository.ErrInvalidCursor):
+		return nil, apperrors.ErrBadRequest.WithMessage("Invalid cursor").Wrap(err)
+	}
+	return users, err
+}
+
+func (s *UserService) Get(id uint) (*models.User, error) {
+	user, err := s.userRepo.FindByID(id)
+	if err != nil {
+		return nil, notFoundAs(err, ErrUserNotFound)
+	}
+	return user, nil
+}
+
+// Update changes the email and names of a user. A new email has to be verified again.
+func (s *UserService) Update(id uint, email, firstName, lastName string) (*models.User, error) {
+	user, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Email != email {
+		user.EmailVerifiedAt = nil
+	}
+	user.Email = email
+	user.FirstName = firstName
+	user.LastName = lastName
+	if err := s.userRepo.UpdateProfile(user); err != nil {
+		return nil, duplicateAs(err, ErrEmailTaken)
+	}
+	return s.Get(id)
+}
+
+// UpdateNames is Update for callers without admin rights over the user, email has to
+// stay the same and fails with ErrEmailChangeForbidden otherwise
+func (s *UserService) UpdateNames(id uint, email, firstName, lastName string) (*models.User, error) {
+	user, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if user.Email != email {
+		return nil, ErrEmailChangeForbidden
+	}
+	return s.UpdateName(id, &firstName, &lastName)
+}
+
+// UpdateName changes the names of a user, nil leaves a name as it is
+func (s *UserService) UpdateName(id uint, firstName, lastName *string) (*models.User, error) {
+	user, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if firstName != nil {
+		user.FirstName = *firstName
+	}
+	if lastName != nil {
+		user.LastName = *lastName
+	}
+	if err := s.userRepo.UpdateProfile(user); err != nil {
+		return nil, err
+	}
+	return s.Get(id)
+}
+
+// SetRoles replaces the roles of a user. Access tokens carry the roles, so the user's
+// current ones are revoked and the next refresh picks up the new roles.
+func (s *UserService) SetRoles(actorID, id uint, roleIDs []uint) (*models.User, error) {
+	return s.changeRoles(actorID, id, roleIDs, func() error {
+		return s.userRepo.SetRoles(id, roleIDs)
+	})
+}
+
+// AddRole grants one more role, revoking access tokens like SetRoles
+func (s *UserService) AddRole(actorID, id, roleID uint) (*models.User, error) {
+	return s.changeRoles(actorID, id, []uint{roleID}, func() error {
+		return s.userRepo.AddRole(id, roleID)
+	})
+}
+
+// RemoveRole takes a role away, revoking access tokens like SetRoles
+func (s *UserService) RemoveRole(actorID, id, roleID uint) (*models.User, error) {
+	return s.changeRoles(actorID, id, []uint{roleID}, func() error {
+		return s.userRepo.RemoveRole(id, roleID)
+	})
+}
+
+// changeRoles checks the user and roleIDs exist before running change
+func (s *UserService) changeRoles(actorID, id uint, roleIDs []uint, change func() error) (*models.User, error) {
+	if actorID == id {
+		return nil, ErrSelfAction
+	}
+	if _, err := s.Get(id); err != nil {
+		return nil, err
+	}
+	for _, roleID := range roleIDs {
+		if _, err := s.roleRepo.FindByID(roleID); err != nil {
+			return nil, notFoundAs(err, ErrRoleNotFound)
+		}
+	}
+
+	if err := change(); err != nil {
+		return nil, err
+	}
+	if err := s.revocations.RevokeAll(id); err != nil {
+		return nil, err
+	}
+	return s.Get(id)
+}
+
+// Suspend blocks the user from logging in and ends every session. Suspending a
+// suspended user only updates the reason.
+func (s *UserService) Suspend(actorID, id uint, reason string) (*models.User, error) {
+	if actorID == id {
+		return nil, ErrSelfAction
+	}
+	user, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	suspendedAt := time.Now()
+	if user.SuspendedAt != nil {
+		suspendedAt = *user.SuspendedAt
+	}
+	if err := s.userRepo.Suspend(id, reason, suspendedAt); err != nil {
+		return nil, err
+	}
+	if err := s.tokens.LogoutAll(id); err != nil {
+		return nil, err
+	}
+	// LogoutAll cached the user as active, the next request must see the suspension
+	s.revocations.Forget(id)
+	return s.Get(id)
+}
+
+// Reactivate lifts a suspension, the user has to log in again
+func (s *UserService) Reactivate(id uint) (*models.User, error) {
+	if _, err := s.Get(id); err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.Reactivate(id); err != nil {
+		return nil, err
+	}
+	s.revocations.Forget(id)
+	return s.Get(id)
+}
+
+// Delete soft deletes the user after ending every session
+func (s *UserService) Delete(actorID, id uint) error {
+	if actorID == id {
+		return ErrSelfAction
+	}
+	if _, err := s.Get(id); err != nil {
+		return err
+	}
+
+	if err := s.tokens.LogoutAll(id); err != nil {
+		return err
+	}
+	if err := s.userRepo.Delete(id); err != nil {
+		return err
+	}
+	s.revocations.Forget(id)
+	return nil
+}
+
+// Restore brings back a soft deleted user, sessions ended by Delete stay ended
+func (s *UserService) Restore(id uint) (*models.User, error) {
+	if err := s.userRepo.Restore(id); err != nil {
+		return nil, notFoundAs(err, ErrUserNotFound.WithMessage("Deleted user not found"))
+	}
+	s.revocations.Forget(id)
+	return s.Get(id)
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	apperrors "github.com/sukhantharot/go-service/errors"
+	"github.com/sukhantharot/go-service/logger"
+	"github.com/sukhantharot/go-service/mail"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidVerificationToken = apperrors.New(http.StatusBadRequest, "invalid_verification_token", "Invalid or expired verification token")
+	ErrInvalidEmailChangeToken  = apperrors.New(http.StatusBadRequest, "invalid_email_change_token", "Invalid or expired email change token")
+	ErrEmailNotVerified         = apperrors.ErrEmailNotVerified
+)
+
+// UnverifiedPolicy decides what users who have not verified their email may do
+type UnverifiedPolicy string
+
+const (
+	// UnverifiedAllow treats unverified accounts like verified ones
+	UnverifiedAllow UnverifiedPolicy = "allow"
+	// UnverifiedRestrict lets unverified users log in but keeps them out of privileged routes
+	UnverifiedRestrict UnverifiedPolicy = "restrict"
+	// UnverifiedBlock refuses to log in unverified users
+	UnverifiedBlock UnverifiedPolicy = "block"
+)
+
+// ParseUnverifiedPolicy parses the UNVERIFIED_ACCOUNT_POLICY setting
+func ParseUnverifiedPolicy(value string) (UnverifiedPolicy, error) {
+	switch policy := UnverifiedPolicy(value); policy {
+	case UnverifiedAllow, UnverifiedRestrict, UnverifiedBlock:
+		return policy, nil
+	case "":
+		return UnverifiedAllow, nil
+	}
+	return "", fmt.Errorf("unknown unverified account policy %q", value)
+}
+
+// VerificationConfig controls the email verification flow
+type VerificationConfig struct {
+	TTL time.Duration
+	// URL is the frontend page that receives the token as ?token=
+	URL    string
+	Policy UnverifiedPolicy
+	// ChangeURL is the frontend page that confirms an email change, it receives the token as ?token=
+	ChangeURL string
+}
+
+type VerificationService struct {
+	userRepo   repository.UserRepository
+	verifyRepo repository.EmailVerificationRepository
+	changeRepo repository.EmailChangeRepository
+	mailer     mail.Sender
+	config     VerificationConfig
+}
+
+func NewVerificationService(userRepo repository.UserRepository, verifyRepo repository.EmailVerificationRepository, changeRepo repository.EmailChangeRepository, mailer mail.Sender, config VerificationConfig) *VerificationService {
+	return &VerificationService{
+		userRepo:   userRepo,
+		verifyRepo: verifyRepo,
+		changeRepo: changeRepo,
+		mailer:     mailer,
+		config:     config,
+	}
+}
+
+// Policy returns the configured unverified account policy
+func (s *VerificationService) Policy() UnverifiedPolicy {
+	return s.config.Policy
+}
+
+// CheckLogin returns ErrEmailNotVerified when the policy does not let the user log in
+func (s *VerificationService) CheckLogin(user *models.User) error {
+	if s.config.Policy == UnverifiedBlock && !user.IsEmailVerified() {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
+
+// SendVerification emails the user a link that verifies their current email address
+func (s *VerificationService) SendVerification(user *models.User) error {
+	raw, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+	token := &models.EmailVerificationToken{
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(s.config.TTL),
+	}
+	if err := s.verifyRepo.Create(token); err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below. It expires in %s.\n\n%s?token=%s\n\nIf you did not create an account you can ignore this email.\n",
+			user.FirstName, s.config.TTL, s.config.URL, raw),
+	})
+}
+
+// Resend sends a new verification email to an unverified user.
+// Unknown or already verified emails are not an error so callers cannot probe for accounts.
+func (s *VerificationService) Resend(email string) error {
+	user, err := s.userRepo.FindByEmail(email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.IsEmailVerified() {
+		return nil
+	}
+	return s.SendVerification(user)
+}
+
+// Verify marks the email address the token was issued for as verified
+func (s *VerificationService) Verify(rawToken string) error {
+	token, err := s.verifyRepo.FindByHash(hashToken(rawToken))
+	if err != nil {
+		return ErrInvalidVerificationToken
+	}
+
+	now := time.Now()
+	consumed, err := s.verifyRepo.Consume(token.ID, now)
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return ErrInvalidVerificationToken
+	}
+
+	// A token for an address the user no longer has must not verify the new one
+	verified, err := s.userRepo.MarkEmailVerified(token.UserID, token.Email, now)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return ErrInvalidVerificationToken
+	}
+	return s.verifyRepo.InvalidateForUser(token.UserID)
+}
+
+// RequestEmailChange emails a confirmation link to newEmail. The user keeps their current
+// email until the link is followed, so a typo cannot lock them out of their account.
+func (s *VerificationService) RequestEmailChange(userID uint, password, newEmail string) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.CheckPassword(password) {
+		return ErrIncorrectPassword
+	}
+	if newEmail == user.Email {
+		return apperrors.ErrBadRequest.WithMessage("This is already your email")
+	}
+	if _, err := s.userRepo.FindByEmail(newEmail); err == nil {
+		return ErrEmailTaken
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	raw, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+	token := &models.EmailChangeToken{
+		UserID:    user.ID,
+		NewEmail:  newEmail,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(s.config.TTL),
+	}
+	if err := s.changeRepo.Create(token); err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mail.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm that you want to use this address for your account by opening the link below. It expires in %s.\n\n%s?token=%s\n\nIf you did not ask for this change you can ignore this email.\n",
+			user.FirstName, s.config.TTL, s.config.ChangeURL, raw),
+	})
+}
+
+// ConfirmEmailChange swaps in the new email the token was issued for, which counts as
+// verified, and tells the old address about the change
+func (s *VerificationService) ConfirmEmailChange(rawToken string) error {
+	token, err := s.changeRepo.FindByHash(hashToken(rawToken))
+	if err != nil {
+		return ErrInvalidEmailChangeToken
+	}
+
+	now := time.Now()
+	consumed, err := s.changeRepo.Consume(token.ID, now)
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return ErrInvalidEmailChangeToken
+	}
+
+	user, err := s.userRepo.FindByID(token.UserID)
+	if err != nil {
+		return notFoundAs(err, ErrInvalidEmailChangeToken)
+	}
+	oldEmail := user.Email
+	user.Email = token.NewEmail
+	user.EmailVerifiedAt = &now
+	if err := s.userRepo.UpdateProfile(user); err != nil {
+		// Someone registered the address since the change was requested
+		return duplicateAs(err, ErrEmailTaken)
+	}
+
+	// Links for the old address and other pending changes must not apply anymore
+	if err := s.changeRepo.InvalidateForUser(user.ID); err != nil {
+		return err
+	}
+	if err := s.verifyRepo.InvalidateForUser(user.ID); err != nil {
+		return err
+	}
+
+	if err := s.mailer.Send(mail.Message{
+		To:      oldEmail,
+		Subject: "Your email address was changed",
+		Body: fmt.Sprintf("Hi %s,\n\nThe email address of your account was changed to %s. If you did not make this change, please contact support.\n",
+			user.FirstName, user.Email),
+	}); err != nil {
+		logger.Error("Could not notify the old email address of a change", err, logger.Fields{"user_id": user.ID})
+	}
+	return nil
+}
@@ -1,13 +1,18 @@
 package test
 
 import (
+	"bytes"
 	"encoding/json"
-	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/sukhantharot/go-service/models"
+	"github.com/sukhantharot/go-service/repository"
 )
 
 // TestResponse represents a generic test response
@@ -50,31 +55,33 @@ func AssertResponse(t *testing.T, w *httptest.ResponseRecorder, expectedStatus i
 }
 
 // CreateTestUser is a helper function to create a test user
-func CreateTestUser(t *testing.T, db *gorm.DB, email, password string) *models.User {
+func CreateTestUser(t *testing.T, users repository.UserRepository, email, password string) *models.User {
+	hashedPassword, err := models.HashPassword(password)
+	assert.NoError(t, err)
+
 	user := &models.User{
 		Email:     email,
-		Password:  password,
+		Password:  hashedPassword,
 		FirstName: "Test",
 		LastName:  "User",
-		RoleID:    1,
 	}
 
-	err := db.Create(user).Error
+	err = users.Create(user)
 	assert.NoError(t, err)
 
 	return user
 }
 
 // GenerateTestToken is a helper function to generate a JWT token for testing
-func GenerateTestToken(t *testing.T, userID uint, roleID uint) string {
+func GenerateTestToken(t *testing.T, userID uint, roleIDs ...uint) string {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
-		"role_id": roleID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
+		"user_id":  userID,
+		"role_ids": roleIDs,
+		"exp":      time.Now().Add(time.Hour * 24).Unix(),
 	})
 
 	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
 	assert.NoError(t, err)
 
 	return tokenString
-} 
\ No newline at end of file
+}